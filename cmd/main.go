@@ -6,6 +6,7 @@ import (
 	"go-echo-demo/internal/handler/api"
 	"go-echo-demo/internal/handler/frontend"
 	"go-echo-demo/internal/infrastructure"
+	authmiddleware "go-echo-demo/internal/middleware"
 	"go-echo-demo/internal/repository"
 	"go-echo-demo/internal/usecase"
 
@@ -31,6 +32,15 @@ func main() {
 	// リポジトリ初期化
 	authRepo := infrastructure.NewAuthRepository(db)
 	refreshTokenRepo := infrastructure.NewRefreshTokenRepository(db)
+	securityEventRepo := infrastructure.NewSecurityEventRepository(db)
+	revokedTokenStore := infrastructure.NewRevokedTokenStore(db)
+	mfaRepo := infrastructure.NewMFARepository(db)
+	loginAttemptStore := infrastructure.NewLoginAttemptStore(db)
+	passwordResetRepo := infrastructure.NewPasswordResetRepository(db)
+	magicLinkRepo := infrastructure.NewMagicLinkRepository(db)
+	personalAccessTokenRepo := infrastructure.NewPersonalAccessTokenRepository(db)
+	serviceAccountRepo := infrastructure.NewServiceAccountRepository(db)
+	oidcRepo := infrastructure.NewOIDCRepository(db)
 	userRepo := infrastructure.NewUserRepository(db)
 	oauthRepo := infrastructure.NewOAuthRepository(db)
 	rbacRepo := repository.NewRBACRepository(db)
@@ -43,33 +53,61 @@ func main() {
 	}
 
 	// ユースケース初期化
-	userUsecase := infrastructure.NewUserUsecase(db)
-	authUsecase := infrastructure.NewAuthUsecase(authRepo, refreshTokenRepo, userRepo)
+	passwordHasher := infrastructure.NewPasswordHasher()
+	keyManager := infrastructure.NewKeyManager()
+	loginThrottle := infrastructure.NewLoginThrottle(loginAttemptStore, securityEventRepo)
+	mailer := infrastructure.NewMailer()
+	userUsecase := infrastructure.NewUserUsecase(db, passwordHasher)
+	authUsecase := infrastructure.NewAuthUsecase(authRepo, refreshTokenRepo, userRepo, securityEventRepo, revokedTokenStore, mfaRepo, rbacRepo, serviceAccountRepo, loginThrottle, passwordHasher, keyManager)
+	mfaUsecase := infrastructure.NewMFAUsecase(mfaRepo, userRepo, authUsecase, revokedTokenStore, loginThrottle)
+	passwordUsecase := infrastructure.NewPasswordUsecase(authRepo, userRepo, passwordResetRepo, personalAccessTokenRepo, securityEventRepo, authUsecase, mailer, passwordHasher, loginThrottle)
+	magicLinkUsecase := infrastructure.NewMagicLinkUsecase(authRepo, userRepo, magicLinkRepo, authUsecase, mailer, loginThrottle)
+	personalAccessTokenUsecase := infrastructure.NewPersonalAccessTokenUsecase(personalAccessTokenRepo, userRepo, rbacRepo)
+	serviceAccountUsecase := infrastructure.NewServiceAccountUsecase(serviceAccountRepo, authUsecase)
+	oidcUsecase := infrastructure.NewOIDCUsecase(oidcRepo, userRepo, revokedTokenStore, keyManager)
+	registrationUsecase := infrastructure.NewRegistrationUsecase(authRepo, userRepo, mailer, passwordHasher, keyManager, loginThrottle)
 	rbacUsecase := usecase.NewRBACUsecase(rbacRepo)
 	casbinUsecase := infrastructure.NewCasbinRBACUsecase(casbinRepo, rbacRepo)
 	productUsecase := usecase.NewProductUsecase(productRepo)
-	stateManager := infrastructure.NewStateManager()
-	oauthProviders := infrastructure.NewOAuthProviders(oauthRepo, authUsecase, stateManager)
+	stateManager := infrastructure.NewStateManager(db)
+	oauthStateUsecase := infrastructure.NewOAuthStateUsecase(stateManager, keyManager)
+	oauthProviders := infrastructure.NewOAuthProviders()
+	identityUsecase := infrastructure.NewIdentityUsecase(oauthRepo, authRepo, securityEventRepo, revokedTokenStore, authUsecase, keyManager)
+	returnToUsecase := infrastructure.NewReturnToUsecase(keyManager)
 
 	// Echoインスタンス
 	e := echo.New()
+	// クライアントIPの取得方法（X-Forwarded-For は信頼するプロキシを経由した場合のみ使う）
+	e.IPExtractor = infrastructure.NewIPExtractor()
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
+	// 認証に使うクッキーの属性（Secure・SameSite・Domain・プレフィックス）を環境ごとの設定で揃える
+	e.Use(authmiddleware.CookiePolicyMiddleware(infrastructure.NewCookiePolicy()))
 
 	// ルート登録
-	api.RegisterRoutes(e, userUsecase)
+	api.RegisterRoutes(e, userUsecase, authUsecase, rbacUsecase, personalAccessTokenUsecase)
 	api.RegisterHealthRoutes(e)
-	api.RegisterAuthRoutes(e, authUsecase)
-	api.RegisterOAuthRoutes(e, oauthProviders)
+	api.RegisterAuthRoutes(e, authUsecase, returnToUsecase)
+	api.RegisterMFARoutes(e, authUsecase, mfaUsecase, returnToUsecase)
+	api.RegisterRegistrationRoutes(e, registrationUsecase)
+	api.RegisterPasswordRoutes(e, authUsecase, passwordUsecase)
+	api.RegisterMagicLinkRoutes(e, magicLinkUsecase, returnToUsecase)
+	api.RegisterPersonalAccessTokenRoutes(e, authUsecase, personalAccessTokenUsecase)
+	api.RegisterOAuthTokenRoutes(e, serviceAccountUsecase, oidcUsecase)
+	api.RegisterOIDCRoutes(e, authUsecase, rbacUsecase, oidcUsecase)
+	api.RegisterJWKSRoutes(e, keyManager)
+	api.RegisterOAuthRoutes(e, authUsecase, identityUsecase, oauthStateUsecase, returnToUsecase, oauthProviders)
 	api.RegisterRBACRoutes(e, rbacUsecase)
+	api.RegisterAuthAdminRoutes(e, authUsecase, rbacUsecase)
 	api.RegisterCasbinRBACRoutes(e, casbinUsecase)
+	api.RegisterServiceAccountRoutes(e, authUsecase, rbacUsecase, casbinUsecase, serviceAccountUsecase)
 
 	frontend.RegisterTopRoutes(e)
 	frontend.RegisterBasicAuthRoutes(e)
 	frontend.RegisterDigestAuthRoutes(e)
 	frontend.RegisterFrontend(e)
-	frontend.RegisterAuthFrontendRoutes(e, authUsecase)
+	frontend.RegisterAuthFrontendRoutes(e, authUsecase, personalAccessTokenUsecase, returnToUsecase)
 
 	// SQLインジェクションデモルート
 	frontend.RegisterSqlInjectionRoutes(e, productUsecase)
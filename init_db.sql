@@ -4,13 +4,13 @@
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
-    email VARCHAR(100) UNIQUE NOT NULL,
-    password VARCHAR(100),
-    provider_id VARCHAR(100),
+    email VARCHAR(100) UNIQUE, -- メールアドレスを返さないプロバイダー（LINE等）で作成したユーザーはNULL
+    password VARCHAR(255), -- PHC形式のパスワードハッシュ（argon2id / bcrypt）
+    provider_id VARCHAR(100), -- 旧形式の連携（現在は user_identities に保存し、新たには書き込まない）
     provider_name VARCHAR(50),
+    email_verified_at TIMESTAMP, -- メールアドレスの確認日時（未確認の場合はNULL）
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE (provider_id, provider_name)
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 -- rolesテーブルの作成
@@ -53,10 +53,10 @@ CREATE TABLE IF NOT EXISTS role_permissions (
     UNIQUE(role_id, permission_id)
 );
 
--- テストユーザーの追加
-INSERT INTO users (name, email, password) VALUES 
-    ('テストユーザー1', 'user1@example.com', 'password123'),
-    ('テストユーザー2', 'user2@example.com', 'password456')
+-- テストユーザーの追加（パスワードはそれぞれ password123 / password456 のargon2idハッシュ）
+INSERT INTO users (name, email, password, email_verified_at) VALUES 
+    ('テストユーザー1', 'user1@example.com', '$argon2id$v=19$m=65536,t=3,p=2$KBySPc3Kwl78ycdJ6EzaGg$P1TC8o0MsjMRTKJkwUY86Ak1Kbl0yvZC8Nzb5dCbG1E', CURRENT_TIMESTAMP),
+    ('テストユーザー2', 'user2@example.com', '$argon2id$v=19$m=65536,t=3,p=2$OaZ+Kb2svm4se+txPNnANg$qc0ELUP+MnoQWLPWOZ/DSx6r+yQVrqxMDjSuVXZ52UI', CURRENT_TIMESTAMP)
 ON CONFLICT (email) DO NOTHING;
 
 -- 基本ロールの追加
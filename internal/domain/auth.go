@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -8,11 +10,55 @@ import (
 
 // Claims JWTクレームの構造体
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID   int    `json:"user_id"`
+	Email    string `json:"email"`
+	TokenUse string `json:"token_use"` // トークンの用途（access / mfa_challenge）
+	// 認可情報（アクセストークンのみ。下流サービスがDBを参照せずに認可判定できる）
+	Roles []string `json:"roles,omitempty"`
+	Scope string   `json:"scope,omitempty"` // "resource:action" 形式の権限をスペース区切りで列挙
+	// サービスアカウントのトークンの場合のみ設定（UserIDは0になる）
+	ServiceAccountID int    `json:"service_account_id,omitempty"`
+	ClientID         string `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IsServiceAccount サービスアカウントに発行されたトークンかどうか
+func (c *Claims) IsServiceAccount() bool {
+	return c.ServiceAccountID != 0
+}
+
+// HasAuthzClaims ロールまたは権限スコープのクレームを含むかどうか
+// 含まない場合（無効化されている、または以前に発行されたトークン）はDBで判定する
+func (c *Claims) HasAuthzClaims() bool {
+	return len(c.Roles) > 0 || c.Scope != ""
+}
+
+// HasRole ロールのクレームに指定したロールが含まれるか
+func (c *Claims) HasRole(roleName string) bool {
+	for _, role := range c.Roles {
+		if role == roleName {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 権限スコープのクレームに指定したリソース・アクションが含まれるか
+func (c *Claims) HasScope(resource, action string) bool {
+	scope := PermissionScope(resource, action)
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionScope 権限をスコープの文字列（"resource:action"）に変換
+func PermissionScope(resource, action string) string {
+	return resource + ":" + action
+}
+
 // AuthRequest 認証リクエストの構造体
 type AuthRequest struct {
 	Email    string `json:"email"`
@@ -24,55 +70,134 @@ type AuthResponse struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	User         User   `json:"user"`
+	// 二要素認証が必要な場合はトークンの代わりにチャレンジトークンを返す
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+	// ログイン後の戻り先（ログイン前にアクセスした画面など）
+	RedirectTo string `json:"redirect_to,omitempty"`
+}
+
+// ErrInvalidCredentials メールアドレスまたはパスワードが正しくない
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrInvalidAudience トークンのaudが受け付ける値に含まれない、または発行が許可されていないaud
+var ErrInvalidAudience = errors.New("invalid token audience")
+
+// AudienceToken 特定のサービス（aud）向けに発行したアクセストークン
+type AudienceToken struct {
+	AccessToken string `json:"access_token"`
+	Audience    string `json:"audience"`
+	ExpiresIn   int    `json:"expires_in"` // 有効期限（秒）
 }
 
 // AuthRepository 認証リポジトリのインターフェース
 type AuthRepository interface {
-	ValidateCredentials(email, password string) (*User, error)
+	// メールアドレスでユーザーを取得（存在しない場合はnil）
+	GetUserByEmail(email string) (*User, error)
+	// パスワードハッシュを更新
+	UpdatePassword(userID int, passwordHash string) error
+	// メールアドレスを確認済みにする（確認時点のメールアドレスと一致しない場合はfalse）
+	MarkEmailVerified(userID int, email string) (bool, error)
 }
 
 // AuthUsecase 認証ユースケースのインターフェース
 type AuthUsecase interface {
-	Login(email, password string) (*AuthResponse, error)
-	ValidateToken(tokenString string) (*Claims, error)
+	Login(email, password, deviceInfo, ipAddress string) (*AuthResponse, error)
+	// アクセストークンを検証（audiencesを省略した場合は自サービスのaudのみ受け付ける）
+	ValidateToken(tokenString string, audiences ...string) (*Claims, error)
 	GenerateToken(user *User) (string, error)
-	// リフレッシュトークンを使用して新しいトークンペアを生成
-	RefreshToken(refreshToken string) (*TokenPair, error)
-	// ログアウト時にリフレッシュトークンを無効化
-	Logout(userID int) error
+	// 他の内部サービス（aud）向けのアクセストークンを発行
+	GenerateTokenForAudience(userID int, audience string) (*AudienceToken, error)
+	// サービスアカウント向けのアクセストークンを発行（audienceを省略した場合は自サービスのaud）
+	GenerateServiceAccountToken(account *ServiceAccount, audience string) (*OAuthTokenResponse, error)
+	// リフレッシュトークンを使用して新しいトークンペアを生成（ipAddress・deviceInfoは提示したクライアント。再利用の検知時に記録する）
+	RefreshToken(refreshToken, ipAddress, deviceInfo string) (*TokenPair, error)
+	// 現在のセッション（アクセストークンのJTIで特定）をログアウト
+	Logout(userID int, currentJTI string) error
+	// 管理者による強制ログアウト（actorは操作者の表示名。ユーザーまたはサービスアカウント）
+	ForceLogout(actor string, userID int) error
+	// ユーザーのすべてのセッション（リフレッシュトークンと発行済みアクセストークン）を無効化
+	RevokeAllSessions(userID int) error
+	// 管理者によるアカウントのログインロック解除
+	UnlockAccount(actor string, userID int) error
+	// 管理者によるIPアドレスのログインロック解除
+	UnlockIP(actor, ipAddress string) error
+	// ログイン中のセッション一覧を取得
+	GetSessions(userID int, currentJTI string) ([]*Session, error)
+	// 指定したセッションを無効化
+	RevokeSession(userID int, sessionID string) error
+	// 現在のセッション以外をすべて無効化
+	RevokeOtherSessions(userID int, currentJTI string) error
 	// トークンペアを生成
 	GenerateTokenPair(user *User, deviceInfo, ipAddress string) (*TokenPair, error)
+	// 二要素認証が有効なユーザーにチャレンジトークンを発行（無効な場合はnil）
+	IssueMFAChallenge(user *User) (*AuthResponse, error)
+	// チャレンジトークンを検証
+	ValidateMFAChallenge(mfaToken string) (*Claims, error)
 }
 
 // JWTConfig JWT設定の構造体
 type JWTConfig struct {
-	SecretKey              string
-	Duration               time.Duration // アクセストークンの有効期限
-	RefreshTokenDuration   time.Duration // リフレッシュトークンの有効期限
+	Duration             time.Duration // アクセストークンの有効期限
+	RefreshTokenDuration time.Duration // リフレッシュトークンの有効期限
+	MFAChallengeDuration time.Duration // 二要素認証チャレンジトークンの有効期限
+	AuthzClaims          bool          // アクセストークンにロールと権限スコープのクレームを含めるか
+	Issuer               string        // 発行するトークンのiss（検証時も一致を要求）
+	Audience             string        // 自サービスのaud（GenerateTokenで発行するトークンのaud）
+	Audiences            []string      // GenerateTokenForAudienceで発行を許可する他サービスのaud
+	Algorithms           []string      // 検証時に受け付ける署名アルゴリズム
+	Leeway               time.Duration // exp / nbf / iat の検証で許容する時刻のずれ
 }
 
-// StateManager stateパラメータの管理インターフェース
+// Cookie SameSite属性の設定値
+const (
+	CookieSameSiteLax    = "lax"
+	CookieSameSiteStrict = "strict"
+	CookieSameSiteNone   = "none"
+)
+
+// CookieConfig 認証に使うクッキーの設定（環境ごとに切り替える）
+type CookieConfig struct {
+	Secure             bool          // HTTPSの場合のみ送信するか（本番環境ではtrue）
+	SameSite           string        // ログイン状態のクッキーのSameSite属性（lax / strict / none）
+	Domain             string        // Domain属性（空の場合は発行したホストのみに送信）
+	HostPrefix         bool          // 名前に __Host- / __Secure- を付けるか（Secureが必須になり、Domainは指定しない）
+	AccessTokenMaxAge  time.Duration // アクセストークンのクッキーの有効期限
+	RefreshTokenMaxAge time.Duration // リフレッシュトークンのクッキーの有効期限
+	MFAChallengeMaxAge time.Duration // 二要素認証チャレンジトークンのクッキーの有効期限
+}
+
+// StateManager 認可リクエストのstateの保存先インターフェース（PostgreSQL / インメモリ）
 type StateManager interface {
-	GenerateState() string
-	ValidateState(state string) bool
+	// stateと、紐付けた認可リクエストの目的・PKCEのコードベリファイア・nonceを保存
+	SaveState(state *OAuthState) error
+	// stateを取り出して削除（存在しない・期限切れの場合はnil。同じstateは一度だけ取り出せる）
+	ConsumeState(state string) (*OAuthState, error)
+	// 有効期限を過ぎたstateを削除
+	Prune() error
 }
 
 // RefreshToken リフレッシュトークンのエンティティ
 type RefreshToken struct {
-	ID             int       `json:"id"`
-	UserID         int       `json:"user_id"`
-	Token          string    `json:"token"`
-	AccessTokenJTI string    `json:"access_token_jti"`
-	ExpiresAt      time.Time `json:"expires_at"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             int        `json:"id"`
+	UserID         int        `json:"user_id"`
+	TokenHash      string     `json:"-"` // トークン本体のSHA-256ダイジェスト
+	AccessTokenJTI string     `json:"access_token_jti"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 	LastUsedAt     *time.Time `json:"last_used_at"`
-	Revoked        bool      `json:"revoked"`
+	Revoked        bool       `json:"revoked"`
 	RevokedAt      *time.Time `json:"revoked_at"`
-	DeviceInfo     string    `json:"device_info"`
-	IPAddress      string    `json:"ip_address"`
+	DeviceInfo     string     `json:"device_info"`
+	IPAddress      string     `json:"ip_address"`
+	FamilyID       string     `json:"family_id"`      // 同じログインから派生したトークンを束ねるID
+	ReplacedByID   *int       `json:"replaced_by_id"` // ローテーション後の後継トークンのID
 }
 
+// ErrRefreshTokenReused ローテーション済みのリフレッシュトークンが再提示された
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
 // RefreshTokenRequest リフレッシュトークンリクエストの構造体
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token"`
@@ -88,14 +213,22 @@ type RefreshTokenResponse struct {
 type RefreshTokenRepository interface {
 	// リフレッシュトークンを保存
 	Create(token *RefreshToken) error
-	// トークン文字列でリフレッシュトークンを取得
-	GetByToken(token string) (*RefreshToken, error)
+	// トークンのハッシュ値でリフレッシュトークンを取得（再利用検知のため無効化済みのものも返す）
+	GetByTokenHash(tokenHash string) (*RefreshToken, error)
 	// ユーザーIDでリフレッシュトークンを取得
 	GetByUserID(userID int) ([]*RefreshToken, error)
+	// 一緒に発行したアクセストークンのJTIでリフレッシュトークンを取得（無効化済みのものを含む。存在しない場合はnil）
+	GetByAccessTokenJTI(userID int, jti string) (*RefreshToken, error)
+	// ファミリーIDでリフレッシュトークンを取得（無効化済みのものを含む）
+	GetByFamilyID(familyID string) ([]*RefreshToken, error)
 	// リフレッシュトークンを更新
 	Update(token *RefreshToken) error
 	// リフレッシュトークンを無効化
 	Revoke(tokenID int) error
+	// 旧トークンを無効化して後継トークンを保存（旧トークンが既に無効な場合はErrRefreshTokenReused）
+	Rotate(oldTokenID int, newToken *RefreshToken) error
+	// ファミリー内のすべてのリフレッシュトークンを無効化
+	RevokeFamily(familyID string) error
 	// ユーザーのすべてのリフレッシュトークンを無効化
 	RevokeAllByUserID(userID int) error
 	// 期限切れのトークンを削除
@@ -106,5 +239,6 @@ type RefreshTokenRepository interface {
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int    `json:"expires_in"` // アクセストークンの有効期限（秒）
+	ExpiresIn    int    `json:"expires_in"`            // アクセストークンの有効期限（秒）
+	RedirectTo   string `json:"redirect_to,omitempty"` // ログイン後の戻り先（ログインを完了したときのみ）
 }
@@ -0,0 +1,36 @@
+package domain
+
+// GitHubAuthConfig GitHub OAuth設定
+type GitHubAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// BaseURL 認可・トークンエンドポイントのベースURL（デフォルト: https://github.com）
+	BaseURL string
+	// APIBaseURL REST APIのベースURL（デフォルト: https://api.github.com。GitHub Enterprise Serverは {BaseURL}/api/v3）
+	APIBaseURL string
+	// AllowedOrganizations ログインを許可するOrganization（空の場合は制限しない）
+	AllowedOrganizations []string
+}
+
+// GitHubUser GitHubユーザー情報（GET /user）
+type GitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// GitHubEmail GitHubユーザーのメールアドレス（GET /user/emails）
+type GitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// GitHubOrgMembership Organizationのメンバーシップ（GET /user/memberships/orgs/{org}）
+type GitHubOrgMembership struct {
+	State string `json:"state"` // active / pending
+	Role  string `json:"role"`
+}
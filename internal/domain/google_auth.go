@@ -29,8 +29,8 @@ type GoogleAuthRepository interface {
 // GoogleAuthUsecase Google認証ユースケースのインターフェース（OAuthUsecaseに統合）
 type GoogleAuthUsecase interface {
 	GetProviderName() string
-	GetAuthURL() string
-	ExchangeCodeForToken(code string) (*oauth2.Token, error)
+	GetAuthURL() (string, error)
+	ExchangeCodeForToken(code, codeVerifier string) (*oauth2.Token, error)
 	GetUserInfo(token *oauth2.Token) (*OAuthUser, error)
 	Authenticate(code string) (*AuthResponse, error)
 }
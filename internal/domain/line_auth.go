@@ -46,8 +46,8 @@ type LineAuthRepository interface {
 // LineAuthUsecase LINE認証ユースケースのインターフェース
 type LineAuthUsecase interface {
 	GetProviderName() string
-	GetAuthURL() string
-	ExchangeCodeForToken(code string) (*oauth2.Token, error)
+	GetAuthURL() (string, error)
+	ExchangeCodeForToken(code, codeVerifier string) (*oauth2.Token, error)
 	GetUserInfo(token *oauth2.Token) (*OAuthUser, error)
 	Authenticate(code string) (*AuthResponse, error)
 }
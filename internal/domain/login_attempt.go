@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoginLockedError ロックアウト中またはバックオフ中のため試行を拒否した
+type LoginLockedError struct {
+	RetryAfter time.Duration // 次に試行できるまでの時間
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many login attempts; retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginAttempt キー（アカウント・IPアドレス等）ごとのログイン失敗状況
+type LoginAttempt struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`        // 集計期間内の連続失敗回数
+	LastFailureAt time.Time  `json:"last_failure_at"` // 最後に失敗した日時
+	LockedUntil   *time.Time `json:"locked_until"`    // ロックアウトの解除日時
+}
+
+// LoginAttemptConfig ログイン試行制限の設定
+type LoginAttemptConfig struct {
+	MaxAccountFailures int           // アカウントをロックするまでの失敗回数
+	MaxIPFailures      int           // IPアドレスをロックするまでの失敗回数
+	FreeAttempts       int           // バックオフなしで許可する失敗回数
+	BaseDelay          time.Duration // バックオフの初期待ち時間（失敗ごとに倍増）
+	MaxDelay           time.Duration // バックオフの上限
+	LockoutDuration    time.Duration // ロックアウト期間（失敗回数の集計期間も兼ねる）
+}
+
+// LoginAttemptStore ログイン失敗状況の保存先インターフェース
+type LoginAttemptStore interface {
+	// キーの失敗状況を取得（記録がない場合はnil）
+	Get(key string) (*LoginAttempt, error)
+	// 失敗を記録して更新後の状況を返す（最後の失敗からwindow以上経過していれば1から数え直す）
+	RecordFailure(key string, window time.Duration) (*LoginAttempt, error)
+	// 指定日時までロックし、失敗回数をリセット
+	Lock(key string, until time.Time) error
+	// 失敗回数とロックを解除
+	Reset(key string) error
+	// 指定日時より前の失敗で、ロック中でないエントリを削除
+	Prune(before time.Time) error
+}
+
+// LoginThrottle ログイン試行制限のインターフェース
+type LoginThrottle interface {
+	// いずれかのキーがロック中またはバックオフ中なら*LoginLockedErrorを返す
+	Check(keys ...string) error
+	// 失敗を記録し、閾値に達したキーをロックしてセキュリティイベントを記録
+	RecordFailure(userID *int, ipAddress string, keys ...string)
+	// 成功時に失敗回数をリセット
+	Reset(keys ...string)
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidMagicLink ログインリンクが無効・使用済み・期限切れ
+	ErrInvalidMagicLink = errors.New("invalid or expired magic link")
+	// ErrMagicLinkBrowserMismatch ログインリンクを要求したブラウザ以外で開かれた
+	ErrMagicLinkBrowserMismatch = errors.New("magic link was requested from a different browser")
+)
+
+// MagicLinkToken メールで送るワンタイムログインリンクのトークン
+type MagicLinkToken struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	TokenHash   string     `json:"-"` // トークン本体のSHA-256ダイジェスト
+	BindingHash string     `json:"-"` // 要求元ブラウザのクッキーに保存した値のSHA-256ダイジェスト
+	IPAddress   string     `json:"ip_address"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// MagicLinkConfig マジックリンクログインの設定
+type MagicLinkConfig struct {
+	BaseURL       string        // ログインリンクに使用するアプリケーションのURL
+	TokenDuration time.Duration // ログインリンクの有効期限
+}
+
+// MagicLinkRepository マジックリンクトークンリポジトリのインターフェース
+type MagicLinkRepository interface {
+	// トークンを保存
+	Create(token *MagicLinkToken) error
+	// トークンのハッシュ値で取得（存在しない場合はnil）
+	GetByTokenHash(tokenHash string) (*MagicLinkToken, error)
+	// 未使用のトークンを使用済みにする（既に使用済みの場合はfalse）
+	MarkUsed(tokenID int) (bool, error)
+	// ユーザーの未使用のトークンをすべて無効化
+	InvalidateByUserID(userID int) error
+}
+
+// MagicLinkUsecase パスワードなしのマジックリンクログインのユースケースのインターフェース
+type MagicLinkUsecase interface {
+	// ログインリンクをメールで送信し、要求元ブラウザに保存するバインディング値とリンクの有効期限を返す
+	// アカウントが存在しない場合も同じように値を返す（要求回数の制限中は*LoginLockedError）
+	RequestLink(email, ipAddress string) (string, time.Time, error)
+	// ログインリンクのトークンとバインディング値を検証してトークンペアを発行
+	// 二要素認証が有効な場合は *MFARequiredError を返す
+	VerifyLink(token, binding, deviceInfo, ipAddress string) (*TokenPair, error)
+}
@@ -0,0 +1,13 @@
+package domain
+
+// MailMessage 送信するメール
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string // プレーンテキスト本文
+}
+
+// Mailer メール送信のインターフェース（smtp / file / log ドライバーを切り替えられる）
+type Mailer interface {
+	Send(message *MailMessage) error
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// JWTのtoken_useクレームの値
+const (
+	TokenUseAccess       = "access"        // APIアクセス用のアクセストークン
+	TokenUseMFAChallenge = "mfa_challenge" // パスワード検証後、二要素認証の完了までに使う短命なトークン
+)
+
+var (
+	// ErrMFANotEnabled 二要素認証が有効になっていない
+	ErrMFANotEnabled = errors.New("mfa is not enabled")
+	// ErrMFAAlreadyEnabled 二要素認証が既に有効になっている
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	// ErrMFAEnrollmentNotFound 登録開始前に確認しようとした
+	ErrMFAEnrollmentNotFound = errors.New("mfa enrollment not found")
+	// ErrInvalidMFACode 認証コード（またはリカバリーコード）が正しくない
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+	// ErrInvalidMFAChallenge チャレンジトークンが無効または期限切れ
+	ErrInvalidMFAChallenge = errors.New("invalid mfa challenge")
+)
+
+// MFARequiredError 本人確認はできたが、トークンペアの発行前に二要素認証が必要
+// トークンペアを直接返すフロー（マジックリンクなど）でチャレンジを呼び出し元に渡すために使う
+type MFARequiredError struct {
+	Challenge *AuthResponse
+}
+
+func (e *MFARequiredError) Error() string {
+	return "mfa verification required"
+}
+
+// UserMFA ユーザーのTOTP設定
+type UserMFA struct {
+	UserID          int        `json:"user_id"`
+	SecretEncrypted string     `json:"-"`       // AES-GCMで暗号化したTOTPシークレット
+	Enabled         bool       `json:"enabled"` // 最初のコードで確認済みかどうか
+	LastUsedStep    int64      `json:"-"`       // 最後に受け付けたタイムステップ（リプレイ防止）
+	ConfirmedAt     *time.Time `json:"confirmed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// MFAEnrollment TOTP登録開始時のレスポンス
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`      // 手入力用のBase32シークレット
+	OTPAuthURI string `json:"otpauth_uri"` // 認証アプリ用のotpauth:// URI
+	QRCode     string `json:"qr_code"`     // otpauth URIのQRコード（data:image/png;base64,...）
+}
+
+// MFAStatus 二要素認証の設定状況
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// MFAConfig 二要素認証の設定
+type MFAConfig struct {
+	Issuer        string // 認証アプリに表示される発行者名
+	EncryptionKey []byte // TOTPシークレット暗号化用のAES-256鍵
+}
+
+// MFARepository 二要素認証リポジトリのインターフェース
+type MFARepository interface {
+	// ユーザーのTOTP設定を取得（存在しない場合はnil）
+	GetByUserID(userID int) (*UserMFA, error)
+	// 未確認のシークレットを保存（有効化済みの設定は上書きしない）
+	SavePending(userID int, secretEncrypted string) error
+	// 確認済みとして有効化
+	Enable(userID int) error
+	// TOTP設定とリカバリーコードを削除
+	Delete(userID int) error
+	// 受け付けたタイムステップを記録（既に同じか新しいステップを使用済みならfalse）
+	UpdateLastUsedStep(userID int, step int64) (bool, error)
+	// リカバリーコード（ハッシュ値）を入れ替え
+	ReplaceRecoveryCodes(userID int, codeHashes []string) error
+	// 未使用のリカバリーコードを使用済みにする（該当がなければfalse）
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	// 未使用のリカバリーコード数を取得
+	CountRecoveryCodes(userID int) (int, error)
+}
+
+// MFAUsecase 二要素認証ユースケースのインターフェース
+type MFAUsecase interface {
+	// 設定状況を取得
+	GetStatus(userID int) (*MFAStatus, error)
+	// シークレットを生成して登録を開始
+	BeginEnrollment(userID int) (*MFAEnrollment, error)
+	// 最初のコードで登録を確認し、リカバリーコードを発行（コードの試行回数はユーザーとIPアドレスごとに制限し、制限中は*LoginLockedError）
+	ConfirmEnrollment(userID int, code, ipAddress string) ([]string, error)
+	// 二要素認証を無効化（コードの試行回数はConfirmEnrollmentと同様に制限する）
+	Disable(userID int, code, ipAddress string) error
+	// リカバリーコードを再発行（コードの試行回数はDisableと同様に制限する）
+	RegenerateRecoveryCodes(userID int, code, ipAddress string) ([]string, error)
+	// チャレンジトークンと認証コードを検証してトークンペアを発行
+	Verify(mfaToken, code, deviceInfo, ipAddress string) (*AuthResponse, error)
+}
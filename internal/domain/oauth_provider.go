@@ -1,13 +1,30 @@
 package domain
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
 	"golang.org/x/oauth2"
 )
 
+// ErrOAuthAccessDenied プロバイダーでの認証には成功したが、このアプリケーションへのログインが許可されていない
+var ErrOAuthAccessDenied = errors.New("oauth access denied")
+
+// ErrInvalidOAuthState stateが存在しない・期限切れ・使用済み
+var ErrInvalidOAuthState = errors.New("invalid oauth state")
+
+// ErrOAuthStateBrowserMismatch 認可リクエストを開始したブラウザ以外でコールバックを受けた
+var ErrOAuthStateBrowserMismatch = errors.New("oauth state was issued to a different browser")
+
+// TokenUseOAuthState 認可リクエストを開始したブラウザにstateを紐付けるクッキーのトークン
+const TokenUseOAuthState = "oauth_state"
+
 // OAuthProvider OAuthプロバイダーの共通インターフェース
 type OAuthProvider interface {
 	GetProviderName() string
-	GetAuthURL() string
+	GetAuthURL() (string, error)
 	ExchangeCodeForToken(code string) (*oauth2.Token, error)
 	GetUserInfo(token *oauth2.Token) (*OAuthUser, error)
 	Authenticate(code string) (*AuthResponse, error)
@@ -21,6 +38,90 @@ type OAuthUser struct {
 	Name         string `json:"name"`
 	Picture      string `json:"picture"`
 	Verified     bool   `json:"verified"`
+	// プロバイダーから取得したプロフィール（user_identities.profileに保存する）
+	Raw map[string]interface{} `json:"-"`
+}
+
+// OAuthIntent 認可リクエストの目的（stateと一緒に保存し、コールバックで参照する）
+type OAuthIntent struct {
+	// ログイン中のユーザーへのプロバイダーの連携（0の場合はログイン）
+	LinkUserID int
+	// ログイン後の戻り先（空の場合は /protected）
+	ReturnTo string
+}
+
+// OAuthState 外部プロバイダーへの認可リクエストごとに保存する値
+// コールバックのstateで取り出し、トークン交換（PKCE）とIDトークンの検証（nonce）に使う
+type OAuthState struct {
+	OAuthIntent
+	State        string
+	CodeVerifier string // PKCEのコードベリファイア（RFC 7636）
+	Nonce        string // IDトークンのnonce（リプレイ対策）
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// OAuthStateUsecase 認可リクエストのstateの発行と、開始したブラウザでのコールバックの検証
+type OAuthStateUsecase interface {
+	// stateを生成して保存し、開始したブラウザのクッキーに保存する署名付きの値を返す
+	Begin(intent OAuthIntent) (*OAuthState, string, error)
+	// クッキーの値とstateを照合し、保存したstateを取り出す（同じstateは一度だけ使用可能）
+	// 不一致の場合はErrOAuthStateBrowserMismatch、stateが無効な場合はErrInvalidOAuthState
+	Complete(state, cookie string) (*OAuthState, error)
+}
+
+// CodeChallenge コードベリファイアからS256のコードチャレンジを計算
+func (s *OAuthState) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(s.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// IDTokenClaims 外部プロバイダーのIDトークンから取り出したクレーム（検証済み）
+type IDTokenClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+	// 検証済みのすべてのクレーム（設定によるクレームのマッピングに使う）
+	Raw map[string]interface{}
+}
+
+// IDTokenVerifierConfig 外部プロバイダーのIDトークンの検証設定
+type IDTokenVerifierConfig struct {
+	Issuers  []string // 受け付けるiss（Googleは "https://accounts.google.com" と "accounts.google.com" の両方を使う）
+	ClientID string   // aud として要求する自分のクライアントID
+	JWKSURL  string   // 署名検証用の公開鍵（JWKS）の取得先
+	// HS256で署名されたIDトークン（LINEのウェブログイン）の検証に使う共有鍵（空の場合はHS256を受け付けない）
+	ClientSecret  string
+	CacheDuration time.Duration // JWKSのキャッシュ期間（Cache-Controlのmax-ageがあればそちらを優先）
+	Leeway        time.Duration // exp / iat の検証で許容する時刻のずれ
+}
+
+// IDTokenVerifier 外部プロバイダーのIDトークンの検証インターフェース
+type IDTokenVerifier interface {
+	// 署名・iss・aud・exp・nonceを検証してクレームを返す
+	Verify(idToken, nonce string) (*IDTokenClaims, error)
+}
+
+// GenericOIDCConfig 設定だけで追加できる汎用のOpenID Connect プロバイダー（Keycloak・Okta・Azure AD等）の設定
+// エンドポイントと署名鍵は {Issuer}/.well-known/openid-configuration から取得する
+type GenericOIDCConfig struct {
+	Name         string // ルート（/auth/{name}）とusers.provider_nameに使う識別子
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Claims       OIDCClaimMapping
+}
+
+// OIDCClaimMapping ユーザー情報として使うクレーム名（IdPごとの違いを設定で吸収する）
+type OIDCClaimMapping struct {
+	Email         string // デフォルト: email
+	EmailVerified string // デフォルト: email_verified
+	Name          string // デフォルト: name
+	Picture       string // デフォルト: picture
 }
 
 // OAuthConfig OAuth設定の共通構造体
@@ -33,13 +134,25 @@ type OAuthConfig struct {
 
 // OAuthRepository OAuthリポジトリの共通インターフェース
 type OAuthRepository interface {
-	GetOrCreateUser(oauthUser *OAuthUser) (*User, error)
+	// プロバイダーとsubjectで連携済みのユーザーを取得し、連携のメールアドレスとプロフィールを更新（未連携の場合はnil）
+	FindUserByIdentity(oauthUser *OAuthUser) (*User, error)
+	// ユーザーを作成して連携する（同じメールアドレスのユーザーがいる場合はErrOAuthEmailInUse）
+	CreateUserWithIdentity(oauthUser *OAuthUser) (*User, error)
+	// 既存のユーザーにプロバイダーを連携（他のユーザーに連携済みの場合はErrIdentityAlreadyLinked）
+	LinkIdentity(userID int, oauthUser *OAuthUser) (*UserIdentity, error)
+	GetIdentity(userID, identityID int) (*UserIdentity, error)
+	ListIdentities(userID int) ([]*UserIdentity, error)
+	// 他のログイン手段（パスワードまたは他の連携）が残る場合のみ削除する
+	DeleteIdentity(userID, identityID int) (bool, error)
 }
 
 // OAuthUsecase OAuthユースケースの共通インターフェース
+// プロバイダーでの認証のみを行い、stateの管理はOAuthStateUsecase、アカウントの作成・連携はIdentityUsecaseで行う
 type OAuthUsecase interface {
 	GetProviderName() string
-	GetAuthURL() string
+	// stateに紐付けたPKCEのコードチャレンジ（とnonce）を含む認可URLを作成
+	GetAuthURL(state *OAuthState) (string, error)
 	GetUserInfo(token interface{}) (*OAuthUser, error)
-	Authenticate(code string, state string) (*AuthResponse, error)
+	// コールバックのコードをトークンに交換し、プロバイダーのユーザー情報を返す（stateは検証済みのもの）
+	Authenticate(code string, state *OAuthState) (*OAuthUser, error)
 }
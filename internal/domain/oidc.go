@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// OIDCClientIDPrefix OpenID Connect クライアントのクライアントIDの接頭辞
+	OIDCClientIDPrefix = "oidc_"
+	// OIDCClientSecretPrefix OpenID Connect クライアントのシークレットの接頭辞
+	OIDCClientSecretPrefix = "gedoc_"
+
+	// TokenUseOIDCAccess OpenID Connect クライアントに発行するアクセストークン（/oauth/userinfo でのみ使用可能）
+	TokenUseOIDCAccess = "oidc_access"
+	// TokenUseOIDCConsent 同意画面の表示から送信までの間、検証済みの認可リクエストを保持するトークン
+	TokenUseOIDCConsent = "oidc_consent"
+
+	// GrantTypeAuthorizationCode 認可コードグラント
+	GrantTypeAuthorizationCode = "authorization_code"
+	// CodeChallengeMethodS256 PKCEのコードチャレンジ方式（plainは受け付けない）
+	CodeChallengeMethodS256 = "S256"
+)
+
+// OpenID Connect のスコープ
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// SupportedOIDCScopes 認可リクエストで受け付けるスコープ（それ以外は無視する）
+var SupportedOIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+var (
+	// ErrOIDCClientNotFound 指定したクライアントが存在しない
+	ErrOIDCClientNotFound = errors.New("oidc client not found")
+	// ErrInvalidOIDCClientRequest クライアント名やリダイレクトURIの指定が不正
+	ErrInvalidOIDCClientRequest = errors.New("invalid oidc client request")
+	// ErrInvalidGrant 認可コードが無効・期限切れ・使用済み、またはリダイレクトURIやPKCEの検証に失敗
+	ErrInvalidGrant = errors.New("invalid grant")
+	// ErrInvalidConsentChallenge 同意画面のトークンが無効・期限切れ、または別のユーザーのもの
+	ErrInvalidConsentChallenge = errors.New("invalid consent challenge")
+)
+
+// AuthorizationError 認可エンドポイントのエラー（OpenID Connect Core 3.1.2.6）
+// Locationが空の場合はリダイレクトURIが検証できていないため、クライアントにリダイレクトせず画面に表示する
+type AuthorizationError struct {
+	Code        string // invalid_request / unauthorized_client / access_denied / login_required 等
+	Description string
+	Location    string // エラーを付与したリダイレクト先
+}
+
+func (e *AuthorizationError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OIDCClient "Sign in with go-echo-demo" を利用する内部アプリ（リライングパーティ）
+type OIDCClient struct {
+	ID               int       `json:"id"`
+	ClientID         string    `json:"client_id"`
+	ClientSecretHash string    `json:"-"` // クライアントシークレットのSHA-256ダイジェスト（パブリッククライアントは空）
+	Name             string    `json:"name"`
+	RedirectURIs     []string  `json:"redirect_uris"`
+	Public           bool      `json:"public"` // シークレットを保持できないクライアント（SPA・ネイティブアプリ）
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// RegisterOIDCClientRequest クライアント登録リクエスト
+type RegisterOIDCClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Public       bool     `json:"public"`
+}
+
+// OIDCClientCredentials 登録直後のクライアント（平文のシークレットはこのときだけ返す）
+type OIDCClientCredentials struct {
+	ClientSecret string `json:"client_secret,omitempty"`
+	OIDCClient
+}
+
+// AuthorizationRequest 認可エンドポイントへのリクエスト
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              string
+}
+
+// HasPrompt promptパラメータ（スペース区切り）に指定した値が含まれるか
+func (r *AuthorizationRequest) HasPrompt(value string) bool {
+	return slices.Contains(strings.Fields(r.Prompt), value)
+}
+
+// AuthorizationResult 認可リクエストの処理結果（RedirectURLかConsentのどちらか）
+type AuthorizationResult struct {
+	// 同意済みの場合、認可コードを付与したリダイレクト先
+	RedirectURL string
+	// 同意が必要な場合、同意画面に表示する内容
+	Consent *ConsentPrompt
+}
+
+// ConsentPrompt 同意画面に表示する内容
+type ConsentPrompt struct {
+	ClientName string
+	Scopes     []string
+	UserEmail  string
+	// 同意・拒否の送信時にそのまま返すトークン（検証済みの認可リクエストを含む）
+	Challenge string
+}
+
+// AuthorizationCode 認可コード（一度だけ使用可能）
+type AuthorizationCode struct {
+	ID             int
+	CodeHash       string // 認可コードのSHA-256ダイジェスト
+	ClientID       string
+	UserID         int
+	RedirectURI    string
+	Scope          string
+	Nonce          string
+	CodeChallenge  string
+	ExpiresAt      time.Time
+	UsedAt         *time.Time
+	AccessTokenJTI string // 発行したアクセストークンのJTI（コードの再利用時に失効させる）
+	CreatedAt      time.Time
+}
+
+// OIDCConsent ユーザーがクライアントに許可したスコープ
+type OIDCConsent struct {
+	UserID   int
+	ClientID string
+	Scopes   []string
+}
+
+// OpenIDConfiguration ディスカバリードキュメント（OpenID Connect Discovery 1.0）
+type OpenIDConfiguration struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+// OIDCConfig OpenID Connect プロバイダーの設定
+type OIDCConfig struct {
+	CodeDuration    time.Duration // 認可コードの有効期限
+	ConsentDuration time.Duration // 同意画面の表示から送信までの有効期限
+}
+
+// OIDCRepository OpenID Connect プロバイダーのリポジトリのインターフェース
+type OIDCRepository interface {
+	// クライアントを保存
+	CreateClient(client *OIDCClient) error
+	// クライアントIDで取得（存在しない場合はnil）
+	GetClientByClientID(clientID string) (*OIDCClient, error)
+	// クライアント一覧を取得
+	ListClients() ([]*OIDCClient, error)
+	// クライアントを削除（該当するクライアントがない場合はfalse）
+	DeleteClient(id int) (bool, error)
+
+	// 認可コードを保存
+	CreateAuthorizationCode(code *AuthorizationCode) error
+	// ハッシュ値で認可コードを取得（再利用検知のため使用済みのものも返す）
+	GetAuthorizationCodeByHash(codeHash string) (*AuthorizationCode, error)
+	// 認可コードを使用済みにして発行したアクセストークンのJTIを記録（既に使用済みの場合はfalse）
+	MarkAuthorizationCodeUsed(id int, accessTokenJTI string) (bool, error)
+
+	// ユーザーがクライアントに許可したスコープを取得（存在しない場合はnil）
+	GetConsent(userID int, clientID string) (*OIDCConsent, error)
+	// 許可したスコープを保存（既存の同意は上書き）
+	SaveConsent(consent *OIDCConsent) error
+}
+
+// OIDCUsecase OpenID Connect プロバイダーのユースケースのインターフェース
+type OIDCUsecase interface {
+	// クライアントを登録（コンフィデンシャルクライアントの場合はシークレットを返す）
+	RegisterClient(req *RegisterOIDCClientRequest) (*OIDCClientCredentials, error)
+	// クライアント一覧を取得
+	ListClients() ([]*OIDCClient, error)
+	// クライアントを削除
+	DeleteClient(id int) error
+
+	// 認可リクエストのクライアント・リダイレクトURI・パラメータを検証（ログイン前にも呼ばれる）
+	ValidateAuthorizationRequest(req *AuthorizationRequest) error
+	// ログイン済みユーザーの認可リクエストを処理（同意済みなら認可コードを発行）
+	Authorize(userID int, req *AuthorizationRequest) (*AuthorizationResult, error)
+	// 同意画面の結果を処理し、認可コードまたはエラーを付与したリダイレクト先を返す
+	Consent(userID int, challenge string, approved bool) (string, error)
+	// 認可コードをIDトークンとアクセストークンに交換
+	ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier string) (*OAuthTokenResponse, error)
+	// アクセストークンのスコープに応じたユーザー情報を取得
+	UserInfo(accessToken string) (map[string]interface{}, error)
+	// ディスカバリードキュメントを取得
+	Discovery() *OpenIDConfiguration
+}
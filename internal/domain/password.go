@@ -0,0 +1,24 @@
+package domain
+
+// パスワードハッシュのアルゴリズム
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// PasswordHashConfig パスワードハッシュ設定の構造体
+type PasswordHashConfig struct {
+	Algorithm     string // 新規ハッシュに使用するアルゴリズム（argon2id / bcrypt）
+	Argon2Memory  uint32 // argon2idのメモリコスト（KiB）
+	Argon2Time    uint32 // argon2idの反復回数
+	Argon2Threads uint8  // argon2idの並列度
+	BcryptCost    int    // bcryptのコスト
+}
+
+// PasswordHasher パスワードハッシュのインターフェース
+type PasswordHasher interface {
+	// パスワードをハッシュ化（PHC形式のバージョン付き文字列を返す）
+	Hash(password string) (string, error)
+	// パスワードを検証し、現在の設定で再ハッシュが必要かどうかを返す
+	Verify(encodedHash, password string) (match bool, needsRehash bool, err error)
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidResetToken パスワードリセットトークンが無効・使用済み・期限切れ
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetToken パスワードリセットトークンのエンティティ
+type PasswordResetToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	TokenHash string     `json:"-"` // トークン本体のSHA-256ダイジェスト
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PasswordResetConfig パスワードリセットの設定
+type PasswordResetConfig struct {
+	BaseURL       string        // リセットリンクに使用するアプリケーションのURL
+	TokenDuration time.Duration // リセットトークンの有効期限
+}
+
+// PasswordResetRepository パスワードリセットトークンリポジトリのインターフェース
+type PasswordResetRepository interface {
+	// リセットトークンを保存
+	Create(token *PasswordResetToken) error
+	// トークンのハッシュ値で取得（存在しない場合はnil）
+	GetByTokenHash(tokenHash string) (*PasswordResetToken, error)
+	// 未使用のトークンを使用済みにする（既に使用済みの場合はfalse）
+	MarkUsed(tokenID int) (bool, error)
+	// ユーザーの未使用のトークンをすべて無効化
+	InvalidateByUserID(userID int) error
+}
+
+// PasswordUsecase パスワードリセット・変更ユースケースのインターフェース
+type PasswordUsecase interface {
+	// リセットリンクをメールで送信（アカウントが存在しない場合も成功扱い。要求回数の制限中は*LoginLockedError）
+	ForgotPassword(email, ipAddress string) error
+	// リセットトークンを検証して新しいパスワードを設定し、全セッションとパーソナルアクセストークンを無効化
+	ResetPassword(token, newPassword, ipAddress string) error
+	// 現在のパスワードを確認して変更し、全セッションを無効化して新しいトークンペアを発行（試行回数の制限中は*LoginLockedError）
+	ChangePassword(userID int, currentPassword, newPassword, deviceInfo, ipAddress string) (*TokenPair, error)
+}
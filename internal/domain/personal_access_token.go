@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// PersonalAccessTokenPrefix パーソナルアクセストークンの接頭辞（ログやシークレットスキャンで識別できるようにする）
+const PersonalAccessTokenPrefix = "gedp_"
+
+var (
+	// ErrInvalidPersonalAccessToken トークンが存在しない・失効済み・期限切れ
+	ErrInvalidPersonalAccessToken = errors.New("invalid personal access token")
+	// ErrPersonalAccessTokenNotFound 指定したトークンが存在しない（または他のユーザーのトークン）
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	// ErrInvalidScope スコープの形式が不正、またはユーザーが持たない権限
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidPersonalAccessTokenRequest トークン名や有効期限の指定が不正
+	ErrInvalidPersonalAccessTokenRequest = errors.New("invalid personal access token request")
+)
+
+// PersonalAccessToken 自動化スクリプト等で使う長期間有効なAPIキー
+type PersonalAccessToken struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"` // 一覧で識別するためのトークン先頭部分
+	TokenHash   string     `json:"-"`            // トークン本体のSHA-256ダイジェスト
+	Scopes      []string   `json:"scopes"`       // "resource:action" 形式（空の場合はユーザーの全権限）
+	ExpiresAt   *time.Time `json:"expires_at"`   // nilの場合は無期限
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AllowsScope トークンのスコープが指定したリソース・アクションを許可するか
+// 実際の権限はこの結果とユーザーのRBAC権限の積になる
+func (t *PersonalAccessToken) AllowsScope(resource, action string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	scope := PermissionScope(resource, action)
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePersonalAccessTokenRequest トークン作成リクエスト
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0の場合は無期限
+}
+
+// CreatedPersonalAccessToken 作成直後のトークン（平文のトークンはこのときだけ返す）
+type CreatedPersonalAccessToken struct {
+	Token string `json:"token"`
+	PersonalAccessToken
+}
+
+// PersonalAccessTokenRepository パーソナルアクセストークンリポジトリのインターフェース
+type PersonalAccessTokenRepository interface {
+	// トークンを保存
+	Create(token *PersonalAccessToken) error
+	// トークンのハッシュ値で取得（存在しない場合はnil）
+	GetByTokenHash(tokenHash string) (*PersonalAccessToken, error)
+	// ユーザーのトークン一覧を取得（失効済みを除く）
+	ListByUserID(userID int) ([]*PersonalAccessToken, error)
+	// ユーザーのトークンを失効させる（該当するトークンがない場合はfalse）
+	Revoke(userID, tokenID int) (bool, error)
+	// ユーザーのすべてのトークンを失効させる
+	RevokeAllByUserID(userID int) error
+	// 最終使用日時を更新
+	UpdateLastUsed(tokenID int, usedAt time.Time) error
+}
+
+// PersonalAccessTokenUsecase パーソナルアクセストークンユースケースのインターフェース
+type PersonalAccessTokenUsecase interface {
+	// トークンを作成（スコープはユーザーが現在持つ権限のみ指定可能）
+	Create(userID int, req *CreatePersonalAccessTokenRequest) (*CreatedPersonalAccessToken, error)
+	// ユーザーのトークン一覧を取得
+	List(userID int) ([]*PersonalAccessToken, error)
+	// トークンを失効させる
+	Revoke(userID, tokenID int) error
+	// 平文のトークンを検証し、トークンと所有ユーザーを返す
+	Authenticate(token string) (*PersonalAccessToken, *User, error)
+}
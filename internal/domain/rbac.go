@@ -1,6 +1,12 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrRoleNotFound 指定したロールが存在しない
+var ErrRoleNotFound = errors.New("role not found")
 
 // Role ロール情報
 type Role struct {
@@ -80,6 +86,13 @@ type RBACRepository interface {
 	// 権限チェック
 	HasPermission(userID int, resource, action string) (bool, error)
 	HasRole(userID int, roleName string) (bool, error)
+
+	// サービスアカウントロール関連
+	GetServiceAccountRoles(accountID int) ([]Role, error)
+	AssignRoleToServiceAccount(accountID, roleID int) error
+	RemoveRoleFromServiceAccount(accountID, roleID int) error
+	ServiceAccountHasPermission(accountID int, resource, action string) (bool, error)
+	ServiceAccountHasRole(accountID int, roleName string) (bool, error)
 }
 
 // RBACUsecase RBACユースケースインターフェース
@@ -112,4 +125,13 @@ type RBACUsecase interface {
 	HasPermission(userID int, resource, action string) (bool, error)
 	HasRole(userID int, roleName string) (bool, error)
 	CheckPermission(userID int, resource, action string) error
+
+	// サービスアカウントロール管理
+	GetServiceAccountRoles(accountID int) ([]Role, error)
+	AssignRoleToServiceAccount(accountID int, roleName string) error
+	RemoveRoleFromServiceAccount(accountID int, roleName string) error
+
+	// サービスアカウントの権限チェック
+	ServiceAccountHasRole(accountID int, roleName string) (bool, error)
+	CheckServiceAccountPermission(accountID int, resource, action string) error
 }
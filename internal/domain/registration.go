@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// TokenUseEmailVerification メールアドレス確認リンクのトークン
+const TokenUseEmailVerification = "email_verification"
+
+var (
+	// ErrEmailNotVerified メールアドレスが未確認のためログインできない
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrInvalidEmail メールアドレスの形式が正しくない
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrWeakPassword パスワードがポリシーを満たしていない
+	ErrWeakPassword = errors.New("password does not meet the policy")
+	// ErrInvalidVerificationToken 確認リンクが無効または期限切れ
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+)
+
+// RegisterRequest ユーザー登録リクエストの構造体
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RegistrationConfig ユーザー登録の設定
+type RegistrationConfig struct {
+	BaseURL              string        // 確認リンクに使用するアプリケーションのURL
+	VerificationDuration time.Duration // 確認リンクの有効期限
+}
+
+// RegistrationUsecase ユーザー登録ユースケースのインターフェース
+type RegistrationUsecase interface {
+	// 未確認のアカウントを作成して確認メールを送信
+	// 登録済みのメールアドレスでもエラーにせず、持ち主に通知メールを送る（存在有無を応答から判別させない）
+	Register(req *RegisterRequest) error
+	// 確認リンクのトークンを検証してアカウントを有効化
+	VerifyEmail(token string) error
+	// 未確認のアカウントに確認メールを再送（該当しない場合も成功扱い。要求回数の制限中は*LoginLockedError）
+	ResendVerification(email, ipAddress string) error
+}
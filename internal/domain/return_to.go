@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidReturnTo ログイン後の戻り先が許可リストにない、または保存した値の署名・有効期限が正しくない
+var ErrInvalidReturnTo = errors.New("invalid return_to")
+
+// TokenUseReturnTo ログイン後の戻り先を保存するクッキーのトークンの用途
+const TokenUseReturnTo = "return_to"
+
+// ReturnToConfig ログイン後の戻り先の設定
+type ReturnToConfig struct {
+	AllowedPaths []string      // 戻り先にできるこのサービスのパス（パスの区切りで前方一致）
+	AllowedHosts []string      // 戻り先にできる他のホスト（別オリジンのフロントエンドなど。ポートを含めて完全一致）
+	Duration     time.Duration // 戻り先を保存するクッキーの有効期限
+}
+
+// ReturnToUsecase ログイン前にアクセスしたURLを保存し、ログイン後に戻るためのユースケース
+// 戻り先は許可リストで検証し、任意のサイトへのリダイレクト（オープンリダイレクト）に使われないようにする
+type ReturnToUsecase interface {
+	// 戻り先を検証し、正規化した値を返す（許可されていない場合はErrInvalidReturnTo）
+	Validate(returnTo string) (string, error)
+	// 戻り先を検証し、クッキーに保存する署名付きの値とその有効期限を返す
+	Seal(returnTo string) (string, time.Time, error)
+	// クッキーの値から戻り先を取り出す（取り出した値も許可リストで再検証する）
+	Open(sealed string) (string, error)
+}
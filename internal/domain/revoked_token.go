@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTokenRevoked 失効リストに登録済みのアクセストークン
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevokedTokenStore 失効したアクセストークン（JTI）の保存先インターフェース
+type RevokedTokenStore interface {
+	// JTIを失効リストに追加（expiresAtを過ぎたエントリは削除してよい）
+	Revoke(jti string, expiresAt time.Time) error
+	// JTIが失効リストに含まれているかどうか
+	IsRevoked(jti string) (bool, error)
+	// 有効期限を過ぎたエントリを削除
+	Prune() error
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+// セキュリティイベントの種類
+const (
+	// ローテーション済みのリフレッシュトークンが再利用された
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	// 管理者によって強制ログアウトされた
+	SecurityEventForceLogout = "force_logout"
+	// ログイン失敗の繰り返しによりアカウントまたはIPアドレスがロックされた
+	SecurityEventLoginLocked = "login_locked"
+	// パスワードリセット等のメール送信の要求の繰り返しによりメールアドレスまたはIPアドレスが制限された
+	SecurityEventMailRequestLocked = "mail_request_locked"
+	// 管理者によってログインのロックが解除された
+	SecurityEventLoginUnlocked = "login_unlocked"
+	// パスワードが変更された
+	SecurityEventPasswordChanged = "password_changed"
+	// パスワードリセットのリンクでパスワードが再設定された
+	SecurityEventPasswordReset = "password_reset"
+	// 外部プロバイダーのアカウントが連携された
+	SecurityEventIdentityLinked = "identity_linked"
+	// 外部プロバイダーのアカウントの連携が解除された
+	SecurityEventIdentityUnlinked = "identity_unlinked"
+)
+
+// SecurityEvent 監査用のセキュリティイベント
+type SecurityEvent struct {
+	ID          int       `json:"id"`
+	UserID      *int      `json:"user_id"`
+	EventType   string    `json:"event_type"`
+	Description string    `json:"description"`
+	IPAddress   string    `json:"ip_address"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SecurityEventRepository セキュリティイベントリポジトリのインターフェース
+type SecurityEventRepository interface {
+	// セキュリティイベントを記録
+	Create(event *SecurityEvent) error
+}
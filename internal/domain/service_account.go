@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// ServiceAccountClientIDPrefix サービスアカウントのクライアントIDの接頭辞
+	ServiceAccountClientIDPrefix = "sa_"
+	// ServiceAccountSecretPrefix クライアントシークレットの接頭辞（ログやシークレットスキャンで識別できるようにする）
+	ServiceAccountSecretPrefix = "gedsa_"
+	// ServiceAccountSubjectPrefix サービスアカウントのトークンのsub、およびCasbinのサブジェクトの接頭辞
+	ServiceAccountSubjectPrefix = "service:"
+)
+
+// OAuth 2.0 のグラントタイプ
+const (
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+var (
+	// ErrInvalidClient クライアントIDが存在しない、またはクライアントシークレットが一致しない
+	ErrInvalidClient = errors.New("invalid client")
+	// ErrServiceAccountNotFound 指定したサービスアカウントが存在しない
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	// ErrInvalidServiceAccountRequest サービスアカウント名等の指定が不正
+	ErrInvalidServiceAccountRequest = errors.New("invalid service account request")
+)
+
+// ServiceAccount バックエンドのジョブ等が使う、ユーザーに紐付かない主体
+type ServiceAccount struct {
+	ID               int        `json:"id"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	ClientID         string     `json:"client_id"`
+	ClientSecretHash string     `json:"-"` // クライアントシークレットのSHA-256ダイジェスト
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Subject トークンのsub、およびCasbinのサブジェクトとして使う識別子
+func (a *ServiceAccount) Subject() string {
+	return ServiceAccountSubject(a.ClientID)
+}
+
+// ServiceAccountSubject クライアントIDをサービスアカウントのサブジェクト（"service:<client_id>"）に変換
+func ServiceAccountSubject(clientID string) string {
+	return ServiceAccountSubjectPrefix + clientID
+}
+
+// CreateServiceAccountRequest サービスアカウント作成リクエスト
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ServiceAccountCredentials 作成・シークレット再発行の直後のサービスアカウント（平文のシークレットはこのときだけ返す）
+type ServiceAccountCredentials struct {
+	ClientSecret string `json:"client_secret"`
+	ServiceAccount
+}
+
+// OAuthTokenResponse トークンエンドポイントのレスポンス（RFC 6749 5.1）
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	IDToken     string `json:"id_token,omitempty"` // 認可コードグラント（OpenID Connect）の場合のみ
+}
+
+// ServiceAccountRepository サービスアカウントリポジトリのインターフェース
+type ServiceAccountRepository interface {
+	// サービスアカウントを保存
+	Create(account *ServiceAccount) error
+	// IDで取得（存在しない場合はnil）
+	GetByID(id int) (*ServiceAccount, error)
+	// クライアントIDで取得（存在しない場合はnil）
+	GetByClientID(clientID string) (*ServiceAccount, error)
+	// サービスアカウント一覧を取得
+	List() ([]*ServiceAccount, error)
+	// クライアントシークレットのハッシュ値を更新（該当するサービスアカウントがない場合はfalse）
+	UpdateSecretHash(id int, secretHash string) (bool, error)
+	// サービスアカウントを削除（該当するサービスアカウントがない場合はfalse）
+	Delete(id int) (bool, error)
+	// 最終使用日時を更新
+	UpdateLastUsed(id int, usedAt time.Time) error
+}
+
+// ServiceAccountUsecase サービスアカウントユースケースのインターフェース
+type ServiceAccountUsecase interface {
+	// サービスアカウントを作成し、クライアントシークレットを返す
+	Create(req *CreateServiceAccountRequest) (*ServiceAccountCredentials, error)
+	// サービスアカウント一覧を取得
+	List() ([]*ServiceAccount, error)
+	// サービスアカウントを取得
+	Get(id int) (*ServiceAccount, error)
+	// クライアントシークレットを再発行（以前のシークレットは使えなくなる）
+	RotateSecret(id int) (*ServiceAccountCredentials, error)
+	// サービスアカウントを削除
+	Delete(id int) error
+	// クライアントクレデンシャルズグラントでアクセストークンを発行（audienceを省略した場合は自サービスのaud）
+	IssueToken(clientID, clientSecret, audience string) (*OAuthTokenResponse, error)
+}
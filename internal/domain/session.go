@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSessionNotFound 指定されたセッションが存在しない（または他のユーザーのもの）
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session ログイン中のデバイスごとのセッション
+// リフレッシュトークンのファミリー単位で管理するため、ローテーションしてもIDは変わらない
+type Session struct {
+	ID           string    `json:"id"`             // リフレッシュトークンのファミリーID
+	DeviceInfo   string    `json:"device_info"`    // ログイン時のUser-Agent
+	IPAddress    string    `json:"ip_address"`     // ログイン時のクライアントIP
+	LastActiveAt time.Time `json:"last_active_at"` // 最後にトークンが発行された日時
+	ExpiresAt    time.Time `json:"expires_at"`     // セッションの有効期限
+	Current      bool      `json:"current"`        // リクエスト中のアクセストークンのセッションかどうか
+}
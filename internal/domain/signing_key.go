@@ -0,0 +1,48 @@
+package domain
+
+import "crypto"
+
+// JWT署名アルゴリズム
+const (
+	SigningAlgRS256 = "RS256"
+	SigningAlgES256 = "ES256"
+	SigningAlgEdDSA = "EdDSA"
+)
+
+// SigningKey JWTの署名・検証に使用する鍵
+type SigningKey struct {
+	KID        string           // 鍵ID（JWTヘッダーのkid）
+	Algorithm  string           // RS256 / ES256 / EdDSA
+	PrivateKey crypto.Signer    // 署名用の秘密鍵（検証専用の鍵はnil）
+	PublicKey  crypto.PublicKey // 検証用の公開鍵
+}
+
+// JWK JSON Web Key（RFC 7517）
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet JWKの集合（/.well-known/jwks.json のレスポンス）
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager JWT署名鍵の管理インターフェース
+// アクティブな鍵で署名し、退役した鍵は検証専用として保持することで
+// ログイン中のユーザーを失効させずに鍵をローテーションできる
+type KeyManager interface {
+	// 署名に使用するアクティブな鍵を取得
+	SigningKey() *SigningKey
+	// kidに対応する検証用の鍵を取得
+	VerificationKey(kid string) (*SigningKey, error)
+	// すべての公開鍵をJWKS形式で取得
+	JWKS() JWKSet
+}
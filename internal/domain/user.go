@@ -1,16 +1,21 @@
 package domain
 
+import "time"
+
 type User struct {
 	ID           int
 	Name         string
 	Email        string
-	Password     string
+	Password     string `json:"-"` // パスワードハッシュ（レスポンスには含めない）
 	ProviderID   string
 	ProviderName string
+	// メールアドレスの確認日時（未確認の場合はnil）
+	EmailVerifiedAt *time.Time `json:",omitempty"`
 }
 
 // UserRepository ユーザーリポジトリのインターフェース
 type UserRepository interface {
+	// IDでユーザーを取得（存在しない場合はnil）
 	GetByID(id int) (*User, error)
 	GetByEmail(email string) (*User, error)
 	Create(user *User) error
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrIdentityNotFound 連携が存在しない（他のユーザーの連携を含む）
+var ErrIdentityNotFound = errors.New("identity not found")
+
+// ErrIdentityAlreadyLinked プロバイダーのアカウントが他のユーザーに連携済み
+var ErrIdentityAlreadyLinked = errors.New("identity is already linked to another account")
+
+// ErrLastLoginMethod 最後のログイン手段は解除できない
+var ErrLastLoginMethod = errors.New("cannot unlink the last login method")
+
+// ErrOAuthEmailInUse プロバイダーのメールアドレスが既存のアカウントで使われている
+var ErrOAuthEmailInUse = errors.New("email is already used by another account")
+
+// ErrInvalidAccountLink 連携の確認トークンが無効・期限切れ・使用済み、または別のユーザーのもの
+var ErrInvalidAccountLink = errors.New("invalid account link")
+
+// TokenUseAccountLink 既存のアカウントへの連携を、そのアカウントでログインして確認するまで保持するトークン
+const TokenUseAccountLink = "account_link"
+
+// AccountLinkPolicy 外部プロバイダーのメールアドレスが既存のアカウントと一致した場合の扱い
+type AccountLinkPolicy struct {
+	// 確認なしで既存のアカウントに連携するプロバイダー
+	// プロバイダーが確認済みのメールアドレスを返し、既存のアカウントのメールアドレスも確認済みの場合のみ連携する
+	AutoLinkProviders []string
+	// 既存のアカウントでログインして連携を確認するまでの有効期限
+	LinkDuration time.Duration
+}
+
+// AccountLinkRequiredError 既存のアカウントでログインして連携を確認する必要がある
+// Tokenを保持したまま既存のアカウントでログインし、IdentityUsecase.ConfirmLinkで連携する
+type AccountLinkRequiredError struct {
+	Provider  string
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (e *AccountLinkRequiredError) Error() string {
+	return "account link confirmation required for " + e.Provider
+}
+
+// PendingAccountLink 確認待ちの連携（確認画面に表示する内容）
+type PendingAccountLink struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UserIdentity ユーザーに連携した外部プロバイダーのアカウント
+// 1人のユーザーが複数のプロバイダー（同じプロバイダーの複数のアカウントも可）でログインできる
+type UserIdentity struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"` // プロバイダー内で不変のユーザーID（IDトークンのsub等）
+	Email    string `json:"email,omitempty"`
+	// プロバイダーから取得したプロフィール（最後のログイン時点）
+	Profile     map[string]interface{} `json:"-"`
+	LastLoginAt *time.Time             `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// IdentityUsecase 外部プロバイダーによるログインとアカウント連携のユースケース
+type IdentityUsecase interface {
+	// プロバイダーで認証したユーザーでログイン（二要素認証が有効な場合はチャレンジを返す）
+	// メールアドレスが既存のアカウントと一致し、ポリシーで自動連携できない場合は*AccountLinkRequiredErrorを返す
+	SignIn(oauthUser *OAuthUser, deviceInfo, ipAddress string) (*AuthResponse, error)
+	Link(userID int, oauthUser *OAuthUser, ipAddress string) (*UserIdentity, error)
+	// 確認待ちの連携の内容を取得
+	GetPendingLink(token string) (*PendingAccountLink, error)
+	// ログイン中のユーザーが確認待ちの連携を確定する（連携先のユーザーでログインしている場合のみ）
+	ConfirmLink(userID int, token, ipAddress string) (*UserIdentity, error)
+	ListIdentities(userID int) ([]*UserIdentity, error)
+	Unlink(userID, identityID int, ipAddress string) error
+}
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 
 	"go-echo-demo/internal/domain"
 	"go-echo-demo/internal/middleware"
@@ -10,11 +14,12 @@ import (
 )
 
 type AuthHandler struct {
-	authUsecase domain.AuthUsecase
+	authUsecase     domain.AuthUsecase
+	returnToUsecase domain.ReturnToUsecase
 }
 
-func RegisterAuthRoutes(e *echo.Echo, authUsecase domain.AuthUsecase) {
-	h := NewAuthHandler(authUsecase)
+func RegisterAuthRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, returnToUsecase domain.ReturnToUsecase) {
+	h := NewAuthHandler(authUsecase, returnToUsecase)
 
 	// 認証不要のルート
 	e.POST("/api/auth/login", h.Login)
@@ -25,10 +30,31 @@ func RegisterAuthRoutes(e *echo.Echo, authUsecase domain.AuthUsecase) {
 	protected.Use(middleware.JWTAuth(authUsecase))
 	protected.GET("/protected", h.Protected)
 	protected.POST("/logout", h.Logout)
+	protected.POST("/token", h.IssueAudienceToken)
+
+	// セッション管理
+	protected.GET("/sessions", h.GetSessions)
+	protected.DELETE("/sessions", h.RevokeOtherSessions)
+	protected.DELETE("/sessions/:id", h.RevokeSession)
+}
+
+// RegisterAuthAdminRoutes 管理者向けの認証管理ルートを登録
+func RegisterAuthAdminRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsecase) {
+	h := NewAuthHandler(authUsecase, nil) // 管理者向けのルートではログイン後の戻り先を使わない
+
+	// JWT認証 + adminロールが必要（adminロールを割り当てたサービスアカウントも可）
+	admin := e.Group("/api/admin/users")
+	admin.Use(middleware.JWTOrServiceAccountAuthWithRole(authUsecase, rbacUsecase, "admin"))
+	admin.POST("/:user_id/logout", h.ForceLogout)
+	admin.POST("/:user_id/unlock", h.UnlockAccount)
+
+	attempts := e.Group("/api/admin/login-attempts")
+	attempts.Use(middleware.JWTOrServiceAccountAuthWithRole(authUsecase, rbacUsecase, "admin"))
+	attempts.DELETE("/ip/:ip", h.UnlockIP)
 }
 
-func NewAuthHandler(authUsecase domain.AuthUsecase) *AuthHandler {
-	return &AuthHandler{authUsecase: authUsecase}
+func NewAuthHandler(authUsecase domain.AuthUsecase, returnToUsecase domain.ReturnToUsecase) *AuthHandler {
+	return &AuthHandler{authUsecase: authUsecase, returnToUsecase: returnToUsecase}
 }
 
 func (h *AuthHandler) Login(c echo.Context) error {
@@ -41,36 +67,58 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Email and password are required")
 	}
 
-	response, err := h.authUsecase.Login(req.Email, req.Password)
+	// セッション管理のためデバイス情報とクライアントIPを記録
+	response, err := h.authUsecase.Login(req.Email, req.Password, c.Request().UserAgent(), c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if errors.Is(err, domain.ErrEmailNotVerified) {
+		return echo.NewHTTPError(http.StatusForbidden, "Email address is not verified")
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
 	}
 
-	// アクセストークンをクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:     "token",
-		Value:    response.Token,
-		Path:     "/",
-		MaxAge:   15 * 60,  // 15分（アクセストークンの有効期限に合わせる）
-		HttpOnly: true,     // XSS対策のためJavaScriptからアクセス不可にする
-		Secure:   false,    // 開発環境ではfalse、本番環境ではtrue
-		SameSite: http.SameSiteLaxMode,
-	})
-
-	// リフレッシュトークンをHTTP Onlyクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:     "refresh_token",
-		Value:    response.RefreshToken,
-		Path:     "/api/auth/refresh", // リフレッシュエンドポイントのみで使用
-		MaxAge:   7 * 24 * 60 * 60,   // 7日間
-		HttpOnly: true,                // XSS対策
-		Secure:   false,               // 開発環境ではfalse、本番環境ではtrue
-		SameSite: http.SameSiteStrictMode,
-	})
+	// 二要素認証が必要な場合はチャレンジトークンのみを返す
+	if response.MFARequired {
+		setMFAChallengeCookie(c, response.MFAToken)
+		return c.JSON(http.StatusOK, response)
+	}
 
+	setLoginCookies(c, response)
+	// ログイン画面で保存した戻り先を返す（二要素認証が必要な場合は認証の完了まで引き継ぐ）
+	response.RedirectTo = restoreReturnTo(c, h.returnToUsecase)
 	return c.JSON(http.StatusOK, response)
 }
 
+// setLoginCookies ログイン成功時のアクセストークンとリフレッシュトークンをクッキーに保存
+func setLoginCookies(c echo.Context, response *domain.AuthResponse) {
+	middleware.CookiesFromContext(c).SetTokens(c, response.Token, response.RefreshToken)
+}
+
+// IssueAudienceToken 他の内部サービス（aud）向けのアクセストークンを発行
+func (h *AuthHandler) IssueAudienceToken(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	var req struct {
+		Audience string `json:"audience"`
+	}
+	if err := c.Bind(&req); err != nil || req.Audience == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Audience is required")
+	}
+
+	token, err := h.authUsecase.GenerateTokenForAudience(userID, req.Audience)
+	if errors.Is(err, domain.ErrInvalidAudience) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Audience is not allowed")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to issue token")
+	}
+
+	return c.JSON(http.StatusOK, token)
+}
+
 func (h *AuthHandler) Protected(c echo.Context) error {
 	userID := c.Get("user_id").(int)
 	email := c.Get("email").(string)
@@ -88,86 +136,154 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 	var req domain.RefreshTokenRequest
 	if err := c.Bind(&req); err == nil && req.RefreshToken != "" {
 		// ボディから取得
-		tokenPair, err := h.authUsecase.RefreshToken(req.RefreshToken)
+		tokenPair, err := h.authUsecase.RefreshToken(req.RefreshToken, c.RealIP(), c.Request().UserAgent())
 		if err != nil {
 			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 		}
-		return h.setTokensAndRespond(c, tokenPair)
+		return setTokensAndRespond(c, tokenPair)
 	}
 
 	// クッキーからリフレッシュトークンを取得
-	cookie, err := c.Cookie("refresh_token")
-	if err != nil {
+	refreshToken := middleware.CookiesFromContext(c).Get(c, middleware.RefreshTokenCookie)
+	if refreshToken == "" {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token not found")
 	}
 
-	tokenPair, err := h.authUsecase.RefreshToken(cookie.Value)
+	tokenPair, err := h.authUsecase.RefreshToken(refreshToken, c.RealIP(), c.Request().UserAgent())
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
 
-	return h.setTokensAndRespond(c, tokenPair)
+	return setTokensAndRespond(c, tokenPair)
 }
 
-// Logout ログアウト処理
+// Logout ログアウト処理（現在のセッションのみ）
 func (h *AuthHandler) Logout(c echo.Context) error {
 	userID := c.Get("user_id").(int)
+	jti := c.Get("jti").(string)
 
-	// リフレッシュトークンを無効化
-	if err := h.authUsecase.Logout(userID); err != nil {
+	// 現在のセッションのリフレッシュトークンとアクセストークンを無効化
+	if err := h.authUsecase.Logout(userID, jti); err != nil {
 		// エラーが発生してもログアウト処理は続行
 		c.Logger().Error("Failed to revoke refresh tokens: ", err)
 	}
 
 	// クッキーを削除
-	c.SetCookie(&http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteLaxMode,
+	middleware.CookiesFromContext(c).ClearTokens(c)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Logged out successfully",
 	})
+}
+
+// GetSessions ログイン中のセッション一覧を取得
+func (h *AuthHandler) GetSessions(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+	jti := c.Get("jti").(string)
+
+	sessions, err := h.authUsecase.GetSessions(userID, jti)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get sessions")
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession 指定したセッションをログアウト
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	userID := c.Get("user_id").(int)
 
-	c.SetCookie(&http.Cookie{
-		Name:     "refresh_token",
-		Value:    "",
-		Path:     "/api/auth/refresh",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteStrictMode,
+	err := h.authUsecase.RevokeSession(userID, c.Param("id"))
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke session")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Session revoked successfully",
 	})
+}
+
+// RevokeOtherSessions 現在のセッション以外をすべてログアウト
+func (h *AuthHandler) RevokeOtherSessions(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+	jti := c.Get("jti").(string)
+
+	if err := h.authUsecase.RevokeOtherSessions(userID, jti); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{
-		"message": "Logged out successfully",
+		"message": "Other sessions revoked successfully",
 	})
 }
 
-// setTokensAndRespond トークンをクッキーに設定してレスポンスを返す
-func (h *AuthHandler) setTokensAndRespond(c echo.Context, tokenPair *domain.TokenPair) error {
-	// アクセストークンをクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:     "token",
-		Value:    tokenPair.AccessToken,
-		Path:     "/",
-		MaxAge:   tokenPair.ExpiresIn,
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteLaxMode,
+// ForceLogout 管理者による強制ログアウト（全セッションとアクセストークンを失効）
+func (h *AuthHandler) ForceLogout(c echo.Context) error {
+	actor := middleware.ActorFromContext(c)
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.authUsecase.ForceLogout(actor, userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "User sessions revoked successfully",
 	})
+}
 
-	// リフレッシュトークンをクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:     "refresh_token",
-		Value:    tokenPair.RefreshToken,
-		Path:     "/api/auth/refresh",
-		MaxAge:   7 * 24 * 60 * 60, // 7日間
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteStrictMode,
+// UnlockAccount 管理者によるアカウントのログインロック解除
+func (h *AuthHandler) UnlockAccount(c echo.Context) error {
+	actor := middleware.ActorFromContext(c)
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.authUsecase.UnlockAccount(actor, userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock account")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Account unlocked successfully",
 	})
+}
+
+// UnlockIP 管理者によるIPアドレスのログインロック解除
+func (h *AuthHandler) UnlockIP(c echo.Context) error {
+	actor := middleware.ActorFromContext(c)
+
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid IP address")
+	}
+
+	if err := h.authUsecase.UnlockIP(actor, ip.String()); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock IP address")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "IP address unlocked successfully",
+	})
+}
+
+// tooManyAttempts ロックアウト中のログイン試行に429を返す
+func tooManyAttempts(c echo.Context, lockedErr *domain.LoginLockedError) error {
+	retryAfter := int(math.Ceil(lockedErr.RetryAfter.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	return echo.NewHTTPError(http.StatusTooManyRequests, "Too many login attempts. Please try again later")
+}
+
+// setTokensAndRespond トークンをクッキーに設定してレスポンスを返す
+func setTokensAndRespond(c echo.Context, tokenPair *domain.TokenPair) error {
+	middleware.CookiesFromContext(c).SetTokens(c, tokenPair.AccessToken, tokenPair.RefreshToken)
 
 	return c.JSON(http.StatusOK, tokenPair)
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"go-echo-demo/internal/domain"
@@ -12,14 +13,16 @@ import (
 
 type GoogleAuthHandler struct {
 	googleAuthUsecase domain.OAuthUsecase
+	identityUsecase   domain.IdentityUsecase
+	stateUsecase      domain.OAuthStateUsecase
 }
 
-func NewGoogleAuthHandler(googleAuthUsecase domain.OAuthUsecase) *GoogleAuthHandler {
-	return &GoogleAuthHandler{googleAuthUsecase: googleAuthUsecase}
+func NewGoogleAuthHandler(googleAuthUsecase domain.OAuthUsecase, identityUsecase domain.IdentityUsecase, stateUsecase domain.OAuthStateUsecase) *GoogleAuthHandler {
+	return &GoogleAuthHandler{googleAuthUsecase: googleAuthUsecase, identityUsecase: identityUsecase, stateUsecase: stateUsecase}
 }
 
-func RegisterGoogleAuthRoutes(e *echo.Echo, googleAuthUsecase domain.OAuthUsecase) {
-	h := NewGoogleAuthHandler(googleAuthUsecase)
+func RegisterGoogleAuthRoutes(e *echo.Echo, googleAuthUsecase domain.OAuthUsecase, identityUsecase domain.IdentityUsecase, stateUsecase domain.OAuthStateUsecase) {
+	h := NewGoogleAuthHandler(googleAuthUsecase, identityUsecase, stateUsecase)
 
 	// Google OAuth認証ルート
 	e.GET("/auth/google", h.GoogleLogin)
@@ -27,7 +30,10 @@ func RegisterGoogleAuthRoutes(e *echo.Echo, googleAuthUsecase domain.OAuthUsecas
 }
 
 func (h *GoogleAuthHandler) GoogleLogin(c echo.Context) error {
-	authURL := h.googleAuthUsecase.GetAuthURL()
+	authURL, err := beginOAuth(c, h.stateUsecase, h.googleAuthUsecase, domain.OAuthIntent{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, "Failed to start authentication with Google")
+	}
 	return c.Redirect(http.StatusTemporaryRedirect, authURL)
 }
 
@@ -43,23 +49,32 @@ func (h *GoogleAuthHandler) GoogleCallback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "State parameter is required")
 	}
 
-	// Google認証を実行（stateパラメータを含む）
-	authResponse, err := h.googleAuthUsecase.Authenticate(code, state)
+	// stateを検証してGoogle認証を実行
+	oauthState, err := completeOAuthState(c, h.stateUsecase, state)
+	if err != nil {
+		return err
+	}
+	oauthUser, err := h.googleAuthUsecase.Authenticate(code, oauthState)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to authenticate with Google")
 	}
+	authResponse, err := h.identityUsecase.SignIn(oauthUser, c.Request().UserAgent(), c.RealIP())
+	var linkRequired *domain.AccountLinkRequiredError
+	if errors.As(err, &linkRequired) {
+		return redirectToAccountLink(c, linkRequired)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to sign in with Google")
+	}
+
+	// 二要素認証が必要な場合はチャレンジトークンを保存して確認画面へ
+	if authResponse.MFARequired {
+		setMFAChallengeCookie(c, authResponse.MFAToken)
+		return c.Redirect(http.StatusTemporaryRedirect, "/login/mfa")
+	}
 
-	// トークンをクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:     "token",
-		Value:    authResponse.Token,
-		Path:     "/",
-		MaxAge:   3600,  // 1時間
-		HttpOnly: false, // JavaScriptからアクセス可能にする
-		Secure:   false, // 開発環境ではfalse、本番環境ではtrue
-		SameSite: http.SameSiteLaxMode,
-		Domain:   "localhost", // ドメインを明示的に指定
-	})
+	// アクセストークンとリフレッシュトークンをクッキーに保存
+	setLoginCookies(c, authResponse)
 
 	log.Printf("Cookie set: token=%s", authResponse.Token[:20]+"...")
 
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+type JWKSHandler struct {
+	keyManager domain.KeyManager
+}
+
+func NewJWKSHandler(keyManager domain.KeyManager) *JWKSHandler {
+	return &JWKSHandler{keyManager: keyManager}
+}
+
+// RegisterJWKSRoutes 公開鍵（JWKS）の配布ルートを登録
+func RegisterJWKSRoutes(e *echo.Echo, keyManager domain.KeyManager) {
+	h := NewJWKSHandler(keyManager)
+	e.GET("/.well-known/jwks.json", h.JWKS)
+}
+
+// JWKS 署名検証用の公開鍵一覧を返す（退役済みの検証専用鍵を含む）
+func (h *JWKSHandler) JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.keyManager.JWKS())
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"go-echo-demo/internal/domain"
@@ -10,14 +11,16 @@ import (
 
 type LineAuthHandler struct {
 	lineAuthUsecase domain.OAuthUsecase
+	identityUsecase domain.IdentityUsecase
+	stateUsecase    domain.OAuthStateUsecase
 }
 
-func NewLineAuthHandler(lineAuthUsecase domain.OAuthUsecase) *LineAuthHandler {
-	return &LineAuthHandler{lineAuthUsecase: lineAuthUsecase}
+func NewLineAuthHandler(lineAuthUsecase domain.OAuthUsecase, identityUsecase domain.IdentityUsecase, stateUsecase domain.OAuthStateUsecase) *LineAuthHandler {
+	return &LineAuthHandler{lineAuthUsecase: lineAuthUsecase, identityUsecase: identityUsecase, stateUsecase: stateUsecase}
 }
 
-func RegisterLineAuthRoutes(e *echo.Echo, lineAuthUsecase domain.OAuthUsecase) {
-	h := NewLineAuthHandler(lineAuthUsecase)
+func RegisterLineAuthRoutes(e *echo.Echo, lineAuthUsecase domain.OAuthUsecase, identityUsecase domain.IdentityUsecase, stateUsecase domain.OAuthStateUsecase) {
+	h := NewLineAuthHandler(lineAuthUsecase, identityUsecase, stateUsecase)
 
 	// LINE OAuth認証ルート
 	e.GET("/auth/line", h.LineLogin)
@@ -25,7 +28,10 @@ func RegisterLineAuthRoutes(e *echo.Echo, lineAuthUsecase domain.OAuthUsecase) {
 }
 
 func (h *LineAuthHandler) LineLogin(c echo.Context) error {
-	authURL := h.lineAuthUsecase.GetAuthURL()
+	authURL, err := beginOAuth(c, h.stateUsecase, h.lineAuthUsecase, domain.OAuthIntent{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, "Failed to start authentication with LINE")
+	}
 	return c.Redirect(http.StatusTemporaryRedirect, authURL)
 }
 
@@ -41,23 +47,32 @@ func (h *LineAuthHandler) LineCallback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "State parameter is required")
 	}
 
-	// LINE認証を実行（stateパラメータを含む）
-	authResponse, err := h.lineAuthUsecase.Authenticate(code, state)
+	// stateを検証してLINE認証を実行
+	oauthState, err := completeOAuthState(c, h.stateUsecase, state)
+	if err != nil {
+		return err
+	}
+	oauthUser, err := h.lineAuthUsecase.Authenticate(code, oauthState)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to authenticate with LINE")
 	}
+	authResponse, err := h.identityUsecase.SignIn(oauthUser, c.Request().UserAgent(), c.RealIP())
+	var linkRequired *domain.AccountLinkRequiredError
+	if errors.As(err, &linkRequired) {
+		return redirectToAccountLink(c, linkRequired)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to sign in with LINE")
+	}
+
+	// 二要素認証が必要な場合はチャレンジトークンを保存して確認画面へ
+	if authResponse.MFARequired {
+		setMFAChallengeCookie(c, authResponse.MFAToken)
+		return c.Redirect(http.StatusTemporaryRedirect, "/login/mfa")
+	}
 
-	// トークンをクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:     "token",
-		Value:    authResponse.Token,
-		Path:     "/",
-		MaxAge:   3600,  // 1時間
-		HttpOnly: false, // JavaScriptからアクセス可能にする
-		Secure:   false, // 開発環境ではfalse、本番環境ではtrue
-		SameSite: http.SameSiteLaxMode,
-		Domain:   "localhost", // ドメインを明示的に指定
-	})
+	// アクセストークンとリフレッシュトークンをクッキーに保存
+	setLoginCookies(c, authResponse)
 
 	// 保護されたページにリダイレクト
 	return c.Redirect(http.StatusTemporaryRedirect, "/protected")
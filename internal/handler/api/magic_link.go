@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// magicLinkBindingCookie ログインリンクを要求したブラウザを識別するクッキー（リンクの検証エンドポイントのみで使用）
+var magicLinkBindingCookie = middleware.CookieSpec{Name: "magic_link_binding", Path: "/api/auth/magic-link/verify", SameSite: http.SameSiteStrictMode}
+
+type MagicLinkHandler struct {
+	magicLinkUsecase domain.MagicLinkUsecase
+	returnToUsecase  domain.ReturnToUsecase
+}
+
+func RegisterMagicLinkRoutes(e *echo.Echo, magicLinkUsecase domain.MagicLinkUsecase, returnToUsecase domain.ReturnToUsecase) {
+	h := NewMagicLinkHandler(magicLinkUsecase, returnToUsecase)
+
+	e.POST("/api/auth/magic-link", h.RequestLink)
+	e.POST("/api/auth/magic-link/verify", h.VerifyLink)
+}
+
+func NewMagicLinkHandler(magicLinkUsecase domain.MagicLinkUsecase, returnToUsecase domain.ReturnToUsecase) *MagicLinkHandler {
+	return &MagicLinkHandler{magicLinkUsecase: magicLinkUsecase, returnToUsecase: returnToUsecase}
+}
+
+// RequestLink ログインリンクをメールで送信し、要求元ブラウザにクッキーを設定
+func (h *MagicLinkHandler) RequestLink(c echo.Context) error {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+	}
+
+	binding, expiresAt, err := h.magicLinkUsecase.RequestLink(req.Email, c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if err != nil {
+		c.Logger().Error("Failed to send magic link: ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send login link")
+	}
+
+	// ログインリンクの有効期限（MAGIC_LINK_MINUTES）に合わせる
+	middleware.CookiesFromContext(c).Set(c, magicLinkBindingCookie, binding, time.Until(expiresAt))
+
+	// アカウントの有無に関わらず同じ応答を返す
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "If the account exists, a login link has been sent",
+	})
+}
+
+// VerifyLink ログインリンクのトークンを検証してトークンペアを発行
+func (h *MagicLinkHandler) VerifyLink(c echo.Context) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Token is required")
+	}
+
+	binding := middleware.CookiesFromContext(c).Get(c, magicLinkBindingCookie)
+
+	tokenPair, err := h.magicLinkUsecase.VerifyLink(req.Token, binding, c.Request().UserAgent(), c.RealIP())
+	var mfaErr *domain.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		clearMagicLinkBindingCookie(c)
+		setMFAChallengeCookie(c, mfaErr.Challenge.MFAToken)
+		return c.JSON(http.StatusOK, mfaErr.Challenge)
+	}
+	if errors.Is(err, domain.ErrMagicLinkBrowserMismatch) {
+		return echo.NewHTTPError(http.StatusForbidden, "Open the login link in the browser that requested it")
+	}
+	if errors.Is(err, domain.ErrInvalidMagicLink) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired login link")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify login link")
+	}
+
+	clearMagicLinkBindingCookie(c)
+	// リンクを要求したブラウザでログイン画面に保存した戻り先を返す
+	tokenPair.RedirectTo = restoreReturnTo(c, h.returnToUsecase)
+	return setTokensAndRespond(c, tokenPair)
+}
+
+func clearMagicLinkBindingCookie(c echo.Context) {
+	middleware.CookiesFromContext(c).Clear(c, magicLinkBindingCookie)
+}
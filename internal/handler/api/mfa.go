@@ -0,0 +1,206 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// mfaChallengeCookie ログイン後、二要素認証の完了までチャレンジトークンを保持するクッキー
+var mfaChallengeCookie = middleware.CookieSpec{Name: "mfa_token", Path: "/api/auth/mfa/verify", SameSite: http.SameSiteStrictMode}
+
+type MFAHandler struct {
+	mfaUsecase      domain.MFAUsecase
+	returnToUsecase domain.ReturnToUsecase
+}
+
+// MFACodeRequest 認証コード（またはリカバリーコード）を含むリクエスト
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFAVerifyRequest ログイン時の二要素認証リクエスト
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token"` // 省略時はクッキーから取得
+	Code     string `json:"code"`
+}
+
+func RegisterMFARoutes(e *echo.Echo, authUsecase domain.AuthUsecase, mfaUsecase domain.MFAUsecase, returnToUsecase domain.ReturnToUsecase) {
+	h := NewMFAHandler(mfaUsecase, returnToUsecase)
+
+	// ログインの2段階目（チャレンジトークンで認証）
+	e.POST("/api/auth/mfa/verify", h.Verify)
+
+	// 登録・解除はログイン済みユーザーのみ
+	protected := e.Group("/api/auth/mfa")
+	protected.Use(middleware.JWTAuth(authUsecase))
+	protected.GET("", h.GetStatus)
+	protected.POST("/enroll", h.BeginEnrollment)
+	protected.POST("/confirm", h.ConfirmEnrollment)
+	protected.POST("/disable", h.Disable)
+	protected.POST("/recovery-codes", h.RegenerateRecoveryCodes)
+}
+
+func NewMFAHandler(mfaUsecase domain.MFAUsecase, returnToUsecase domain.ReturnToUsecase) *MFAHandler {
+	return &MFAHandler{mfaUsecase: mfaUsecase, returnToUsecase: returnToUsecase}
+}
+
+// GetStatus 二要素認証の設定状況を取得
+func (h *MFAHandler) GetStatus(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	status, err := h.mfaUsecase.GetStatus(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get MFA status")
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+// BeginEnrollment TOTPの登録を開始（シークレット・otpauth URI・QRコードを返す）
+func (h *MFAHandler) BeginEnrollment(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	enrollment, err := h.mfaUsecase.BeginEnrollment(userID)
+	if errors.Is(err, domain.ErrMFAAlreadyEnabled) {
+		return echo.NewHTTPError(http.StatusConflict, "MFA is already enabled")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start MFA enrollment")
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmEnrollment 最初のコードで登録を確認し、リカバリーコードを返す
+func (h *MFAHandler) ConfirmEnrollment(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Code is required")
+	}
+
+	codes, err := h.mfaUsecase.ConfirmEnrollment(userID, req.Code, c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if err != nil {
+		return mfaError(err, "Failed to confirm MFA enrollment")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":        "MFA enabled successfully",
+		"recovery_codes": codes,
+	})
+}
+
+// Disable 二要素認証を無効化
+func (h *MFAHandler) Disable(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Code is required")
+	}
+
+	err := h.mfaUsecase.Disable(userID, req.Code, c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if err != nil {
+		return mfaError(err, "Failed to disable MFA")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "MFA disabled successfully",
+	})
+}
+
+// RegenerateRecoveryCodes リカバリーコードを再発行
+func (h *MFAHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Code is required")
+	}
+
+	codes, err := h.mfaUsecase.RegenerateRecoveryCodes(userID, req.Code, c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if err != nil {
+		return mfaError(err, "Failed to regenerate recovery codes")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// Verify チャレンジトークンと認証コードを検証してログインを完了
+func (h *MFAHandler) Verify(c echo.Context) error {
+	var req MFAVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.MFAToken == "" {
+		req.MFAToken = middleware.CookiesFromContext(c).Get(c, mfaChallengeCookie)
+	}
+	if req.MFAToken == "" || req.Code == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "MFA token and code are required")
+	}
+
+	response, err := h.mfaUsecase.Verify(req.MFAToken, req.Code, c.Request().UserAgent(), c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if err != nil {
+		return mfaError(err, "Failed to verify MFA code")
+	}
+
+	clearMFAChallengeCookie(c)
+	setLoginCookies(c, response)
+	response.RedirectTo = restoreReturnTo(c, h.returnToUsecase)
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// mfaError ユースケースのエラーをHTTPエラーに変換
+func mfaError(err error, message string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidMFACode):
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid MFA code")
+	case errors.Is(err, domain.ErrInvalidMFAChallenge):
+		return echo.NewHTTPError(http.StatusUnauthorized, "MFA challenge is invalid or expired")
+	case errors.Is(err, domain.ErrMFANotEnabled):
+		return echo.NewHTTPError(http.StatusBadRequest, "MFA is not enabled")
+	case errors.Is(err, domain.ErrMFAAlreadyEnabled):
+		return echo.NewHTTPError(http.StatusConflict, "MFA is already enabled")
+	case errors.Is(err, domain.ErrMFAEnrollmentNotFound):
+		return echo.NewHTTPError(http.StatusBadRequest, "MFA enrollment has not been started")
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
+
+// setMFAChallengeCookie チャレンジトークンをクッキーに保存（/login/mfa 画面から送信される）
+// 二要素認証エンドポイントのみで使用し、有効期限はチャレンジトークンに合わせる
+func setMFAChallengeCookie(c echo.Context, mfaToken string) {
+	cookies := middleware.CookiesFromContext(c)
+	cookies.Set(c, mfaChallengeCookie, mfaToken, cookies.MFAChallengeMaxAge())
+}
+
+func clearMFAChallengeCookie(c echo.Context) {
+	middleware.CookiesFromContext(c).Clear(c, mfaChallengeCookie)
+}
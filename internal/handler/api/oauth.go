@@ -1,24 +1,62 @@
 package api
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
 
 	"github.com/labstack/echo/v4"
 )
 
+// pendingLinkPath 確認待ちの連携の取得・確定・取り消しのエンドポイント
+const pendingLinkPath = "/api/auth/pending-link"
+
+var (
+	// oauthStateCookie 認可リクエストを開始したブラウザにstateを紐付けるクッキー
+	// コールバック（/auth/{provider}/callback）のみで使用し、プロバイダーからのリダイレクト（別サイトからの遷移）でも送信されるようSameSite=Laxにする
+	oauthStateCookie = middleware.CookieSpec{Name: "oauth_state", Path: "/auth/", SameSite: http.SameSiteLaxMode}
+	// accountLinkCookie 確認待ちの連携のトークンを保存するクッキー（連携の確認エンドポイントのみで使用）
+	accountLinkCookie = middleware.CookieSpec{Name: "account_link", Path: pendingLinkPath, SameSite: http.SameSiteStrictMode}
+)
+
 type OAuthHandler struct {
-	providers map[string]domain.OAuthUsecase
+	authUsecase     domain.AuthUsecase
+	identityUsecase domain.IdentityUsecase
+	stateUsecase    domain.OAuthStateUsecase
+	returnToUsecase domain.ReturnToUsecase
+	providers       map[string]domain.OAuthUsecase
 }
 
-func NewOAuthHandler(providers map[string]domain.OAuthUsecase) *OAuthHandler {
-	return &OAuthHandler{providers: providers}
+func NewOAuthHandler(authUsecase domain.AuthUsecase, identityUsecase domain.IdentityUsecase, stateUsecase domain.OAuthStateUsecase, returnToUsecase domain.ReturnToUsecase, providers map[string]domain.OAuthUsecase) *OAuthHandler {
+	return &OAuthHandler{
+		authUsecase:     authUsecase,
+		identityUsecase: identityUsecase,
+		stateUsecase:    stateUsecase,
+		returnToUsecase: returnToUsecase,
+		providers:       providers,
+	}
 }
 
-func RegisterOAuthRoutes(e *echo.Echo, providers map[string]domain.OAuthUsecase) {
-	h := NewOAuthHandler(providers)
+func RegisterOAuthRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, identityUsecase domain.IdentityUsecase, stateUsecase domain.OAuthStateUsecase, returnToUsecase domain.ReturnToUsecase, providers map[string]domain.OAuthUsecase) {
+	h := NewOAuthHandler(authUsecase, identityUsecase, stateUsecase, returnToUsecase, providers)
+
+	// ログイン中のユーザーへのプロバイダーの連携・解除
+	identities := e.Group("/api/auth/identities")
+	identities.Use(middleware.JWTAuth(authUsecase))
+	identities.GET("", h.ListIdentities)
+	identities.POST("/:provider", h.LinkIdentity)
+	identities.DELETE("/:id", h.UnlinkIdentity)
+
+	// 既存のアカウントでのログインによる連携の確認（確認待ちの連携はクッキーで保持する）
+	e.GET(pendingLinkPath, h.GetPendingLink)
+	e.POST(pendingLinkPath, h.ConfirmPendingLink, middleware.JWTAuth(authUsecase))
+	e.DELETE(pendingLinkPath, h.CancelPendingLink)
 
 	log.Printf("Registering OAuth routes for %d providers", len(providers))
 
@@ -45,7 +83,13 @@ func (h *OAuthHandler) OAuthLogin(providerName string) echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusNotFound, "Provider not found")
 		}
 
-		authURL := provider.GetAuthURL()
+		// ログイン画面で保存した戻り先をstateと一緒に保存する
+		intent := domain.OAuthIntent{ReturnTo: h.loginReturnTo(c)}
+		authURL, err := beginOAuth(c, h.stateUsecase, provider, intent)
+		if err != nil {
+			log.Printf("Failed to build auth URL for %s: %v", providerName, err)
+			return echo.NewHTTPError(http.StatusBadGateway, "Failed to start authentication with "+providerName)
+		}
 		log.Printf("Redirecting to auth URL: %s", authURL)
 		return c.Redirect(http.StatusTemporaryRedirect, authURL)
 	}
@@ -74,30 +118,269 @@ func (h *OAuthHandler) OAuthCallback(providerName string) echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, "State parameter is required")
 		}
 
-		log.Printf("Authorization code received: %s", code)
-		log.Printf("State parameter received: %s", state)
+		// 認可リクエストを開始したブラウザであることを確認してstateを取り出す
+		oauthState, err := completeOAuthState(c, h.stateUsecase, state)
+		if err != nil {
+			return err
+		}
 
-		// OAuth認証を実行（stateパラメータを含む）
-		authResponse, err := provider.Authenticate(code, state)
+		// プロバイダーでの認証を実行（stateに紐付けたコードベリファイアとnonceを使う）
+		oauthUser, err := provider.Authenticate(code, oauthState)
+		if errors.Is(err, domain.ErrOAuthAccessDenied) {
+			log.Printf("Access denied for %s: %v", providerName, err)
+			return echo.NewHTTPError(http.StatusForbidden, "You are not allowed to sign in with "+providerName)
+		}
 		if err != nil {
 			log.Printf("Authentication failed for %s: %v", providerName, err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to authenticate with "+providerName+": "+err.Error())
 		}
 
-		// トークンをクッキーに保存
-		c.SetCookie(&http.Cookie{
-			Name:     "token",
-			Value:    authResponse.Token,
-			Path:     "/",
-			MaxAge:   3600,  // 1時間
-			HttpOnly: false, // JavaScriptからアクセス可能にする
-			Secure:   false, // 開発環境ではfalse、本番環境ではtrue
-			SameSite: http.SameSiteLaxMode,
-			Domain:   "localhost", // ドメインを明示的に指定
-		})
-
-		// 保護されたページにリダイレクト
-		log.Printf("Authentication successful, redirecting to: /protected (with cookie)")
-		return c.Redirect(http.StatusTemporaryRedirect, "/protected")
+		// 連携のリクエストの場合はログインせず、ログイン中のユーザーに連携する
+		if oauthState.LinkUserID != 0 {
+			return h.completeLink(c, providerName, oauthUser, oauthState)
+		}
+
+		// セッション管理のためデバイス情報とクライアントIPを記録
+		authResponse, err := h.identityUsecase.SignIn(oauthUser, c.Request().UserAgent(), c.RealIP())
+		var linkRequired *domain.AccountLinkRequiredError
+		if errors.As(err, &linkRequired) {
+			// 同じメールアドレスの既存のアカウントでログインして連携を確認してもらう
+			return redirectToAccountLink(c, linkRequired)
+		}
+		if errors.Is(err, domain.ErrOAuthEmailInUse) {
+			return echo.NewHTTPError(http.StatusConflict, "An account with this email already exists. Sign in to that account and link "+providerName+" from your account settings.")
+		}
+		if err != nil {
+			log.Printf("Sign in failed for %s: %v", providerName, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to sign in with "+providerName)
+		}
+
+		// 二要素認証が必要な場合はチャレンジトークンを保存して確認画面へ（戻り先は認証の完了まで引き継ぐ）
+		if authResponse.MFARequired {
+			setMFAChallengeCookie(c, authResponse.MFAToken)
+			saveReturnTo(c, h.returnToUsecase, oauthState.ReturnTo)
+			return c.Redirect(http.StatusTemporaryRedirect, "/login/mfa")
+		}
+
+		// パスワードでのログインと同じく、アクセストークンとリフレッシュトークンをクッキーに保存
+		setLoginCookies(c, authResponse)
+
+		// stateと一緒に保存した戻り先（なければ保護されたページ）にリダイレクト
+		// 保存後に許可リストが変わった場合に備えて再検証する
+		middleware.CookiesFromContext(c).Clear(c, middleware.ReturnToCookie)
+		returnTo, err := h.returnToUsecase.Validate(oauthState.ReturnTo)
+		if err != nil {
+			returnTo = defaultReturnTo
+		}
+		log.Printf("Authentication successful, redirecting to: %s (with cookie)", returnTo)
+		return c.Redirect(http.StatusTemporaryRedirect, returnTo)
 	}
 }
+
+// completeLink コールバックで受け取ったプロバイダーのアカウントを連携
+// 連携を開始したユーザーと、コールバックを受けたブラウザでログイン中のユーザーが同じ場合のみ連携する
+// （他人に連携用のURLを開かせて、その人のアカウントを自分に連携させる攻撃を防ぐ）
+func (h *OAuthHandler) completeLink(c echo.Context, providerName string, oauthUser *domain.OAuthUser, oauthState *domain.OAuthState) error {
+	if h.currentUserID(c) != oauthState.LinkUserID {
+		log.Printf("Link callback for %s was received by a different session", providerName)
+		return echo.NewHTTPError(http.StatusForbidden, "The link request was started by a different account")
+	}
+
+	_, err := h.identityUsecase.Link(oauthState.LinkUserID, oauthUser, c.RealIP())
+	if errors.Is(err, domain.ErrIdentityAlreadyLinked) {
+		return echo.NewHTTPError(http.StatusConflict, "This "+providerName+" account is already linked to another account")
+	}
+	if err != nil {
+		log.Printf("Link failed for %s: %v", providerName, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link "+providerName)
+	}
+
+	log.Printf("Linked %s account to user %d", providerName, oauthState.LinkUserID)
+	return c.Redirect(http.StatusTemporaryRedirect, "/protected?linked="+url.QueryEscape(providerName))
+}
+
+// beginOAuth stateを発行して開始したブラウザのクッキーに紐付け、プロバイダーの認可URLを返す
+func beginOAuth(c echo.Context, stateUsecase domain.OAuthStateUsecase, provider domain.OAuthUsecase, intent domain.OAuthIntent) (string, error) {
+	oauthState, cookie, err := stateUsecase.Begin(intent)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.GetAuthURL(oauthState)
+	if err != nil {
+		return "", err
+	}
+
+	middleware.CookiesFromContext(c).Set(c, oauthStateCookie, cookie, time.Until(oauthState.ExpiresAt))
+
+	return authURL, nil
+}
+
+// completeOAuthState コールバックのstateをクッキーと照合して取り出す
+// 攻撃者が自分で開始した認可リクエストのコールバックURLを開かせ、攻撃者のアカウントでログインさせる攻撃（ログインCSRF）を防ぐ
+func completeOAuthState(c echo.Context, stateUsecase domain.OAuthStateUsecase, state string) (*domain.OAuthState, error) {
+	cookies := middleware.CookiesFromContext(c)
+
+	oauthState, err := stateUsecase.Complete(state, cookies.Get(c, oauthStateCookie))
+	if errors.Is(err, domain.ErrOAuthStateBrowserMismatch) {
+		log.Printf("OAuth callback was received by a browser that did not start the request")
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Complete the sign-in in the browser that started it")
+	}
+	if errors.Is(err, domain.ErrInvalidOAuthState) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired state parameter")
+	}
+	if err != nil {
+		log.Printf("Failed to verify OAuth state: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify state parameter")
+	}
+
+	cookies.Clear(c, oauthStateCookie)
+	return oauthState, nil
+}
+
+// loginReturnTo 認可リクエストと一緒に保存するログイン後の戻り先
+// next パラメータ（許可リストで検証する）、なければログイン画面でクッキーに保存した戻り先
+func (h *OAuthHandler) loginReturnTo(c echo.Context) string {
+	if next := c.QueryParam("next"); next != "" {
+		if returnTo, err := h.returnToUsecase.Validate(next); err == nil {
+			return returnTo
+		}
+	}
+	return pendingReturnTo(c, h.returnToUsecase)
+}
+
+// redirectToAccountLink 確認待ちの連携をクッキーに保存し、既存のアカウントでログインしてから確認画面へ進んでもらう
+func redirectToAccountLink(c echo.Context, linkRequired *domain.AccountLinkRequiredError) error {
+	middleware.CookiesFromContext(c).Set(c, accountLinkCookie, linkRequired.Token, time.Until(linkRequired.ExpiresAt))
+
+	log.Printf("Account link confirmation required for %s", linkRequired.Provider)
+	return c.Redirect(http.StatusTemporaryRedirect, "/login?next="+url.QueryEscape("/link-account"))
+}
+
+func clearAccountLinkCookie(c echo.Context) {
+	middleware.CookiesFromContext(c).Clear(c, accountLinkCookie)
+}
+
+// currentUserID アクセストークンのクッキーでログイン中のユーザーIDを取得（未ログインの場合は0）
+func (h *OAuthHandler) currentUserID(c echo.Context) int {
+	token := middleware.CookiesFromContext(c).Get(c, middleware.AccessTokenCookie)
+	if token == "" {
+		return 0
+	}
+
+	claims, err := h.authUsecase.ValidateToken(token)
+	if err != nil || claims.IsServiceAccount() {
+		return 0
+	}
+	return claims.UserID
+}
+
+// ListIdentities 連携しているプロバイダーの一覧を取得
+func (h *OAuthHandler) ListIdentities(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	identities, err := h.identityUsecase.ListIdentities(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get linked identities")
+	}
+
+	return c.JSON(http.StatusOK, identities)
+}
+
+// LinkIdentity プロバイダーの連携を開始し、認可URLを返す（ブラウザでこのURLに遷移してもらう）
+func (h *OAuthHandler) LinkIdentity(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+	providerName := c.Param("provider")
+
+	provider, exists := h.providers[providerName]
+	if !exists {
+		return echo.NewHTTPError(http.StatusNotFound, "Provider not found")
+	}
+
+	authURL, err := beginOAuth(c, h.stateUsecase, provider, domain.OAuthIntent{LinkUserID: userID})
+	if err != nil {
+		log.Printf("Failed to build auth URL for %s: %v", providerName, err)
+		return echo.NewHTTPError(http.StatusBadGateway, "Failed to start authentication with "+providerName)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"authorization_url": authURL,
+	})
+}
+
+// UnlinkIdentity プロバイダーの連携を解除（最後のログイン手段は解除できない）
+func (h *OAuthHandler) UnlinkIdentity(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	identityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid identity ID")
+	}
+
+	err = h.identityUsecase.Unlink(userID, identityID, c.RealIP())
+	if errors.Is(err, domain.ErrIdentityNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Identity not found")
+	}
+	if errors.Is(err, domain.ErrLastLoginMethod) {
+		return echo.NewHTTPError(http.StatusConflict, "Cannot unlink the last login method. Set a password or link another provider first.")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlink identity")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Identity unlinked successfully",
+	})
+}
+
+// GetPendingLink 確認待ちの連携の内容を取得（確認画面に表示する）
+func (h *OAuthHandler) GetPendingLink(c echo.Context) error {
+	token := middleware.CookiesFromContext(c).Get(c, accountLinkCookie)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "No pending account link")
+	}
+
+	pending, err := h.identityUsecase.GetPendingLink(token)
+	if errors.Is(err, domain.ErrInvalidAccountLink) {
+		clearAccountLinkCookie(c)
+		return echo.NewHTTPError(http.StatusNotFound, "No pending account link")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get pending account link")
+	}
+
+	return c.JSON(http.StatusOK, pending)
+}
+
+// ConfirmPendingLink ログイン中のアカウントに確認待ちの連携を確定
+func (h *OAuthHandler) ConfirmPendingLink(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	token := middleware.CookiesFromContext(c).Get(c, accountLinkCookie)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "No pending account link")
+	}
+
+	identity, err := h.identityUsecase.ConfirmLink(userID, token, c.RealIP())
+	if errors.Is(err, domain.ErrInvalidAccountLink) {
+		return echo.NewHTTPError(http.StatusForbidden, "The pending link is invalid, expired, or belongs to a different account")
+	}
+	if errors.Is(err, domain.ErrIdentityAlreadyLinked) {
+		clearAccountLinkCookie(c)
+		return echo.NewHTTPError(http.StatusConflict, "This account is already linked to another account")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+	}
+
+	clearAccountLinkCookie(c)
+	return c.JSON(http.StatusOK, identity)
+}
+
+// CancelPendingLink 確認待ちの連携を取り消す
+func (h *OAuthHandler) CancelPendingLink(c echo.Context) error {
+	clearAccountLinkCookie(c)
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Pending account link cancelled",
+	})
+}
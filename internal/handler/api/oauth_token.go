@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OAuthTokenHandler struct {
+	serviceAccountUsecase domain.ServiceAccountUsecase
+	oidcUsecase           domain.OIDCUsecase
+}
+
+// OAuthErrorResponse トークンエンドポイントのエラーレスポンス（RFC 6749 5.2）
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func RegisterOAuthTokenRoutes(e *echo.Echo, serviceAccountUsecase domain.ServiceAccountUsecase, oidcUsecase domain.OIDCUsecase) {
+	h := NewOAuthTokenHandler(serviceAccountUsecase, oidcUsecase)
+
+	// OAuth 2.0 トークンエンドポイント（クライアント認証はリクエスト内で行う）
+	e.POST("/oauth/token", h.Token)
+}
+
+func NewOAuthTokenHandler(serviceAccountUsecase domain.ServiceAccountUsecase, oidcUsecase domain.OIDCUsecase) *OAuthTokenHandler {
+	return &OAuthTokenHandler{
+		serviceAccountUsecase: serviceAccountUsecase,
+		oidcUsecase:           oidcUsecase,
+	}
+}
+
+// Token グラントタイプに応じてアクセストークンを発行
+// パラメータは application/x-www-form-urlencoded のボディで受け取る
+func (h *OAuthTokenHandler) Token(c echo.Context) error {
+	// トークンを含むレスポンスはキャッシュさせない
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	req := c.Request()
+	if err := req.ParseForm(); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "Request body must be application/x-www-form-urlencoded")
+	}
+
+	switch grantType := req.PostForm.Get("grant_type"); grantType {
+	case "":
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+	case domain.GrantTypeClientCredentials:
+		return h.clientCredentials(c)
+	case domain.GrantTypeAuthorizationCode:
+		return h.authorizationCode(c)
+	default:
+		return oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant_type: "+grantType)
+	}
+}
+
+// clientCredentials クライアントクレデンシャルズグラント（RFC 6749 4.4）
+func (h *OAuthTokenHandler) clientCredentials(c echo.Context) error {
+	clientID, clientSecret, usedBasic, ok := clientCredentialsFromRequest(c.Request())
+	if !ok {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "Client credentials must be sent using exactly one authentication method")
+	}
+	if clientID == "" || clientSecret == "" {
+		return invalidClient(c, usedBasic)
+	}
+
+	// scopeはサービスアカウントに割り当てたロールで決まるため、要求された値は無視する（レスポンスのscopeで通知）
+	token, err := h.serviceAccountUsecase.IssueToken(clientID, clientSecret, c.Request().PostForm.Get("audience"))
+	if errors.Is(err, domain.ErrInvalidClient) {
+		return invalidClient(c, usedBasic)
+	}
+	if errors.Is(err, domain.ErrInvalidAudience) {
+		return oauthError(c, http.StatusBadRequest, "invalid_target", "The requested audience is not allowed")
+	}
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue access token")
+	}
+
+	return c.JSON(http.StatusOK, token)
+}
+
+// authorizationCode 認可コードグラント（RFC 6749 4.1.3、OpenID Connect Core 3.1.3）
+// パブリッククライアントはclient_idのみを送り、PKCEのcode_verifierで認可リクエストとの対応を証明する
+func (h *OAuthTokenHandler) authorizationCode(c echo.Context) error {
+	clientID, clientSecret, usedBasic, ok := clientCredentialsFromRequest(c.Request())
+	if !ok {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "Client credentials must be sent using exactly one authentication method")
+	}
+	if clientID == "" {
+		return invalidClient(c, usedBasic)
+	}
+
+	form := c.Request().PostForm
+	code, redirectURI, codeVerifier := form.Get("code"), form.Get("redirect_uri"), form.Get("code_verifier")
+	if code == "" || redirectURI == "" || codeVerifier == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "code, redirect_uri and code_verifier are required")
+	}
+
+	token, err := h.oidcUsecase.ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier)
+	if errors.Is(err, domain.ErrInvalidClient) {
+		return invalidClient(c, usedBasic)
+	}
+	if errors.Is(err, domain.ErrInvalidGrant) {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "The authorization code is invalid, expired, or was issued to another client")
+	}
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue tokens")
+	}
+
+	return c.JSON(http.StatusOK, token)
+}
+
+// clientCredentialsFromRequest client_secret_basic（Authorizationヘッダー）または client_secret_post（ボディ）からクライアント認証情報を取得
+// 両方の方式が使われている場合はokがfalse
+func clientCredentialsFromRequest(req *http.Request) (clientID, clientSecret string, usedBasic, ok bool) {
+	postID, postSecret := req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+
+	basicID, basicSecret, usedBasic := req.BasicAuth()
+	if !usedBasic {
+		return postID, postSecret, false, true
+	}
+
+	// client_secret_basic ではIDとシークレットはURLエンコードされている（RFC 6749 2.3.1）
+	clientID, err := url.QueryUnescape(basicID)
+	if err != nil {
+		return "", "", true, false
+	}
+	clientSecret, err = url.QueryUnescape(basicSecret)
+	if err != nil {
+		return "", "", true, false
+	}
+
+	// ボディのclient_idはヘッダーと一致する場合のみ許容する
+	if postSecret != "" || (postID != "" && postID != clientID) {
+		return "", "", true, false
+	}
+	return clientID, clientSecret, true, true
+}
+
+// invalidClient クライアント認証の失敗（Authorizationヘッダーで認証した場合はWWW-Authenticateを返す）
+func invalidClient(c echo.Context, usedBasic bool) error {
+	if usedBasic {
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	return oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+}
+
+// oauthError RFC 6749 形式のエラーレスポンスを返す
+func oauthError(c echo.Context, status int, code, description string) error {
+	return c.JSON(status, OAuthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OIDCHandler struct {
+	authUsecase domain.AuthUsecase
+	oidcUsecase domain.OIDCUsecase
+}
+
+func RegisterOIDCRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsecase, oidcUsecase domain.OIDCUsecase) {
+	h := NewOIDCHandler(authUsecase, oidcUsecase)
+
+	// OpenID Connect プロバイダーのエンドポイント（トークンエンドポイントは /oauth/token を共用）
+	e.GET("/.well-known/openid-configuration", h.Discovery)
+	e.GET("/oauth/authorize", h.Authorize)
+	e.POST("/oauth/authorize", h.Consent)
+	e.GET("/oauth/userinfo", h.UserInfo)
+	e.POST("/oauth/userinfo", h.UserInfo)
+
+	// クライアントの管理はadminロールのユーザーのみ
+	admin := e.Group("/api/admin/oidc/clients")
+	admin.Use(middleware.JWTAuthWithRole(authUsecase, rbacUsecase, "admin"))
+	admin.GET("", h.ListClients)
+	admin.POST("", h.RegisterClient)
+	admin.DELETE("/:id", h.DeleteClient)
+}
+
+func NewOIDCHandler(authUsecase domain.AuthUsecase, oidcUsecase domain.OIDCUsecase) *OIDCHandler {
+	return &OIDCHandler{
+		authUsecase: authUsecase,
+		oidcUsecase: oidcUsecase,
+	}
+}
+
+// Discovery ディスカバリードキュメントを返す
+func (h *OIDCHandler) Discovery(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.oidcUsecase.Discovery())
+}
+
+// Authorize 認可エンドポイント（認可コードフロー、PKCE必須）
+// 未ログインの場合はログイン画面に誘導し、ログイン後に同じURLへ戻す
+func (h *OIDCHandler) Authorize(c echo.Context) error {
+	params := c.QueryParams()
+	req := &domain.AuthorizationRequest{
+		ClientID:            params.Get("client_id"),
+		RedirectURI:         params.Get("redirect_uri"),
+		ResponseType:        params.Get("response_type"),
+		Scope:               params.Get("scope"),
+		State:               params.Get("state"),
+		Nonce:               params.Get("nonce"),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+		Prompt:              params.Get("prompt"),
+	}
+
+	// ログイン画面に誘導する前に、リダイレクトURIとパラメータを検証する
+	if err := h.oidcUsecase.ValidateAuthorizationRequest(req); err != nil {
+		return h.authorizationError(c, err)
+	}
+
+	userID := h.currentUserID(c)
+	if userID == 0 && !req.HasPrompt("none") {
+		return c.Redirect(http.StatusFound, "/login?next="+url.QueryEscape(c.Request().URL.RequestURI()))
+	}
+
+	result, err := h.oidcUsecase.Authorize(userID, req)
+	if err != nil {
+		return h.authorizationError(c, err)
+	}
+	if result.RedirectURL != "" {
+		return c.Redirect(http.StatusFound, result.RedirectURL)
+	}
+
+	// 同意画面は他サイトのフレームに埋め込ませない（クリックジャッキング対策）
+	c.Response().Header().Set("X-Frame-Options", "DENY")
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Render(http.StatusOK, "oidc_consent.html", map[string]interface{}{
+		"title":       "アクセスの許可",
+		"client_name": result.Consent.ClientName,
+		"scopes":      result.Consent.Scopes,
+		"user_email":  result.Consent.UserEmail,
+		"challenge":   result.Consent.Challenge,
+	})
+}
+
+// Consent 同意画面の送信を処理し、クライアントにリダイレクト
+func (h *OIDCHandler) Consent(c echo.Context) error {
+	userID := h.currentUserID(c)
+	if userID == 0 {
+		return h.renderError(c, http.StatusUnauthorized, "login_required", "Your session has expired. Please start over from the application.")
+	}
+
+	location, err := h.oidcUsecase.Consent(userID, c.FormValue("challenge"), c.FormValue("decision") == "approve")
+	if errors.Is(err, domain.ErrInvalidConsentChallenge) {
+		return h.renderError(c, http.StatusBadRequest, "invalid_request", "The consent request is invalid or has expired. Please start over from the application.")
+	}
+	if err != nil {
+		return h.authorizationError(c, err)
+	}
+
+	return c.Redirect(http.StatusFound, location)
+}
+
+// UserInfo アクセストークンのスコープに応じたユーザー情報を返す
+func (h *OIDCHandler) UserInfo(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	tokenString, found := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if !found || tokenString == "" {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Missing token")
+	}
+
+	info, err := h.oidcUsecase.UserInfo(tokenString)
+	if err != nil {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer realm="userinfo", error="invalid_token"`)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// ListClients クライアント一覧を取得
+func (h *OIDCHandler) ListClients(c echo.Context) error {
+	clients, err := h.oidcUsecase.ListClients()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get OIDC clients")
+	}
+
+	return c.JSON(http.StatusOK, clients)
+}
+
+// RegisterClient クライアントを登録（クライアントシークレットはこのレスポンスでのみ返す）
+func (h *OIDCHandler) RegisterClient(c echo.Context) error {
+	var req domain.RegisterOIDCClientRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	client, err := h.oidcUsecase.RegisterClient(&req)
+	if errors.Is(err, domain.ErrInvalidOIDCClientRequest) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to register OIDC client")
+	}
+
+	return c.JSON(http.StatusCreated, client)
+}
+
+// DeleteClient クライアントを削除
+func (h *OIDCHandler) DeleteClient(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid client ID")
+	}
+
+	err = h.oidcUsecase.DeleteClient(id)
+	if errors.Is(err, domain.ErrOIDCClientNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "OIDC client not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete OIDC client")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "OIDC client deleted successfully",
+	})
+}
+
+// currentUserID ログイン中のユーザーIDを取得（未ログインの場合は0）
+// 認可エンドポイントはブラウザから遷移してくるため、アクセストークンのクッキーのみを参照する
+func (h *OIDCHandler) currentUserID(c echo.Context) int {
+	token := middleware.CookiesFromContext(c).Get(c, middleware.AccessTokenCookie)
+	if token == "" {
+		return 0
+	}
+
+	claims, err := h.authUsecase.ValidateToken(token)
+	if err != nil || claims.IsServiceAccount() {
+		return 0
+	}
+	return claims.UserID
+}
+
+// authorizationError リダイレクト可能なエラーはクライアントに返し、それ以外は画面に表示する
+func (h *OIDCHandler) authorizationError(c echo.Context, err error) error {
+	var authErr *domain.AuthorizationError
+	if !errors.As(err, &authErr) {
+		log.Printf("Failed to process authorization request: %v", err)
+		return h.renderError(c, http.StatusInternalServerError, "server_error", "An unexpected error occurred. Please try again later.")
+	}
+
+	if authErr.Location != "" {
+		return c.Redirect(http.StatusFound, authErr.Location)
+	}
+	return h.renderError(c, http.StatusBadRequest, authErr.Code, authErr.Description)
+}
+
+// renderError 認可リクエストのエラー画面を表示
+func (h *OIDCHandler) renderError(c echo.Context, status int, code, description string) error {
+	return c.Render(status, "oidc_consent.html", map[string]interface{}{
+		"title":             "認可リクエストエラー",
+		"error":             code,
+		"error_description": description,
+	})
+}
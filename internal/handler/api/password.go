@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+type PasswordHandler struct {
+	passwordUsecase domain.PasswordUsecase
+}
+
+func RegisterPasswordRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, passwordUsecase domain.PasswordUsecase) {
+	h := NewPasswordHandler(passwordUsecase)
+
+	// 認証不要のルート
+	e.POST("/api/auth/password/forgot", h.ForgotPassword)
+	e.POST("/api/auth/password/reset", h.ResetPassword)
+
+	// 認証が必要なルート
+	protected := e.Group("/api/auth/password")
+	protected.Use(middleware.JWTAuth(authUsecase))
+	protected.POST("/change", h.ChangePassword)
+}
+
+func NewPasswordHandler(passwordUsecase domain.PasswordUsecase) *PasswordHandler {
+	return &PasswordHandler{passwordUsecase: passwordUsecase}
+}
+
+// ForgotPassword パスワードリセットのリンクをメールで送信
+func (h *PasswordHandler) ForgotPassword(c echo.Context) error {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+	}
+
+	err := h.passwordUsecase.ForgotPassword(req.Email, c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if err != nil {
+		c.Logger().Error("Failed to send password reset email: ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send password reset email")
+	}
+
+	// アカウントの有無に関わらず同じ応答を返す
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "If the account exists, a password reset link has been sent",
+	})
+}
+
+// ResetPassword リセットトークンで新しいパスワードを設定
+func (h *PasswordHandler) ResetPassword(c echo.Context) error {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.Bind(&req); err != nil || req.Token == "" || req.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Token and password are required")
+	}
+
+	err := h.passwordUsecase.ResetPassword(req.Token, req.Password, c.RealIP())
+	if errors.Is(err, domain.ErrInvalidResetToken) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset link")
+	}
+	if errors.Is(err, domain.ErrWeakPassword) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset password")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Password has been reset. Please log in again",
+	})
+}
+
+// ChangePassword 現在のパスワードを確認して変更（他のセッションはログアウトされる）
+func (h *PasswordHandler) ChangePassword(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.Bind(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Current password and new password are required")
+	}
+
+	tokenPair, err := h.passwordUsecase.ChangePassword(userID, req.CurrentPassword, req.NewPassword, c.Request().UserAgent(), c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if errors.Is(err, domain.ErrInvalidCredentials) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Current password is incorrect")
+	}
+	if errors.Is(err, domain.ErrWeakPassword) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to change password")
+	}
+
+	// 現在のセッションも無効化されているため、新しいトークンをクッキーに設定して返す
+	return setTokensAndRespond(c, tokenPair)
+}
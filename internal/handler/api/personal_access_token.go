@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+type PersonalAccessTokenHandler struct {
+	patUsecase domain.PersonalAccessTokenUsecase
+}
+
+func RegisterPersonalAccessTokenRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, patUsecase domain.PersonalAccessTokenUsecase) {
+	h := NewPersonalAccessTokenHandler(patUsecase)
+
+	// トークンの管理はJWT（ログインしたユーザー本人）のみ。トークンで別のトークンは作れない
+	protected := e.Group("/api/auth/tokens")
+	protected.Use(middleware.JWTAuth(authUsecase))
+	protected.GET("", h.List)
+	protected.POST("", h.Create)
+	protected.DELETE("/:id", h.Revoke)
+}
+
+func NewPersonalAccessTokenHandler(patUsecase domain.PersonalAccessTokenUsecase) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{patUsecase: patUsecase}
+}
+
+// List パーソナルアクセストークン一覧を取得
+func (h *PersonalAccessTokenHandler) List(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	tokens, err := h.patUsecase.List(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get personal access tokens")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// Create パーソナルアクセストークンを作成（平文のトークンはこのレスポンスでのみ返す）
+func (h *PersonalAccessTokenHandler) Create(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	var req domain.CreatePersonalAccessTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	token, err := h.patUsecase.Create(userID, &req)
+	if errors.Is(err, domain.ErrInvalidScope) || errors.Is(err, domain.ErrInvalidPersonalAccessTokenRequest) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create personal access token")
+	}
+
+	return c.JSON(http.StatusCreated, token)
+}
+
+// Revoke パーソナルアクセストークンを失効させる
+func (h *PersonalAccessTokenHandler) Revoke(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid token ID")
+	}
+
+	err = h.patUsecase.Revoke(userID, tokenID)
+	if errors.Is(err, domain.ErrPersonalAccessTokenNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Personal access token not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke personal access token")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Personal access token revoked successfully",
+	})
+}
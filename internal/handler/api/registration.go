@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RegistrationHandler struct {
+	registrationUsecase domain.RegistrationUsecase
+}
+
+func RegisterRegistrationRoutes(e *echo.Echo, registrationUsecase domain.RegistrationUsecase) {
+	h := NewRegistrationHandler(registrationUsecase)
+
+	e.POST("/api/auth/register", h.Register)
+	e.POST("/api/auth/verify-email", h.VerifyEmail)
+	e.POST("/api/auth/verify-email/resend", h.ResendVerification)
+}
+
+func NewRegistrationHandler(registrationUsecase domain.RegistrationUsecase) *RegistrationHandler {
+	return &RegistrationHandler{registrationUsecase: registrationUsecase}
+}
+
+// Register ユーザー登録（確認メールを送信）
+func (h *RegistrationHandler) Register(c echo.Context) error {
+	var req domain.RegisterRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.Name == "" || req.Email == "" || req.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Name, email and password are required")
+	}
+
+	err := h.registrationUsecase.Register(&req)
+	if errors.Is(err, domain.ErrInvalidEmail) || errors.Is(err, domain.ErrWeakPassword) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to register")
+	}
+
+	// 登録済みのメールアドレスでも同じ応答を返す
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "Registration accepted. Please check your email to verify your address",
+	})
+}
+
+// VerifyEmail 確認リンクのトークンでメールアドレスを確認
+func (h *RegistrationHandler) VerifyEmail(c echo.Context) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Token is required")
+	}
+
+	err := h.registrationUsecase.VerifyEmail(req.Token)
+	if errors.Is(err, domain.ErrInvalidVerificationToken) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify email")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerification 確認メールを再送
+func (h *RegistrationHandler) ResendVerification(c echo.Context) error {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+	}
+
+	err := h.registrationUsecase.ResendVerification(req.Email, c.RealIP())
+	var lockedErr *domain.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		return tooManyAttempts(c, lockedErr)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send verification email")
+	}
+
+	// アカウントの有無に関わらず同じ応答を返す
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "If the account exists and is not verified, a verification email has been sent",
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"log"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultReturnTo 戻り先が保存されていない場合のログイン後の画面
+const defaultReturnTo = "/protected"
+
+// pendingReturnTo クッキーに保存したログイン後の戻り先を取得（ない場合・無効な場合は空文字）
+func pendingReturnTo(c echo.Context, returnToUsecase domain.ReturnToUsecase) string {
+	sealed := middleware.CookiesFromContext(c).Get(c, middleware.ReturnToCookie)
+	if sealed == "" {
+		return ""
+	}
+
+	returnTo, err := returnToUsecase.Open(sealed)
+	if err != nil {
+		return ""
+	}
+	return returnTo
+}
+
+// restoreReturnTo ログインの完了時に戻り先を取り出してクッキーを削除（ない場合は /protected）
+func restoreReturnTo(c echo.Context, returnToUsecase domain.ReturnToUsecase) string {
+	returnTo := pendingReturnTo(c, returnToUsecase)
+	middleware.CookiesFromContext(c).Clear(c, middleware.ReturnToCookie)
+	if returnTo == "" {
+		return defaultReturnTo
+	}
+	return returnTo
+}
+
+// saveReturnTo 戻り先をクッキーに保存（外部プロバイダーでのログイン後、二要素認証の完了まで引き継ぐ）
+func saveReturnTo(c echo.Context, returnToUsecase domain.ReturnToUsecase, returnTo string) {
+	if returnTo == "" {
+		return
+	}
+
+	sealed, expiresAt, err := returnToUsecase.Seal(returnTo)
+	if err != nil {
+		log.Printf("Failed to save return_to: %v", err)
+		return
+	}
+	middleware.CookiesFromContext(c).Set(c, middleware.ReturnToCookie, sealed, time.Until(expiresAt))
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountUsecase domain.ServiceAccountUsecase
+	rbacUsecase           domain.RBACUsecase
+	casbinUsecase         domain.CasbinRBACUsecase
+}
+
+// ServiceAccountRoleRequest サービスアカウントへのロール割り当てリクエスト
+type ServiceAccountRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func RegisterServiceAccountRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsecase, casbinUsecase domain.CasbinRBACUsecase, serviceAccountUsecase domain.ServiceAccountUsecase) {
+	h := NewServiceAccountHandler(serviceAccountUsecase, rbacUsecase, casbinUsecase)
+
+	// サービスアカウントの管理はadminロールのユーザーのみ（サービスアカウント自身では権限を広げられない）
+	admin := e.Group("/api/admin/service-accounts")
+	admin.Use(middleware.JWTAuthWithRole(authUsecase, rbacUsecase, "admin"))
+	admin.GET("", h.List)
+	admin.POST("", h.Create)
+	admin.GET("/:id", h.Get)
+	admin.DELETE("/:id", h.Delete)
+	admin.POST("/:id/secret", h.RotateSecret)
+
+	// DBベースのRBACのロール
+	admin.GET("/:id/roles", h.GetRoles)
+	admin.POST("/:id/roles", h.AssignRole)
+	admin.DELETE("/:id/roles/:role", h.RemoveRole)
+
+	// Casbinのロール（サブジェクトは "service:<client_id>"）
+	admin.GET("/:id/casbin-roles", h.GetCasbinRoles)
+	admin.POST("/:id/casbin-roles", h.AssignCasbinRole)
+	admin.DELETE("/:id/casbin-roles/:role", h.RemoveCasbinRole)
+}
+
+func NewServiceAccountHandler(serviceAccountUsecase domain.ServiceAccountUsecase, rbacUsecase domain.RBACUsecase, casbinUsecase domain.CasbinRBACUsecase) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountUsecase: serviceAccountUsecase,
+		rbacUsecase:           rbacUsecase,
+		casbinUsecase:         casbinUsecase,
+	}
+}
+
+// List サービスアカウント一覧を取得
+func (h *ServiceAccountHandler) List(c echo.Context) error {
+	accounts, err := h.serviceAccountUsecase.List()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get service accounts")
+	}
+
+	return c.JSON(http.StatusOK, accounts)
+}
+
+// Create サービスアカウントを作成（クライアントシークレットはこのレスポンスでのみ返す）
+func (h *ServiceAccountHandler) Create(c echo.Context) error {
+	var req domain.CreateServiceAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	account, err := h.serviceAccountUsecase.Create(&req)
+	if errors.Is(err, domain.ErrInvalidServiceAccountRequest) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create service account")
+	}
+
+	return c.JSON(http.StatusCreated, account)
+}
+
+// Get サービスアカウントを取得
+func (h *ServiceAccountHandler) Get(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, account)
+}
+
+// Delete サービスアカウントを削除
+func (h *ServiceAccountHandler) Delete(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	// Casbinのロールはファイルに保存されているため、個別に削除する
+	// 削除に失敗した場合にロールだけが残らないよう、アカウントより先に削除する
+	if err := h.removeAllCasbinRoles(account); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove Casbin roles of service account")
+	}
+
+	if err := h.serviceAccountUsecase.Delete(account.ID); err != nil {
+		return serviceAccountError(err, "Failed to delete service account")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Service account deleted successfully",
+	})
+}
+
+// RotateSecret クライアントシークレットを再発行（新しいシークレットはこのレスポンスでのみ返す）
+func (h *ServiceAccountHandler) RotateSecret(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid service account ID")
+	}
+
+	account, err := h.serviceAccountUsecase.RotateSecret(id)
+	if err != nil {
+		return serviceAccountError(err, "Failed to rotate client secret")
+	}
+
+	return c.JSON(http.StatusOK, account)
+}
+
+// GetRoles サービスアカウントに割り当てたロールを取得
+func (h *ServiceAccountHandler) GetRoles(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	roles, err := h.rbacUsecase.GetServiceAccountRoles(account.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get service account roles")
+	}
+	if roles == nil {
+		roles = []domain.Role{}
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+// AssignRole サービスアカウントにロールを割り当て
+func (h *ServiceAccountHandler) AssignRole(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req ServiceAccountRoleRequest
+	if err := c.Bind(&req); err != nil || req.Role == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Role is required")
+	}
+
+	err = h.rbacUsecase.AssignRoleToServiceAccount(account.ID, req.Role)
+	if errors.Is(err, domain.ErrRoleNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Role not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to assign role")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveRole サービスアカウントからロールを削除
+func (h *ServiceAccountHandler) RemoveRole(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.rbacUsecase.RemoveRoleFromServiceAccount(account.ID, c.Param("role"))
+	if errors.Is(err, domain.ErrRoleNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Role not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove role")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetCasbinRoles サービスアカウントに割り当てたCasbinのロールを取得
+func (h *ServiceAccountHandler) GetCasbinRoles(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	roles, err := h.casbinUsecase.GetUserRoles(account.Subject())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get service account roles")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"subject": account.Subject(),
+		"roles":   roles,
+	})
+}
+
+// AssignCasbinRole サービスアカウントにCasbinのロールを割り当て
+func (h *ServiceAccountHandler) AssignCasbinRole(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req ServiceAccountRoleRequest
+	if err := c.Bind(&req); err != nil || req.Role == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Role is required")
+	}
+
+	if err := h.casbinUsecase.AssignRoleToUser(account.Subject(), req.Role); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to assign role")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveCasbinRole サービスアカウントからCasbinのロールを削除
+func (h *ServiceAccountHandler) RemoveCasbinRole(c echo.Context) error {
+	account, err := h.serviceAccountFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.casbinUsecase.RemoveRoleFromUser(account.Subject(), c.Param("role")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove role")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// serviceAccountFromParam パスパラメータのIDでサービスアカウントを取得
+func (h *ServiceAccountHandler) serviceAccountFromParam(c echo.Context) (*domain.ServiceAccount, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid service account ID")
+	}
+
+	account, err := h.serviceAccountUsecase.Get(id)
+	if err != nil {
+		return nil, serviceAccountError(err, "Failed to get service account")
+	}
+	return account, nil
+}
+
+// removeAllCasbinRoles サービスアカウントのCasbinのロールをすべて削除
+func (h *ServiceAccountHandler) removeAllCasbinRoles(account *domain.ServiceAccount) error {
+	roles, err := h.casbinUsecase.GetUserRoles(account.Subject())
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if err := h.casbinUsecase.RemoveRoleFromUser(account.Subject(), role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serviceAccountError ユースケースのエラーをHTTPエラーに変換
+func serviceAccountError(err error, message string) error {
+	if errors.Is(err, domain.ErrServiceAccountNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, message)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
 	"go-echo-demo/internal/usecase"
 	"net/http"
 	"strconv"
@@ -13,13 +14,19 @@ type UserHandler struct {
 	Usecase usecase.UserUsecase
 }
 
-func RegisterRoutes(e *echo.Echo, userUsecase usecase.UserUsecase) {
+func RegisterRoutes(e *echo.Echo, userUsecase usecase.UserUsecase, authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsecase, patUsecase domain.PersonalAccessTokenUsecase) {
 	h := &UserHandler{Usecase: userUsecase}
-	e.GET("/users", h.GetUsers)
-	e.POST("/users", h.CreateUser)
-	e.GET("/users/:id", h.GetUser)
-	e.PUT("/users/:id", h.UpdateUser)
-	e.DELETE("/users/:id", h.DeleteUser)
+
+	// ユーザー管理はuserリソースの権限で判定（スクリプトからはスコープを限定したパーソナルアクセストークンでも呼べる）
+	canRead := middleware.JWTOrPersonalAccessTokenAuthWithRBAC(authUsecase, patUsecase, rbacUsecase, "user", "read")
+	canWrite := middleware.JWTOrPersonalAccessTokenAuthWithRBAC(authUsecase, patUsecase, rbacUsecase, "user", "write")
+	canDelete := middleware.JWTOrPersonalAccessTokenAuthWithRBAC(authUsecase, patUsecase, rbacUsecase, "user", "delete")
+
+	e.GET("/users", h.GetUsers, canRead)
+	e.POST("/users", h.CreateUser, canWrite)
+	e.GET("/users/:id", h.GetUser, canRead)
+	e.PUT("/users/:id", h.UpdateUser, canWrite)
+	e.DELETE("/users/:id", h.DeleteUser, canDelete)
 }
 
 func (h *UserHandler) GetUsers(c echo.Context) error {
@@ -33,17 +40,22 @@ func (h *UserHandler) GetUsers(c echo.Context) error {
 func (h *UserHandler) GetUser(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
 	user, err := h.Usecase.GetUser(id)
-	if err != nil {
+	if err != nil || user == nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
 	}
 	return c.JSON(http.StatusOK, user)
 }
 
 func (h *UserHandler) CreateUser(c echo.Context) error {
-	user := new(domain.User)
-	if err := c.Bind(user); err != nil {
+	var req struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	user := &domain.User{Name: req.Name, Email: req.Email, Password: req.Password}
 	if err := h.Usecase.CreateUser(user); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -4,12 +4,71 @@ import (
 	"log"
 	"net/http"
 
+	"go-echo-demo/internal/middleware"
+
 	"github.com/labstack/echo/v4"
 )
 
 func LoginPage(c echo.Context) error {
 	return c.Render(http.StatusOK, "login.html", map[string]interface{}{
-		"title": "ログイン",
+		"title":    "ログイン",
+		"returnTo": middleware.ReturnToFromContext(c), // 検証済みの戻り先（ログイン済みの場合にすぐ戻る）
+	})
+}
+
+// MFAPage ログイン時の二要素認証コード入力画面
+func MFAPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "mfa_verify.html", map[string]interface{}{
+		"title": "二要素認証",
+	})
+}
+
+// LinkAccountPage 既存のアカウントへのプロバイダーの連携を確認する画面
+func LinkAccountPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "link_account.html", map[string]interface{}{
+		"title": "アカウントの連携",
+	})
+}
+
+// MagicLinkPage ログインリンクの送信画面（パスワードなしログイン）
+func MagicLinkPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "magic_link.html", map[string]interface{}{
+		"title": "メールでログイン",
+	})
+}
+
+// MagicLinkVerifyPage ログインリンクのリンク先（トークンをAPIに送信してログインする）
+func MagicLinkVerifyPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "magic_link_verify.html", map[string]interface{}{
+		"title": "メールでログイン",
+	})
+}
+
+// RegisterPage 新規登録画面
+func RegisterPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "register.html", map[string]interface{}{
+		"title": "新規登録",
+	})
+}
+
+// VerifyEmailPage 確認メールのリンク先（トークンをAPIに送信して確認する）
+func VerifyEmailPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "verify_email.html", map[string]interface{}{
+		"title": "メールアドレスの確認",
+	})
+}
+
+// ForgotPasswordPage パスワード再設定リンクの送信画面
+func ForgotPasswordPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "forgot_password.html", map[string]interface{}{
+		"title": "パスワードの再設定",
+	})
+}
+
+// ResetPasswordPage 再設定メールのリンク先（新しいパスワードとトークンをAPIに送信する）
+func ResetPasswordPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "reset_password.html", map[string]interface{}{
+		"title": "新しいパスワードの設定",
 	})
 }
 
@@ -25,9 +25,18 @@ func RegisterFrontend(e *echo.Echo) {
 			"templates/basic.html",
 			"templates/digest.html",
 			"templates/login.html",
+			"templates/mfa_verify.html",
+			"templates/link_account.html",
+			"templates/register.html",
+			"templates/verify_email.html",
+			"templates/forgot_password.html",
+			"templates/reset_password.html",
+			"templates/magic_link.html",
+			"templates/magic_link_verify.html",
 			"templates/protected.html",
 			"templates/google_login.html",
 			"templates/line_login.html",
+			"templates/oidc_consent.html",
 			"templates/_header.html",
 			"templates/_footer.html",
 			"templates/sql_injection_demo.html",
@@ -38,9 +47,18 @@ func RegisterFrontend(e *echo.Echo) {
 	e.File("/casbin", "templates/casbin_admin.html")
 }
 
-func RegisterAuthFrontendRoutes(e *echo.Echo, authUsecase domain.AuthUsecase) {
+func RegisterAuthFrontendRoutes(e *echo.Echo, authUsecase domain.AuthUsecase, patUsecase domain.PersonalAccessTokenUsecase, returnToUsecase domain.ReturnToUsecase) {
 	// 認証不要のルート
-	e.GET("/login", LoginPage)
+	// ログイン画面の next（ログイン前にアクセスした画面）は検証してクッキーに保存し、ログインの完了時に戻る
+	e.GET("/login", LoginPage, middleware.CaptureReturnTo(returnToUsecase))
+	e.GET("/login/mfa", MFAPage)
+	e.GET("/link-account", LinkAccountPage)
+	e.GET("/login/magic-link", MagicLinkPage)
+	e.GET("/login/magic-link/verify", MagicLinkVerifyPage)
+	e.GET("/register", RegisterPage)
+	e.GET("/verify-email", VerifyEmailPage)
+	e.GET("/forgot-password", ForgotPasswordPage)
+	e.GET("/reset-password", ResetPasswordPage)
 	e.GET("/line-login", LineLoginPage)
 
 	// 保護されたページ（JWT認証付き）
@@ -48,8 +66,8 @@ func RegisterAuthFrontendRoutes(e *echo.Echo, authUsecase domain.AuthUsecase) {
 	protected.Use(middleware.JWTAuth(authUsecase))
 	protected.GET("", ProtectedPage)
 
-	// 認証が必要なAPIエンドポイント（ユーザー情報取得用）
+	// 認証が必要なAPIエンドポイント（ユーザー情報取得用。スクリプトからはパーソナルアクセストークンでも呼べる）
 	apiProtected := e.Group("/api/user")
-	apiProtected.Use(middleware.JWTAuth(authUsecase))
+	apiProtected.Use(middleware.JWTOrPersonalAccessTokenAuth(authUsecase, patUsecase))
 	apiProtected.GET("/info", GetUserInfo)
 }
@@ -1,11 +1,9 @@
 package infrastructure
 
 import (
-	"crypto/rand"
 	"database/sql"
-	"encoding/hex"
+	"log"
 	"strconv"
-	"sync"
 	"time"
 
 	"go-echo-demo/internal/domain"
@@ -21,91 +19,70 @@ func NewRefreshTokenRepository(db *sql.DB) domain.RefreshTokenRepository {
 	return repository.NewRefreshTokenRepository(db)
 }
 
-func NewAuthUsecase(authRepo domain.AuthRepository, refreshTokenRepo domain.RefreshTokenRepository, userRepo domain.UserRepository) domain.AuthUsecase {
-	// JWT有効期限の設定（デフォルト: 15分）
-	jwtDurationStr := getEnv("JWT_DURATION_MINUTES", "15")
-	jwtDurationMinutes, _ := strconv.Atoi(jwtDurationStr)
-	
-	// リフレッシュトークン有効期限の設定（デフォルト: 7日）
-	refreshDurationStr := getEnv("REFRESH_TOKEN_DURATION_DAYS", "7")
-	refreshDurationDays, _ := strconv.Atoi(refreshDurationStr)
-	
-	jwtConfig := domain.JWTConfig{
-		SecretKey:            getEnv("JWT_SECRET_KEY", "your-secret-key"),
-		Duration:             time.Duration(jwtDurationMinutes) * time.Minute,
-		RefreshTokenDuration: time.Duration(refreshDurationDays) * 24 * time.Hour,
+// NewPasswordHasher 環境変数の設定からパスワードハッシャーを作成（設定が不正な場合は起動しない）
+func NewPasswordHasher() domain.PasswordHasher {
+	passwordHasher, err := usecase.NewPasswordHasher(domain.PasswordHashConfig{
+		Algorithm:     getEnv("PASSWORD_HASH_ALGORITHM", domain.PasswordHashArgon2id),
+		Argon2Memory:  uint32(parseUintEnv("ARGON2_MEMORY_KB", "65536", 32)),
+		Argon2Time:    uint32(parseUintEnv("ARGON2_ITERATIONS", "3", 32)),
+		Argon2Threads: uint8(parseUintEnv("ARGON2_PARALLELISM", "2", 8)),
+		BcryptCost:    int(parseUintEnv("BCRYPT_COST", "12", 8)),
+	})
+	if err != nil {
+		log.Fatalf("パスワードハッシュの設定が不正です: %v", err)
 	}
-
-	return usecase.NewAuthUsecase(authRepo, refreshTokenRepo, userRepo, jwtConfig)
-}
-
-// StateManagerImpl stateパラメータの実装
-type StateManagerImpl struct {
-	states map[string]time.Time
-	mutex  sync.RWMutex
+	return passwordHasher
 }
 
-func NewStateManager() domain.StateManager {
-	sm := &StateManagerImpl{
-		states: make(map[string]time.Time),
+// parseUintEnv 符号なし整数の環境変数を読み込む（数値でない値や、型に収まらない値の場合は起動しない）
+func parseUintEnv(key, defaultValue string, bitSize int) uint64 {
+	value, err := strconv.ParseUint(getEnv(key, defaultValue), 10, bitSize)
+	if err != nil {
+		log.Fatalf("%s の値が不正です: %v", key, err)
 	}
-
-	// 古いstateを定期的にクリーンアップ
-	go sm.cleanupExpiredStates()
-
-	return sm
+	return value
 }
 
-func (sm *StateManagerImpl) GenerateState() string {
-	// 32バイトのランダムな値を生成
-	bytes := make([]byte, 32)
-	rand.Read(bytes)
-	state := hex.EncodeToString(bytes)
-
-	sm.mutex.Lock()
-	sm.states[state] = time.Now()
-	sm.mutex.Unlock()
-
-	return state
+func NewSecurityEventRepository(db *sql.DB) domain.SecurityEventRepository {
+	return repository.NewSecurityEventRepository(db)
 }
 
-func (sm *StateManagerImpl) ValidateState(state string) bool {
-	sm.mutex.RLock()
-	timestamp, exists := sm.states[state]
-	sm.mutex.RUnlock()
-
-	if !exists {
-		return false
-	}
-
-	// 10分以内のstateのみ有効
-	if time.Since(timestamp) > 10*time.Minute {
-		sm.mutex.Lock()
-		delete(sm.states, state)
-		sm.mutex.Unlock()
-		return false
+func NewAuthUsecase(authRepo domain.AuthRepository, refreshTokenRepo domain.RefreshTokenRepository, userRepo domain.UserRepository, securityEventRepo domain.SecurityEventRepository, revokedTokenStore domain.RevokedTokenStore, mfaRepo domain.MFARepository, rbacRepo domain.RBACRepository, serviceAccountRepo domain.ServiceAccountRepository, loginThrottle domain.LoginThrottle, passwordHasher domain.PasswordHasher, keyManager domain.KeyManager) domain.AuthUsecase {
+	authUsecase, err := usecase.NewAuthUsecase(authRepo, refreshTokenRepo, userRepo, securityEventRepo, revokedTokenStore, mfaRepo, rbacRepo, serviceAccountRepo, loginThrottle, passwordHasher, keyManager, newJWTConfig())
+	if err != nil {
+		log.Fatalf("認証ユースケースの初期化に失敗しました: %v", err)
 	}
+	return authUsecase
+}
 
-	// 使用済みのstateを削除
-	sm.mutex.Lock()
-	delete(sm.states, state)
-	sm.mutex.Unlock()
+// newJWTConfig 環境変数からJWTの設定を読み込む（AuthUsecaseとOpenID Connect プロバイダーで共通）
+func newJWTConfig() domain.JWTConfig {
+	// JWT有効期限の設定（デフォルト: 15分）
+	jwtDurationStr := getEnv("JWT_DURATION_MINUTES", "15")
+	jwtDurationMinutes, _ := strconv.Atoi(jwtDurationStr)
+	
+	// リフレッシュトークン有効期限の設定（デフォルト: 7日）
+	refreshDurationStr := getEnv("REFRESH_TOKEN_DURATION_DAYS", "7")
+	refreshDurationDays, _ := strconv.Atoi(refreshDurationStr)
 
-	return true
-}
+	// 二要素認証チャレンジの有効期限（デフォルト: 5分）
+	mfaChallengeMinutes, _ := strconv.Atoi(getEnv("MFA_CHALLENGE_MINUTES", "5"))
 
-func (sm *StateManagerImpl) cleanupExpiredStates() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+	// アクセストークンにロールと権限スコープを含めるか（デフォルト: true）
+	authzClaims, _ := strconv.ParseBool(getEnv("JWT_AUTHZ_CLAIMS", "true"))
 
-	for range ticker.C {
-		sm.mutex.Lock()
-		now := time.Now()
-		for state, timestamp := range sm.states {
-			if now.Sub(timestamp) > 10*time.Minute {
-				delete(sm.states, state)
-			}
-		}
-		sm.mutex.Unlock()
+	// 検証時に許容する時刻のずれ（デフォルト: 30秒）
+	leewaySeconds, _ := strconv.Atoi(getEnv("JWT_LEEWAY_SECONDS", "30"))
+	
+	return domain.JWTConfig{
+		Duration:             time.Duration(jwtDurationMinutes) * time.Minute,
+		RefreshTokenDuration: time.Duration(refreshDurationDays) * 24 * time.Hour,
+		MFAChallengeDuration: time.Duration(mfaChallengeMinutes) * time.Minute,
+		AuthzClaims:          authzClaims,
+		Issuer:               getEnv("JWT_ISSUER", getEnv("APP_BASE_URL", "http://localhost:8080")),
+		Audience:             getEnv("JWT_AUDIENCE", "go-echo-demo"),
+		Audiences:            splitList(getEnv("JWT_AUDIENCES", "")),
+		Algorithms:           splitList(getEnv("JWT_ALLOWED_ALGORITHMS", "RS256,ES256,EdDSA")),
+		Leeway:               time.Duration(leewaySeconds) * time.Second,
 	}
 }
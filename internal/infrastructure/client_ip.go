@@ -0,0 +1,35 @@
+package infrastructure
+
+import (
+	"log"
+	"net"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NewIPExtractor 環境変数の信頼するプロキシの設定からクライアントIPの取得方法を作成
+// ログイン試行制限・セッション・セキュリティイベントに記録するIPアドレスに使う
+//   - TRUSTED_PROXIES が空の場合: 接続元のIPアドレス（X-Forwarded-For / X-Real-IP はクライアントが偽装できるため使わない）
+//   - CIDRを指定した場合: 信頼するプロキシを経由したリクエストのみ X-Forwarded-For から取得する
+func NewIPExtractor() echo.IPExtractor {
+	proxies := splitList(getEnv("TRUSTED_PROXIES", ""))
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// デフォルトで信頼するループバック・リンクローカル・プライベートアドレスも、指定したもの以外は信頼しない
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range proxies {
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Fatalf("TRUSTED_PROXIES=%s は無効なCIDRです: %v", proxy, err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -0,0 +1,47 @@
+package infrastructure
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/middleware"
+)
+
+// NewCookiePolicy 環境変数の設定から認証に使うクッキーのポリシーを作成
+// 有効期限はJWTの設定（アクセストークン・リフレッシュトークン・二要素認証チャレンジ）に合わせる
+func NewCookiePolicy() *middleware.CookiePolicy {
+	jwtConfig := newJWTConfig()
+
+	// HTTPSの場合のみ送信するか（デフォルト: false。本番環境ではtrue）
+	secure, _ := strconv.ParseBool(getEnv("COOKIE_SECURE", "false"))
+	// 名前に __Host- / __Secure- を付けるか（デフォルト: false。HTTPSが必須）
+	hostPrefix, _ := strconv.ParseBool(getEnv("COOKIE_HOST_PREFIX", "false"))
+
+	sameSite := strings.ToLower(getEnv("COOKIE_SAMESITE", domain.CookieSameSiteLax))
+	switch sameSite {
+	case domain.CookieSameSiteLax, domain.CookieSameSiteStrict, domain.CookieSameSiteNone:
+	default:
+		log.Printf("Warning: COOKIE_SAMESITE=%s は無効な値です。lax を使用します", sameSite)
+		sameSite = domain.CookieSameSiteLax
+	}
+
+	config := domain.CookieConfig{
+		Secure:             secure,
+		SameSite:           sameSite,
+		Domain:             getEnv("COOKIE_DOMAIN", ""),
+		HostPrefix:         hostPrefix,
+		AccessTokenMaxAge:  jwtConfig.Duration,
+		RefreshTokenMaxAge: jwtConfig.RefreshTokenDuration,
+		MFAChallengeMaxAge: jwtConfig.MFAChallengeDuration,
+	}
+	if config.HostPrefix && config.Domain != "" {
+		log.Printf("Warning: COOKIE_HOST_PREFIX が有効なため COOKIE_DOMAIN は使用しません")
+	}
+	if !config.Secure && (config.HostPrefix || config.SameSite == domain.CookieSameSiteNone) {
+		log.Printf("Warning: COOKIE_HOST_PREFIX または COOKIE_SAMESITE=none ではクッキーにSecureを付けます（HTTPSでのみ送信されます）")
+	}
+
+	return middleware.NewCookiePolicy(config)
+}
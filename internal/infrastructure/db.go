@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -36,6 +37,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// splitList カンマ区切りの環境変数を空要素を除いたスライスに変換
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // NewDBX returns a sqlx.DB instance
 func NewDBX() *sqlx.DB {
 	dbHost := getEnv("DB_HOST", "localhost")
@@ -9,12 +9,12 @@ import (
 )
 
 // NewGoogleAuthRepository Google認証リポジトリを作成
-func NewGoogleAuthRepository(db *sql.DB) domain.OAuthRepository {
+func NewGoogleAuthRepository(db *sql.DB) domain.GoogleAuthRepository {
 	return repository.NewGoogleAuthRepository(db)
 }
 
 // NewGoogleAuthUsecase Google認証ユースケースを作成
-func NewGoogleAuthUsecase(oauthRepo domain.OAuthRepository, authUsecase domain.AuthUsecase, stateManager domain.StateManager) domain.OAuthUsecase {
+func NewGoogleAuthUsecase() domain.OAuthUsecase {
 	config := domain.GoogleAuthConfig{
 		ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 		ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
@@ -22,5 +22,5 @@ func NewGoogleAuthUsecase(oauthRepo domain.OAuthRepository, authUsecase domain.A
 		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
 	}
 
-	return usecase.NewGoogleAuthUsecase(config, oauthRepo, authUsecase, stateManager)
+	return usecase.NewGoogleAuthUsecase(config)
 }
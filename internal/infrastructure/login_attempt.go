@@ -0,0 +1,147 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+// NewLoginAttemptStore 環境変数 LOGIN_ATTEMPT_STORE（postgres / memory）に応じた失敗状況の保存先を作成
+func NewLoginAttemptStore(db *sql.DB) domain.LoginAttemptStore {
+	var store domain.LoginAttemptStore
+	switch getEnv("LOGIN_ATTEMPT_STORE", "postgres") {
+	case "memory":
+		store = NewMemoryLoginAttemptStore()
+	default:
+		store = repository.NewLoginAttemptRepository(db)
+	}
+
+	go pruneLoginAttempts(store, 5*time.Minute, 24*time.Hour)
+
+	return store
+}
+
+func pruneLoginAttempts(store domain.LoginAttemptStore, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := store.Prune(time.Now().Add(-retention)); err != nil {
+			log.Printf("Failed to prune login attempts: %v", err)
+		}
+	}
+}
+
+// NewLoginThrottle 環境変数の設定からログイン試行制限を作成
+func NewLoginThrottle(store domain.LoginAttemptStore, securityEventRepo domain.SecurityEventRepository) domain.LoginThrottle {
+	maxAccountFailures := positiveIntEnv("LOGIN_MAX_FAILURES_ACCOUNT", 10)
+	maxIPFailures := positiveIntEnv("LOGIN_MAX_FAILURES_IP", 50)
+	lockoutMinutes := positiveIntEnv("LOGIN_LOCKOUT_MINUTES", 15)
+
+	config := domain.LoginAttemptConfig{
+		MaxAccountFailures: maxAccountFailures,
+		MaxIPFailures:      maxIPFailures,
+		FreeAttempts:       3,
+		BaseDelay:          time.Second,
+		MaxDelay:           time.Minute,
+		LockoutDuration:    time.Duration(lockoutMinutes) * time.Minute,
+	}
+
+	return usecase.NewLoginThrottle(store, securityEventRepo, config)
+}
+
+// positiveIntEnv 環境変数の正の整数値を取得
+// 無効な値（数値でない・0以下）の場合は、警告を出してデフォルト値を使う
+func positiveIntEnv(key string, defaultValue int) int {
+	value := getEnv(key, strconv.Itoa(defaultValue))
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("Warning: %s=%s は無効な値です。%d を使用します", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// MemoryLoginAttemptStore ログイン失敗状況のインメモリ実装（単一インスタンス・テスト用）
+type MemoryLoginAttemptStore struct {
+	attempts map[string]*domain.LoginAttempt
+	mutex    sync.Mutex
+}
+
+func NewMemoryLoginAttemptStore() domain.LoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: make(map[string]*domain.LoginAttempt),
+	}
+}
+
+func (s *MemoryLoginAttemptStore) Get(key string) (*domain.LoginAttempt, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	attempt, exists := s.attempts[key]
+	if !exists {
+		return nil, nil
+	}
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(key string, window time.Duration) (*domain.LoginAttempt, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	attempt, exists := s.attempts[key]
+	if !exists {
+		attempt = &domain.LoginAttempt{Key: key}
+		s.attempts[key] = attempt
+	}
+
+	if now.Sub(attempt.LastFailureAt) > window {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if attempt, exists := s.attempts[key]; exists {
+		attempt.LockedUntil = &until
+		attempt.Failures = 0
+	}
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Prune(before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for key, attempt := range s.attempts {
+		locked := attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil)
+		if attempt.LastFailureAt.Before(before) && !locked {
+			delete(s.attempts, key)
+		}
+	}
+	return nil
+}
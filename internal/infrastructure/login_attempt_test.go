@@ -0,0 +1,29 @@
+package infrastructure
+
+import "testing"
+
+func TestPositiveIntEnv(t *testing.T) {
+	const key = "TEST_LOGIN_MAX_FAILURES"
+
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{name: "unset", value: "", want: 5},
+		{name: "positive", value: "10", want: 10},
+		{name: "zero", value: "0", want: 5},
+		{name: "negative", value: "-1", want: 5},
+		{name: "not a number", value: "ten", want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(key, tt.value)
+
+			if got := positiveIntEnv(key, 5); got != tt.want {
+				t.Errorf("positiveIntEnv() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+func NewMagicLinkRepository(db *sql.DB) domain.MagicLinkRepository {
+	return repository.NewMagicLinkRepository(db)
+}
+
+// NewMagicLinkUsecase 環境変数の設定からマジックリンクログインのユースケースを作成
+func NewMagicLinkUsecase(authRepo domain.AuthRepository, userRepo domain.UserRepository, magicLinkRepo domain.MagicLinkRepository, authUsecase domain.AuthUsecase, mailer domain.Mailer, loginThrottle domain.LoginThrottle) domain.MagicLinkUsecase {
+	// ログインリンクの有効期限（デフォルト: 10分）
+	magicLinkMinutes, _ := strconv.Atoi(getEnv("MAGIC_LINK_MINUTES", "10"))
+
+	config := domain.MagicLinkConfig{
+		BaseURL:       getEnv("APP_BASE_URL", "http://localhost:8080"),
+		TokenDuration: time.Duration(magicLinkMinutes) * time.Minute,
+	}
+
+	return usecase.NewMagicLinkUsecase(authRepo, userRepo, magicLinkRepo, authUsecase, mailer, loginThrottle, config)
+}
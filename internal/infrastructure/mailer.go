@@ -0,0 +1,118 @@
+package infrastructure
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// NewMailer 環境変数 MAIL_DRIVER（smtp / file / log）に応じたメール送信を作成
+// 外部サービスなしで動かせるよう、デフォルトはログ出力
+func NewMailer() domain.Mailer {
+	from := getEnv("MAIL_FROM", "no-reply@localhost")
+
+	switch driver := getEnv("MAIL_DRIVER", "log"); driver {
+	case "smtp":
+		return NewSMTPMailer(
+			getEnv("SMTP_HOST", "localhost"),
+			getEnv("SMTP_PORT", "587"),
+			getEnv("SMTP_USERNAME", ""),
+			getEnv("SMTP_PASSWORD", ""),
+			from,
+		)
+	case "file":
+		return NewFileMailer(getEnv("MAIL_OUTBOX_DIR", "tmp/mail"), from)
+	case "log":
+		return NewLogMailer()
+	default:
+		panic(fmt.Sprintf("unsupported MAIL_DRIVER: %s", driver))
+	}
+}
+
+// buildMailMessage RFC 5322形式のメッセージを組み立てる
+func buildMailMessage(from string, message *domain.MailMessage) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + message.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", message.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SMTPMailer SMTPサーバー経由で送信
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) domain.Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr: host + ":" + port,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *SMTPMailer) Send(message *domain.MailMessage) error {
+	// smtp.SendMailはサーバーが対応していればSTARTTLSを使用する
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, buildMailMessage(m.from, message)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// FileMailer 送信する代わりに .eml ファイルとして保存（ローカル開発用のアウトボックス）
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) domain.Mailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(message *domain.MailMessage) error {
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.New().String()[:8])
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, buildMailMessage(m.from, message), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+
+	log.Printf("Mail saved to %s (to=%s subject=%s)", path, message.To, message.Subject)
+	return nil
+}
+
+// LogMailer 送信内容をログに出力（開発用）
+type LogMailer struct{}
+
+func NewLogMailer() domain.Mailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(message *domain.MailMessage) error {
+	log.Printf("Mail to=%s subject=%s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
@@ -0,0 +1,55 @@
+package infrastructure
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+func NewMFARepository(db *sql.DB) domain.MFARepository {
+	return repository.NewMFARepository(db)
+}
+
+// NewMFAUsecase 環境変数の設定から二要素認証ユースケースを作成
+//
+// MFA_ENCRYPTION_KEY はTOTPシークレット暗号化用の32バイト鍵（Base64）。
+// 未設定の場合は起動ごとに一時的な鍵を生成する（開発用。再起動で登録済みのTOTPは復号できなくなる）。
+func NewMFAUsecase(mfaRepo domain.MFARepository, userRepo domain.UserRepository, authUsecase domain.AuthUsecase, revokedTokenStore domain.RevokedTokenStore, loginThrottle domain.LoginThrottle) domain.MFAUsecase {
+	encryptionKey, err := loadMFAEncryptionKey(getEnv("MFA_ENCRYPTION_KEY", ""))
+	if err != nil {
+		panic(err)
+	}
+
+	config := domain.MFAConfig{
+		Issuer:        getEnv("MFA_ISSUER", "go-echo-demo"),
+		EncryptionKey: encryptionKey,
+	}
+
+	return usecase.NewMFAUsecase(mfaRepo, userRepo, authUsecase, revokedTokenStore, loginThrottle, config)
+}
+
+func loadMFAEncryptionKey(encoded string) ([]byte, error) {
+	if encoded == "" {
+		log.Printf("Warning: MFA_ENCRYPTION_KEY が未設定です。一時的な鍵を生成します（再起動で登録済みのTOTPは使えなくなります）")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY must be base64 encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
@@ -3,19 +3,40 @@ package infrastructure
 import (
 	"database/sql"
 	"log"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"go-echo-demo/internal/domain"
 	"go-echo-demo/internal/repository"
 	"go-echo-demo/internal/usecase"
 )
 
+// oidcProviderNamePattern 汎用OIDCプロバイダー名（ルートのパスに使うため英小文字・数字・ハイフンに限定）
+var oidcProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
 func NewOAuthRepository(db *sql.DB) domain.OAuthRepository {
 	return repository.NewOAuthRepository(db)
 }
 
+// NewIdentityUsecase 環境変数のアカウント連携ポリシーで外部プロバイダーのログインのユースケースを作成
+func NewIdentityUsecase(oauthRepo domain.OAuthRepository, authRepo domain.AuthRepository, securityEventRepo domain.SecurityEventRepository, revokedTokenStore domain.RevokedTokenStore, authUsecase domain.AuthUsecase, keyManager domain.KeyManager) domain.IdentityUsecase {
+	// 既存のアカウントでのログインによる確認の有効期限（デフォルト: 10分）
+	linkMinutes, _ := strconv.Atoi(getEnv("OAUTH_ACCOUNT_LINK_MINUTES", "10"))
+
+	policy := domain.AccountLinkPolicy{
+		// デフォルトはどのプロバイダーも自動連携しない
+		AutoLinkProviders: splitList(getEnv("OAUTH_AUTO_LINK_PROVIDERS", "")),
+		LinkDuration:      time.Duration(linkMinutes) * time.Minute,
+	}
+
+	return usecase.NewIdentityUsecase(oauthRepo, authRepo, securityEventRepo, revokedTokenStore, authUsecase, keyManager, newJWTConfig(), policy)
+}
+
 // OAuthプロバイダーのマップを作成
-func NewOAuthProviders(oauthRepo domain.OAuthRepository, authUsecase domain.AuthUsecase, stateManager domain.StateManager) map[string]domain.OAuthUsecase {
+func NewOAuthProviders() map[string]domain.OAuthUsecase {
 	providers := make(map[string]domain.OAuthUsecase)
 
 	// Google認証を追加
@@ -29,7 +50,7 @@ func NewOAuthProviders(oauthRepo domain.OAuthRepository, authUsecase domain.Auth
 			RedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/google/callback"),
 			Scopes:       []string{"openid", "email", "profile"},
 		}
-		googleAuth := usecase.NewGoogleAuthUsecase(config, oauthRepo, authUsecase, stateManager)
+		googleAuth := usecase.NewGoogleAuthUsecase(config)
 		providers["google"] = googleAuth
 		log.Printf("Google OAuth provider initialized")
 	} else {
@@ -47,13 +68,58 @@ func NewOAuthProviders(oauthRepo domain.OAuthRepository, authUsecase domain.Auth
 			RedirectURL:   getEnv("LINE_CALLBACK_URL", "http://localhost:8080/auth/line/callback"),
 			Scopes:        strings.Split(getEnv("LINE_SCOPES", "profile"), ","),
 		}
-		lineAuth := usecase.NewLineAuthUsecase(config, oauthRepo, authUsecase, stateManager)
+		lineAuth := usecase.NewLineAuthUsecase(config)
 		providers["line"] = lineAuth
 		log.Printf("LINE OAuth provider initialized")
 	} else {
 		log.Printf("LINE Channel ID not found, skipping LINE OAuth initialization")
 	}
 
+	// GitHub認証を追加
+	githubClientID := getEnv("GITHUB_CLIENT_ID", "")
+	log.Printf("GitHub Client ID: %s", githubClientID)
+
+	if githubClientID != "" {
+		config := domain.GitHubAuthConfig{
+			ClientID:             githubClientID,
+			ClientSecret:         getEnv("GITHUB_CLIENT_SECRET", ""),
+			RedirectURL:          getEnv("GITHUB_REDIRECT_URL", "http://localhost:8080/auth/github/callback"),
+			Scopes:               []string{"read:user", "user:email"},
+			BaseURL:              getEnv("GITHUB_BASE_URL", "https://github.com"),
+			APIBaseURL:           getEnv("GITHUB_API_BASE_URL", "https://api.github.com"),
+			AllowedOrganizations: splitList(getEnv("GITHUB_ALLOWED_ORGS", "")),
+		}
+		// 非公開のメンバーシップを確認するにはread:orgスコープが必要
+		if len(config.AllowedOrganizations) > 0 {
+			config.Scopes = append(config.Scopes, "read:org")
+		}
+		githubAuth := usecase.NewGitHubAuthUsecase(config)
+		providers["github"] = githubAuth
+		log.Printf("GitHub OAuth provider initialized")
+	} else {
+		log.Printf("GitHub Client ID not found, skipping GitHub OAuth initialization")
+	}
+
+	// 汎用OpenID Connect プロバイダーを追加（OIDC_PROVIDERS=keycloak,okta のように列挙）
+	for _, name := range splitList(getEnv("OIDC_PROVIDERS", "")) {
+		if !oidcProviderNamePattern.MatchString(name) {
+			log.Printf("Invalid OIDC provider name %q, skipping", name)
+			continue
+		}
+		if _, exists := providers[name]; exists {
+			log.Printf("OAuth provider %s is already registered, skipping OIDC provider", name)
+			continue
+		}
+
+		config, ok := newGenericOIDCConfig(name)
+		if !ok {
+			log.Printf("OIDC provider %s is missing issuer or client ID, skipping", name)
+			continue
+		}
+		providers[name] = usecase.NewGenericOIDCUsecase(config)
+		log.Printf("OIDC provider %s initialized (%s)", name, config.Issuer)
+	}
+
 	log.Printf("Total OAuth providers initialized: %d", len(providers))
 	for providerName := range providers {
 		log.Printf("Provider: %s", providerName)
@@ -61,3 +127,32 @@ func NewOAuthProviders(oauthRepo domain.OAuthRepository, authUsecase domain.Auth
 
 	return providers
 }
+
+// newGenericOIDCConfig OIDC_PROVIDER_{NAME}_* の環境変数から汎用OIDCプロバイダーの設定を作成
+func newGenericOIDCConfig(name string) (domain.GenericOIDCConfig, bool) {
+	prefix := "OIDC_PROVIDER_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	config := domain.GenericOIDCConfig{
+		Name:         name,
+		Issuer:       getEnv(prefix+"ISSUER", ""),
+		ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+		ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+		RedirectURL:  getEnv(prefix+"REDIRECT_URL", getEnv("APP_BASE_URL", "http://localhost:8080")+"/auth/"+name+"/callback"),
+		Scopes:       splitList(getEnv(prefix+"SCOPES", "openid,email,profile")),
+		Claims: domain.OIDCClaimMapping{
+			Email:         getEnv(prefix+"CLAIM_EMAIL", "email"),
+			EmailVerified: getEnv(prefix+"CLAIM_EMAIL_VERIFIED", "email_verified"),
+			Name:          getEnv(prefix+"CLAIM_NAME", "name"),
+			Picture:       getEnv(prefix+"CLAIM_PICTURE", "picture"),
+		},
+	}
+	if config.Issuer == "" || config.ClientID == "" {
+		return config, false
+	}
+
+	// IDトークンを要求するため、openidスコープは常に含める
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+
+	return config, true
+}
@@ -0,0 +1,97 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+// NewStateManager 環境変数 OAUTH_STATE_STORE（postgres / memory）に応じたstateの保存先を作成
+// どちらの実装でも期限切れのstateを定期的に削除する
+func NewStateManager(db *sql.DB) domain.StateManager {
+	var store domain.StateManager
+	switch getEnv("OAUTH_STATE_STORE", "postgres") {
+	case "memory":
+		store = NewMemoryStateManager()
+	default:
+		store = repository.NewOAuthStateRepository(db)
+	}
+
+	go pruneOAuthStates(store, 5*time.Minute)
+
+	return store
+}
+
+// NewOAuthStateUsecase stateの発行とブラウザの確認のユースケースを作成（クッキーのトークンの設定はAuthUsecaseと共通）
+func NewOAuthStateUsecase(stateManager domain.StateManager, keyManager domain.KeyManager) domain.OAuthStateUsecase {
+	// 認可リクエストの開始からコールバックまでの有効期限（デフォルト: 10分）
+	stateMinutes, _ := strconv.Atoi(getEnv("OAUTH_STATE_MINUTES", "10"))
+
+	return usecase.NewOAuthStateUsecase(stateManager, keyManager, newJWTConfig(), time.Duration(stateMinutes)*time.Minute)
+}
+
+func pruneOAuthStates(store domain.StateManager, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := store.Prune(); err != nil {
+			log.Printf("Failed to prune OAuth states: %v", err)
+		}
+	}
+}
+
+// MemoryStateManager stateのインメモリ実装（単一インスタンス・テスト用）
+type MemoryStateManager struct {
+	states map[string]*domain.OAuthState
+	mutex  sync.Mutex
+}
+
+func NewMemoryStateManager() domain.StateManager {
+	return &MemoryStateManager{
+		states: make(map[string]*domain.OAuthState),
+	}
+}
+
+func (sm *MemoryStateManager) SaveState(state *domain.OAuthState) error {
+	stored := *state
+
+	sm.mutex.Lock()
+	sm.states[state.State] = &stored
+	sm.mutex.Unlock()
+
+	return nil
+}
+
+func (sm *MemoryStateManager) ConsumeState(state string) (*domain.OAuthState, error) {
+	// 使用済みのstateを削除（同じstateは一度だけ使用可能）
+	sm.mutex.Lock()
+	oauthState, exists := sm.states[state]
+	delete(sm.states, state)
+	sm.mutex.Unlock()
+
+	if !exists || !time.Now().Before(oauthState.ExpiresAt) {
+		return nil, nil
+	}
+
+	return oauthState, nil
+}
+
+func (sm *MemoryStateManager) Prune() error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	now := time.Now()
+	for state, oauthState := range sm.states {
+		if !now.Before(oauthState.ExpiresAt) {
+			delete(sm.states, state)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package infrastructure
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/usecase"
+)
+
+// newTestOAuthStateUsecase インメモリのstateの保存先を使うOAuthStateUsecaseを作成
+func newTestOAuthStateUsecase(t *testing.T) (domain.OAuthStateUsecase, *MemoryStateManager) {
+	t.Helper()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signingKey, err := usecase.NewSigningKey("test-key", privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signing key: %v", err)
+	}
+	keyManager, err := usecase.NewKeyManager("test-key", []*domain.SigningKey{signingKey})
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+
+	stateManager := NewMemoryStateManager().(*MemoryStateManager)
+	jwtConfig := domain.JWTConfig{Issuer: "http://localhost:8080"}
+	return usecase.NewOAuthStateUsecase(stateManager, keyManager, jwtConfig, 10*time.Minute), stateManager
+}
+
+func beginTestOAuthState(t *testing.T, stateUsecase domain.OAuthStateUsecase, intent domain.OAuthIntent) (*domain.OAuthState, string) {
+	t.Helper()
+
+	oauthState, cookie, err := stateUsecase.Begin(intent)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	return oauthState, cookie
+}
+
+func TestOAuthStateCompleteSingleUse(t *testing.T) {
+	stateUsecase, _ := newTestOAuthStateUsecase(t)
+	began, cookie := beginTestOAuthState(t, stateUsecase, domain.OAuthIntent{LinkUserID: 42})
+
+	oauthState, err := stateUsecase.Complete(began.State, cookie)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if oauthState.State != began.State || oauthState.CodeVerifier != began.CodeVerifier || oauthState.Nonce != began.Nonce {
+		t.Errorf("Complete() = %+v, want the state saved by Begin %+v", oauthState, began)
+	}
+	if oauthState.LinkUserID != 42 {
+		t.Errorf("Complete() intent = %+v, want LinkUserID 42", oauthState.OAuthIntent)
+	}
+
+	// 同じstateは一度だけ使用できる（コールバックの再送・リプレイを拒否する）
+	if _, err := stateUsecase.Complete(began.State, cookie); !errors.Is(err, domain.ErrInvalidOAuthState) {
+		t.Fatalf("second Complete() error = %v, want ErrInvalidOAuthState", err)
+	}
+}
+
+func TestOAuthStateCompleteBrowserMismatch(t *testing.T) {
+	stateUsecase, stateManager := newTestOAuthStateUsecase(t)
+	// 攻撃者が自分のブラウザで開始した認可リクエストと、被害者のブラウザで開始した認可リクエスト
+	attackerState, attackerCookie := beginTestOAuthState(t, stateUsecase, domain.OAuthIntent{})
+	victimState, victimCookie := beginTestOAuthState(t, stateUsecase, domain.OAuthIntent{})
+
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{name: "cookie from another browser", cookie: victimCookie},
+		{name: "missing cookie", cookie: ""},
+		{name: "tampered cookie", cookie: attackerCookie[:len(attackerCookie)-2] + "xx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := stateUsecase.Complete(attackerState.State, tt.cookie); !errors.Is(err, domain.ErrOAuthStateBrowserMismatch) {
+				t.Fatalf("Complete() error = %v, want ErrOAuthStateBrowserMismatch", err)
+			}
+		})
+	}
+
+	// 不一致の場合はstateを消費しないため、開始したブラウザでは引き続きログインできる
+	if _, exists := stateManager.states[attackerState.State]; !exists {
+		t.Fatal("state was consumed by a callback from another browser")
+	}
+	if _, err := stateUsecase.Complete(attackerState.State, attackerCookie); err != nil {
+		t.Fatalf("Complete() from the starting browser error = %v", err)
+	}
+	if _, err := stateUsecase.Complete(victimState.State, victimCookie); err != nil {
+		t.Fatalf("Complete() for the other browser error = %v", err)
+	}
+}
+
+func TestOAuthStateCompleteExpired(t *testing.T) {
+	stateUsecase, stateManager := newTestOAuthStateUsecase(t)
+	expired, expiredCookie := beginTestOAuthState(t, stateUsecase, domain.OAuthIntent{})
+	valid, validCookie := beginTestOAuthState(t, stateUsecase, domain.OAuthIntent{})
+
+	// 保存したstateのみ期限切れにする（クッキーのトークンは有効なまま）
+	stateManager.states[expired.State].ExpiresAt = time.Now().Add(-time.Second)
+
+	if _, err := stateUsecase.Complete(expired.State, expiredCookie); !errors.Is(err, domain.ErrInvalidOAuthState) {
+		t.Fatalf("Complete() error = %v, want ErrInvalidOAuthState", err)
+	}
+	if _, exists := stateManager.states[expired.State]; exists {
+		t.Error("expired state was left in the store after Complete()")
+	}
+	if _, err := stateUsecase.Complete(valid.State, validCookie); err != nil {
+		t.Fatalf("Complete() for a valid state error = %v", err)
+	}
+}
+
+func TestOAuthStateCompleteEmptyState(t *testing.T) {
+	stateUsecase, _ := newTestOAuthStateUsecase(t)
+	_, cookie := beginTestOAuthState(t, stateUsecase, domain.OAuthIntent{})
+
+	if _, err := stateUsecase.Complete("", cookie); !errors.Is(err, domain.ErrInvalidOAuthState) {
+		t.Fatalf("Complete() error = %v, want ErrInvalidOAuthState", err)
+	}
+}
+
+func TestMemoryStateManagerPrune(t *testing.T) {
+	stateManager := NewMemoryStateManager().(*MemoryStateManager)
+	now := time.Now()
+	stateManager.SaveState(&domain.OAuthState{State: "expired", ExpiresAt: now.Add(-time.Second)})
+	stateManager.SaveState(&domain.OAuthState{State: "valid", ExpiresAt: now.Add(time.Minute)})
+
+	if err := stateManager.Prune(); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if _, exists := stateManager.states["expired"]; exists {
+		t.Error("Prune() kept an expired state")
+	}
+	if _, exists := stateManager.states["valid"]; !exists {
+		t.Error("Prune() removed a valid state")
+	}
+}
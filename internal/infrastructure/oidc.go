@@ -0,0 +1,29 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+func NewOIDCRepository(db *sql.DB) domain.OIDCRepository {
+	return repository.NewOIDCRepository(db)
+}
+
+// NewOIDCUsecase OpenID Connect プロバイダーのユースケースを作成（発行するトークンの設定はAuthUsecaseと共通）
+func NewOIDCUsecase(oidcRepo domain.OIDCRepository, userRepo domain.UserRepository, revokedTokenStore domain.RevokedTokenStore, keyManager domain.KeyManager) domain.OIDCUsecase {
+	// 認可コードの有効期限（デフォルト: 60秒）
+	codeSeconds, _ := strconv.Atoi(getEnv("OIDC_AUTHORIZATION_CODE_SECONDS", "60"))
+
+	// 同意画面の表示から送信までの有効期限（デフォルト: 10分）
+	consentMinutes, _ := strconv.Atoi(getEnv("OIDC_CONSENT_MINUTES", "10"))
+
+	return usecase.NewOIDCUsecase(oidcRepo, userRepo, revokedTokenStore, keyManager, newJWTConfig(), domain.OIDCConfig{
+		CodeDuration:    time.Duration(codeSeconds) * time.Second,
+		ConsentDuration: time.Duration(consentMinutes) * time.Minute,
+	})
+}
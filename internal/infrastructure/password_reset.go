@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+func NewPasswordResetRepository(db *sql.DB) domain.PasswordResetRepository {
+	return repository.NewPasswordResetRepository(db)
+}
+
+// NewPasswordUsecase 環境変数の設定からパスワードリセット・変更ユースケースを作成
+func NewPasswordUsecase(authRepo domain.AuthRepository, userRepo domain.UserRepository, passwordResetRepo domain.PasswordResetRepository, patRepo domain.PersonalAccessTokenRepository, securityEventRepo domain.SecurityEventRepository, authUsecase domain.AuthUsecase, mailer domain.Mailer, passwordHasher domain.PasswordHasher, loginThrottle domain.LoginThrottle) domain.PasswordUsecase {
+	// リセットリンクの有効期限（デフォルト: 30分）
+	resetMinutes, _ := strconv.Atoi(getEnv("PASSWORD_RESET_MINUTES", "30"))
+
+	config := domain.PasswordResetConfig{
+		BaseURL:       getEnv("APP_BASE_URL", "http://localhost:8080"),
+		TokenDuration: time.Duration(resetMinutes) * time.Minute,
+	}
+
+	return usecase.NewPasswordUsecase(authRepo, userRepo, passwordResetRepo, patRepo, securityEventRepo, authUsecase, mailer, passwordHasher, loginThrottle, config)
+}
@@ -0,0 +1,17 @@
+package infrastructure
+
+import (
+	"database/sql"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+func NewPersonalAccessTokenRepository(db *sql.DB) domain.PersonalAccessTokenRepository {
+	return repository.NewPersonalAccessTokenRepository(db)
+}
+
+func NewPersonalAccessTokenUsecase(tokenRepo domain.PersonalAccessTokenRepository, userRepo domain.UserRepository, rbacRepo domain.RBACRepository) domain.PersonalAccessTokenUsecase {
+	return usecase.NewPersonalAccessTokenUsecase(tokenRepo, userRepo, rbacRepo)
+}
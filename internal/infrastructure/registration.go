@@ -0,0 +1,22 @@
+package infrastructure
+
+import (
+	"strconv"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/usecase"
+)
+
+// NewRegistrationUsecase 環境変数の設定からユーザー登録ユースケースを作成
+func NewRegistrationUsecase(authRepo domain.AuthRepository, userRepo domain.UserRepository, mailer domain.Mailer, passwordHasher domain.PasswordHasher, keyManager domain.KeyManager, loginThrottle domain.LoginThrottle) domain.RegistrationUsecase {
+	// 確認リンクの有効期限（デフォルト: 24時間）
+	verificationHours, _ := strconv.Atoi(getEnv("EMAIL_VERIFICATION_HOURS", "24"))
+
+	config := domain.RegistrationConfig{
+		BaseURL:              getEnv("APP_BASE_URL", "http://localhost:8080"),
+		VerificationDuration: time.Duration(verificationHours) * time.Hour,
+	}
+
+	return usecase.NewRegistrationUsecase(authRepo, userRepo, mailer, passwordHasher, keyManager, loginThrottle, newJWTConfig(), config)
+}
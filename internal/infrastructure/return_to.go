@@ -0,0 +1,21 @@
+package infrastructure
+
+import (
+	"strconv"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/usecase"
+)
+
+// NewReturnToUsecase 環境変数の許可リストからログイン後の戻り先のユースケースを作成（クッキーのトークンの設定はAuthUsecaseと共通）
+func NewReturnToUsecase(keyManager domain.KeyManager) domain.ReturnToUsecase {
+	// 戻り先を保存してからログインを完了するまでの有効期限（デフォルト: 10分）
+	returnToMinutes, _ := strconv.Atoi(getEnv("RETURN_TO_MINUTES", "10"))
+
+	return usecase.NewReturnToUsecase(keyManager, newJWTConfig(), domain.ReturnToConfig{
+		AllowedPaths: splitList(getEnv("RETURN_TO_ALLOWED_PATHS", "/protected,/oauth/authorize,/link-account")),
+		AllowedHosts: splitList(getEnv("RETURN_TO_ALLOWED_HOSTS", "")),
+		Duration:     time.Duration(returnToMinutes) * time.Minute,
+	})
+}
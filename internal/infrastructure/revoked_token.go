@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+)
+
+// NewRevokedTokenStore 環境変数 TOKEN_REVOCATION_STORE（postgres / memory）に応じた失効リストを作成
+// どちらの実装でも期限切れエントリを定期的に削除する
+func NewRevokedTokenStore(db *sql.DB) domain.RevokedTokenStore {
+	var store domain.RevokedTokenStore
+	switch getEnv("TOKEN_REVOCATION_STORE", "postgres") {
+	case "memory":
+		store = NewMemoryRevokedTokenStore()
+	default:
+		store = repository.NewRevokedTokenRepository(db)
+	}
+
+	go pruneRevokedTokens(store, 5*time.Minute)
+
+	return store
+}
+
+func pruneRevokedTokens(store domain.RevokedTokenStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := store.Prune(); err != nil {
+			log.Printf("Failed to prune revoked tokens: %v", err)
+		}
+	}
+}
+
+// MemoryRevokedTokenStore 失効リストのインメモリ実装（単一インスタンス・テスト用）
+type MemoryRevokedTokenStore struct {
+	tokens map[string]time.Time
+	mutex  sync.RWMutex
+}
+
+func NewMemoryRevokedTokenStore() domain.RevokedTokenStore {
+	return &MemoryRevokedTokenStore{
+		tokens: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryRevokedTokenStore) Revoke(jti string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if current, exists := s.tokens[jti]; !exists || expiresAt.After(current) {
+		s.tokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (s *MemoryRevokedTokenStore) IsRevoked(jti string) (bool, error) {
+	s.mutex.RLock()
+	expiresAt, exists := s.tokens[jti]
+	s.mutex.RUnlock()
+
+	return exists && time.Now().Before(expiresAt), nil
+}
+
+func (s *MemoryRevokedTokenStore) Prune() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for jti, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package infrastructure
+
+import (
+	"database/sql"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/repository"
+	"go-echo-demo/internal/usecase"
+)
+
+func NewServiceAccountRepository(db *sql.DB) domain.ServiceAccountRepository {
+	return repository.NewServiceAccountRepository(db)
+}
+
+func NewServiceAccountUsecase(accountRepo domain.ServiceAccountRepository, authUsecase domain.AuthUsecase) domain.ServiceAccountUsecase {
+	return usecase.NewServiceAccountUsecase(accountRepo, authUsecase)
+}
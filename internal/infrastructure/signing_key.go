@@ -0,0 +1,127 @@
+package infrastructure
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go-echo-demo/internal/domain"
+	"go-echo-demo/internal/usecase"
+)
+
+// NewKeyManager JWT署名鍵を読み込んで鍵マネージャーを作成
+//
+// JWT_KEYS_DIR 配下の "<kid>.pem" をすべて読み込み、JWT_ACTIVE_KID の鍵で署名する。
+// それ以外の鍵（公開鍵のみのPEMを含む）は退役済みの検証専用鍵として扱う。
+// 鍵が1つも見つからない場合は起動ごとに一時的なES256鍵を生成する（開発用）。
+func NewKeyManager() domain.KeyManager {
+	keysDir := getEnv("JWT_KEYS_DIR", "config/keys")
+	activeKID := getEnv("JWT_ACTIVE_KID", "")
+
+	keys, err := loadSigningKeys(keysDir)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(keys) == 0 {
+		log.Printf("Warning: JWT署名鍵が %s に見つかりません。一時的なES256鍵を生成します（再起動でトークンは無効になります）", keysDir)
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		key, err := usecase.NewSigningKey("ephemeral", privateKey)
+		if err != nil {
+			panic(err)
+		}
+		keys = append(keys, key)
+		activeKID = key.KID
+	}
+
+	// 署名可能な鍵が1つだけの場合はJWT_ACTIVE_KIDの指定を省略できる
+	if activeKID == "" {
+		for _, key := range keys {
+			if key.PrivateKey != nil {
+				if activeKID != "" {
+					panic("JWT_ACTIVE_KID must be set when multiple private keys are configured")
+				}
+				activeKID = key.KID
+			}
+		}
+	}
+
+	keyManager, err := usecase.NewKeyManager(activeKID, keys)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, key := range keys {
+		role := "verify-only"
+		if key.KID == activeKID {
+			role = "active"
+		}
+		log.Printf("JWT signing key loaded: kid=%s alg=%s (%s)", key.KID, key.Algorithm, role)
+	}
+
+	return keyManager
+}
+
+// loadSigningKeys ディレクトリ内のPEMファイルから署名鍵を読み込む（ファイル名がkidになる）
+func loadSigningKeys(dir string) ([]*domain.SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var keys []*domain.SigningKey
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+		}
+
+		rawKey, err := parsePEMKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+		}
+
+		key, err := usecase.NewSigningKey(kid, rawKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// parsePEMKey PKCS#8 / PKCS#1 / SEC1 の秘密鍵、またはPKIXの公開鍵を読み込む
+func parsePEMKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+}
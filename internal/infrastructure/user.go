@@ -16,7 +16,7 @@ func NewUserRepository(db *sql.DB) repository.UserRepository {
 }
 
 func (r *userRepository) FindAll() ([]domain.User, error) {
-	rows, err := r.db.Query("SELECT id, name, email FROM users")
+	rows, err := r.db.Query("SELECT id, name, COALESCE(email, '') FROM users")
 	if err != nil {
 		return nil, err
 	}
@@ -33,9 +33,14 @@ func (r *userRepository) FindAll() ([]domain.User, error) {
 	return users, nil
 }
 
+// FindByID IDでユーザーを取得（存在しない場合はnil）
 func (r *userRepository) FindByID(id int) (*domain.User, error) {
 	var user domain.User
-	err := r.db.QueryRow("SELECT id, name, email FROM users WHERE id = $1", id).Scan(&user.ID, &user.Name, &user.Email)
+	err := r.db.QueryRow("SELECT id, name, COALESCE(email, ''), email_verified_at FROM users WHERE id = $1", id).
+		Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -43,11 +48,13 @@ func (r *userRepository) FindByID(id int) (*domain.User, error) {
 }
 
 func (r *userRepository) Create(user *domain.User) error {
-	return r.db.QueryRow("INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id", user.Name, user.Email).Scan(&user.ID)
+	// パスワードはユースケースでハッシュ化済みの値のみを受け取る
+	// メールアドレスのないユーザー（メールアドレスを返さないプロバイダーで作成）はNULLで保存する
+	return r.db.QueryRow("INSERT INTO users (name, email, password) VALUES ($1, NULLIF($2, ''), NULLIF($3, '')) RETURNING id", user.Name, user.Email, user.Password).Scan(&user.ID)
 }
 
 func (r *userRepository) Update(user *domain.User) error {
-	result, err := r.db.Exec("UPDATE users SET name = $1, email = $2 WHERE id = $3", user.Name, user.Email, user.ID)
+	result, err := r.db.Exec("UPDATE users SET name = $1, email = NULLIF($2, '') WHERE id = $3", user.Name, user.Email, user.ID)
 	if err != nil {
 		return err
 	}
@@ -77,7 +84,7 @@ func (r *userRepository) GetByID(id int) (*domain.User, error) {
 
 func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 	var user domain.User
-	err := r.db.QueryRow("SELECT id, name, email, password FROM users WHERE email = $1", email).
+	err := r.db.QueryRow("SELECT id, name, email, COALESCE(password, '') FROM users WHERE email = $1", email).
 		Scan(&user.ID, &user.Name, &user.Email, &user.Password)
 	if err != nil {
 		return nil, err
@@ -85,9 +92,9 @@ func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 	return &user, nil
 }
 
-func NewUserUsecase(db *sql.DB) usecase.UserUsecase {
+func NewUserUsecase(db *sql.DB, passwordHasher domain.PasswordHasher) usecase.UserUsecase {
 	repo := NewUserRepository(db)
-	return usecase.NewUserUsecase(repo)
+	return usecase.NewUserUsecase(repo, passwordHasher)
 }
 
 // ... 実装は後で追加 ...
@@ -12,25 +12,19 @@ import (
 func CasbinRBACMiddleware(casbinUsecase domain.CasbinRBACUsecase, resource, action string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// JWTからユーザーIDを取得
-			userIDStr := c.Get("user_id")
-			if userIDStr == nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "認証が必要です")
+			// JWTからCasbinのサブジェクトを取得
+			user, err := casbinSubjectFromContext(c)
+			if err != nil {
+				return err
 			}
 
-			// ユーザーIDを文字列に変換
-			var user string
-			switch v := userIDStr.(type) {
-			case int:
-				user = string(rune(v)) // 一時的な変換
-			case string:
-				user = v
-			default:
-				return echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
+			// パーソナルアクセストークンの場合は、トークンのスコープとユーザーの権限の両方を満たす必要がある
+			if !personalAccessTokenAllows(c, resource, action) {
+				return echo.NewHTTPError(http.StatusForbidden, "トークンのスコープに含まれない操作です")
 			}
 
 			// 権限チェック
-			err := casbinUsecase.CheckPermission(user, resource, action)
+			err = casbinUsecase.CheckPermission(user, resource, action)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusForbidden, "権限がありません")
 			}
@@ -44,21 +38,15 @@ func CasbinRBACMiddleware(casbinUsecase domain.CasbinRBACUsecase, resource, acti
 func CasbinRequireRole(casbinUsecase domain.CasbinRBACUsecase, roleName string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// JWTからユーザーIDを取得
-			userIDStr := c.Get("user_id")
-			if userIDStr == nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "認証が必要です")
+			// JWTからCasbinのサブジェクトを取得
+			user, err := casbinSubjectFromContext(c)
+			if err != nil {
+				return err
 			}
 
-			// ユーザーIDを文字列に変換
-			var user string
-			switch v := userIDStr.(type) {
-			case int:
-				user = string(rune(v)) // 一時的な変換
-			case string:
-				user = v
-			default:
-				return echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
+			// スコープを限定したトークンでロール全体の権限を使わせない
+			if scopedPersonalAccessToken(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "スコープを限定したトークンでは使用できません")
 			}
 
 			// ロールチェック
@@ -80,21 +68,15 @@ func CasbinRequireRole(casbinUsecase domain.CasbinRBACUsecase, roleName string)
 func CasbinRequireAnyRole(casbinUsecase domain.CasbinRBACUsecase, roleNames ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// JWTからユーザーIDを取得
-			userIDStr := c.Get("user_id")
-			if userIDStr == nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "認証が必要です")
+			// JWTからCasbinのサブジェクトを取得
+			user, err := casbinSubjectFromContext(c)
+			if err != nil {
+				return err
 			}
 
-			// ユーザーIDを文字列に変換
-			var user string
-			switch v := userIDStr.(type) {
-			case int:
-				user = string(rune(v)) // 一時的な変換
-			case string:
-				user = v
-			default:
-				return echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
+			// スコープを限定したトークンでロール全体の権限を使わせない
+			if scopedPersonalAccessToken(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "スコープを限定したトークンでは使用できません")
 			}
 
 			// いずれかのロールを持っているかチェック
@@ -113,6 +95,30 @@ func CasbinRequireAnyRole(casbinUsecase domain.CasbinRBACUsecase, roleNames ...s
 	}
 }
 
+// casbinSubjectFromContext 認証済みの主体をCasbinのサブジェクトに変換
+// サービスアカウントの場合はトークンのsub（"service:<client_id>"）を使用する
+func casbinSubjectFromContext(c echo.Context) (string, error) {
+	if account := serviceAccountFromContext(c); account != nil {
+		return account.Subject, nil
+	}
+
+	// JWTからユーザーIDを取得
+	userIDStr := c.Get("user_id")
+	if userIDStr == nil {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, "認証が必要です")
+	}
+
+	// ユーザーIDを文字列に変換
+	switch v := userIDStr.(type) {
+	case int:
+		return string(rune(v)), nil // 一時的な変換
+	case string:
+		return v, nil
+	default:
+		return "", echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
+	}
+}
+
 // CasbinJWTAuthWithRBAC JWT認証とCasbin RBAC権限チェックを組み合わせたミドルウェア
 func CasbinJWTAuthWithRBAC(authUsecase domain.AuthUsecase, casbinUsecase domain.CasbinRBACUsecase, resource, action string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// cookiePolicyKey クッキーポリシーを保存するコンテキストのキー
+const cookiePolicyKey = "cookie_policy"
+
+// CookieSpec 用途ごとのクッキーの名前・パス・SameSite属性
+type CookieSpec struct {
+	Name string
+	Path string
+	// 用途に必要なSameSite属性（0の場合はポリシーに設定したログイン状態の値を使う）
+	SameSite http.SameSite
+}
+
+var (
+	// AccessTokenCookie アクセストークンを保存するクッキー（画面遷移でも送信する）
+	AccessTokenCookie = CookieSpec{Name: "token", Path: "/"}
+	// RefreshTokenCookie リフレッシュトークンを保存するクッキー（リフレッシュエンドポイントのみで使用）
+	RefreshTokenCookie = CookieSpec{Name: "refresh_token", Path: "/api/auth/refresh", SameSite: http.SameSiteStrictMode}
+)
+
+// CookiePolicy 認証に使うクッキーの属性を環境ごとの設定で揃える
+// クッキーはすべてHttpOnlyで書き込み、Secure・SameSite・Domain・名前のプレフィックスはこのポリシーで決める
+type CookiePolicy struct {
+	config   domain.CookieConfig
+	sameSite http.SameSite
+}
+
+func NewCookiePolicy(config domain.CookieConfig) *CookiePolicy {
+	policy := &CookiePolicy{config: config, sameSite: http.SameSiteLaxMode}
+	switch config.SameSite {
+	case domain.CookieSameSiteStrict:
+		policy.sameSite = http.SameSiteStrictMode
+	case domain.CookieSameSiteNone:
+		policy.sameSite = http.SameSiteNoneMode
+	}
+	// __Host- / __Secure- とSameSite=NoneのクッキーはSecureでなければブラウザに拒否される
+	if config.HostPrefix || policy.sameSite == http.SameSiteNoneMode {
+		policy.config.Secure = true
+	}
+	return policy
+}
+
+// defaultCookiePolicy ポリシーが設定されていない場合に使う開発環境向けの設定
+var defaultCookiePolicy = NewCookiePolicy(domain.CookieConfig{
+	SameSite:           domain.CookieSameSiteLax,
+	AccessTokenMaxAge:  15 * time.Minute,
+	RefreshTokenMaxAge: 7 * 24 * time.Hour,
+	MFAChallengeMaxAge: 5 * time.Minute,
+})
+
+// CookiePolicyMiddleware リクエストのコンテキストにクッキーポリシーを設定するミドルウェア
+// 認証ミドルウェアとハンドラーは CookiesFromContext で同じポリシーを参照する
+func CookiePolicyMiddleware(policy *CookiePolicy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(cookiePolicyKey, policy)
+			return next(c)
+		}
+	}
+}
+
+// CookiesFromContext コンテキストのクッキーポリシーを取得（未設定の場合は開発環境向けの設定）
+func CookiesFromContext(c echo.Context) *CookiePolicy {
+	if policy, ok := c.Get(cookiePolicyKey).(*CookiePolicy); ok {
+		return policy
+	}
+	return defaultCookiePolicy
+}
+
+// SetTokens ログイン状態（アクセストークンとリフレッシュトークン）をクッキーに保存
+// 有効期限はJWTの設定に合わせる
+func (p *CookiePolicy) SetTokens(c echo.Context, accessToken, refreshToken string) {
+	p.Set(c, AccessTokenCookie, accessToken, p.config.AccessTokenMaxAge)
+	if refreshToken != "" {
+		p.Set(c, RefreshTokenCookie, refreshToken, p.config.RefreshTokenMaxAge)
+	}
+}
+
+// ClearTokens ログイン状態のクッキーを削除
+func (p *CookiePolicy) ClearTokens(c echo.Context) {
+	p.Clear(c, AccessTokenCookie)
+	p.Clear(c, RefreshTokenCookie)
+}
+
+// MFAChallengeMaxAge 二要素認証チャレンジトークンのクッキーの有効期限
+func (p *CookiePolicy) MFAChallengeMaxAge() time.Duration {
+	return p.config.MFAChallengeMaxAge
+}
+
+// Set クッキーを保存
+func (p *CookiePolicy) Set(c echo.Context, spec CookieSpec, value string, maxAge time.Duration) {
+	cookie := p.cookie(spec)
+	cookie.Value = value
+	cookie.MaxAge = int(maxAge.Seconds())
+	if cookie.MaxAge <= 0 {
+		// 有効期限を過ぎた値は保存しない（MaxAge=0は有効期限なしのセッションクッキーになるため）
+		cookie.Value = ""
+		cookie.MaxAge = -1
+	}
+	c.SetCookie(cookie)
+}
+
+// Clear クッキーを削除（保存時と同じ名前・パス・Domainで上書きする）
+func (p *CookiePolicy) Clear(c echo.Context, spec CookieSpec) {
+	cookie := p.cookie(spec)
+	cookie.MaxAge = -1
+	c.SetCookie(cookie)
+}
+
+// Get クッキーの値を取得（ない場合は空文字）
+// プレフィックスを付ける設定では、プレフィックスのない同名のクッキーは読まない（サブドメインから書き込まれた値を受け付けないため）
+func (p *CookiePolicy) Get(c echo.Context, spec CookieSpec) string {
+	cookie, err := c.Cookie(p.name(spec))
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (p *CookiePolicy) cookie(spec CookieSpec) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     p.name(spec),
+		Path:     spec.Path,
+		HttpOnly: true, // XSS対策のためJavaScriptからアクセス不可にする
+		Secure:   p.config.Secure,
+		SameSite: p.sameSiteFor(spec),
+	}
+	// __Host- / __Secure- のクッキーは発行したホストのみに送信する
+	if !p.config.HostPrefix {
+		cookie.Domain = p.config.Domain
+	}
+	return cookie
+}
+
+// name プレフィックスを付けた名前（Path=/ のクッキーは __Host-、それ以外は __Secure-）
+func (p *CookiePolicy) name(spec CookieSpec) string {
+	if !p.config.HostPrefix {
+		return spec.Name
+	}
+	if spec.Path == "/" {
+		return "__Host-" + spec.Name
+	}
+	return "__Secure-" + spec.Name
+}
+
+// sameSiteFor 別サイトのフロントエンドから利用する設定（none）ではすべてのクッキーをSameSite=Noneにする
+func (p *CookiePolicy) sameSiteFor(spec CookieSpec) http.SameSite {
+	if p.sameSite == http.SameSiteNoneMode || spec.SameSite == 0 {
+		return p.sameSite
+	}
+	return spec.SameSite
+}
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestContext レスポンスのクッキーを確認できるechoのコンテキストを作成
+func newTestContext(cookies ...*http.Cookie) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+// responseCookies 名前ごとのSet-Cookie
+func responseCookies(rec *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+func testCookieConfig() domain.CookieConfig {
+	return domain.CookieConfig{
+		SameSite:           domain.CookieSameSiteLax,
+		AccessTokenMaxAge:  15 * time.Minute,
+		RefreshTokenMaxAge: 7 * 24 * time.Hour,
+		MFAChallengeMaxAge: 5 * time.Minute,
+	}
+}
+
+func TestCookiePolicySetTokens(t *testing.T) {
+	type wantCookie struct {
+		name     string
+		path     string
+		domain   string
+		secure   bool
+		sameSite http.SameSite
+		maxAge   int
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(config *domain.CookieConfig)
+		wantAccess  wantCookie
+		wantRefresh wantCookie
+	}{
+		{
+			name:        "development",
+			modify:      func(config *domain.CookieConfig) {},
+			wantAccess:  wantCookie{name: "token", path: "/", sameSite: http.SameSiteLaxMode, maxAge: 900},
+			wantRefresh: wantCookie{name: "refresh_token", path: "/api/auth/refresh", sameSite: http.SameSiteStrictMode, maxAge: 604800},
+		},
+		{
+			name: "shared parent domain",
+			modify: func(config *domain.CookieConfig) {
+				config.Secure = true
+				config.Domain = "example.com"
+			},
+			wantAccess:  wantCookie{name: "token", path: "/", domain: "example.com", secure: true, sameSite: http.SameSiteLaxMode, maxAge: 900},
+			wantRefresh: wantCookie{name: "refresh_token", path: "/api/auth/refresh", domain: "example.com", secure: true, sameSite: http.SameSiteStrictMode, maxAge: 604800},
+		},
+		{
+			// プレフィックスを付ける場合はSecureを強制し、Domainは指定しない
+			name: "host prefix",
+			modify: func(config *domain.CookieConfig) {
+				config.HostPrefix = true
+				config.Domain = "example.com"
+			},
+			wantAccess:  wantCookie{name: "__Host-token", path: "/", secure: true, sameSite: http.SameSiteLaxMode, maxAge: 900},
+			wantRefresh: wantCookie{name: "__Secure-refresh_token", path: "/api/auth/refresh", secure: true, sameSite: http.SameSiteStrictMode, maxAge: 604800},
+		},
+		{
+			name:        "strict",
+			modify:      func(config *domain.CookieConfig) { config.SameSite = domain.CookieSameSiteStrict },
+			wantAccess:  wantCookie{name: "token", path: "/", sameSite: http.SameSiteStrictMode, maxAge: 900},
+			wantRefresh: wantCookie{name: "refresh_token", path: "/api/auth/refresh", sameSite: http.SameSiteStrictMode, maxAge: 604800},
+		},
+		{
+			// 別サイトのフロントエンドから使う場合はすべてSameSite=NoneでSecureを強制する
+			name:        "cross-site frontend",
+			modify:      func(config *domain.CookieConfig) { config.SameSite = domain.CookieSameSiteNone },
+			wantAccess:  wantCookie{name: "token", path: "/", secure: true, sameSite: http.SameSiteNoneMode, maxAge: 900},
+			wantRefresh: wantCookie{name: "refresh_token", path: "/api/auth/refresh", secure: true, sameSite: http.SameSiteNoneMode, maxAge: 604800},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testCookieConfig()
+			tt.modify(&config)
+			c, rec := newTestContext()
+
+			NewCookiePolicy(config).SetTokens(c, "access", "refresh")
+
+			cookies := responseCookies(rec)
+			for _, want := range []wantCookie{tt.wantAccess, tt.wantRefresh} {
+				cookie, ok := cookies[want.name]
+				if !ok {
+					t.Errorf("cookie %q not set, got %v", want.name, cookies)
+					continue
+				}
+				if !cookie.HttpOnly {
+					t.Errorf("%s HttpOnly = false, want true", want.name)
+				}
+				got := wantCookie{
+					name:     cookie.Name,
+					path:     cookie.Path,
+					domain:   cookie.Domain,
+					secure:   cookie.Secure,
+					sameSite: cookie.SameSite,
+					maxAge:   cookie.MaxAge,
+				}
+				if got != want {
+					t.Errorf("cookie = %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCookiePolicySetTokensWithoutRefreshToken(t *testing.T) {
+	c, rec := newTestContext()
+
+	NewCookiePolicy(testCookieConfig()).SetTokens(c, "access", "")
+
+	cookies := responseCookies(rec)
+	if _, ok := cookies["refresh_token"]; ok {
+		t.Error("refresh_token cookie set, want only the access token")
+	}
+	if cookies["token"] == nil || cookies["token"].Value != "access" {
+		t.Errorf("token cookie = %v, want %q", cookies["token"], "access")
+	}
+}
+
+func TestCookiePolicySetExpired(t *testing.T) {
+	c, rec := newTestContext()
+
+	// MaxAge=0はセッションクッキーになるため、期限切れの値は削除として書き込む
+	NewCookiePolicy(testCookieConfig()).Set(c, AccessTokenCookie, "access", 0)
+
+	cookie := responseCookies(rec)["token"]
+	if cookie == nil || cookie.Value != "" || cookie.MaxAge >= 0 {
+		t.Errorf("token cookie = %v, want a deletion", cookie)
+	}
+}
+
+func TestCookiePolicyClearTokens(t *testing.T) {
+	config := testCookieConfig()
+	config.Domain = "example.com"
+	c, rec := newTestContext()
+
+	NewCookiePolicy(config).ClearTokens(c)
+
+	cookies := responseCookies(rec)
+	for _, spec := range []CookieSpec{AccessTokenCookie, RefreshTokenCookie} {
+		cookie := cookies[spec.Name]
+		// 保存時と同じパス・Domainでなければブラウザのクッキーを上書きできない
+		if cookie == nil || cookie.MaxAge >= 0 || cookie.Path != spec.Path || cookie.Domain != "example.com" {
+			t.Errorf("%s cookie = %v, want a deletion on path %s and domain example.com", spec.Name, cookie, spec.Path)
+		}
+	}
+}
+
+func TestCookiePolicyGet(t *testing.T) {
+	tests := []struct {
+		name       string
+		hostPrefix bool
+		cookies    []*http.Cookie
+		want       string
+	}{
+		{name: "plain name", cookies: []*http.Cookie{{Name: "token", Value: "access"}}, want: "access"},
+		{name: "missing", want: ""},
+		{name: "host prefix", hostPrefix: true, cookies: []*http.Cookie{{Name: "__Host-token", Value: "access"}}, want: "access"},
+		{
+			// サブドメインから書き込まれたプレフィックスのない値は読まない
+			name:       "host prefix ignores unprefixed cookie",
+			hostPrefix: true,
+			cookies:    []*http.Cookie{{Name: "token", Value: "injected"}},
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testCookieConfig()
+			config.HostPrefix = tt.hostPrefix
+			c, _ := newTestContext(tt.cookies...)
+
+			if got := NewCookiePolicy(config).Get(c, AccessTokenCookie); got != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookiesFromContext(t *testing.T) {
+	c, _ := newTestContext()
+	if got := CookiesFromContext(c); got != defaultCookiePolicy {
+		t.Errorf("CookiesFromContext() without middleware = %p, want the default policy", got)
+	}
+
+	policy := NewCookiePolicy(testCookieConfig())
+	handler := CookiePolicyMiddleware(policy)(func(c echo.Context) error {
+		if got := CookiesFromContext(c); got != policy {
+			t.Errorf("CookiesFromContext() = %p, want the configured policy %p", got, policy)
+		}
+		return nil
+	})
+	if err := handler(c); err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"go-echo-demo/internal/domain"
@@ -11,7 +12,23 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// JWTAuthConfig JWT認証ミドルウェアの設定
+type JWTAuthConfig struct {
+	// 受け付けるトークンのaud（省略時は自サービスのaud）
+	// ルートグループごとに、そのグループを利用するサービス向けのトークンのみを受け付けられる
+	Audiences []string
+	// サービスアカウントのトークンを受け付けるか
+	// サービスアカウントにはユーザーIDがないため、user_idを前提としないルートでのみ有効にする
+	AllowServiceAccounts bool
+}
+
+// JWTAuth 自サービス向けのアクセストークンを要求するJWT認証ミドルウェア
 func JWTAuth(authUsecase domain.AuthUsecase) echo.MiddlewareFunc {
+	return JWTAuthWithConfig(authUsecase, JWTAuthConfig{})
+}
+
+// JWTAuthWithConfig 設定を指定したJWT認証ミドルウェア
+func JWTAuthWithConfig(authUsecase domain.AuthUsecase, config JWTAuthConfig) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			var tokenString string
@@ -31,10 +48,7 @@ func JWTAuth(authUsecase domain.AuthUsecase) echo.MiddlewareFunc {
 
 			// Authorizationヘッダーにトークンがない場合は、クッキーから取得
 			if tokenString == "" {
-				cookie, err := c.Cookie("token")
-				if err == nil && cookie.Value != "" {
-					tokenString = cookie.Value
-				}
+				tokenString = CookiesFromContext(c).Get(c, AccessTokenCookie)
 			}
 
 			// トークンが見つからない場合
@@ -42,11 +56,11 @@ func JWTAuth(authUsecase domain.AuthUsecase) echo.MiddlewareFunc {
 				if isAPI {
 					return echo.NewHTTPError(http.StatusUnauthorized, "Missing token")
 				}
-				return c.Redirect(http.StatusTemporaryRedirect, "/login")
+				return c.Redirect(http.StatusTemporaryRedirect, loginURL(c, url.Values{}))
 			}
 
 			// トークンの検証
-			claims, err := authUsecase.ValidateToken(tokenString)
+			claims, err := authUsecase.ValidateToken(tokenString, config.Audiences...)
 			if err != nil {
 				// トークンの有効期限切れの場合
 				if errors.Is(err, jwt.ErrTokenExpired) {
@@ -54,34 +68,40 @@ func JWTAuth(authUsecase domain.AuthUsecase) echo.MiddlewareFunc {
 						return echo.NewHTTPError(http.StatusUnauthorized, "Token expired")
 					}
 					// フロントエンドの場合はクッキーを削除してログインページにリダイレクト
-					c.SetCookie(&http.Cookie{
-							Name:     "token",
-							Value:    "",
-							Path:     "/",
-							MaxAge:   -1,
-							HttpOnly: true,
-						})
-						return c.Redirect(http.StatusTemporaryRedirect, "/login?error=token_expired")
+					CookiesFromContext(c).Clear(c, AccessTokenCookie)
+						return c.Redirect(http.StatusTemporaryRedirect, loginURL(c, url.Values{"error": {"token_expired"}}))
 				}
 
-				// その他のトークンエラー
+				// その他のトークンエラー（失効済みを含む）
 				if isAPI {
+					if errors.Is(err, domain.ErrTokenRevoked) {
+						return echo.NewHTTPError(http.StatusUnauthorized, "Token revoked")
+					}
+					if errors.Is(err, domain.ErrInvalidAudience) {
+						return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token audience")
+					}
 					return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
 				}
 				// フロントエンドの場合はクッキーを削除してログインページにリダイレクト
-				c.SetCookie(&http.Cookie{
-					Name:     "token",
-					Value:    "",
-					Path:     "/",
-					MaxAge:   -1,
-					HttpOnly: true,
-				})
-				return c.Redirect(http.StatusTemporaryRedirect, "/login")
+				CookiesFromContext(c).Clear(c, AccessTokenCookie)
+				return c.Redirect(http.StatusTemporaryRedirect, loginURL(c, url.Values{}))
+			}
+
+			// サービスアカウントの場合はuser_idを設定せず、クレームのみで識別する
+			if claims.IsServiceAccount() {
+				if !config.AllowServiceAccounts {
+					return echo.NewHTTPError(http.StatusForbidden, "Service account tokens are not accepted")
+				}
+				c.Set("jti", claims.ID)
+				c.Set("claims", claims)
+				return next(c)
 			}
 
 			// コンテキストにユーザー情報を設定
 			c.Set("user_id", claims.UserID)
 			c.Set("email", claims.Email)
+			c.Set("jti", claims.ID) // セッション特定用
+			c.Set("claims", claims) // クレームによる認可判定用
 
 			return next(c)
 		}
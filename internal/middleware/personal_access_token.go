@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PersonalAccessTokenAuth パーソナルアクセストークン（APIキー）で認証するミドルウェア
+// トークンは X-API-Key ヘッダーまたは Authorization: Bearer で送信する
+func PersonalAccessTokenAuth(patUsecase domain.PersonalAccessTokenUsecase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tokenString := personalAccessTokenFromRequest(c)
+			if tokenString == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing API key")
+			}
+
+			return authenticatePersonalAccessToken(c, patUsecase, tokenString, next)
+		}
+	}
+}
+
+// JWTOrPersonalAccessTokenAuth JWTとパーソナルアクセストークンのどちらでも認証できるミドルウェア
+// トークンの接頭辞でどちらかを判定する
+func JWTOrPersonalAccessTokenAuth(authUsecase domain.AuthUsecase, patUsecase domain.PersonalAccessTokenUsecase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		jwtHandler := JWTAuth(authUsecase)(next)
+
+		return func(c echo.Context) error {
+			if tokenString := personalAccessTokenFromRequest(c); tokenString != "" {
+				return authenticatePersonalAccessToken(c, patUsecase, tokenString, next)
+			}
+			return jwtHandler(c)
+		}
+	}
+}
+
+// JWTOrPersonalAccessTokenAuthWithRBAC JWTまたはパーソナルアクセストークンの認証とRBAC権限チェックを組み合わせたミドルウェア
+// パーソナルアクセストークンの場合は、トークンのスコープとユーザーの権限の両方を満たす必要がある
+func JWTOrPersonalAccessTokenAuthWithRBAC(authUsecase domain.AuthUsecase, patUsecase domain.PersonalAccessTokenUsecase, rbacUsecase domain.RBACUsecase, resource, action string) echo.MiddlewareFunc {
+	authMiddleware := JWTOrPersonalAccessTokenAuth(authUsecase, patUsecase)
+	rbacMiddleware := RBACMiddleware(rbacUsecase, resource, action)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return authMiddleware(rbacMiddleware(next))
+	}
+}
+
+// authenticatePersonalAccessToken トークンを検証してコンテキストにユーザー情報を設定
+func authenticatePersonalAccessToken(c echo.Context, patUsecase domain.PersonalAccessTokenUsecase, tokenString string, next echo.HandlerFunc) error {
+	token, user, err := patUsecase.Authenticate(tokenString)
+	if errors.Is(err, domain.ErrInvalidPersonalAccessToken) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to authenticate API key")
+	}
+
+	// コンテキストにユーザー情報を設定（JWT認証と同じキー）
+	c.Set("user_id", user.ID)
+	c.Set("email", user.Email)
+	c.Set("personal_access_token", token) // スコープによる認可判定用
+
+	return next(c)
+}
+
+// personalAccessTokenFromRequest X-API-Key ヘッダー、または接頭辞付きのBearerトークンを取得
+func personalAccessTokenFromRequest(c echo.Context) string {
+	if apiKey := c.Request().Header.Get("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+
+	tokenParts := strings.Split(c.Request().Header.Get("Authorization"), " ")
+	if len(tokenParts) == 2 && tokenParts[0] == "Bearer" && strings.HasPrefix(tokenParts[1], domain.PersonalAccessTokenPrefix) {
+		return tokenParts[1]
+	}
+	return ""
+}
+
+// personalAccessTokenAllows パーソナルアクセストークンで認証した場合、トークンのスコープが操作を許可するか
+// JWTで認証した場合は常にtrue
+func personalAccessTokenAllows(c echo.Context, resource, action string) bool {
+	token, ok := c.Get("personal_access_token").(*domain.PersonalAccessToken)
+	return !ok || token.AllowsScope(resource, action)
+}
+
+// scopedPersonalAccessToken スコープを限定したパーソナルアクセストークンで認証したかどうか
+func scopedPersonalAccessToken(c echo.Context) bool {
+	token, ok := c.Get("personal_access_token").(*domain.PersonalAccessToken)
+	return ok && len(token.Scopes) > 0
+}
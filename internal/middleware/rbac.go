@@ -13,6 +13,14 @@ import (
 func RBACMiddleware(rbacUsecase domain.RBACUsecase, resource, action string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// サービスアカウントの場合はサービスアカウントに割り当てたロールの権限で判定
+			if account := serviceAccountFromContext(c); account != nil {
+				if err := rbacUsecase.CheckServiceAccountPermission(account.ServiceAccountID, resource, action); err != nil {
+					return echo.NewHTTPError(http.StatusForbidden, "権限がありません")
+				}
+				return next(c)
+			}
+
 			// JWTからユーザーIDを取得
 			userIDStr := c.Get("user_id")
 			if userIDStr == nil {
@@ -24,6 +32,11 @@ func RBACMiddleware(rbacUsecase domain.RBACUsecase, resource, action string) ech
 				return echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
 			}
 
+			// パーソナルアクセストークンの場合は、トークンのスコープとユーザーの権限の両方を満たす必要がある
+			if !personalAccessTokenAllows(c, resource, action) {
+				return echo.NewHTTPError(http.StatusForbidden, "トークンのスコープに含まれない操作です")
+			}
+
 			// 権限チェック
 			err := rbacUsecase.CheckPermission(userID, resource, action)
 			if err != nil {
@@ -39,6 +52,11 @@ func RBACMiddleware(rbacUsecase domain.RBACUsecase, resource, action string) ech
 func RequireRole(rbacUsecase domain.RBACUsecase, roleName string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// サービスアカウントの場合はサービスアカウントに割り当てたロールで判定
+			if account := serviceAccountFromContext(c); account != nil {
+				return requireServiceAccountRoles(c, rbacUsecase, account.ServiceAccountID, []string{roleName}, true, next)
+			}
+
 			// JWTからユーザーIDを取得
 			userIDStr := c.Get("user_id")
 			if userIDStr == nil {
@@ -50,6 +68,11 @@ func RequireRole(rbacUsecase domain.RBACUsecase, roleName string) echo.Middlewar
 				return echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
 			}
 
+			// スコープを限定したトークンでロール全体の権限を使わせない
+			if scopedPersonalAccessToken(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "スコープを限定したトークンでは使用できません")
+			}
+
 			// ロールチェック
 			hasRole, err := rbacUsecase.HasRole(userID, roleName)
 			if err != nil {
@@ -69,6 +92,11 @@ func RequireRole(rbacUsecase domain.RBACUsecase, roleName string) echo.Middlewar
 func RequireAnyRole(rbacUsecase domain.RBACUsecase, roleNames ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// サービスアカウントの場合はサービスアカウントに割り当てたロールで判定
+			if account := serviceAccountFromContext(c); account != nil {
+				return requireServiceAccountRoles(c, rbacUsecase, account.ServiceAccountID, roleNames, false, next)
+			}
+
 			// JWTからユーザーIDを取得
 			userIDStr := c.Get("user_id")
 			if userIDStr == nil {
@@ -80,6 +108,11 @@ func RequireAnyRole(rbacUsecase domain.RBACUsecase, roleNames ...string) echo.Mi
 				return echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
 			}
 
+			// スコープを限定したトークンでロール全体の権限を使わせない
+			if scopedPersonalAccessToken(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "スコープを限定したトークンでは使用できません")
+			}
+
 			// いずれかのロールを持っているかチェック
 			for _, roleName := range roleNames {
 				hasRole, err := rbacUsecase.HasRole(userID, roleName)
@@ -100,6 +133,11 @@ func RequireAnyRole(rbacUsecase domain.RBACUsecase, roleNames ...string) echo.Mi
 func RequireAllRoles(rbacUsecase domain.RBACUsecase, roleNames ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// サービスアカウントの場合はサービスアカウントに割り当てたロールで判定
+			if account := serviceAccountFromContext(c); account != nil {
+				return requireServiceAccountRoles(c, rbacUsecase, account.ServiceAccountID, roleNames, true, next)
+			}
+
 			// JWTからユーザーIDを取得
 			userIDStr := c.Get("user_id")
 			if userIDStr == nil {
@@ -111,6 +149,11 @@ func RequireAllRoles(rbacUsecase domain.RBACUsecase, roleNames ...string) echo.M
 				return echo.NewHTTPError(http.StatusUnauthorized, "無効なユーザーIDです")
 			}
 
+			// スコープを限定したトークンでロール全体の権限を使わせない
+			if scopedPersonalAccessToken(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "スコープを限定したトークンでは使用できません")
+			}
+
 			// すべてのロールを持っているかチェック
 			for _, roleName := range roleNames {
 				hasRole, err := rbacUsecase.HasRole(userID, roleName)
@@ -127,6 +170,76 @@ func RequireAllRoles(rbacUsecase domain.RBACUsecase, roleNames ...string) echo.M
 	}
 }
 
+// requireServiceAccountRoles サービスアカウントに割り当てたロールでロールチェックを実行
+// requireAllがfalseの場合は、いずれかのロールを持っていればよい
+func requireServiceAccountRoles(c echo.Context, rbacUsecase domain.RBACUsecase, accountID int, roleNames []string, requireAll bool, next echo.HandlerFunc) error {
+	for _, roleName := range roleNames {
+		hasRole, err := rbacUsecase.ServiceAccountHasRole(accountID, roleName)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "ロールチェックに失敗しました")
+		}
+		if hasRole && !requireAll {
+			return next(c)
+		}
+		if !hasRole && requireAll {
+			return echo.NewHTTPError(http.StatusForbidden, "必要なロールがありません")
+		}
+	}
+
+	if requireAll {
+		return next(c)
+	}
+	return echo.NewHTTPError(http.StatusForbidden, "必要なロールがありません")
+}
+
+// ClaimsRBACMiddleware アクセストークンの権限スコープのクレームで判定するRBACミドルウェア
+// DBを参照しないため、ロールや権限の変更はトークンの再発行（リフレッシュ）まで反映されない
+// 認可クレームを含まないトークンの場合はRBACMiddlewareと同様にDBで判定する
+func ClaimsRBACMiddleware(rbacUsecase domain.RBACUsecase, resource, action string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := authzClaimsFromContext(c)
+			if claims == nil {
+				return RBACMiddleware(rbacUsecase, resource, action)(next)(c)
+			}
+
+			if !claims.HasScope(resource, action) {
+				return echo.NewHTTPError(http.StatusForbidden, "権限がありません")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ClaimsRequireRole アクセストークンのロールのクレームで判定するミドルウェア
+// 認可クレームを含まないトークンの場合はRequireRoleと同様にDBで判定する
+func ClaimsRequireRole(rbacUsecase domain.RBACUsecase, roleName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := authzClaimsFromContext(c)
+			if claims == nil {
+				return RequireRole(rbacUsecase, roleName)(next)(c)
+			}
+
+			if !claims.HasRole(roleName) {
+				return echo.NewHTTPError(http.StatusForbidden, "必要なロールがありません")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// authzClaimsFromContext JWTミドルウェアが設定したクレームを取得（認可クレームを含まない場合はnil）
+func authzClaimsFromContext(c echo.Context) *domain.Claims {
+	claims, ok := c.Get("claims").(*domain.Claims)
+	if !ok || !claims.HasAuthzClaims() {
+		return nil
+	}
+	return claims
+}
+
 // GetUserIDFromContext コンテキストからユーザーIDを取得するヘルパー関数
 func GetUserIDFromContext(c echo.Context) (int, error) {
 	userIDStr := c.Get("user_id")
@@ -179,3 +292,35 @@ func JWTAuthWithRole(authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsec
 		}
 	}
 }
+
+// JWTAuthWithClaimsRBAC JWT認証とクレームによるRBAC権限チェックを組み合わせたミドルウェア
+func JWTAuthWithClaimsRBAC(authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsecase, resource, action string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// まずJWT認証を実行
+			jwtMiddleware := JWTAuth(authUsecase)
+			jwtHandler := jwtMiddleware(func(c echo.Context) error {
+				// JWT認証が成功したら、クレームで権限チェックを実行
+				rbacMiddleware := ClaimsRBACMiddleware(rbacUsecase, resource, action)
+				return rbacMiddleware(next)(c)
+			})
+			return jwtHandler(c)
+		}
+	}
+}
+
+// JWTAuthWithClaimsRole JWT認証とクレームによるロールチェックを組み合わせたミドルウェア
+func JWTAuthWithClaimsRole(authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsecase, roleName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// まずJWT認証を実行
+			jwtMiddleware := JWTAuth(authUsecase)
+			jwtHandler := jwtMiddleware(func(c echo.Context) error {
+				// JWT認証が成功したら、クレームでロールチェックを実行
+				roleMiddleware := ClaimsRequireRole(rbacUsecase, roleName)
+				return roleMiddleware(next)(c)
+			})
+			return jwtHandler(c)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// returnToKey 検証済みの戻り先を保存するコンテキストのキー
+const returnToKey = "return_to"
+
+// ReturnToCookie ログイン後の戻り先を保存する署名付きのクッキー
+// ログインを完了するAPIと外部プロバイダーへの認可リクエストの開始で読むため、パスは / にする
+var ReturnToCookie = CookieSpec{Name: "return_to", Path: "/", SameSite: http.SameSiteStrictMode}
+
+// CaptureReturnTo ログイン画面の next パラメータを検証し、ログイン後の戻り先としてクッキーに保存するミドルウェア
+// 許可リストにない値は無視する（任意のサイトへのリダイレクトに使われないようにする）
+func CaptureReturnTo(returnToUsecase domain.ReturnToUsecase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			returnTo := c.QueryParam("next")
+			if returnTo == "" {
+				return next(c)
+			}
+
+			returnTo, err := returnToUsecase.Validate(returnTo)
+			if err != nil {
+				log.Printf("Ignoring return_to that is not allowed: %q", c.QueryParam("next"))
+				return next(c)
+			}
+			sealed, expiresAt, err := returnToUsecase.Seal(returnTo)
+			if err != nil {
+				log.Printf("Failed to save return_to: %v", err)
+				return next(c)
+			}
+
+			CookiesFromContext(c).Set(c, ReturnToCookie, sealed, time.Until(expiresAt))
+			c.Set(returnToKey, returnTo)
+			return next(c)
+		}
+	}
+}
+
+// ReturnToFromContext CaptureReturnTo で検証した戻り先を取得（ない場合は空文字）
+func ReturnToFromContext(c echo.Context) string {
+	returnTo, _ := c.Get(returnToKey).(string)
+	return returnTo
+}
+
+// loginURL 未ログインのブラウザを、アクセスしたURLを戻り先にしてログイン画面へ送る
+// 画面の表示（GET）のみを戻り先にする（POST等はログイン後に再送できないため）
+func loginURL(c echo.Context, query url.Values) string {
+	if c.Request().Method == http.MethodGet {
+		query.Set("next", c.Request().URL.RequestURI())
+	}
+	if len(query) == 0 {
+		return "/login"
+	}
+	return "/login?" + query.Encode()
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// serviceAccountFromContext サービスアカウントのトークンで認証した場合にクレームを取得（それ以外はnil）
+func serviceAccountFromContext(c echo.Context) *domain.Claims {
+	claims, ok := c.Get("claims").(*domain.Claims)
+	if !ok || !claims.IsServiceAccount() {
+		return nil
+	}
+	return claims
+}
+
+// ActorFromContext 監査ログ用に、認証済みの操作者（ユーザーまたはサービスアカウント）の表示名を取得
+func ActorFromContext(c echo.Context) string {
+	if claims := serviceAccountFromContext(c); claims != nil {
+		return "service account " + claims.ClientID
+	}
+	return fmt.Sprintf("user %v", c.Get("user_id"))
+}
+
+// JWTOrServiceAccountAuthWithRole ユーザーとサービスアカウントのどちらのトークンでも認証し、ロールチェックを行うミドルウェア
+// バックエンドのジョブから呼び出す管理APIで使用する
+func JWTOrServiceAccountAuthWithRole(authUsecase domain.AuthUsecase, rbacUsecase domain.RBACUsecase, roleName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// まずサービスアカウントを許可したJWT認証を実行
+			jwtMiddleware := JWTAuthWithConfig(authUsecase, JWTAuthConfig{AllowServiceAccounts: true})
+			jwtHandler := jwtMiddleware(func(c echo.Context) error {
+				// JWT認証が成功したら、ロールチェックを実行
+				roleMiddleware := RequireRole(rbacUsecase, roleName)
+				return roleMiddleware(next)(c)
+			})
+			return jwtHandler(c)
+		}
+	}
+}
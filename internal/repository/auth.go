@@ -2,8 +2,8 @@ package repository
 
 import (
 	"database/sql"
-	"errors"
 	"go-echo-demo/internal/domain"
+	"time"
 )
 
 type AuthRepository struct {
@@ -14,17 +14,45 @@ func NewAuthRepository(db *sql.DB) domain.AuthRepository {
 	return &AuthRepository{db: db}
 }
 
-func (r *AuthRepository) ValidateCredentials(email, password string) (*domain.User, error) {
+// GetUserByEmail メールアドレスで認証対象のユーザーを取得（パスワードハッシュを含む）
+func (r *AuthRepository) GetUserByEmail(email string) (*domain.User, error) {
 	var user domain.User
-	query := `SELECT id, name, email, password FROM users WHERE email = $1 AND password = $2`
+	query := `SELECT id, name, email, COALESCE(password, ''), email_verified_at FROM users WHERE email = $1`
 
-	err := r.db.QueryRow(query, email, password).Scan(&user.ID, &user.Name, &user.Email, &user.Password)
+	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.EmailVerifiedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("invalid credentials")
+			return nil, nil
 		}
 		return nil, err
 	}
 
 	return &user, nil
 }
+
+// UpdatePassword パスワードハッシュを更新
+func (r *AuthRepository) UpdatePassword(userID int, passwordHash string) error {
+	query := `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`
+
+	_, err := r.db.Exec(query, passwordHash, time.Now(), userID)
+	return err
+}
+
+// MarkEmailVerified メールアドレスを確認済みにする（確認済みの場合は日時を変更しない）
+func (r *AuthRepository) MarkEmailVerified(userID int, email string) (bool, error) {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, $3), updated_at = $3
+		WHERE id = $1 AND email = $2`
+
+	result, err := r.db.Exec(query, userID, email, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
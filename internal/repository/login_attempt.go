@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// loginAttemptRepository ログイン失敗状況のPostgreSQL実装
+type loginAttemptRepository struct {
+	db *sql.DB
+}
+
+// NewLoginAttemptRepository ログイン失敗状況リポジトリのコンストラクタ
+func NewLoginAttemptRepository(db *sql.DB) domain.LoginAttemptStore {
+	return &loginAttemptRepository{db: db}
+}
+
+// Get キーの失敗状況を取得
+func (r *loginAttemptRepository) Get(key string) (*domain.LoginAttempt, error) {
+	query := `
+		SELECT attempt_key, failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE attempt_key = $1`
+
+	var attempt domain.LoginAttempt
+	err := r.db.QueryRow(query, key).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+		&attempt.LockedUntil,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure 失敗回数を加算（集計期間を過ぎていれば1から数え直す）
+func (r *loginAttemptRepository) RecordFailure(key string, window time.Duration) (*domain.LoginAttempt, error) {
+	now := time.Now()
+	query := `
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (attempt_key) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.last_failure_at < $3 THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING attempt_key, failures, last_failure_at, locked_until`
+
+	var attempt domain.LoginAttempt
+	err := r.db.QueryRow(query, key, now, now.Add(-window)).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+		&attempt.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// Lock 指定日時までロックし、失敗回数をリセット
+func (r *loginAttemptRepository) Lock(key string, until time.Time) error {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $2, failures = 0
+		WHERE attempt_key = $1`
+
+	_, err := r.db.Exec(query, key, until)
+	return err
+}
+
+// Reset 失敗回数とロックを解除
+func (r *loginAttemptRepository) Reset(key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_attempts WHERE attempt_key = $1`, key)
+	return err
+}
+
+// Prune 古い失敗記録を削除
+func (r *loginAttemptRepository) Prune(before time.Time) error {
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)`
+
+	_, err := r.db.Exec(query, before, time.Now())
+	return err
+}
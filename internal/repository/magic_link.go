@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// magicLinkRepository マジックリンクトークンリポジトリの実装
+type magicLinkRepository struct {
+	db *sql.DB
+}
+
+// NewMagicLinkRepository マジックリンクトークンリポジトリのコンストラクタ
+func NewMagicLinkRepository(db *sql.DB) domain.MagicLinkRepository {
+	return &magicLinkRepository{db: db}
+}
+
+// Create トークンを保存
+func (r *magicLinkRepository) Create(token *domain.MagicLinkToken) error {
+	query := `
+		INSERT INTO magic_link_tokens (user_id, token_hash, binding_hash, ip_address, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, token.UserID, token.TokenHash, token.BindingHash, token.IPAddress, token.ExpiresAt, time.Now()).
+		Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetByTokenHash トークンのハッシュ値で取得
+func (r *magicLinkRepository) GetByTokenHash(tokenHash string) (*domain.MagicLinkToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, binding_hash, COALESCE(ip_address, ''), expires_at, used_at, created_at
+		FROM magic_link_tokens
+		WHERE token_hash = $1`
+
+	var token domain.MagicLinkToken
+	err := r.db.QueryRow(query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.BindingHash,
+		&token.IPAddress,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkUsed 未使用のトークンのみを使用済みにする（同時リクエストによる二重使用を防ぐ）
+func (r *magicLinkRepository) MarkUsed(tokenID int) (bool, error) {
+	query := `
+		UPDATE magic_link_tokens
+		SET used_at = $2
+		WHERE id = $1 AND used_at IS NULL`
+
+	result, err := r.db.Exec(query, tokenID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// InvalidateByUserID ユーザーの未使用のトークンをすべて無効化
+func (r *magicLinkRepository) InvalidateByUserID(userID int) error {
+	query := `
+		UPDATE magic_link_tokens
+		SET used_at = $2
+		WHERE user_id = $1 AND used_at IS NULL`
+
+	_, err := r.db.Exec(query, userID, time.Now())
+	return err
+}
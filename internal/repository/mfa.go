@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// mfaRepository 二要素認証リポジトリの実装
+type mfaRepository struct {
+	db *sql.DB
+}
+
+// NewMFARepository 二要素認証リポジトリのコンストラクタ
+func NewMFARepository(db *sql.DB) domain.MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// GetByUserID ユーザーのTOTP設定を取得
+func (r *mfaRepository) GetByUserID(userID int) (*domain.UserMFA, error) {
+	query := `
+		SELECT user_id, secret_encrypted, enabled, last_used_step, confirmed_at, created_at, updated_at
+		FROM user_mfa
+		WHERE user_id = $1`
+
+	var mfa domain.UserMFA
+	err := r.db.QueryRow(query, userID).Scan(
+		&mfa.UserID,
+		&mfa.SecretEncrypted,
+		&mfa.Enabled,
+		&mfa.LastUsedStep,
+		&mfa.ConfirmedAt,
+		&mfa.CreatedAt,
+		&mfa.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &mfa, nil
+}
+
+// SavePending 未確認のシークレットを保存（登録のやり直しでは上書き）
+func (r *mfaRepository) SavePending(userID int, secretEncrypted string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret_encrypted, enabled, last_used_step, created_at, updated_at)
+		VALUES ($1, $2, false, 0, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_encrypted = EXCLUDED.secret_encrypted, last_used_step = 0, updated_at = EXCLUDED.updated_at
+		WHERE user_mfa.enabled = false`
+
+	_, err := r.db.Exec(query, userID, secretEncrypted, time.Now())
+	return err
+}
+
+// Enable 確認済みとして有効化
+func (r *mfaRepository) Enable(userID int) error {
+	query := `
+		UPDATE user_mfa
+		SET enabled = true, confirmed_at = $2, updated_at = $2
+		WHERE user_id = $1`
+
+	_, err := r.db.Exec(query, userID, time.Now())
+	return err
+}
+
+// Delete TOTP設定とリカバリーコードを削除
+func (r *mfaRepository) Delete(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateLastUsedStep 受け付けたタイムステップを記録
+func (r *mfaRepository) UpdateLastUsedStep(userID int, step int64) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $2, updated_at = $3
+		WHERE user_id = $1 AND last_used_step < $2`
+
+	result, err := r.db.Exec(query, userID, step, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// ReplaceRecoveryCodes 既存のリカバリーコードを削除して新しいコードを保存
+func (r *mfaRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		query := `INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(query, userID, codeHash, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode 未使用のリカバリーコードを使用済みにする
+func (r *mfaRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	result, err := r.db.Exec(query, userID, codeHash, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// CountRecoveryCodes 未使用のリカバリーコード数を取得
+func (r *mfaRepository) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
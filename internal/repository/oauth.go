@@ -2,6 +2,8 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"go-echo-demo/internal/domain"
 )
@@ -14,41 +16,228 @@ func NewOAuthRepository(db *sql.DB) domain.OAuthRepository {
 	return &OAuthRepository{db: db}
 }
 
+// GetOrCreateUser プロバイダーとsubjectで連携済みのユーザーを取得し、なければユーザーを作成して連携する
+// メールアドレスだけで既存のユーザーに紐付けることはしない（他人が同じメールアドレスで登録したIdPから乗っ取れるため）
 func (r *OAuthRepository) GetOrCreateUser(oauthUser *domain.OAuthUser) (*domain.User, error) {
-	// 既存のユーザーを検索（プロバイダーIDまたはメールアドレスで）
-	var user domain.User
-	query := `SELECT id, name, email, password FROM users WHERE email = $1 OR provider_id = $2 AND provider_name = $3`
+	user, err := r.FindUserByIdentity(oauthUser)
+	if err != nil || user != nil {
+		return user, err
+	}
+	return r.CreateUserWithIdentity(oauthUser)
+}
 
-	err := r.db.QueryRow(query, oauthUser.Email, oauthUser.ProviderID, oauthUser.ProviderName).Scan(&user.ID, &user.Name, &user.Email, &user.Password)
+// FindUserByIdentity 連携済みのユーザーを取得し、プロバイダー側のメールアドレスとプロフィールを最新の値に更新
+func (r *OAuthRepository) FindUserByIdentity(oauthUser *domain.OAuthUser) (*domain.User, error) {
+	profile, err := marshalProfile(oauthUser)
+	if err != nil {
+		return nil, err
+	}
 
+	var user domain.User
+	query := `
+		UPDATE user_identities i
+		SET email = $3, profile = $4, last_login_at = $5
+		FROM users u
+		WHERE u.id = i.user_id AND i.provider = $1 AND i.subject = $2
+		RETURNING u.id, u.name, COALESCE(u.email, ''), COALESCE(u.password, ''), COALESCE(u.provider_id, ''), COALESCE(u.provider_name, ''), u.email_verified_at`
+
+	err = r.db.QueryRow(query, oauthUser.ProviderName, oauthUser.ProviderID, oauthUser.Email, profile, time.Now()).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.ProviderID, &user.ProviderName, &user.EmailVerifiedAt)
 	if err == sql.ErrNoRows {
-		// ユーザーが存在しない場合は新規作成
-		insertQuery := `INSERT INTO users (name, email, provider_id, provider_name, password) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-		err = r.db.QueryRow(insertQuery, oauthUser.Name, oauthUser.Email, oauthUser.ProviderID, oauthUser.ProviderName, "").Scan(&user.ID)
-		if err != nil {
-			return nil, err
-		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// CreateUserWithIdentity ユーザーを作成してプロバイダーを連携
+// プロバイダーが確認済みとしたメールアドレスのみをusers.emailに保存する（メールアドレスを返さないプロバイダーや未確認の場合はNULL）
+// 未確認のメールアドレスを保存すると、持ち主が後から登録・パスワード再設定したときに他人のIdPが連携されたアカウントを使うことになるため
+func (r *OAuthRepository) CreateUserWithIdentity(oauthUser *domain.OAuthUser) (*domain.User, error) {
+	profile, err := marshalProfile(oauthUser)
+	if err != nil {
+		return nil, err
+	}
 
-		user.Name = oauthUser.Name
+	// プロバイダーとsubjectはuser_identitiesのみに保存する（users.provider_id / provider_name には書き込まない）
+	// 連携を解除した後に同じプロバイダーのアカウントで再度ログインした場合も、新しいユーザーとして作成できる
+	user := domain.User{
+		Name:     oauthUser.Name,
+		Password: "", // OAuth認証ユーザーはパスワード不要
+	}
+	now := time.Now()
+	if oauthUser.Verified && oauthUser.Email != "" {
 		user.Email = oauthUser.Email
-		user.Password = "" // OAuth認証ユーザーはパスワード不要
+		user.EmailVerifiedAt = &now
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 同じメールアドレスのユーザーが同時に作成された場合は、一意制約で片方だけが成功する
+	insertQuery := `
+		INSERT INTO users (name, email, password, email_verified_at)
+		VALUES ($1, NULLIF($2, ''), NULL, $3)
+		ON CONFLICT (email) DO NOTHING
+		RETURNING id`
+	err = tx.QueryRow(insertQuery, user.Name, user.Email, user.EmailVerifiedAt).Scan(&user.ID)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrOAuthEmailInUse
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identityQuery := `
+		INSERT INTO user_identities (user_id, provider, subject, email, profile, last_login_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)`
+	if _, err := tx.Exec(identityQuery, user.ID, oauthUser.ProviderName, oauthUser.ProviderID, oauthUser.Email, profile, now); err != nil {
+		return nil, err
+	}
+
+	return &user, tx.Commit()
+}
+
+// LinkIdentity 既存のユーザーにプロバイダーを連携（同じユーザーに連携済みの場合はプロフィールを更新）
+func (r *OAuthRepository) LinkIdentity(userID int, oauthUser *domain.OAuthUser) (*domain.UserIdentity, error) {
+	profile, err := marshalProfile(oauthUser)
+	if err != nil {
+		return nil, err
+	}
+
+	// (provider, subject) の一意制約で、同時に別のユーザーへ連携された場合も片方だけが成功する
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, profile, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (provider, subject) DO UPDATE SET email = EXCLUDED.email, profile = EXCLUDED.profile
+		WHERE user_identities.user_id = EXCLUDED.user_id
+		RETURNING id, user_id, provider, subject, COALESCE(email, ''), profile, last_login_at, created_at`
 
-		return &user, nil
+	identity, err := scanUserIdentity(r.db.QueryRow(query, userID, oauthUser.ProviderName, oauthUser.ProviderID, oauthUser.Email, profile, time.Now()))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrIdentityAlreadyLinked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// GetIdentity ユーザーの連携を取得
+func (r *OAuthRepository) GetIdentity(userID, identityID int) (*domain.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), profile, last_login_at, created_at
+		FROM user_identities
+		WHERE id = $1 AND user_id = $2`
+
+	identity, err := scanUserIdentity(r.db.QueryRow(query, identityID, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	return identity, nil
+}
+
+// ListIdentities ユーザーの連携一覧を取得
+func (r *OAuthRepository) ListIdentities(userID int) ([]*domain.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), profile, last_login_at, created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY id`
+
+	rows, err := r.db.Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// 既存ユーザーの場合、プロバイダー情報を更新（まだ設定されていない場合）
-	if user.Password == "" {
-		// OAuth認証ユーザーの場合、プロバイダー情報を更新
-		updateQuery := `UPDATE users SET provider_id = $1, provider_name = $2 WHERE id = $3`
-		_, err = r.db.Exec(updateQuery, oauthUser.ProviderID, oauthUser.ProviderName, user.ID)
+	identities := []*domain.UserIdentity{}
+	for rows.Next() {
+		identity, err := scanUserIdentity(rows)
 		if err != nil {
 			return nil, err
 		}
+		identities = append(identities, identity)
 	}
 
-	return &user, nil
+	return identities, rows.Err()
+}
+
+// DeleteIdentity 他のログイン手段（パスワードまたは他の連携）が残る場合のみ連携を削除
+func (r *OAuthRepository) DeleteIdentity(userID, identityID int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// 同じユーザーの連携を同時に解除して、ログイン手段がなくなることを防ぐ
+	var password string
+	err = tx.QueryRow(`SELECT COALESCE(password, '') FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&password)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		DELETE FROM user_identities
+		WHERE id = $1 AND user_id = $2
+		  AND ($3 OR EXISTS (SELECT 1 FROM user_identities WHERE user_id = $2 AND id <> $1))`
+
+	result, err := tx.Exec(query, identityID, userID, password != "")
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, tx.Commit()
+}
+
+// marshalProfile プロバイダーのプロフィールをJSONに変換（[]byteはbyteaとして送られるため文字列で渡す）
+func marshalProfile(oauthUser *domain.OAuthUser) (string, error) {
+	if oauthUser.Raw == nil {
+		return "{}", nil
+	}
+	profile, err := json.Marshal(oauthUser.Raw)
+	return string(profile), err
+}
+
+// scanUserIdentity 1行分の連携を読み取る
+func scanUserIdentity(row interface{ Scan(...interface{}) error }) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	var profile []byte
+	err := row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&profile,
+		&identity.LastLoginAt,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(profile, &identity.Profile); err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
 }
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// oauthStateRepository 認可リクエストのstateのPostgreSQL実装
+type oauthStateRepository struct {
+	db *sql.DB
+}
+
+// NewOAuthStateRepository stateリポジトリのコンストラクタ
+func NewOAuthStateRepository(db *sql.DB) domain.StateManager {
+	return &oauthStateRepository{db: db}
+}
+
+// SaveState stateを保存（stateはSHA-256ダイジェストのみを保存する）
+func (r *oauthStateRepository) SaveState(state *domain.OAuthState) error {
+	query := `
+		INSERT INTO oauth_states (state_hash, code_verifier, nonce, link_user_id, return_to, created_at, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7)`
+
+	_, err := r.db.Exec(query, hashState(state.State), state.CodeVerifier, state.Nonce, state.LinkUserID, state.ReturnTo, state.CreatedAt, state.ExpiresAt)
+	return err
+}
+
+// ConsumeState stateを削除して取り出す（同時に同じstateでコールバックを受けても、片方だけが取り出せる）
+func (r *oauthStateRepository) ConsumeState(state string) (*domain.OAuthState, error) {
+	query := `
+		DELETE FROM oauth_states
+		WHERE state_hash = $1
+		RETURNING code_verifier, nonce, COALESCE(link_user_id, 0), return_to, created_at, expires_at`
+
+	oauthState := domain.OAuthState{State: state}
+	err := r.db.QueryRow(query, hashState(state)).Scan(
+		&oauthState.CodeVerifier,
+		&oauthState.Nonce,
+		&oauthState.LinkUserID,
+		&oauthState.ReturnTo,
+		&oauthState.CreatedAt,
+		&oauthState.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(oauthState.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &oauthState, nil
+}
+
+// Prune 有効期限を過ぎたstateを削除
+func (r *oauthStateRepository) Prune() error {
+	_, err := r.db.Exec(`DELETE FROM oauth_states WHERE expires_at <= $1`, time.Now())
+	return err
+}
+
+// hashState stateのSHA-256ダイジェスト（16進数）
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/lib/pq"
+)
+
+// oidcRepository OpenID Connect プロバイダーのリポジトリの実装
+type oidcRepository struct {
+	db *sql.DB
+}
+
+// NewOIDCRepository OpenID Connect プロバイダーのリポジトリのコンストラクタ
+func NewOIDCRepository(db *sql.DB) domain.OIDCRepository {
+	return &oidcRepository{db: db}
+}
+
+// CreateClient クライアントを保存
+func (r *oidcRepository) CreateClient(client *domain.OIDCClient) error {
+	query := `
+		INSERT INTO oidc_clients (client_id, client_secret_hash, name, redirect_uris, public, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, client.ClientID, client.ClientSecretHash, client.Name, pq.Array(client.RedirectURIs), client.Public, time.Now()).
+		Scan(&client.ID, &client.CreatedAt)
+}
+
+// GetClientByClientID クライアントIDで取得
+func (r *oidcRepository) GetClientByClientID(clientID string) (*domain.OIDCClient, error) {
+	query := `
+		SELECT id, client_id, client_secret_hash, name, redirect_uris, public, created_at
+		FROM oidc_clients
+		WHERE client_id = $1`
+
+	client, err := scanOIDCClient(r.db.QueryRow(query, clientID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// ListClients クライアント一覧を取得
+func (r *oidcRepository) ListClients() ([]*domain.OIDCClient, error) {
+	query := `
+		SELECT id, client_id, client_secret_hash, name, redirect_uris, public, created_at
+		FROM oidc_clients
+		ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []*domain.OIDCClient{}
+	for rows.Next() {
+		client, err := scanOIDCClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+// DeleteClient クライアントを削除（認可コードと同意も削除される）
+func (r *oidcRepository) DeleteClient(id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM oidc_clients WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// CreateAuthorizationCode 認可コードを保存
+func (r *oidcRepository) CreateAuthorizationCode(code *domain.AuthorizationCode) error {
+	query := `
+		INSERT INTO oidc_authorization_codes (code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope, code.Nonce, code.CodeChallenge, code.ExpiresAt, time.Now()).
+		Scan(&code.ID, &code.CreatedAt)
+}
+
+// GetAuthorizationCodeByHash ハッシュ値で認可コードを取得
+func (r *oidcRepository) GetAuthorizationCodeByHash(codeHash string) (*domain.AuthorizationCode, error) {
+	query := `
+		SELECT id, code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, expires_at, used_at, COALESCE(access_token_jti, ''), created_at
+		FROM oidc_authorization_codes
+		WHERE code_hash = $1`
+
+	var code domain.AuthorizationCode
+	err := r.db.QueryRow(query, codeHash).Scan(
+		&code.ID,
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.ExpiresAt,
+		&code.UsedAt,
+		&code.AccessTokenJTI,
+		&code.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// MarkAuthorizationCodeUsed 未使用の認可コードのみ使用済みにする（同時に交換された場合は片方だけが成功する）
+func (r *oidcRepository) MarkAuthorizationCodeUsed(id int, accessTokenJTI string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE oidc_authorization_codes
+		SET used_at = $2, access_token_jti = $3
+		WHERE id = $1 AND used_at IS NULL`, id, time.Now(), accessTokenJTI)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// GetConsent ユーザーがクライアントに許可したスコープを取得
+func (r *oidcRepository) GetConsent(userID int, clientID string) (*domain.OIDCConsent, error) {
+	consent := domain.OIDCConsent{UserID: userID, ClientID: clientID}
+	var scopes pq.StringArray
+	err := r.db.QueryRow(`SELECT scopes FROM oidc_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID).Scan(&scopes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	consent.Scopes = []string(scopes)
+
+	return &consent, nil
+}
+
+// SaveConsent 許可したスコープを保存
+func (r *oidcRepository) SaveConsent(consent *domain.OIDCConsent) error {
+	query := `
+		INSERT INTO oidc_consents (user_id, client_id, scopes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = EXCLUDED.updated_at`
+
+	_, err := r.db.Exec(query, consent.UserID, consent.ClientID, pq.Array(consent.Scopes), time.Now())
+	return err
+}
+
+// scanOIDCClient 1行分のクライアントを読み取る
+func scanOIDCClient(row interface{ Scan(...interface{}) error }) (*domain.OIDCClient, error) {
+	var client domain.OIDCClient
+	var redirectURIs pq.StringArray
+	err := row.Scan(
+		&client.ID,
+		&client.ClientID,
+		&client.ClientSecretHash,
+		&client.Name,
+		&redirectURIs,
+		&client.Public,
+		&client.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	client.RedirectURIs = []string(redirectURIs)
+
+	return &client, nil
+}
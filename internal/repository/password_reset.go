@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// passwordResetRepository パスワードリセットトークンリポジトリの実装
+type passwordResetRepository struct {
+	db *sql.DB
+}
+
+// NewPasswordResetRepository パスワードリセットトークンリポジトリのコンストラクタ
+func NewPasswordResetRepository(db *sql.DB) domain.PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// Create リセットトークンを保存
+func (r *passwordResetRepository) Create(token *domain.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, token.UserID, token.TokenHash, token.ExpiresAt, time.Now()).
+		Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetByTokenHash トークンのハッシュ値でリセットトークンを取得
+func (r *passwordResetRepository) GetByTokenHash(tokenHash string) (*domain.PasswordResetToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1`
+
+	var token domain.PasswordResetToken
+	err := r.db.QueryRow(query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkUsed 未使用のトークンのみを使用済みにする（同時リクエストによる二重使用を防ぐ）
+func (r *passwordResetRepository) MarkUsed(tokenID int) (bool, error) {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = $2
+		WHERE id = $1 AND used_at IS NULL`
+
+	result, err := r.db.Exec(query, tokenID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// InvalidateByUserID ユーザーの未使用のトークンをすべて無効化
+func (r *passwordResetRepository) InvalidateByUserID(userID int) error {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = $2
+		WHERE user_id = $1 AND used_at IS NULL`
+
+	_, err := r.db.Exec(query, userID, time.Now())
+	return err
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/lib/pq"
+)
+
+// personalAccessTokenRepository パーソナルアクセストークンリポジトリの実装
+type personalAccessTokenRepository struct {
+	db *sql.DB
+}
+
+// NewPersonalAccessTokenRepository パーソナルアクセストークンリポジトリのコンストラクタ
+func NewPersonalAccessTokenRepository(db *sql.DB) domain.PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{db: db}
+}
+
+// Create トークンを保存
+func (r *personalAccessTokenRepository) Create(token *domain.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, token.UserID, token.Name, token.TokenPrefix, token.TokenHash, pq.Array(token.Scopes), token.ExpiresAt, time.Now()).
+		Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetByTokenHash トークンのハッシュ値で取得
+func (r *personalAccessTokenRepository) GetByTokenHash(tokenHash string) (*domain.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE token_hash = $1`
+
+	token, err := scanPersonalAccessToken(r.db.QueryRow(query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ListByUserID ユーザーの有効なトークン一覧を取得
+func (r *personalAccessTokenRepository) ListByUserID(userID int) ([]*domain.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*domain.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Revoke ユーザーのトークンを失効させる（他のユーザーのトークンは失効できない）
+func (r *personalAccessTokenRepository) Revoke(userID, tokenID int) (bool, error) {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, tokenID, userID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// RevokeAllByUserID ユーザーのすべてのトークンを失効させる
+func (r *personalAccessTokenRepository) RevokeAllByUserID(userID int) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(query, userID, time.Now())
+	return err
+}
+
+// UpdateLastUsed 最終使用日時を更新
+func (r *personalAccessTokenRepository) UpdateLastUsed(tokenID int, usedAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE personal_access_tokens SET last_used_at = $2 WHERE id = $1`, tokenID, usedAt)
+	return err
+}
+
+// scanPersonalAccessToken 1行分のトークンを読み取る
+func scanPersonalAccessToken(row interface{ Scan(...interface{}) error }) (*domain.PersonalAccessToken, error) {
+	var token domain.PersonalAccessToken
+	var scopes pq.StringArray
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenPrefix,
+		&token.TokenHash,
+		&scopes,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = []string(scopes)
+
+	return &token, nil
+}
@@ -219,7 +219,7 @@ func (r *RBACRepositoryImpl) RemoveRoleFromUser(userID, roleID int) error {
 
 func (r *RBACRepositoryImpl) GetUsersByRole(roleID int) ([]domain.User, error) {
 	query := `
-		SELECT u.id, u.name, u.email, u.password, u.provider_id, u.provider_name 
+		SELECT u.id, u.name, COALESCE(u.email, ''), COALESCE(u.password, ''), COALESCE(u.provider_id, ''), COALESCE(u.provider_name, '') 
 		FROM users u 
 		JOIN user_roles ur ON u.id = ur.user_id 
 		WHERE ur.role_id = $1 
@@ -319,3 +319,79 @@ func (r *RBACRepositoryImpl) HasRole(userID int, roleName string) (bool, error)
 	}
 	return hasRole, nil
 }
+
+// サービスアカウントロール関連
+func (r *RBACRepositoryImpl) GetServiceAccountRoles(accountID int) ([]domain.Role, error) {
+	query := `
+		SELECT r.id, r.name, r.description, r.created_at, r.updated_at 
+		FROM roles r 
+		JOIN service_account_roles sr ON r.id = sr.role_id 
+		WHERE sr.service_account_id = $1 
+		ORDER BY r.id
+	`
+	rows, err := r.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []domain.Role
+	for rows.Next() {
+		var role domain.Role
+		err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan service account role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *RBACRepositoryImpl) AssignRoleToServiceAccount(accountID, roleID int) error {
+	query := `INSERT INTO service_account_roles (service_account_id, role_id) VALUES ($1, $2) ON CONFLICT (service_account_id, role_id) DO NOTHING`
+	_, err := r.db.Exec(query, accountID, roleID)
+	if err != nil {
+		return fmt.Errorf("failed to assign role to service account: %w", err)
+	}
+	return nil
+}
+
+func (r *RBACRepositoryImpl) RemoveRoleFromServiceAccount(accountID, roleID int) error {
+	query := `DELETE FROM service_account_roles WHERE service_account_id = $1 AND role_id = $2`
+	_, err := r.db.Exec(query, accountID, roleID)
+	if err != nil {
+		return fmt.Errorf("failed to remove role from service account: %w", err)
+	}
+	return nil
+}
+
+func (r *RBACRepositoryImpl) ServiceAccountHasPermission(accountID int, resource, action string) (bool, error) {
+	query := `
+		SELECT COUNT(*) > 0 
+		FROM service_account_roles sr 
+		JOIN role_permissions rp ON sr.role_id = rp.role_id 
+		JOIN permissions p ON rp.permission_id = p.id 
+		WHERE sr.service_account_id = $1 AND p.resource = $2 AND p.action = $3
+	`
+	var hasPermission bool
+	err := r.db.QueryRow(query, accountID, resource, action).Scan(&hasPermission)
+	if err != nil {
+		return false, fmt.Errorf("failed to check service account permission: %w", err)
+	}
+	return hasPermission, nil
+}
+
+func (r *RBACRepositoryImpl) ServiceAccountHasRole(accountID int, roleName string) (bool, error) {
+	query := `
+		SELECT COUNT(*) > 0 
+		FROM service_account_roles sr 
+		JOIN roles r ON sr.role_id = r.id 
+		WHERE sr.service_account_id = $1 AND r.name = $2
+	`
+	var hasRole bool
+	err := r.db.QueryRow(query, accountID, roleName).Scan(&hasRole)
+	if err != nil {
+		return false, fmt.Errorf("failed to check service account role: %w", err)
+	}
+	return hasRole, nil
+}
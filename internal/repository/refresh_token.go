@@ -17,73 +17,120 @@ func NewRefreshTokenRepository(db *sql.DB) domain.RefreshTokenRepository {
 	return &refreshTokenRepository{db: db}
 }
 
+const refreshTokenColumns = `
+	id, user_id, token_hash, access_token_jti, expires_at,
+	created_at, updated_at, last_used_at, revoked, revoked_at,
+	device_info, ip_address, family_id, replaced_by_id`
+
+// scanRefreshToken 1行分のリフレッシュトークンを読み込む
+func scanRefreshToken(row interface{ Scan(dest ...any) error }) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.AccessTokenJTI,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.UpdatedAt,
+		&token.LastUsedAt,
+		&token.Revoked,
+		&token.RevokedAt,
+		&token.DeviceInfo,
+		&token.IPAddress,
+		&token.FamilyID,
+		&token.ReplacedByID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
 // Create リフレッシュトークンを保存
 func (r *refreshTokenRepository) Create(token *domain.RefreshToken) error {
+	return r.insert(r.db, token)
+}
+
+// insert リフレッシュトークンを挿入（トランザクション内からも利用）
+func (r *refreshTokenRepository) insert(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}, token *domain.RefreshToken) error {
 	query := `
 		INSERT INTO refresh_tokens (
-			user_id, token, access_token_jti, expires_at,
-			device_info, ip_address, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			user_id, token_hash, access_token_jti, expires_at,
+			device_info, ip_address, family_id, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id`
 
-	err := r.db.QueryRow(
+	return q.QueryRow(
 		query,
 		token.UserID,
-		token.Token,
+		token.TokenHash,
 		token.AccessTokenJTI,
 		token.ExpiresAt,
 		token.DeviceInfo,
 		token.IPAddress,
+		token.FamilyID,
 		time.Now(),
 		time.Now(),
 	).Scan(&token.ID)
-
-	return err
 }
 
-// GetByToken トークン文字列でリフレッシュトークンを取得
-func (r *refreshTokenRepository) GetByToken(tokenString string) (*domain.RefreshToken, error) {
-	query := `
-		SELECT id, user_id, token, access_token_jti, expires_at,
-			   created_at, updated_at, last_used_at, revoked, revoked_at,
-			   device_info, ip_address
+// GetByTokenHash トークンのハッシュ値でリフレッシュトークンを取得
+// ローテーション済みトークンの再利用を検知できるよう、無効化済みのトークンも返す
+func (r *refreshTokenRepository) GetByTokenHash(tokenHash string) (*domain.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + `
 		FROM refresh_tokens
-		WHERE token = $1 AND revoked = false`
-
-	var token domain.RefreshToken
-	err := r.db.QueryRow(query, tokenString).Scan(
-		&token.ID,
-		&token.UserID,
-		&token.Token,
-		&token.AccessTokenJTI,
-		&token.ExpiresAt,
-		&token.CreatedAt,
-		&token.UpdatedAt,
-		&token.LastUsedAt,
-		&token.Revoked,
-		&token.RevokedAt,
-		&token.DeviceInfo,
-		&token.IPAddress,
-	)
+		WHERE token_hash = $1`
 
+	token, err := scanRefreshToken(r.db.QueryRow(query, tokenHash))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 
-	return &token, err
+	return token, err
 }
 
 // GetByUserID ユーザーIDでリフレッシュトークンを取得
 func (r *refreshTokenRepository) GetByUserID(userID int) ([]*domain.RefreshToken, error) {
-	query := `
-		SELECT id, user_id, token, access_token_jti, expires_at,
-			   created_at, updated_at, last_used_at, revoked, revoked_at,
-			   device_info, ip_address
+	query := `SELECT ` + refreshTokenColumns + `
 		FROM refresh_tokens
 		WHERE user_id = $1 AND revoked = false
 		ORDER BY created_at DESC`
 
-	rows, err := r.db.Query(query, userID)
+	return r.queryTokens(query, userID)
+}
+
+// GetByAccessTokenJTI 一緒に発行したアクセストークンのJTIでリフレッシュトークンを取得（無効化済みのものを含む）
+func (r *refreshTokenRepository) GetByAccessTokenJTI(userID int, jti string) (*domain.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + `
+		FROM refresh_tokens
+		WHERE user_id = $1 AND access_token_jti = $2
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	token, err := scanRefreshToken(r.db.QueryRow(query, userID, jti))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return token, err
+}
+
+// GetByFamilyID ファミリーIDでリフレッシュトークンを取得（無効化済みのものを含む）
+func (r *refreshTokenRepository) GetByFamilyID(familyID string) ([]*domain.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + `
+		FROM refresh_tokens
+		WHERE family_id = $1
+		ORDER BY created_at DESC`
+
+	return r.queryTokens(query, familyID)
+}
+
+// queryTokens 複数行のリフレッシュトークンを読み込む
+func (r *refreshTokenRepository) queryTokens(query string, args ...any) ([]*domain.RefreshToken, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -91,28 +138,14 @@ func (r *refreshTokenRepository) GetByUserID(userID int) ([]*domain.RefreshToken
 
 	var tokens []*domain.RefreshToken
 	for rows.Next() {
-		var token domain.RefreshToken
-		err := rows.Scan(
-			&token.ID,
-			&token.UserID,
-			&token.Token,
-			&token.AccessTokenJTI,
-			&token.ExpiresAt,
-			&token.CreatedAt,
-			&token.UpdatedAt,
-			&token.LastUsedAt,
-			&token.Revoked,
-			&token.RevokedAt,
-			&token.DeviceInfo,
-			&token.IPAddress,
-		)
+		token, err := scanRefreshToken(rows)
 		if err != nil {
 			return nil, err
 		}
-		tokens = append(tokens, &token)
+		tokens = append(tokens, token)
 	}
 
-	return tokens, nil
+	return tokens, rows.Err()
 }
 
 // Update リフレッシュトークンを更新
@@ -144,6 +177,50 @@ func (r *refreshTokenRepository) Revoke(tokenID int) error {
 	return err
 }
 
+// Rotate 旧トークンを無効化し、後継トークンを同一トランザクションで保存
+func (r *refreshTokenRepository) Rotate(oldTokenID int, newToken *domain.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.insert(tx, newToken); err != nil {
+		return err
+	}
+
+	// 未使用の旧トークンのみを無効化する（同時リクエストによる二重ローテーションもここで検知）
+	query := `
+		UPDATE refresh_tokens
+		SET revoked = true, revoked_at = $2, updated_at = $2, last_used_at = $2, replaced_by_id = $3
+		WHERE id = $1 AND revoked = false`
+
+	result, err := tx.Exec(query, oldTokenID, time.Now(), newToken.ID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrRefreshTokenReused
+	}
+
+	return tx.Commit()
+}
+
+// RevokeFamily ファミリー内のすべてのリフレッシュトークンを無効化
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked = true, revoked_at = $2, updated_at = $3
+		WHERE family_id = $1 AND revoked = false`
+
+	_, err := r.db.Exec(query, familyID, time.Now(), time.Now())
+	return err
+}
+
 // RevokeAllByUserID ユーザーのすべてのリフレッシュトークンを無効化
 func (r *refreshTokenRepository) RevokeAllByUserID(userID int) error {
 	query := `
@@ -165,4 +242,4 @@ func (r *refreshTokenRepository) DeleteExpired() error {
 	sevenDaysAgo := time.Now().Add(-7 * 24 * time.Hour)
 	_, err := r.db.Exec(query, time.Now(), sevenDaysAgo)
 	return err
-}
\ No newline at end of file
+}
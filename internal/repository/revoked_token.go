@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// revokedTokenRepository 失効トークンリストのPostgreSQL実装
+type revokedTokenRepository struct {
+	db *sql.DB
+}
+
+// NewRevokedTokenRepository 失効トークンリポジトリのコンストラクタ
+func NewRevokedTokenRepository(db *sql.DB) domain.RevokedTokenStore {
+	return &revokedTokenRepository{db: db}
+}
+
+// Revoke JTIを失効リストに追加
+func (r *revokedTokenRepository) Revoke(jti string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)`
+
+	_, err := r.db.Exec(query, jti, expiresAt, time.Now())
+	return err
+}
+
+// IsRevoked JTIが失効リストに含まれているかどうか
+func (r *revokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > $2)`
+
+	var revoked bool
+	err := r.db.QueryRow(query, jti, time.Now()).Scan(&revoked)
+	return revoked, err
+}
+
+// Prune 有効期限を過ぎたエントリを削除
+func (r *revokedTokenRepository) Prune() error {
+	_, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= $1`, time.Now())
+	return err
+}
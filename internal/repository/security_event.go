@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// securityEventRepository セキュリティイベントリポジトリの実装
+type securityEventRepository struct {
+	db *sql.DB
+}
+
+// NewSecurityEventRepository セキュリティイベントリポジトリのコンストラクタ
+func NewSecurityEventRepository(db *sql.DB) domain.SecurityEventRepository {
+	return &securityEventRepository{db: db}
+}
+
+// Create セキュリティイベントを記録
+func (r *securityEventRepository) Create(event *domain.SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (user_id, event_type, description, ip_address, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	event.CreatedAt = time.Now()
+	return r.db.QueryRow(
+		query,
+		event.UserID,
+		event.EventType,
+		event.Description,
+		event.IPAddress,
+		event.CreatedAt,
+	).Scan(&event.ID)
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// serviceAccountRepository サービスアカウントリポジトリの実装
+type serviceAccountRepository struct {
+	db *sql.DB
+}
+
+// NewServiceAccountRepository サービスアカウントリポジトリのコンストラクタ
+func NewServiceAccountRepository(db *sql.DB) domain.ServiceAccountRepository {
+	return &serviceAccountRepository{db: db}
+}
+
+// Create サービスアカウントを保存
+func (r *serviceAccountRepository) Create(account *domain.ServiceAccount) error {
+	query := `
+		INSERT INTO service_accounts (name, description, client_id, client_secret_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(query, account.Name, account.Description, account.ClientID, account.ClientSecretHash, time.Now()).
+		Scan(&account.ID, &account.CreatedAt, &account.UpdatedAt)
+}
+
+// GetByID IDで取得
+func (r *serviceAccountRepository) GetByID(id int) (*domain.ServiceAccount, error) {
+	query := `
+		SELECT id, name, description, client_id, client_secret_hash, last_used_at, created_at, updated_at
+		FROM service_accounts
+		WHERE id = $1`
+
+	return r.getOne(query, id)
+}
+
+// GetByClientID クライアントIDで取得
+func (r *serviceAccountRepository) GetByClientID(clientID string) (*domain.ServiceAccount, error) {
+	query := `
+		SELECT id, name, description, client_id, client_secret_hash, last_used_at, created_at, updated_at
+		FROM service_accounts
+		WHERE client_id = $1`
+
+	return r.getOne(query, clientID)
+}
+
+// List サービスアカウント一覧を取得
+func (r *serviceAccountRepository) List() ([]*domain.ServiceAccount, error) {
+	query := `
+		SELECT id, name, description, client_id, client_secret_hash, last_used_at, created_at, updated_at
+		FROM service_accounts
+		ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []*domain.ServiceAccount{}
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// UpdateSecretHash クライアントシークレットのハッシュ値を更新
+func (r *serviceAccountRepository) UpdateSecretHash(id int, secretHash string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE service_accounts SET client_secret_hash = $2, updated_at = $3 WHERE id = $1`, id, secretHash, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// Delete サービスアカウントを削除（割り当てたロールも削除される）
+func (r *serviceAccountRepository) Delete(id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM service_accounts WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// UpdateLastUsed 最終使用日時を更新
+func (r *serviceAccountRepository) UpdateLastUsed(id int, usedAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE service_accounts SET last_used_at = $2 WHERE id = $1`, id, usedAt)
+	return err
+}
+
+func (r *serviceAccountRepository) getOne(query string, arg interface{}) (*domain.ServiceAccount, error) {
+	account, err := scanServiceAccount(r.db.QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// scanServiceAccount 1行分のサービスアカウントを読み取る
+func scanServiceAccount(row interface{ Scan(...interface{}) error }) (*domain.ServiceAccount, error) {
+	var account domain.ServiceAccount
+	err := row.Scan(
+		&account.ID,
+		&account.Name,
+		&account.Description,
+		&account.ClientID,
+		&account.ClientSecretHash,
+		&account.LastUsedAt,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+// signInLinkRequired メールアドレスが一致する既存のアカウントへの連携の確認を求められることを確認し、そのトークンを返す
+func signInLinkRequired(t *testing.T, i *testIdentity, oauthUser *domain.OAuthUser) string {
+	t.Helper()
+
+	var linkErr *domain.AccountLinkRequiredError
+	if _, err := i.usecase.SignIn(oauthUser, "test-agent", "192.0.2.1"); !errors.As(err, &linkErr) {
+		t.Fatalf("SignIn() error = %v, want *domain.AccountLinkRequiredError", err)
+	}
+	return linkErr.Token
+}
+
+func TestSignInAccountMatchingPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		existingVerified bool
+		oauthUser        *domain.OAuthUser
+		wantAutoLink     bool
+	}{
+		{
+			name:             "auto-link provider with verified emails",
+			existingVerified: true,
+			oauthUser:        testOAuthUser("google", "google-1", "alice@example.com"),
+			wantAutoLink:     true,
+		},
+		{
+			name:             "provider not allowed to auto-link",
+			existingVerified: true,
+			oauthUser:        testOAuthUser("line", "line-1", "alice@example.com"),
+		},
+		{
+			name:             "email not verified by the provider",
+			existingVerified: true,
+			oauthUser:        &domain.OAuthUser{ProviderID: "google-1", ProviderName: "google", Email: "alice@example.com"},
+		},
+		{
+			// 他人が先に未確認のまま登録したアカウントには連携しない
+			name:      "existing account not verified",
+			oauthUser: testOAuthUser("google", "google-1", "alice@example.com"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &domain.User{Name: "alice", Email: "alice@example.com", Password: testStrongPassword}
+			if tt.existingVerified {
+				existing = verifiedUser("alice@example.com", testStrongPassword)
+			}
+			i := newTestIdentityUsecase(t, existing)
+
+			response, err := i.usecase.SignIn(tt.oauthUser, "test-agent", "192.0.2.1")
+			identities, _ := i.usecase.ListIdentities(1)
+
+			if tt.wantAutoLink {
+				if err != nil {
+					t.Fatalf("SignIn() error = %v", err)
+				}
+				if response.User.ID != 1 || len(identities) != 1 {
+					t.Errorf("SignIn() = user %d with %d identities, want user 1 with the identity linked", response.User.ID, len(identities))
+				}
+				return
+			}
+
+			var linkErr *domain.AccountLinkRequiredError
+			if !errors.As(err, &linkErr) {
+				t.Fatalf("SignIn() error = %v, want *domain.AccountLinkRequiredError", err)
+			}
+			if linkErr.Provider != tt.oauthUser.ProviderName || linkErr.Email != tt.oauthUser.Email || linkErr.Token == "" {
+				t.Errorf("AccountLinkRequiredError = %+v, want provider, email and token", linkErr)
+			}
+			// 確認されるまで連携もアカウントの作成もしない
+			if len(identities) != 0 || len(i.users.users) != 1 {
+				t.Errorf("%d identities and %d users, want no changes before confirmation", len(identities), len(i.users.users))
+			}
+		})
+	}
+}
+
+func TestConfirmLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int
+		token   func(t *testing.T, i *testIdentity, token string) string
+		wantErr error
+	}{
+		{
+			name:   "existing account owner",
+			userID: 1,
+			token:  func(t *testing.T, i *testIdentity, token string) string { return token },
+		},
+		{
+			name:    "another user",
+			userID:  2,
+			token:   func(t *testing.T, i *testIdentity, token string) string { return token },
+			wantErr: domain.ErrInvalidAccountLink,
+		},
+		{
+			name:   "used token",
+			userID: 1,
+			token: func(t *testing.T, i *testIdentity, token string) string {
+				if _, err := i.usecase.ConfirmLink(1, token, "192.0.2.1"); err != nil {
+					t.Fatalf("ConfirmLink() error = %v", err)
+				}
+				return token
+			},
+			wantErr: domain.ErrInvalidAccountLink,
+		},
+		{
+			name:   "access token",
+			userID: 1,
+			token: func(t *testing.T, i *testIdentity, token string) string {
+				return loginTestUser(t, i.testAuthDeps).AccessToken
+			},
+			wantErr: domain.ErrInvalidAccountLink,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestIdentityUsecase(t,
+				verifiedUser("alice@example.com", testStrongPassword),
+				verifiedUser("bob@example.com", testStrongPassword),
+			)
+			oauthUser := testOAuthUser("line", "line-1", "alice@example.com")
+			token := tt.token(t, i, signInLinkRequired(t, i, oauthUser))
+
+			identity, err := i.usecase.ConfirmLink(tt.userID, token, "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConfirmLink() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if identity.UserID != 1 || identity.Provider != "line" || identity.Subject != "line-1" {
+				t.Errorf("ConfirmLink() = %+v, want line/line-1 for user 1", identity)
+			}
+			// 確認後はプロバイダーでログインできる
+			response, err := i.usecase.SignIn(oauthUser, "test-agent", "192.0.2.1")
+			if err != nil || response.User.ID != 1 {
+				t.Errorf("SignIn() after confirmation = %+v, %v, want user 1", response, err)
+			}
+		})
+	}
+}
+
+func TestGetPendingLink(t *testing.T) {
+	i := newTestIdentityUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+	token := signInLinkRequired(t, i, testOAuthUser("line", "line-1", "alice@example.com"))
+
+	pending, err := i.usecase.GetPendingLink(token)
+	if err != nil {
+		t.Fatalf("GetPendingLink() error = %v", err)
+	}
+	if pending.Provider != "line" || pending.Email != "alice@example.com" || pending.ExpiresAt.IsZero() {
+		t.Errorf("GetPendingLink() = %+v, want the line account and an expiry", pending)
+	}
+
+	if _, err := i.usecase.GetPendingLink(token + "x"); !errors.Is(err, domain.ErrInvalidAccountLink) {
+		t.Errorf("GetPendingLink() with tampered token error = %v, want %v", err, domain.ErrInvalidAccountLink)
+	}
+}
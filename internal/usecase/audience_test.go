@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testOtherAudience = "billing-service"
+
+// accessClaims テスト用のアクセストークンのクレーム
+func accessClaims() domain.Claims {
+	now := time.Now()
+	return domain.Claims{
+		UserID:   1,
+		Email:    "alice@example.com",
+		TokenUse: domain.TokenUseAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "access-jti",
+			Issuer:    testServiceIssuer,
+			Subject:   "1",
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestGenerateTokenForAudience(t *testing.T) {
+	tests := []struct {
+		name              string
+		audience          string
+		validateAudiences []string // ValidateTokenに渡すaud（省略時は自サービス）
+		wantIssueErr      error
+		wantValidateErr   error
+	}{
+		{name: "own audience", audience: testAudience},
+		{name: "other service", audience: testOtherAudience, validateAudiences: []string{testOtherAudience}},
+		{
+			name:            "other service token rejected by own api",
+			audience:        testOtherAudience,
+			wantValidateErr: domain.ErrInvalidAudience,
+		},
+		{
+			name:              "route group accepting several audiences",
+			audience:          testOtherAudience,
+			validateAudiences: []string{testAudience, testOtherAudience},
+		},
+		{name: "not configured", audience: "https://evil.example.com", wantIssueErr: domain.ErrInvalidAudience},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+			deps.usecase.jwtConfig.Audiences = []string{testOtherAudience}
+
+			token, err := deps.usecase.GenerateTokenForAudience(1, tt.audience)
+			if !errors.Is(err, tt.wantIssueErr) {
+				t.Fatalf("GenerateTokenForAudience() error = %v, want %v", err, tt.wantIssueErr)
+			}
+			if err != nil {
+				return
+			}
+			if token.Audience != tt.audience {
+				t.Errorf("Audience = %q, want %q", token.Audience, tt.audience)
+			}
+
+			claims, err := deps.usecase.ValidateToken(token.AccessToken, tt.validateAudiences...)
+			if !errors.Is(err, tt.wantValidateErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantValidateErr)
+			}
+			if err == nil && claims.Issuer != testServiceIssuer {
+				t.Errorf("Issuer = %q, want %q", claims.Issuer, testServiceIssuer)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRegisteredClaims(t *testing.T) {
+	leeway := testJWTConfig().Leeway
+
+	tests := []struct {
+		name    string
+		modify  func(claims *domain.Claims)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(claims *domain.Claims) {}},
+		{
+			name:    "another issuer",
+			modify:  func(claims *domain.Claims) { claims.Issuer = "https://evil.example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "missing issuer",
+			modify:  func(claims *domain.Claims) { claims.Issuer = "" },
+			wantErr: true,
+		},
+		{
+			name:    "missing audience",
+			modify:  func(claims *domain.Claims) { claims.Audience = nil },
+			wantErr: true,
+		},
+		{
+			name: "expired within leeway",
+			modify: func(claims *domain.Claims) {
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-leeway / 2))
+			},
+		},
+		{
+			name: "expired beyond leeway",
+			modify: func(claims *domain.Claims) {
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-2 * leeway))
+			},
+			wantErr: true,
+		},
+		{
+			name: "not yet valid within leeway",
+			modify: func(claims *domain.Claims) {
+				claims.NotBefore = jwt.NewNumericDate(time.Now().Add(leeway / 2))
+			},
+		},
+		{
+			name: "not yet valid beyond leeway",
+			modify: func(claims *domain.Claims) {
+				claims.NotBefore = jwt.NewNumericDate(time.Now().Add(2 * leeway))
+			},
+			wantErr: true,
+		},
+		{
+			name:    "mfa challenge token",
+			modify:  func(claims *domain.Claims) { claims.TokenUse = domain.TokenUseMFAChallenge },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestAuthUsecase(t)
+			claims := accessClaims()
+			tt.modify(&claims)
+			token, err := signJWT(deps.keyManager, claims)
+			if err != nil {
+				t.Fatalf("signJWT() error = %v", err)
+			}
+
+			if _, err := deps.usecase.ValidateToken(token); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTokenPinsAlgorithm(t *testing.T) {
+	deps := newTestAuthUsecase(t)
+
+	// 鍵セットにRS256の鍵があっても、設定で許可していないアルゴリズムは拒否する
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	signingKey, err := NewSigningKey("rsa-key", rsaKey)
+	if err != nil {
+		t.Fatalf("NewSigningKey() error = %v", err)
+	}
+	keyManager, err := NewKeyManager("rsa-key", []*domain.SigningKey{signingKey, deps.keyManager.SigningKey()})
+	if err != nil {
+		t.Fatalf("NewKeyManager() error = %v", err)
+	}
+	deps.usecase.keyManager = keyManager
+
+	token, err := signJWT(keyManager, accessClaims())
+	if err != nil {
+		t.Fatalf("signJWT() error = %v", err)
+	}
+	if _, err := deps.usecase.ValidateToken(token); err == nil {
+		t.Error("ValidateToken() with RS256 token error = nil, want error")
+	}
+
+	deps.usecase.jwtConfig.Algorithms = []string{domain.SigningAlgES256, domain.SigningAlgRS256}
+	if _, err := deps.usecase.ValidateToken(token); err != nil {
+		t.Errorf("ValidateToken() with RS256 allowed error = %v", err)
+	}
+}
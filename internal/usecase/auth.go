@@ -1,9 +1,13 @@
 package usecase
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"go-echo-demo/internal/domain"
@@ -13,34 +17,94 @@ import (
 )
 
 type AuthUsecase struct {
-	authRepo         domain.AuthRepository
-	refreshTokenRepo domain.RefreshTokenRepository
-	userRepo         domain.UserRepository
-	jwtConfig        domain.JWTConfig
+	authRepo           domain.AuthRepository
+	refreshTokenRepo   domain.RefreshTokenRepository
+	userRepo           domain.UserRepository
+	securityEventRepo  domain.SecurityEventRepository
+	revokedTokenStore  domain.RevokedTokenStore
+	mfaRepo            domain.MFARepository
+	rbacRepo           domain.RBACRepository
+	serviceAccountRepo domain.ServiceAccountRepository
+	loginThrottle      domain.LoginThrottle
+	passwordHasher     domain.PasswordHasher
+	keyManager         domain.KeyManager
+	jwtConfig          domain.JWTConfig
+	// ユーザーが存在しない場合にも同じコストの検証を行うためのダミーハッシュ
+	dummyPasswordHash string
 }
 
 func NewAuthUsecase(
 	authRepo domain.AuthRepository,
 	refreshTokenRepo domain.RefreshTokenRepository,
 	userRepo domain.UserRepository,
+	securityEventRepo domain.SecurityEventRepository,
+	revokedTokenStore domain.RevokedTokenStore,
+	mfaRepo domain.MFARepository,
+	rbacRepo domain.RBACRepository,
+	serviceAccountRepo domain.ServiceAccountRepository,
+	loginThrottle domain.LoginThrottle,
+	passwordHasher domain.PasswordHasher,
+	keyManager domain.KeyManager,
 	jwtConfig domain.JWTConfig,
-) domain.AuthUsecase {
-	return &AuthUsecase{
-		authRepo:         authRepo,
-		refreshTokenRepo: refreshTokenRepo,
-		userRepo:         userRepo,
-		jwtConfig:        jwtConfig,
+) (domain.AuthUsecase, error) {
+	// 空のハッシュで検証すると、存在しないユーザーのログインだけが速く失敗して存在有無が分かるため、作成できない場合は起動しない
+	dummyPasswordHash, err := passwordHasher.Hash(uuid.New().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dummy password hash: %w", err)
 	}
+
+	return &AuthUsecase{
+		authRepo:           authRepo,
+		refreshTokenRepo:   refreshTokenRepo,
+		userRepo:           userRepo,
+		securityEventRepo:  securityEventRepo,
+		revokedTokenStore:  revokedTokenStore,
+		mfaRepo:            mfaRepo,
+		rbacRepo:           rbacRepo,
+		serviceAccountRepo: serviceAccountRepo,
+		loginThrottle:      loginThrottle,
+		passwordHasher:     passwordHasher,
+		keyManager:         keyManager,
+		jwtConfig:          jwtConfig,
+		dummyPasswordHash:  dummyPasswordHash,
+	}, nil
 }
 
-func (u *AuthUsecase) Login(email, password string) (*domain.AuthResponse, error) {
-	user, err := u.authRepo.ValidateCredentials(email, password)
+func (u *AuthUsecase) Login(email, password, deviceInfo, ipAddress string) (*domain.AuthResponse, error) {
+	// アカウントとクライアントIPの両方で試行回数を制限する
+	// メールアドレスが存在しない場合も同じように記録し、ロック状態から存在有無が分からないようにする
+	attemptKeys := []string{accountAttemptKey(email), ipAttemptKey(ipAddress)}
+	if err := u.loginThrottle.Check(attemptKeys...); err != nil {
+		return nil, err
+	}
+
+	user, err := u.verifyCredentials(email, password)
+	if errors.Is(err, domain.ErrInvalidCredentials) {
+		u.loginThrottle.RecordFailure(nil, ipAddress, attemptKeys...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 共有IPの利用者が巻き込まれないよう、成功時はアカウントのキーのみリセット
+	u.loginThrottle.Reset(accountAttemptKey(email))
+
+	// メールアドレスが未確認のアカウントはログインさせない
+	if user.EmailVerifiedAt == nil {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	// 二要素認証が有効な場合はトークンペアの代わりに短命なチャレンジトークンを返す
+	challenge, err := u.IssueMFAChallenge(user)
 	if err != nil {
 		return nil, err
 	}
+	if challenge != nil {
+		return challenge, nil
+	}
 
-	// トークンペアを生成（デバイス情報とIPアドレスは後でハンドラーから渡すことも可能）
-	tokenPair, err := u.GenerateTokenPair(user, "", "")
+	// トークンペアを生成（セッション管理のためデバイス情報とIPアドレスを記録）
+	tokenPair, err := u.GenerateTokenPair(user, deviceInfo, ipAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -52,39 +116,295 @@ func (u *AuthUsecase) Login(email, password string) (*domain.AuthResponse, error
 	}, nil
 }
 
+// verifyCredentials パスワードを検証し、必要に応じて現在の設定で再ハッシュする
+func (u *AuthUsecase) verifyCredentials(email, password string) (*domain.User, error) {
+	user, err := u.authRepo.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		// メールアドレスの存在有無が応答時間から分からないようにダミー検証を行う
+		u.passwordHasher.Verify(u.dummyPasswordHash, password)
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	match, needsRehash, err := u.passwordHasher.Verify(user.Password, password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	// 平文や弱いパラメータのハッシュはログイン成功時に透過的に更新
+	if needsRehash {
+		if newHash, err := u.passwordHasher.Hash(password); err != nil {
+			log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		} else if err := u.authRepo.UpdatePassword(user.ID, newHash); err != nil {
+			log.Printf("Failed to update password hash for user %d: %v", user.ID, err)
+		} else {
+			user.Password = newHash
+		}
+	}
+
+	return user, nil
+}
+
 func (u *AuthUsecase) GenerateToken(user *domain.User) (string, error) {
+	return u.generateAccessToken(user, u.jwtConfig.Audience)
+}
+
+// GenerateTokenForAudience 他の内部サービス（aud）向けのアクセストークンを発行
+// 発行したトークンは自サービスのAPIでは受け付けられない
+func (u *AuthUsecase) GenerateTokenForAudience(userID int, audience string) (*domain.AudienceToken, error) {
+	if audience != u.jwtConfig.Audience && !slices.Contains(u.jwtConfig.Audiences, audience) {
+		return nil, domain.ErrInvalidAudience
+	}
+
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	token, err := u.generateAccessToken(user, audience)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AudienceToken{
+		AccessToken: token,
+		Audience:    audience,
+		ExpiresIn:   int(u.jwtConfig.Duration.Seconds()),
+	}, nil
+}
+
+// GenerateServiceAccountToken サービスアカウント向けのアクセストークンを発行
+// subはサービスアカウント（"service:<client_id>"）で、リフレッシュトークンは発行しない
+func (u *AuthUsecase) GenerateServiceAccountToken(account *domain.ServiceAccount, audience string) (*domain.OAuthTokenResponse, error) {
+	if audience == "" {
+		audience = u.jwtConfig.Audience
+	}
+	if audience != u.jwtConfig.Audience && !slices.Contains(u.jwtConfig.Audiences, audience) {
+		return nil, domain.ErrInvalidAudience
+	}
+
+	claims := domain.Claims{
+		TokenUse:         domain.TokenUseAccess,
+		ServiceAccountID: account.ID,
+		ClientID:         account.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			Subject:   account.Subject(),
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(u.jwtConfig.Duration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	if u.jwtConfig.AuthzClaims {
+		roles, err := u.rbacRepo.GetServiceAccountRoles(account.ID)
+		if err != nil {
+			log.Printf("Failed to get roles for service account %d: %v", account.ID, err)
+		} else {
+			claims.Roles, claims.Scope = u.roleClaims(roles)
+		}
+	}
+
+	token, err := signJWT(u.keyManager, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(u.jwtConfig.Duration.Seconds()),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+// generateAccessToken 指定したaud向けのアクセストークンを生成
+func (u *AuthUsecase) generateAccessToken(user *domain.User, audience string) (string, error) {
 	// JWT IDを生成
 	jti := uuid.New().String()
-	
+
 	claims := domain.Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:   user.ID,
+		Email:    user.Email,
+		TokenUse: domain.TokenUseAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        jti, // JWT IDを追加
+			Issuer:    u.jwtConfig.Issuer,
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{audience},
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(u.jwtConfig.Duration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(u.jwtConfig.SecretKey))
+	// ログイン時・リフレッシュ時のロールと権限をクレームに含める
+	if u.jwtConfig.AuthzClaims {
+		claims.Roles, claims.Scope = u.authzClaims(user.ID)
+	}
+
+	// アクティブな署名鍵で署名（kidヘッダー付き）
+	return signJWT(u.keyManager, claims)
+}
+
+// authzClaims ユーザーのロール名と権限スコープを取得
+// 取得に失敗した場合はクレームなしで発行し、認可はDBでの判定にフォールバックさせる
+func (u *AuthUsecase) authzClaims(userID int) ([]string, string) {
+	roles, err := u.rbacRepo.GetUserRoles(userID)
+	if err != nil {
+		log.Printf("Failed to get roles for user %d: %v", userID, err)
+		return nil, ""
+	}
+
+	return u.roleClaims(roles)
 }
 
-func (u *AuthUsecase) ValidateToken(tokenString string) (*domain.Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &domain.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(u.jwtConfig.SecretKey), nil
-	})
+// roleClaims ロールの一覧からロール名と権限スコープのクレームを作成
+func (u *AuthUsecase) roleClaims(roles []domain.Role) ([]string, string) {
+	roleNames := make([]string, 0, len(roles))
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+
+		permissions, err := u.rbacRepo.GetRolePermissions(role.ID)
+		if err != nil {
+			log.Printf("Failed to get permissions for role %d: %v", role.ID, err)
+			return nil, ""
+		}
+		for _, permission := range permissions {
+			scope := domain.PermissionScope(permission.Resource, permission.Action)
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	sort.Strings(scopes)
 
+	return roleNames, strings.Join(scopes, " ")
+}
+
+// ValidateToken アクセストークンを検証
+// audiencesを省略した場合は自サービスのaud向けのトークンのみ受け付ける
+func (u *AuthUsecase) ValidateToken(tokenString string, audiences ...string) (*domain.Claims, error) {
+	claims := &domain.Claims{}
+	if _, err := parseJWT(u.keyManager, tokenString, claims, u.parserOptions()...); err != nil {
+		return nil, err
+	}
+
+	// 二要素認証のチャレンジトークン等、アクセストークン以外は受け付けない
+	if claims.TokenUse != domain.TokenUseAccess {
+		return nil, errors.New("token is not an access token")
+	}
+
+	// 他のサービス向けに発行されたトークンは受け付けない
+	if len(audiences) == 0 {
+		audiences = []string{u.jwtConfig.Audience}
+	}
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool { return slices.Contains(audiences, aud) }) {
+		return nil, domain.ErrInvalidAudience
+	}
+
+	if err := u.checkRevoked(claims.ID); err != nil {
+		return nil, err
+	}
+
+	// 削除されたサービスアカウントのトークンは有効期限内でも拒否する
+	if claims.IsServiceAccount() {
+		account, err := u.serviceAccountRepo.GetByID(claims.ServiceAccountID)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil || account.ClientID != claims.ClientID {
+			return nil, domain.ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+// IssueMFAChallenge 二要素認証が有効なユーザーにチャレンジトークンを発行（無効な場合はnil）
+// パスワードログインとOAuthログインの両方から呼ばれる
+func (u *AuthUsecase) IssueMFAChallenge(user *domain.User) (*domain.AuthResponse, error) {
+	mfa, err := u.mfaRepo.GetByUserID(user.ID)
 	if err != nil {
 		return nil, err
 	}
+	if mfa == nil || !mfa.Enabled {
+		return nil, nil
+	}
+
+	claims := domain.Claims{
+		UserID:   user.ID,
+		Email:    user.Email,
+		TokenUse: domain.TokenUseMFAChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(u.jwtConfig.MFAChallengeDuration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	mfaToken, err := signJWT(u.keyManager, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AuthResponse{
+		User:        *user,
+		MFARequired: true,
+		MFAToken:    mfaToken,
+	}, nil
+}
+
+// ValidateMFAChallenge チャレンジトークンを検証
+func (u *AuthUsecase) ValidateMFAChallenge(mfaToken string) (*domain.Claims, error) {
+	claims := &domain.Claims{}
+	if _, err := parseJWT(u.keyManager, mfaToken, claims, u.parserOptions()...); err != nil {
+		return nil, err
+	}
+
+	if claims.TokenUse != domain.TokenUseMFAChallenge {
+		return nil, errors.New("token is not an mfa challenge")
+	}
 
-	if claims, ok := token.Claims.(*domain.Claims); ok && token.Valid {
-		return claims, nil
+	// 使用済みのチャレンジトークンは失効リストに登録される
+	if err := u.checkRevoked(claims.ID); err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("invalid token")
+	return claims, nil
+}
+
+// parserOptions 設定に基づくトークン検証のオプション（iss・署名アルゴリズム・時刻のずれ）
+func (u *AuthUsecase) parserOptions() []jwt.ParserOption {
+	return jwtParserOptions(u.jwtConfig)
+}
+
+// checkRevoked ログアウト等で失効したJTIは署名が有効でも拒否する
+func (u *AuthUsecase) checkRevoked(jti string) error {
+	revoked, err := u.revokedTokenStore.IsRevoked(jti)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return domain.ErrTokenRevoked
+	}
+	return nil
 }
 
 // GenerateTokenPair アクセストークンとリフレッシュトークンのペアを生成
@@ -107,14 +427,15 @@ func (u *AuthUsecase) GenerateTokenPair(user *domain.User, deviceInfo, ipAddress
 		return nil, err
 	}
 
-	// リフレッシュトークンをデータベースに保存
+	// リフレッシュトークンはハッシュ値のみをデータベースに保存
 	refreshToken := &domain.RefreshToken{
 		UserID:         user.ID,
-		Token:          refreshTokenString,
+		TokenHash:      hashToken(refreshTokenString),
 		AccessTokenJTI: claims.ID,
 		ExpiresAt:      time.Now().Add(u.jwtConfig.RefreshTokenDuration),
 		DeviceInfo:     deviceInfo,
 		IPAddress:      ipAddress,
+		FamilyID:       uuid.New().String(), // ログインごとに新しいファミリーを開始
 	}
 
 	if err := u.refreshTokenRepo.Create(refreshToken); err != nil {
@@ -128,10 +449,10 @@ func (u *AuthUsecase) GenerateTokenPair(user *domain.User, deviceInfo, ipAddress
 	}, nil
 }
 
-// RefreshToken リフレッシュトークンを使用して新しいトークンペアを生成
-func (u *AuthUsecase) RefreshToken(refreshTokenString string) (*domain.TokenPair, error) {
+// RefreshToken リフレッシュトークンをローテーションして新しいトークンペアを生成
+func (u *AuthUsecase) RefreshToken(refreshTokenString, ipAddress, deviceInfo string) (*domain.TokenPair, error) {
 	// リフレッシュトークンを検証
-	refreshToken, err := u.refreshTokenRepo.GetByToken(refreshTokenString)
+	refreshToken, err := u.refreshTokenRepo.GetByTokenHash(hashToken(refreshTokenString))
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +461,15 @@ func (u *AuthUsecase) RefreshToken(refreshTokenString string) (*domain.TokenPair
 		return nil, errors.New("invalid refresh token")
 	}
 
+	if refreshToken.Revoked {
+		// ローテーション済みのトークンが再提示された場合は盗用とみなしてファミリーごと無効化
+		if refreshToken.ReplacedByID != nil {
+			u.handleRefreshTokenReuse(refreshToken, ipAddress, deviceInfo)
+			return nil, domain.ErrRefreshTokenReused
+		}
+		return nil, errors.New("invalid refresh token")
+	}
+
 	// 有効期限をチェック
 	if time.Now().After(refreshToken.ExpiresAt) {
 		return nil, errors.New("refresh token expired")
@@ -167,32 +497,255 @@ func (u *AuthUsecase) RefreshToken(refreshTokenString string) (*domain.TokenPair
 		return nil, err
 	}
 
-	// リフレッシュトークンのJTIを更新
-	refreshToken.AccessTokenJTI = claims.ID
-	refreshToken.LastUsedAt = &[]time.Time{time.Now()}[0]
-	
-	if err := u.refreshTokenRepo.Update(refreshToken); err != nil {
+	// 新しいリフレッシュトークンを生成（有効期限はファミリーの当初の期限を引き継ぐ）
+	newRefreshTokenString, err := u.generateRefreshTokenString()
+	if err != nil {
+		return nil, err
+	}
+
+	newRefreshToken := &domain.RefreshToken{
+		UserID:         user.ID,
+		TokenHash:      hashToken(newRefreshTokenString),
+		AccessTokenJTI: claims.ID,
+		ExpiresAt:      refreshToken.ExpiresAt,
+		DeviceInfo:     refreshToken.DeviceInfo,
+		IPAddress:      refreshToken.IPAddress,
+		FamilyID:       refreshToken.FamilyID,
+	}
+
+	// 旧トークンを無効化して新しいトークンを保存
+	if err := u.refreshTokenRepo.Rotate(refreshToken.ID, newRefreshToken); err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenReused) {
+			// 同じトークンで並行してローテーションされた
+			u.handleRefreshTokenReuse(refreshToken, ipAddress, deviceInfo)
+		}
 		return nil, err
 	}
 
 	return &domain.TokenPair{
 		AccessToken:  newAccessToken,
-		RefreshToken: refreshTokenString, // 同じリフレッシュトークンを返す
+		RefreshToken: newRefreshTokenString,
 		ExpiresIn:    int(u.jwtConfig.Duration.Seconds()),
 	}, nil
 }
 
-// Logout ログアウト時にリフレッシュトークンを無効化
-func (u *AuthUsecase) Logout(userID int) error {
-	return u.refreshTokenRepo.RevokeAllByUserID(userID)
+// handleRefreshTokenReuse ファミリー全体を無効化してセキュリティイベントを記録
+func (u *AuthUsecase) handleRefreshTokenReuse(refreshToken *domain.RefreshToken, ipAddress, deviceInfo string) {
+	log.Printf("Refresh token reuse detected: user=%d family=%s ip=%s", refreshToken.UserID, refreshToken.FamilyID, ipAddress)
+
+	// ファミリーから発行されたアクセストークンも失効させる
+	if err := u.revokeFamily(refreshToken.FamilyID); err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", refreshToken.FamilyID, err)
+	}
+
+	// 調査に使えるよう、トークンを発行したログイン時ではなく再提示したクライアントのIPアドレスとUser-Agentを記録する
+	userID := refreshToken.UserID
+	event := &domain.SecurityEvent{
+		UserID:    &userID,
+		EventType: domain.SecurityEventRefreshTokenReuse,
+		Description: fmt.Sprintf("rotated refresh token %d was presented again; family %s revoked (user agent: %q, issued to %s)",
+			refreshToken.ID, refreshToken.FamilyID, deviceInfo, refreshToken.IPAddress),
+		IPAddress: ipAddress,
+	}
+	if err := u.securityEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
 }
 
-// generateRefreshTokenString セキュアなランダムトークンを生成
-func (u *AuthUsecase) generateRefreshTokenString() (string, error) {
-	b := make([]byte, 32) // 256ビットのランダム値
-	_, err := rand.Read(b)
+// Logout 現在のセッションのリフレッシュトークンとアクセストークンを無効化
+func (u *AuthUsecase) Logout(userID int, currentJTI string) error {
+	// リフレッシュトークンを伴わないアクセストークンもあるため、提示されたJTIは必ず失効させる
+	if err := u.revokedTokenStore.Revoke(currentJTI, time.Now().Add(u.jwtConfig.Duration)); err != nil {
+		return err
+	}
+
+	familyID, err := u.currentFamilyID(userID, currentJTI)
+	if err != nil {
+		return err
+	}
+	if familyID == "" {
+		return nil
+	}
+
+	return u.revokeFamily(familyID)
+}
+
+// ForceLogout 管理者による強制ログアウト
+func (u *AuthUsecase) ForceLogout(actor string, userID int) error {
+	if err := u.RevokeAllSessions(userID); err != nil {
+		return err
+	}
+
+	event := &domain.SecurityEvent{
+		UserID:      &userID,
+		EventType:   domain.SecurityEventForceLogout,
+		Description: fmt.Sprintf("all sessions revoked by %s", actor),
+	}
+	if err := u.securityEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+
+	return nil
+}
+
+// UnlockAccount 管理者によるアカウント（と二要素認証）のログインロック解除
+func (u *AuthUsecase) UnlockAccount(actor string, userID int) error {
+	user, err := u.userRepo.GetByID(userID)
 	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	u.loginThrottle.Reset(accountAttemptKey(user.Email), mfaAttemptKey(user.ID))
+
+	event := &domain.SecurityEvent{
+		UserID:      &userID,
+		EventType:   domain.SecurityEventLoginUnlocked,
+		Description: fmt.Sprintf("login unlocked by %s", actor),
+	}
+	if err := u.securityEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+
+	return nil
+}
+
+// UnlockIP 管理者によるIPアドレスのログインロック解除
+func (u *AuthUsecase) UnlockIP(actor, ipAddress string) error {
+	u.loginThrottle.Reset(ipAttemptKey(ipAddress))
+
+	event := &domain.SecurityEvent{
+		EventType:   domain.SecurityEventLoginUnlocked,
+		Description: fmt.Sprintf("login from %s unlocked by %s", ipAddress, actor),
+		IPAddress:   ipAddress,
+	}
+	if err := u.securityEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+
+	return nil
+}
+
+// GetSessions ログイン中のセッション一覧を取得
+func (u *AuthUsecase) GetSessions(userID int, currentJTI string) ([]*domain.Session, error) {
+	tokens, err := u.refreshTokenRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	currentFamilyID, err := u.currentFamilyID(userID, currentJTI)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*domain.Session, 0, len(tokens))
+	for _, token := range tokens {
+		if time.Now().After(token.ExpiresAt) {
+			continue
+		}
+		sessions = append(sessions, &domain.Session{
+			ID:           token.FamilyID,
+			DeviceInfo:   token.DeviceInfo,
+			IPAddress:    token.IPAddress,
+			LastActiveAt: token.CreatedAt,
+			ExpiresAt:    token.ExpiresAt,
+			Current:      currentFamilyID != "" && token.FamilyID == currentFamilyID,
+		})
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession 指定したセッションを無効化
+func (u *AuthUsecase) RevokeSession(userID int, sessionID string) error {
+	tokens, err := u.refreshTokenRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	// 自分のセッションのみ無効化できる
+	for _, token := range tokens {
+		if token.FamilyID == sessionID {
+			return u.revokeFamily(sessionID)
+		}
+	}
+
+	return domain.ErrSessionNotFound
+}
+
+// RevokeOtherSessions 現在のセッション以外をすべて無効化
+func (u *AuthUsecase) RevokeOtherSessions(userID int, currentJTI string) error {
+	tokens, err := u.refreshTokenRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	currentFamilyID, err := u.currentFamilyID(userID, currentJTI)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if currentFamilyID != "" && token.FamilyID == currentFamilyID {
+			continue
+		}
+		if err := u.revokeFamily(token.FamilyID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// currentFamilyID アクセストークンのJTIから現在のセッション（リフレッシュトークンのファミリー）を特定（ない場合は空文字）
+// 最新のローテーションより前に発行されたアクセストークンでも同じセッションとして扱うため、
+// JTIが一致するリフレッシュトークンを無効化済みのものも含めて探し、そのファミリーを返す
+func (u *AuthUsecase) currentFamilyID(userID int, jti string) (string, error) {
+	token, err := u.refreshTokenRepo.GetByAccessTokenJTI(userID, jti)
+	if err != nil || token == nil {
 		return "", err
 	}
-	return base64.URLEncoding.EncodeToString(b), nil
+	return token.FamilyID, nil
+}
+
+// revokeFamily ファミリーのリフレッシュトークンと発行済みアクセストークンを無効化
+func (u *AuthUsecase) revokeFamily(familyID string) error {
+	family, err := u.refreshTokenRepo.GetByFamilyID(familyID)
+	if err != nil {
+		return err
+	}
+	u.revokeAccessTokens(family)
+
+	return u.refreshTokenRepo.RevokeFamily(familyID)
+}
+
+// RevokeAllSessions ユーザーのすべてのリフレッシュトークンと発行済みアクセストークンを無効化
+// 強制ログアウトやパスワードの変更・リセット時に使用する
+func (u *AuthUsecase) RevokeAllSessions(userID int) error {
+	tokens, err := u.refreshTokenRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	u.revokeAccessTokens(tokens)
+
+	return u.refreshTokenRepo.RevokeAllByUserID(userID)
+}
+
+// revokeAccessTokens リフレッシュトークンに紐づくアクセストークンのJTIを失効リストに追加
+// アクセストークンは発行から最大でも有効期間分しか使えないため、その間だけ保持すればよい
+func (u *AuthUsecase) revokeAccessTokens(tokens []*domain.RefreshToken) {
+	expiresAt := time.Now().Add(u.jwtConfig.Duration)
+	for _, token := range tokens {
+		if token.AccessTokenJTI == "" {
+			continue
+		}
+		if err := u.revokedTokenStore.Revoke(token.AccessTokenJTI, expiresAt); err != nil {
+			log.Printf("Failed to revoke access token %s: %v", token.AccessTokenJTI, err)
+		}
+	}
+}
+
+// generateRefreshTokenString セキュアなランダムトークンを生成
+func (u *AuthUsecase) generateRefreshTokenString() (string, error) {
+	return generateSecureToken(32) // 256ビットのランダム値
 }
@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+// assignTestRoles ユーザー1にeditor（products:read, products:write）とviewer（products:read）を割り当てる
+func assignTestRoles(deps *testAuthDeps) {
+	deps.rbac.userRoles[1] = []domain.Role{{ID: 1, Name: "editor"}, {ID: 2, Name: "viewer"}}
+	deps.rbac.rolePermissions[1] = []domain.Permission{
+		{Resource: "products", Action: "write"},
+		{Resource: "products", Action: "read"},
+	}
+	deps.rbac.rolePermissions[2] = []domain.Permission{{Resource: "products", Action: "read"}}
+}
+
+func TestAccessTokenAuthzClaims(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		rbacErr     error
+		wantRoles   []string
+		wantScope   string
+		wantHasAuth bool
+	}{
+		{
+			name:        "enabled",
+			enabled:     true,
+			wantRoles:   []string{"editor", "viewer"},
+			wantScope:   "products:read products:write",
+			wantHasAuth: true,
+		},
+		{name: "disabled", enabled: false},
+		{name: "role lookup fails", enabled: true, rbacErr: errors.New("db down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+			deps.usecase.jwtConfig.AuthzClaims = tt.enabled
+			assignTestRoles(deps)
+			deps.rbac.err = tt.rbacErr
+
+			// 取得に失敗してもトークンは発行する
+			claims, err := deps.usecase.ValidateToken(loginTestUser(t, deps).AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if !reflect.DeepEqual(claims.Roles, tt.wantRoles) {
+				t.Errorf("Roles = %v, want %v", claims.Roles, tt.wantRoles)
+			}
+			if claims.Scope != tt.wantScope {
+				t.Errorf("Scope = %q, want %q", claims.Scope, tt.wantScope)
+			}
+			if claims.HasAuthzClaims() != tt.wantHasAuth {
+				t.Errorf("HasAuthzClaims() = %v, want %v", claims.HasAuthzClaims(), tt.wantHasAuth)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenUpdatesAuthzClaims(t *testing.T) {
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+	deps.usecase.jwtConfig.AuthzClaims = true
+	assignTestRoles(deps)
+	tokenPair := loginTestUser(t, deps)
+
+	// ロールの変更はリフレッシュ時に反映する
+	deps.rbac.userRoles[1] = []domain.Role{{ID: 2, Name: "viewer"}}
+	refreshed, err := deps.usecase.RefreshToken(tokenPair.RefreshToken, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	claims, err := deps.usecase.ValidateToken(refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+
+	if !claims.HasRole("viewer") || claims.HasRole("editor") {
+		t.Errorf("Roles = %v, want [viewer]", claims.Roles)
+	}
+	if !claims.HasScope("products", "read") || claims.HasScope("products", "write") {
+		t.Errorf("Scope = %q, want %q", claims.Scope, "products:read")
+	}
+}
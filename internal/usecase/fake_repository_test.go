@@ -0,0 +1,1255 @@
+package usecase
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+const (
+	testServiceIssuer = "http://localhost:8080"
+	testAudience      = "go-echo-demo"
+)
+
+// fakeUserStore ユーザーのインメモリ実装（AuthRepositoryとUserRepositoryの両方として使う）
+type fakeUserStore struct {
+	mutex  sync.Mutex
+	users  map[int]*domain.User
+	nextID int
+}
+
+func newFakeUserStore(users ...*domain.User) *fakeUserStore {
+	s := &fakeUserStore{users: map[int]*domain.User{}, nextID: 1}
+	for _, user := range users {
+		s.Create(user)
+	}
+	return s
+}
+
+// user 保存されているユーザー（パスワードハッシュを含む）を取得
+func (s *fakeUserStore) user(id int) *domain.User {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil
+	}
+	copied := *user
+	return &copied
+}
+
+func (s *fakeUserStore) GetUserByEmail(email string) (*domain.User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, user := range s.users {
+		if user.Email != "" && user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeUserStore) UpdatePassword(userID int, passwordHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if user, exists := s.users[userID]; exists {
+		user.Password = passwordHash
+	}
+	return nil
+}
+
+func (s *fakeUserStore) MarkEmailVerified(userID int, email string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists || user.Email != email {
+		return false, nil
+	}
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	return true, nil
+}
+
+// GetByID パスワードハッシュはユーザーリポジトリからは取得できない
+func (s *fakeUserStore) GetByID(id int) (*domain.User, error) {
+	user := s.user(id)
+	if user != nil {
+		user.Password = ""
+	}
+	return user, nil
+}
+
+func (s *fakeUserStore) GetByEmail(email string) (*domain.User, error) {
+	return s.GetUserByEmail(email)
+}
+
+func (s *fakeUserStore) Create(user *domain.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user.ID = s.nextID
+	s.nextID++
+	copied := *user
+	s.users[user.ID] = &copied
+	return nil
+}
+
+func (s *fakeUserStore) Update(user *domain.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if stored, exists := s.users[user.ID]; exists {
+		stored.Name = user.Name
+		stored.Email = user.Email
+	}
+	return nil
+}
+
+func (s *fakeUserStore) Delete(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.users, id)
+	return nil
+}
+
+// fakeLoginAttemptStore ログイン失敗状況のインメモリ実装
+type fakeLoginAttemptStore struct {
+	mutex    sync.Mutex
+	attempts map[string]*domain.LoginAttempt
+}
+
+func newFakeLoginAttemptStore() *fakeLoginAttemptStore {
+	return &fakeLoginAttemptStore{attempts: map[string]*domain.LoginAttempt{}}
+}
+
+// age 失敗状況の日時を過去にずらし、時間の経過を再現する
+func (s *fakeLoginAttemptStore) age(key string, elapsed time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	attempt, exists := s.attempts[key]
+	if !exists {
+		return
+	}
+	attempt.LastFailureAt = attempt.LastFailureAt.Add(-elapsed)
+	if attempt.LockedUntil != nil {
+		lockedUntil := attempt.LockedUntil.Add(-elapsed)
+		attempt.LockedUntil = &lockedUntil
+	}
+}
+
+func (s *fakeLoginAttemptStore) Get(key string) (*domain.LoginAttempt, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	attempt, exists := s.attempts[key]
+	if !exists {
+		return nil, nil
+	}
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *fakeLoginAttemptStore) RecordFailure(key string, window time.Duration) (*domain.LoginAttempt, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	attempt, exists := s.attempts[key]
+	if !exists {
+		attempt = &domain.LoginAttempt{Key: key}
+		s.attempts[key] = attempt
+	}
+	if now.Sub(attempt.LastFailureAt) > window {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *fakeLoginAttemptStore) Lock(key string, until time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if attempt, exists := s.attempts[key]; exists {
+		attempt.LockedUntil = &until
+		attempt.Failures = 0
+	}
+	return nil
+}
+
+func (s *fakeLoginAttemptStore) Reset(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *fakeLoginAttemptStore) Prune(before time.Time) error {
+	return nil
+}
+
+// fakeSecurityEventRepository 記録したセキュリティイベントを保持する
+type fakeSecurityEventRepository struct {
+	mutex  sync.Mutex
+	events []*domain.SecurityEvent
+}
+
+func (r *fakeSecurityEventRepository) Create(event *domain.SecurityEvent) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+// eventsOfType 指定した種類のイベントを取得
+func (r *fakeSecurityEventRepository) eventsOfType(eventType string) []*domain.SecurityEvent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var events []*domain.SecurityEvent
+	for _, event := range r.events {
+		if event.EventType == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// fakeRevokedTokenStore 失効リストのインメモリ実装
+type fakeRevokedTokenStore struct {
+	mutex   sync.Mutex
+	revoked map[string]time.Time
+}
+
+func newFakeRevokedTokenStore() *fakeRevokedTokenStore {
+	return &fakeRevokedTokenStore{revoked: map[string]time.Time{}}
+}
+
+func (s *fakeRevokedTokenStore) Revoke(jti string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *fakeRevokedTokenStore) IsRevoked(jti string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, revoked := s.revoked[jti]
+	return revoked, nil
+}
+
+func (s *fakeRevokedTokenStore) Prune() error {
+	return nil
+}
+
+// fakeRefreshTokenRepository リフレッシュトークンのインメモリ実装
+// 使用しないメソッドは埋め込んだインターフェース（nil）を呼んでpanicする
+type fakeRefreshTokenRepository struct {
+	domain.RefreshTokenRepository
+
+	mutex  sync.Mutex
+	tokens []*domain.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepository) Create(token *domain.RefreshToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.insert(token)
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) insert(token *domain.RefreshToken) {
+	token.ID = len(r.tokens) + 1
+	token.CreatedAt = time.Now()
+	copied := *token
+	r.tokens = append(r.tokens, &copied)
+}
+
+func (r *fakeRefreshTokenRepository) GetByTokenHash(tokenHash string) (*domain.RefreshToken, error) {
+	tokens := r.find(func(token *domain.RefreshToken) bool { return token.TokenHash == tokenHash })
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return tokens[0], nil
+}
+
+func (r *fakeRefreshTokenRepository) GetByUserID(userID int) ([]*domain.RefreshToken, error) {
+	return r.find(func(token *domain.RefreshToken) bool { return token.UserID == userID && !token.Revoked }), nil
+}
+
+func (r *fakeRefreshTokenRepository) GetByAccessTokenJTI(userID int, jti string) (*domain.RefreshToken, error) {
+	tokens := r.find(func(token *domain.RefreshToken) bool { return token.UserID == userID && token.AccessTokenJTI == jti })
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return tokens[0], nil
+}
+
+func (r *fakeRefreshTokenRepository) GetByFamilyID(familyID string) ([]*domain.RefreshToken, error) {
+	return r.find(func(token *domain.RefreshToken) bool { return token.FamilyID == familyID }), nil
+}
+
+func (r *fakeRefreshTokenRepository) Revoke(tokenID int) error {
+	r.revokeWhere(func(token *domain.RefreshToken) bool { return token.ID == tokenID })
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) Rotate(oldTokenID int, newToken *domain.RefreshToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old := r.tokens[oldTokenID-1]
+	if old.Revoked {
+		return domain.ErrRefreshTokenReused
+	}
+	r.insert(newToken)
+	now := time.Now()
+	replacedByID := newToken.ID
+	old.Revoked = true
+	old.RevokedAt = &now
+	old.ReplacedByID = &replacedByID
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeFamily(familyID string) error {
+	r.revokeWhere(func(token *domain.RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeAllByUserID(userID int) error {
+	r.revokeWhere(func(token *domain.RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+// find 条件に一致するトークンのコピーを取得
+func (r *fakeRefreshTokenRepository) find(match func(token *domain.RefreshToken) bool) []*domain.RefreshToken {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var tokens []*domain.RefreshToken
+	for _, token := range r.tokens {
+		if match(token) {
+			copied := *token
+			tokens = append(tokens, &copied)
+		}
+	}
+	return tokens
+}
+
+func (r *fakeRefreshTokenRepository) revokeWhere(match func(token *domain.RefreshToken) bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if !token.Revoked && match(token) {
+			token.Revoked = true
+			token.RevokedAt = &now
+		}
+	}
+}
+
+// fakeMFARepository 二要素認証の設定のインメモリ実装
+type fakeMFARepository struct {
+	mutex         sync.Mutex
+	configs       map[int]*domain.UserMFA
+	recoveryCodes map[int]map[string]bool // コードのハッシュ値 → 使用済みかどうか
+}
+
+func newFakeMFARepository() *fakeMFARepository {
+	return &fakeMFARepository{configs: map[int]*domain.UserMFA{}, recoveryCodes: map[int]map[string]bool{}}
+}
+
+func (r *fakeMFARepository) GetByUserID(userID int) (*domain.UserMFA, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	mfa, exists := r.configs[userID]
+	if !exists {
+		return nil, nil
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (r *fakeMFARepository) SavePending(userID int, secretEncrypted string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if mfa, exists := r.configs[userID]; exists && mfa.Enabled {
+		return nil
+	}
+	r.configs[userID] = &domain.UserMFA{UserID: userID, SecretEncrypted: secretEncrypted, CreatedAt: time.Now()}
+	return nil
+}
+
+func (r *fakeMFARepository) Enable(userID int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if mfa, exists := r.configs[userID]; exists {
+		now := time.Now()
+		mfa.Enabled = true
+		mfa.ConfirmedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeMFARepository) Delete(userID int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.configs, userID)
+	delete(r.recoveryCodes, userID)
+	return nil
+}
+
+func (r *fakeMFARepository) UpdateLastUsedStep(userID int, step int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	mfa, exists := r.configs[userID]
+	if !exists || step <= mfa.LastUsedStep {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (r *fakeMFARepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	codes := make(map[string]bool, len(codeHashes))
+	for _, codeHash := range codeHashes {
+		codes[codeHash] = false
+	}
+	r.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *fakeMFARepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	used, exists := r.recoveryCodes[userID][codeHash]
+	if !exists || used {
+		return false, nil
+	}
+	r.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+func (r *fakeMFARepository) CountRecoveryCodes(userID int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var remaining int
+	for _, used := range r.recoveryCodes[userID] {
+		if !used {
+			remaining++
+		}
+	}
+	return remaining, nil
+}
+
+// fakePasswordResetRepository パスワードリセットトークンのインメモリ実装
+type fakePasswordResetRepository struct {
+	mutex  sync.Mutex
+	tokens []*domain.PasswordResetToken
+}
+
+func (r *fakePasswordResetRepository) Create(token *domain.PasswordResetToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token.ID = len(r.tokens) + 1
+	token.CreatedAt = time.Now()
+	copied := *token
+	r.tokens = append(r.tokens, &copied)
+	return nil
+}
+
+func (r *fakePasswordResetRepository) GetByTokenHash(tokenHash string) (*domain.PasswordResetToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakePasswordResetRepository) MarkUsed(tokenID int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token := r.tokens[tokenID-1]
+	if token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+func (r *fakePasswordResetRepository) InvalidateByUserID(userID int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// expire トークンの有効期限を過ぎた状態にする
+func (r *fakePasswordResetRepository) expire(tokenHash string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			token.ExpiresAt = time.Now().Add(-time.Minute)
+		}
+	}
+}
+
+// fakeMagicLinkRepository マジックリンクトークンのインメモリ実装
+type fakeMagicLinkRepository struct {
+	mutex  sync.Mutex
+	tokens []*domain.MagicLinkToken
+}
+
+func (r *fakeMagicLinkRepository) Create(token *domain.MagicLinkToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token.ID = len(r.tokens) + 1
+	token.CreatedAt = time.Now()
+	copied := *token
+	r.tokens = append(r.tokens, &copied)
+	return nil
+}
+
+func (r *fakeMagicLinkRepository) GetByTokenHash(tokenHash string) (*domain.MagicLinkToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeMagicLinkRepository) MarkUsed(tokenID int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token := r.tokens[tokenID-1]
+	if token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+func (r *fakeMagicLinkRepository) InvalidateByUserID(userID int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// expire トークンの有効期限を過ぎた状態にする
+func (r *fakeMagicLinkRepository) expire(tokenHash string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			token.ExpiresAt = time.Now().Add(-time.Minute)
+		}
+	}
+}
+
+// fakePersonalAccessTokenRepository パーソナルアクセストークンのインメモリ実装
+type fakePersonalAccessTokenRepository struct {
+	mutex  sync.Mutex
+	tokens []*domain.PersonalAccessToken
+}
+
+func (r *fakePersonalAccessTokenRepository) Create(token *domain.PersonalAccessToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token.ID = len(r.tokens) + 1
+	token.CreatedAt = time.Now()
+	copied := *token
+	r.tokens = append(r.tokens, &copied)
+	return nil
+}
+
+func (r *fakePersonalAccessTokenRepository) GetByTokenHash(tokenHash string) (*domain.PersonalAccessToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// expire トークンの有効期限を過ぎた状態にする
+func (r *fakePersonalAccessTokenRepository) expire(tokenHash string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			expiresAt := time.Now().Add(-time.Minute)
+			token.ExpiresAt = &expiresAt
+		}
+	}
+}
+
+func (r *fakePersonalAccessTokenRepository) ListByUserID(userID int) ([]*domain.PersonalAccessToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var tokens []*domain.PersonalAccessToken
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			copied := *token
+			tokens = append(tokens, &copied)
+		}
+	}
+	return tokens, nil
+}
+
+func (r *fakePersonalAccessTokenRepository) Revoke(userID, tokenID int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.ID == tokenID && token.UserID == userID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakePersonalAccessTokenRepository) RevokeAllByUserID(userID int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakePersonalAccessTokenRepository) UpdateLastUsed(tokenID int, usedAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tokens[tokenID-1].LastUsedAt = &usedAt
+	return nil
+}
+
+// fakeMailer 送信したメールを保持する
+type fakeMailer struct {
+	mutex    sync.Mutex
+	messages []*domain.MailMessage
+}
+
+func (m *fakeMailer) Send(message *domain.MailMessage) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// sent 送信済みのメールを取得
+func (m *fakeMailer) sent() []*domain.MailMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]*domain.MailMessage(nil), m.messages...)
+}
+
+// waitForMessages 非同期に送信されるメールがcount通に達するまで待つ
+func (m *fakeMailer) waitForMessages(t *testing.T, count int) []*domain.MailMessage {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		messages := m.sent()
+		if len(messages) >= count {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sent %d messages, want %d", len(messages), count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// mailLinkToken メール本文のリンクからトークン（queryパラメータ）を取り出す
+func mailLinkToken(t *testing.T, message *domain.MailMessage, param string) string {
+	t.Helper()
+
+	for _, field := range strings.Fields(message.Body) {
+		link, err := url.Parse(field)
+		if err != nil || link.Scheme == "" {
+			continue
+		}
+		if token := link.Query().Get(param); token != "" {
+			return token
+		}
+	}
+	t.Fatalf("no link with %s in message: %s", param, message.Body)
+	return ""
+}
+
+// testAuthDeps テスト用の認証ユースケースと、その保存先
+// fakeRBACRepository ユーザーに割り当てたロールと権限のインメモリ実装
+type fakeRBACRepository struct {
+	domain.RBACRepository
+	userRoles           map[int][]domain.Role
+	serviceAccountRoles map[int][]domain.Role
+	rolePermissions     map[int][]domain.Permission
+	err                 error // 設定した場合はロールの取得に失敗させる
+}
+
+func newFakeRBACRepository() *fakeRBACRepository {
+	return &fakeRBACRepository{
+		userRoles:           make(map[int][]domain.Role),
+		serviceAccountRoles: make(map[int][]domain.Role),
+		rolePermissions:     make(map[int][]domain.Permission),
+	}
+}
+
+func (r *fakeRBACRepository) GetServiceAccountRoles(accountID int) ([]domain.Role, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.serviceAccountRoles[accountID], nil
+}
+
+func (r *fakeRBACRepository) GetUserRoles(userID int) ([]domain.Role, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.userRoles[userID], nil
+}
+
+func (r *fakeRBACRepository) GetRolePermissions(roleID int) ([]domain.Permission, error) {
+	return r.rolePermissions[roleID], nil
+}
+
+func (r *fakeRBACRepository) HasPermission(userID int, resource, action string) (bool, error) {
+	for _, role := range r.userRoles[userID] {
+		for _, permission := range r.rolePermissions[role.ID] {
+			if permission.Resource == resource && permission.Action == action {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// fakeServiceAccountRepository サービスアカウントのインメモリ実装
+type fakeServiceAccountRepository struct {
+	mutex    sync.Mutex
+	accounts map[int]*domain.ServiceAccount
+	nextID   int
+}
+
+func newFakeServiceAccountRepository() *fakeServiceAccountRepository {
+	return &fakeServiceAccountRepository{accounts: make(map[int]*domain.ServiceAccount)}
+}
+
+func (r *fakeServiceAccountRepository) Create(account *domain.ServiceAccount) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.nextID++
+	account.ID = r.nextID
+	account.CreatedAt = time.Now()
+	account.UpdatedAt = account.CreatedAt
+	copied := *account
+	r.accounts[account.ID] = &copied
+	return nil
+}
+
+func (r *fakeServiceAccountRepository) GetByID(id int) (*domain.ServiceAccount, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (r *fakeServiceAccountRepository) GetByClientID(clientID string) (*domain.ServiceAccount, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, account := range r.accounts {
+		if account.ClientID == clientID {
+			copied := *account
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeServiceAccountRepository) List() ([]*domain.ServiceAccount, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var accounts []*domain.ServiceAccount
+	for id := 1; id <= r.nextID; id++ {
+		if account, ok := r.accounts[id]; ok {
+			copied := *account
+			accounts = append(accounts, &copied)
+		}
+	}
+	return accounts, nil
+}
+
+func (r *fakeServiceAccountRepository) UpdateSecretHash(id int, secretHash string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok {
+		return false, nil
+	}
+	account.ClientSecretHash = secretHash
+	return true, nil
+}
+
+func (r *fakeServiceAccountRepository) Delete(id int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.accounts[id]; !ok {
+		return false, nil
+	}
+	delete(r.accounts, id)
+	return true, nil
+}
+
+func (r *fakeServiceAccountRepository) UpdateLastUsed(id int, usedAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if account, ok := r.accounts[id]; ok {
+		account.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+// fakeOIDCRepository OpenID Connect プロバイダーのインメモリ実装
+type fakeOIDCRepository struct {
+	mutex    sync.Mutex
+	clients  []*domain.OIDCClient
+	codes    []*domain.AuthorizationCode
+	consents map[string]*domain.OIDCConsent
+}
+
+func newFakeOIDCRepository() *fakeOIDCRepository {
+	return &fakeOIDCRepository{consents: make(map[string]*domain.OIDCConsent)}
+}
+
+func (r *fakeOIDCRepository) CreateClient(client *domain.OIDCClient) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	client.ID = len(r.clients) + 1
+	client.CreatedAt = time.Now()
+	copied := *client
+	r.clients = append(r.clients, &copied)
+	return nil
+}
+
+func (r *fakeOIDCRepository) GetClientByClientID(clientID string) (*domain.OIDCClient, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, client := range r.clients {
+		if client != nil && client.ClientID == clientID {
+			copied := *client
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOIDCRepository) ListClients() ([]*domain.OIDCClient, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var clients []*domain.OIDCClient
+	for _, client := range r.clients {
+		if client != nil {
+			copied := *client
+			clients = append(clients, &copied)
+		}
+	}
+	return clients, nil
+}
+
+func (r *fakeOIDCRepository) DeleteClient(id int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if id < 1 || id > len(r.clients) || r.clients[id-1] == nil {
+		return false, nil
+	}
+	r.clients[id-1] = nil
+	return true, nil
+}
+
+func (r *fakeOIDCRepository) CreateAuthorizationCode(code *domain.AuthorizationCode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code.ID = len(r.codes) + 1
+	code.CreatedAt = time.Now()
+	copied := *code
+	r.codes = append(r.codes, &copied)
+	return nil
+}
+
+func (r *fakeOIDCRepository) GetAuthorizationCodeByHash(codeHash string) (*domain.AuthorizationCode, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, code := range r.codes {
+		if code.CodeHash == codeHash {
+			copied := *code
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOIDCRepository) MarkAuthorizationCodeUsed(id int, accessTokenJTI string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code := r.codes[id-1]
+	if code.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	code.AccessTokenJTI = accessTokenJTI
+	return true, nil
+}
+
+func (r *fakeOIDCRepository) GetConsent(userID int, clientID string) (*domain.OIDCConsent, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	consent, ok := r.consents[fmt.Sprintf("%d:%s", userID, clientID)]
+	if !ok {
+		return nil, nil
+	}
+	copied := *consent
+	return &copied, nil
+}
+
+func (r *fakeOIDCRepository) SaveConsent(consent *domain.OIDCConsent) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	copied := *consent
+	r.consents[fmt.Sprintf("%d:%s", consent.UserID, consent.ClientID)] = &copied
+	return nil
+}
+
+// expireCodes 発行済みの認可コードをすべて有効期限切れにする
+func (r *fakeOIDCRepository) expireCodes() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, code := range r.codes {
+		code.ExpiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+// fakeOAuthRepository 外部プロバイダーの連携のインメモリ実装（ユーザーはfakeUserStoreに保存する）
+type fakeOAuthRepository struct {
+	mutex      sync.Mutex
+	users      *fakeUserStore
+	identities []*domain.UserIdentity
+}
+
+func (r *fakeOAuthRepository) FindUserByIdentity(oauthUser *domain.OAuthUser) (*domain.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, identity := range r.identities {
+		if identity != nil && identity.Provider == oauthUser.ProviderName && identity.Subject == oauthUser.ProviderID {
+			identity.Email = oauthUser.Email
+			return r.users.GetByID(identity.UserID)
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOAuthRepository) CreateUserWithIdentity(oauthUser *domain.OAuthUser) (*domain.User, error) {
+	if oauthUser.Email != "" {
+		if existing, _ := r.users.GetUserByEmail(oauthUser.Email); existing != nil {
+			return nil, domain.ErrOAuthEmailInUse
+		}
+	}
+
+	user := &domain.User{Name: oauthUser.Name, Email: oauthUser.Email}
+	if err := r.users.Create(user); err != nil {
+		return nil, err
+	}
+	if _, err := r.LinkIdentity(user.ID, oauthUser); err != nil {
+		return nil, err
+	}
+	return r.users.GetByID(user.ID)
+}
+
+func (r *fakeOAuthRepository) LinkIdentity(userID int, oauthUser *domain.OAuthUser) (*domain.UserIdentity, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, identity := range r.identities {
+		if identity != nil && identity.Provider == oauthUser.ProviderName && identity.Subject == oauthUser.ProviderID {
+			if identity.UserID != userID {
+				return nil, domain.ErrIdentityAlreadyLinked
+			}
+			copied := *identity
+			return &copied, nil
+		}
+	}
+
+	identity := &domain.UserIdentity{
+		ID:        len(r.identities) + 1,
+		UserID:    userID,
+		Provider:  oauthUser.ProviderName,
+		Subject:   oauthUser.ProviderID,
+		Email:     oauthUser.Email,
+		Profile:   oauthUser.Raw,
+		CreatedAt: time.Now(),
+	}
+	r.identities = append(r.identities, identity)
+	copied := *identity
+	return &copied, nil
+}
+
+func (r *fakeOAuthRepository) GetIdentity(userID, identityID int) (*domain.UserIdentity, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, identity := range r.identities {
+		if identity != nil && identity.ID == identityID && identity.UserID == userID {
+			copied := *identity
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOAuthRepository) ListIdentities(userID int) ([]*domain.UserIdentity, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var identities []*domain.UserIdentity
+	for _, identity := range r.identities {
+		if identity != nil && identity.UserID == userID {
+			copied := *identity
+			identities = append(identities, &copied)
+		}
+	}
+	return identities, nil
+}
+
+func (r *fakeOAuthRepository) DeleteIdentity(userID, identityID int) (bool, error) {
+	identities, _ := r.ListIdentities(userID)
+	user := r.users.user(userID)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// パスワードも他の連携もない場合は削除しない
+	if (user == nil || user.Password == "") && len(identities) <= 1 {
+		return false, nil
+	}
+	for i, identity := range r.identities {
+		if identity != nil && identity.ID == identityID && identity.UserID == userID {
+			r.identities[i] = nil
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type testAuthDeps struct {
+	usecase         *AuthUsecase
+	users           *fakeUserStore
+	refreshTokens   *fakeRefreshTokenRepository
+	securityEvents  *fakeSecurityEventRepository
+	revokedTokens   *fakeRevokedTokenStore
+	mfa             *fakeMFARepository
+	rbac            *fakeRBACRepository
+	serviceAccounts *fakeServiceAccountRepository
+	loginAttempts   *fakeLoginAttemptStore
+	loginThrottle   domain.LoginThrottle
+	keyManager      domain.KeyManager
+}
+
+// newTestKeyManager ES256の鍵を1つ持つKeyManagerを作成
+func newTestKeyManager(t *testing.T) domain.KeyManager {
+	t.Helper()
+
+	key := newTestECKey(t, "test-key")
+	keyManager, err := NewKeyManager(key.KID, []*domain.SigningKey{key})
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	return keyManager
+}
+
+// newTestPasswordHasher テストが遅くならないよう、コストを下げたargon2idのハッシャーを作成
+func newTestPasswordHasher(t *testing.T) domain.PasswordHasher {
+	t.Helper()
+
+	hasher, err := NewPasswordHasher(testPasswordHashConfig())
+	if err != nil {
+		t.Fatalf("NewPasswordHasher() error = %v", err)
+	}
+	return hasher
+}
+
+func testPasswordHashConfig() domain.PasswordHashConfig {
+	return domain.PasswordHashConfig{
+		Algorithm:     domain.PasswordHashArgon2id,
+		Argon2Memory:  64,
+		Argon2Time:    1,
+		Argon2Threads: 1,
+		BcryptCost:    4,
+	}
+}
+
+func testJWTConfig() domain.JWTConfig {
+	return domain.JWTConfig{
+		Duration:             15 * time.Minute,
+		RefreshTokenDuration: 7 * 24 * time.Hour,
+		MFAChallengeDuration: 5 * time.Minute,
+		Issuer:               testServiceIssuer,
+		Audience:             testAudience,
+		Algorithms:           []string{"ES256"},
+		Leeway:               30 * time.Second,
+	}
+}
+
+func testLoginAttemptConfig() domain.LoginAttemptConfig {
+	return domain.LoginAttemptConfig{
+		MaxAccountFailures: 5,
+		MaxIPFailures:      20,
+		FreeAttempts:       3,
+		BaseDelay:          time.Second,
+		MaxDelay:           time.Minute,
+		LockoutDuration:    15 * time.Minute,
+	}
+}
+
+// newTestAuthUsecase インメモリの保存先を使う認証ユースケースを作成
+func newTestAuthUsecase(t *testing.T, users ...*domain.User) *testAuthDeps {
+	t.Helper()
+
+	deps := &testAuthDeps{
+		users:           newFakeUserStore(users...),
+		refreshTokens:   &fakeRefreshTokenRepository{},
+		securityEvents:  &fakeSecurityEventRepository{},
+		revokedTokens:   newFakeRevokedTokenStore(),
+		mfa:             newFakeMFARepository(),
+		rbac:            newFakeRBACRepository(),
+		serviceAccounts: newFakeServiceAccountRepository(),
+		loginAttempts:   newFakeLoginAttemptStore(),
+		keyManager:      newTestKeyManager(t),
+	}
+	deps.loginThrottle = NewLoginThrottle(deps.loginAttempts, deps.securityEvents, testLoginAttemptConfig())
+
+	authUsecase, err := NewAuthUsecase(
+		deps.users, deps.refreshTokens, deps.users, deps.securityEvents, deps.revokedTokens, deps.mfa,
+		deps.rbac, deps.serviceAccounts, deps.loginThrottle, newTestPasswordHasher(t), deps.keyManager, testJWTConfig(),
+	)
+	if err != nil {
+		t.Fatalf("NewAuthUsecase() error = %v", err)
+	}
+	deps.usecase = authUsecase.(*AuthUsecase)
+	return deps
+}
+
+// verifiedUser メールアドレス確認済みのテストユーザー（passwordは保存するハッシュ）
+func verifiedUser(email, password string) *domain.User {
+	verifiedAt := time.Now().Add(-time.Hour)
+	return &domain.User{
+		Name:            strings.Split(email, "@")[0],
+		Email:           email,
+		Password:        password,
+		EmailVerifiedAt: &verifiedAt,
+	}
+}
@@ -0,0 +1,289 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcDiscoveryRetryInterval ディスカバリーに失敗した後、再取得を試みるまでの間隔
+	oidcDiscoveryRetryInterval = 30 * time.Second
+	// oidcMaxResponseSize ディスカバリー・ユーザー情報のレスポンスの最大サイズ
+	oidcMaxResponseSize = 1 << 20
+)
+
+// GenericOIDCUsecase 設定だけで追加できる汎用のOpenID Connect プロバイダー
+// エンドポイントと署名鍵は初回の利用時にディスカバリーで取得する（起動時にIdPが停止していてもサーバーは起動できる）
+type GenericOIDCUsecase struct {
+	config     domain.GenericOIDCConfig
+	httpClient *http.Client
+
+	mutex           sync.Mutex
+	metadata        *domain.OpenIDConfiguration
+	oauthConfig     *oauth2.Config
+	idTokenVerifier domain.IDTokenVerifier
+	lastAttempt     time.Time
+}
+
+func NewGenericOIDCUsecase(config domain.GenericOIDCConfig) domain.OAuthUsecase {
+	return &GenericOIDCUsecase{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (u *GenericOIDCUsecase) GetProviderName() string {
+	return u.config.Name
+}
+
+func (u *GenericOIDCUsecase) GetAuthURL(state *domain.OAuthState) (string, error) {
+	oauthConfig, _, err := u.discover()
+	if err != nil {
+		return "", err
+	}
+
+	// stateと一緒に保存したコードベリファイアとnonceを認可リクエストに含める
+	return oauthConfig.AuthCodeURL(state.State,
+		oauth2.S256ChallengeOption(state.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", state.Nonce),
+	), nil
+}
+
+func (u *GenericOIDCUsecase) ExchangeCodeForToken(code, codeVerifier string) (*oauth2.Token, error) {
+	oauthConfig, _, err := u.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Exchanging code for token with %s...", u.config.Name)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, u.httpClient)
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+	return token, nil
+}
+
+// GetUserInfo ユーザー情報エンドポイントのクレームからユーザー情報を取得
+func (u *GenericOIDCUsecase) GetUserInfo(token interface{}) (*domain.OAuthUser, error) {
+	oauthToken, ok := token.(*oauth2.Token)
+	if !ok {
+		return nil, fmt.Errorf("invalid token type")
+	}
+
+	claims, err := u.fetchUserInfo(oauthToken)
+	if err != nil {
+		return nil, err
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("userinfo response has no sub claim")
+	}
+
+	return u.mapClaims(subject, claims), nil
+}
+
+func (u *GenericOIDCUsecase) Authenticate(code string, oauthState *domain.OAuthState) (*domain.OAuthUser, error) {
+	log.Printf("Starting %s authentication...", u.config.Name)
+
+	// 認証コードをトークンに交換（PKCEのコードベリファイアを送信）
+	token, err := u.ExchangeCodeForToken(code, oauthState.CodeVerifier)
+	if err != nil {
+		log.Printf("Token exchange failed: %v", err)
+		return nil, err
+	}
+
+	// IDトークンの署名・iss・aud・exp・nonceを検証
+	_, verifier, err := u.discover()
+	if err != nil {
+		return nil, err
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	idTokenClaims, err := verifier.Verify(idToken, oauthState.Nonce)
+	if err != nil {
+		log.Printf("ID token verification failed: %v", err)
+		return nil, err
+	}
+
+	// IDトークンに含まれないクレーム（IdPによってはemail等）はユーザー情報エンドポイントで補う
+	claims := idTokenClaims.Raw
+	if u.needsUserInfo(claims) {
+		userInfo, err := u.fetchUserInfo(token)
+		if err != nil {
+			log.Printf("Get user info failed: %v", err)
+			return nil, err
+		}
+		// 別のユーザーの情報で置き換えられないよう、subの一致を確認する（OpenID Connect Core 5.3.4）
+		if subject, _ := userInfo["sub"].(string); subject != idTokenClaims.Subject {
+			return nil, errors.New("userinfo sub does not match id_token sub")
+		}
+		claims = mergeClaims(claims, userInfo)
+	}
+
+	oauthUser := u.mapClaims(idTokenClaims.Subject, claims)
+	log.Printf("User info retrieved from %s: %s (%s)", u.config.Name, oauthUser.Name, oauthUser.Email)
+
+	return oauthUser, nil
+}
+
+// discover ディスカバリードキュメントを取得してエンドポイントとIDトークンの検証を設定（成功後はキャッシュ）
+func (u *GenericOIDCUsecase) discover() (*oauth2.Config, domain.IDTokenVerifier, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.metadata != nil {
+		return u.oauthConfig, u.idTokenVerifier, nil
+	}
+	// IdPの停止中にリクエストのたびに取得を試みないようにする
+	if time.Since(u.lastAttempt) < oidcDiscoveryRetryInterval {
+		return nil, nil, fmt.Errorf("discovery for %s failed recently; retrying later", u.config.Name)
+	}
+	u.lastAttempt = time.Now()
+
+	discoveryURL := strings.TrimSuffix(u.config.Issuer, "/") + "/.well-known/openid-configuration"
+	var metadata domain.OpenIDConfiguration
+	if err := u.getJSON(discoveryURL, "", &metadata); err != nil {
+		return nil, nil, fmt.Errorf("failed to discover %s: %w", u.config.Name, err)
+	}
+
+	// 別のIdPのメタデータで置き換えられないよう、issuerの完全一致を要求する（OpenID Connect Discovery 4.3）
+	if metadata.Issuer != u.config.Issuer {
+		return nil, nil, fmt.Errorf("discovered issuer %q does not match configured issuer %q", metadata.Issuer, u.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, nil, fmt.Errorf("discovery document for %s is missing required endpoints", u.config.Name)
+	}
+	// PKCEを送っても無視されるIdPでは認可コードの横取りを防げないため、S256への対応を要求する
+	if len(metadata.CodeChallengeMethodsSupported) > 0 && !slices.Contains(metadata.CodeChallengeMethodsSupported, domain.CodeChallengeMethodS256) {
+		return nil, nil, fmt.Errorf("%s does not support PKCE with S256", u.config.Name)
+	}
+
+	u.oauthConfig = &oauth2.Config{
+		ClientID:     u.config.ClientID,
+		ClientSecret: u.config.ClientSecret,
+		RedirectURL:  u.config.RedirectURL,
+		Scopes:       u.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}
+	u.idTokenVerifier = NewIDTokenVerifier(domain.IDTokenVerifierConfig{
+		Issuers:  []string{metadata.Issuer},
+		ClientID: u.config.ClientID,
+		JWKSURL:  metadata.JWKSURI,
+		Leeway:   providerIDTokenLeeway,
+	}, u.httpClient)
+	u.metadata = &metadata
+
+	log.Printf("Discovered OIDC provider %s (%s)", u.config.Name, metadata.Issuer)
+	return u.oauthConfig, u.idTokenVerifier, nil
+}
+
+// needsUserInfo マッピングしたクレームがIDトークンに含まれず、ユーザー情報エンドポイントで補えるか
+func (u *GenericOIDCUsecase) needsUserInfo(claims map[string]interface{}) bool {
+	u.mutex.Lock()
+	userInfoEndpoint := u.metadata.UserinfoEndpoint
+	u.mutex.Unlock()
+	if userInfoEndpoint == "" {
+		return false
+	}
+
+	for _, name := range []string{u.config.Claims.Email, u.config.Claims.Name} {
+		if _, exists := claims[name]; name != "" && !exists {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchUserInfo ユーザー情報エンドポイントからクレームを取得
+func (u *GenericOIDCUsecase) fetchUserInfo(token *oauth2.Token) (map[string]interface{}, error) {
+	u.mutex.Lock()
+	metadata := u.metadata
+	u.mutex.Unlock()
+	if metadata == nil || metadata.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("%s has no userinfo endpoint", u.config.Name)
+	}
+
+	claims := map[string]interface{}{}
+	if err := u.getJSON(metadata.UserinfoEndpoint, token.AccessToken, &claims); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	return claims, nil
+}
+
+// mapClaims 設定したクレーム名でユーザー情報に変換
+func (u *GenericOIDCUsecase) mapClaims(subject string, claims map[string]interface{}) *domain.OAuthUser {
+	stringClaim := func(name string) string {
+		value, _ := claims[name].(string)
+		return value
+	}
+
+	// email_verifiedを文字列で返すIdPもある
+	var verified bool
+	switch value := claims[u.config.Claims.EmailVerified].(type) {
+	case bool:
+		verified = value
+	case string:
+		verified = value == "true"
+	}
+
+	return &domain.OAuthUser{
+		ProviderID:   subject,
+		ProviderName: u.config.Name,
+		Email:        stringClaim(u.config.Claims.Email),
+		Name:         stringClaim(u.config.Claims.Name),
+		Picture:      stringClaim(u.config.Claims.Picture),
+		Verified:     u.config.Claims.EmailVerified != "" && verified,
+		Raw:          claims,
+	}
+}
+
+// getJSON JSONを取得（accessTokenを指定した場合はBearerトークンを付与）
+func (u *GenericOIDCUsecase) getJSON(url, accessToken string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(http.MaxBytesReader(nil, resp.Body, oidcMaxResponseSize)).Decode(v)
+}
+
+// mergeClaims IDトークンのクレームを優先し、含まれないものだけをユーザー情報のクレームで補う
+func mergeClaims(idTokenClaims, userInfo map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(idTokenClaims)+len(userInfo))
+	for name, value := range userInfo {
+		merged[name] = value
+	}
+	for name, value := range idTokenClaims {
+		merged[name] = value
+	}
+	return merged
+}
@@ -0,0 +1,379 @@
+package usecase
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testCodeVerifier = "test-code-verifier-0123456789-abcdefghijklmnopqrstuvwxyz"
+	testAccessToken  = "test-access-token"
+)
+
+// fakeIdP ディスカバリー・JWKS・トークン・ユーザー情報のエンドポイントを持つOpenID Connect プロバイダーのスタブ
+type fakeIdP struct {
+	server *httptest.Server
+	key    *domain.SigningKey
+
+	mutex            sync.Mutex
+	discoveryStatus  int
+	discoveryFetches int
+	modifyDiscovery  func(metadata *domain.OpenIDConfiguration)
+	idTokenClaims    jwt.MapClaims
+	userInfo         map[string]interface{}
+	userInfoFetches  int
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	idp := &fakeIdP{
+		key:             newTestRSAKey(t, "idp-key"),
+		discoveryStatus: http.StatusOK,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) { idp.token(t, w, r) })
+	mux.HandleFunc("/userinfo", idp.userinfo)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	now := time.Now()
+	idp.idTokenClaims = jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"sub":   "subject-1",
+		"aud":   testClientID,
+		"nonce": testNonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+	}
+	idp.userInfo = map[string]interface{}{
+		"sub":            "subject-1",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Test User",
+	}
+	return idp
+}
+
+func (idp *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+
+	idp.discoveryFetches++
+	if idp.discoveryStatus != http.StatusOK {
+		w.WriteHeader(idp.discoveryStatus)
+		return
+	}
+
+	metadata := domain.OpenIDConfiguration{
+		Issuer:                        idp.server.URL,
+		AuthorizationEndpoint:         idp.server.URL + "/authorize",
+		TokenEndpoint:                 idp.server.URL + "/token",
+		UserinfoEndpoint:              idp.server.URL + "/userinfo",
+		JWKSURI:                       idp.server.URL + "/jwks",
+		CodeChallengeMethodsSupported: []string{domain.CodeChallengeMethodS256},
+	}
+	if idp.modifyDiscovery != nil {
+		idp.modifyDiscovery(&metadata)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metadata)
+}
+
+func (idp *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domain.JWKSet{Keys: []domain.JWK{toJWK(idp.key)}})
+}
+
+// token 認可コードとPKCEのコードベリファイアを確認してIDトークンを発行
+func (idp *fakeIdP) token(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("code") != "test-code" || r.PostFormValue("code_verifier") != testCodeVerifier {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	idp.mutex.Lock()
+	idToken := signTestToken(t, idp.key, idp.idTokenClaims)
+	idp.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": testAccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (idp *fakeIdP) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+
+	idp.userInfoFetches++
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(idp.userInfo)
+}
+
+func (idp *fakeIdP) discoveryCount() int {
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+	return idp.discoveryFetches
+}
+
+func newTestGenericOIDC(issuer string) *GenericOIDCUsecase {
+	return NewGenericOIDCUsecase(domain.GenericOIDCConfig{
+		Name:        "test-idp",
+		Issuer:      issuer,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/auth/test-idp/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+		Claims: domain.OIDCClaimMapping{
+			Email:         "email",
+			EmailVerified: "email_verified",
+			Name:          "name",
+			Picture:       "picture",
+		},
+	}).(*GenericOIDCUsecase)
+}
+
+func testOAuthState() *domain.OAuthState {
+	return &domain.OAuthState{State: "test-state", CodeVerifier: testCodeVerifier, Nonce: testNonce}
+}
+
+func TestGenericOIDCAuthenticate(t *testing.T) {
+	idp := newFakeIdP(t)
+	// IDトークンにemailを含めないIdPでは、ユーザー情報エンドポイントで補う
+	idp.userInfo["email_verified"] = "true"
+	u := newTestGenericOIDC(idp.server.URL)
+
+	authURL, err := u.GetAuthURL(testOAuthState())
+	if err != nil {
+		t.Fatalf("GetAuthURL() error = %v", err)
+	}
+	if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") || !strings.Contains(authURL, "code_challenge_method=S256") || !strings.Contains(authURL, "nonce="+testNonce) {
+		t.Fatalf("GetAuthURL() = %s, want authorization endpoint with S256 challenge and nonce", authURL)
+	}
+
+	oauthUser, err := u.Authenticate("test-code", testOAuthState())
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if oauthUser.ProviderID != "subject-1" || oauthUser.ProviderName != "test-idp" {
+		t.Errorf("Authenticate() identity = %s/%s, want test-idp/subject-1", oauthUser.ProviderName, oauthUser.ProviderID)
+	}
+	if oauthUser.Email != "user@example.com" || oauthUser.Name != "Test User" || !oauthUser.Verified {
+		t.Errorf("Authenticate() = %+v, want email, name and verified from userinfo", oauthUser)
+	}
+	if got := idp.discoveryCount(); got != 1 {
+		t.Errorf("discovery fetched %d times, want 1 (cached after success)", got)
+	}
+}
+
+func TestGenericOIDCAuthenticateSkipsUserInfo(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.idTokenClaims["email"] = "token@example.com"
+	idp.idTokenClaims["email_verified"] = true
+	idp.idTokenClaims["name"] = "Token User"
+	u := newTestGenericOIDC(idp.server.URL)
+
+	oauthUser, err := u.Authenticate("test-code", testOAuthState())
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if oauthUser.Email != "token@example.com" || oauthUser.Name != "Token User" || !oauthUser.Verified {
+		t.Errorf("Authenticate() = %+v, want claims from the ID token", oauthUser)
+	}
+	if idp.userInfoFetches != 0 {
+		t.Errorf("userinfo fetched %d times, want 0 when the ID token has every mapped claim", idp.userInfoFetches)
+	}
+}
+
+func TestGenericOIDCAuthenticateUserInfoSubjectMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.userInfo["sub"] = "other-subject"
+	u := newTestGenericOIDC(idp.server.URL)
+
+	_, err := u.Authenticate("test-code", testOAuthState())
+	if err == nil || !strings.Contains(err.Error(), "userinfo sub does not match id_token sub") {
+		t.Fatalf("Authenticate() error = %v, want sub mismatch", err)
+	}
+}
+
+func TestGenericOIDCAuthenticateNonceMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	u := newTestGenericOIDC(idp.server.URL)
+
+	state := testOAuthState()
+	state.Nonce = "other-nonce"
+	if _, err := u.Authenticate("test-code", state); err == nil || !strings.Contains(err.Error(), "nonce does not match") {
+		t.Fatalf("Authenticate() error = %v, want nonce mismatch", err)
+	}
+}
+
+func TestGenericOIDCDiscoveryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(metadata *domain.OpenIDConfiguration)
+		wantErr string
+	}{
+		{
+			name:    "issuer mismatch",
+			modify:  func(metadata *domain.OpenIDConfiguration) { metadata.Issuer = "https://evil.example.com" },
+			wantErr: "does not match configured issuer",
+		},
+		{
+			name:    "issuer with trailing slash",
+			modify:  func(metadata *domain.OpenIDConfiguration) { metadata.Issuer += "/" },
+			wantErr: "does not match configured issuer",
+		},
+		{
+			name: "PKCE without S256",
+			modify: func(metadata *domain.OpenIDConfiguration) {
+				metadata.CodeChallengeMethodsSupported = []string{"plain"}
+			},
+			wantErr: "does not support PKCE with S256",
+		},
+		{
+			name:    "missing JWKS URI",
+			modify:  func(metadata *domain.OpenIDConfiguration) { metadata.JWKSURI = "" },
+			wantErr: "missing required endpoints",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			idp.modifyDiscovery = tt.modify
+			u := newTestGenericOIDC(idp.server.URL)
+
+			if _, err := u.GetAuthURL(testOAuthState()); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("GetAuthURL() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if u.metadata != nil {
+				t.Fatal("metadata was cached after a rejected discovery document")
+			}
+		})
+	}
+}
+
+func TestGenericOIDCDiscoveryRetryBackoff(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.discoveryStatus = http.StatusServiceUnavailable
+	u := newTestGenericOIDC(idp.server.URL)
+
+	if _, err := u.GetAuthURL(testOAuthState()); err == nil || !strings.Contains(err.Error(), "failed to discover") {
+		t.Fatalf("GetAuthURL() error = %v, want discovery failure", err)
+	}
+
+	// 間隔を空けずに再試行した場合はIdPにリクエストしない
+	idp.mutex.Lock()
+	idp.discoveryStatus = http.StatusOK
+	idp.mutex.Unlock()
+	if _, err := u.GetAuthURL(testOAuthState()); err == nil || !strings.Contains(err.Error(), "failed recently") {
+		t.Fatalf("GetAuthURL() error = %v, want retry backoff", err)
+	}
+	if got := idp.discoveryCount(); got != 1 {
+		t.Fatalf("discovery fetched %d times during backoff, want 1", got)
+	}
+
+	// 間隔を過ぎた後は再取得し、成功したらキャッシュする
+	u.lastAttempt = time.Now().Add(-oidcDiscoveryRetryInterval - time.Second)
+	for range 2 {
+		if _, err := u.GetAuthURL(testOAuthState()); err != nil {
+			t.Fatalf("GetAuthURL() after backoff error = %v", err)
+		}
+	}
+	if got := idp.discoveryCount(); got != 2 {
+		t.Fatalf("discovery fetched %d times, want 2", got)
+	}
+}
+
+func TestGenericOIDCMapClaims(t *testing.T) {
+	tests := []struct {
+		name         string
+		mapping      domain.OIDCClaimMapping
+		claims       map[string]interface{}
+		wantEmail    string
+		wantName     string
+		wantVerified bool
+	}{
+		{
+			name:         "boolean email_verified",
+			mapping:      domain.OIDCClaimMapping{Email: "email", EmailVerified: "email_verified", Name: "name"},
+			claims:       map[string]interface{}{"email": "user@example.com", "email_verified": true, "name": "User"},
+			wantEmail:    "user@example.com",
+			wantName:     "User",
+			wantVerified: true,
+		},
+		{
+			name:         "string email_verified true",
+			mapping:      domain.OIDCClaimMapping{Email: "email", EmailVerified: "email_verified"},
+			claims:       map[string]interface{}{"email": "user@example.com", "email_verified": "true"},
+			wantEmail:    "user@example.com",
+			wantVerified: true,
+		},
+		{
+			name:      "string email_verified false",
+			mapping:   domain.OIDCClaimMapping{Email: "email", EmailVerified: "email_verified"},
+			claims:    map[string]interface{}{"email": "user@example.com", "email_verified": "false"},
+			wantEmail: "user@example.com",
+		},
+		{
+			name:      "missing email_verified",
+			mapping:   domain.OIDCClaimMapping{Email: "email", EmailVerified: "email_verified"},
+			claims:    map[string]interface{}{"email": "user@example.com"},
+			wantEmail: "user@example.com",
+		},
+		{
+			name:      "email_verified not mapped",
+			mapping:   domain.OIDCClaimMapping{Email: "email"},
+			claims:    map[string]interface{}{"email": "user@example.com", "email_verified": true},
+			wantEmail: "user@example.com",
+		},
+		{
+			name:         "custom claim names",
+			mapping:      domain.OIDCClaimMapping{Email: "upn", EmailVerified: "upn_verified", Name: "preferred_username"},
+			claims:       map[string]interface{}{"upn": "user@corp.example.com", "upn_verified": true, "preferred_username": "user"},
+			wantEmail:    "user@corp.example.com",
+			wantName:     "user",
+			wantVerified: true,
+		},
+		{
+			name:      "non-string email",
+			mapping:   domain.OIDCClaimMapping{Email: "email"},
+			claims:    map[string]interface{}{"email": []interface{}{"user@example.com"}},
+			wantEmail: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newTestGenericOIDC("https://idp.example.com")
+			u.config.Claims = tt.mapping
+
+			got := u.mapClaims("subject-1", tt.claims)
+			if got.ProviderID != "subject-1" || got.Email != tt.wantEmail || got.Name != tt.wantName || got.Verified != tt.wantVerified {
+				t.Errorf("mapClaims() = %+v, want email %q, name %q, verified %v", got, tt.wantEmail, tt.wantName, tt.wantVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// githubAPIVersion リクエストに指定するGitHub REST APIのバージョン
+	githubAPIVersion = "2022-11-28"
+)
+
+type GitHubAuthUsecase struct {
+	config      domain.GitHubAuthConfig
+	oauthConfig *oauth2.Config
+	httpClient  *http.Client
+}
+
+func NewGitHubAuthUsecase(config domain.GitHubAuthConfig) domain.OAuthUsecase {
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	config.APIBaseURL = strings.TrimSuffix(config.APIBaseURL, "/")
+
+	oauthConfig := &oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURL:  config.RedirectURL,
+		Scopes:       config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   config.BaseURL + "/login/oauth/authorize",
+			TokenURL:  config.BaseURL + "/login/oauth/access_token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+
+	return &GitHubAuthUsecase{
+		config:      config,
+		oauthConfig: oauthConfig,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (u *GitHubAuthUsecase) GetProviderName() string {
+	return "github"
+}
+
+func (u *GitHubAuthUsecase) GetAuthURL(state *domain.OAuthState) (string, error) {
+	// GitHubはOpenID Connectに対応していないため、nonceは送らずPKCEのコードチャレンジのみを含める
+	return u.oauthConfig.AuthCodeURL(state.State,
+		oauth2.S256ChallengeOption(state.CodeVerifier),
+		oauth2.SetAuthURLParam("allow_signup", "false"),
+	), nil
+}
+
+func (u *GitHubAuthUsecase) ExchangeCodeForToken(code, codeVerifier string) (*oauth2.Token, error) {
+	log.Printf("Exchanging GitHub code for token...")
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, u.httpClient)
+	token, err := u.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+
+	log.Printf("GitHub token exchange successful")
+	return token, nil
+}
+
+// GetUserInfo ユーザー情報とメールアドレス一覧を取得
+// メールアドレスは確認済みのもののみを使う（プロフィールに公開されたメールアドレスは確認済みとは限らない）
+func (u *GitHubAuthUsecase) GetUserInfo(token interface{}) (*domain.OAuthUser, error) {
+	log.Printf("Getting user info from GitHub...")
+
+	oauthToken, ok := token.(*oauth2.Token)
+	if !ok {
+		return nil, fmt.Errorf("invalid token type")
+	}
+
+	var profile json.RawMessage
+	if _, err := u.getJSON(oauthToken, "/user", &profile); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	var githubUser domain.GitHubUser
+	var raw map[string]interface{}
+	if err := json.Unmarshal(profile, &githubUser); err != nil {
+		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	}
+	if err := json.Unmarshal(profile, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	}
+	if githubUser.ID == 0 {
+		return nil, fmt.Errorf("github user response has no id")
+	}
+
+	var emails []domain.GitHubEmail
+	if _, err := u.getJSON(oauthToken, "/user/emails", &emails); err != nil {
+		return nil, fmt.Errorf("failed to get user emails: %w", err)
+	}
+	email := selectGitHubEmail(emails)
+
+	// 表示名を設定していないユーザーはログイン名を使う
+	name := githubUser.Name
+	if name == "" {
+		name = githubUser.Login
+	}
+
+	log.Printf("GitHub user info retrieved: %s (%d)", githubUser.Login, githubUser.ID)
+
+	// ログイン名は変更できるため、プロバイダーIDには数値のユーザーIDを使う
+	return &domain.OAuthUser{
+		ProviderID:   strconv.FormatInt(githubUser.ID, 10),
+		ProviderName: "github",
+		Email:        email,
+		Name:         name,
+		Picture:      githubUser.AvatarURL,
+		Verified:     email != "",
+		Raw:          raw,
+	}, nil
+}
+
+func (u *GitHubAuthUsecase) Authenticate(code string, oauthState *domain.OAuthState) (*domain.OAuthUser, error) {
+	log.Printf("Starting GitHub authentication...")
+
+	// 認証コードをトークンに交換（PKCEのコードベリファイアを送信）
+	token, err := u.ExchangeCodeForToken(code, oauthState.CodeVerifier)
+	if err != nil {
+		log.Printf("Token exchange failed: %v", err)
+		return nil, err
+	}
+
+	oauthUser, err := u.GetUserInfo(token)
+	if err != nil {
+		log.Printf("Get user info failed: %v", err)
+		return nil, err
+	}
+
+	// Organizationが設定されている場合はメンバーのみログインを許可
+	if err := u.checkOrganizations(token); err != nil {
+		log.Printf("GitHub organization check failed for %s: %v", oauthUser.ProviderID, err)
+		return nil, err
+	}
+
+	return oauthUser, nil
+}
+
+// checkOrganizations 許可したOrganizationのいずれかのアクティブなメンバーであることを確認
+// 非公開のメンバーシップも確認できるよう、read:orgスコープで自分のメンバーシップを取得する
+func (u *GitHubAuthUsecase) checkOrganizations(token *oauth2.Token) error {
+	if len(u.config.AllowedOrganizations) == 0 {
+		return nil
+	}
+
+	for _, org := range u.config.AllowedOrganizations {
+		var membership domain.GitHubOrgMembership
+		status, err := u.getJSON(token, "/user/memberships/orgs/"+url.PathEscape(org), &membership)
+		// メンバーでない場合は404、OrganizationがこのOAuthアプリを承認していない場合は403が返る
+		if status == http.StatusNotFound || status == http.StatusForbidden {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get organization membership: %w", err)
+		}
+		if membership.State == "active" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: not a member of an allowed GitHub organization", domain.ErrOAuthAccessDenied)
+}
+
+// getJSON REST APIを呼び出してJSONを取得（エラー時もステータスコードを返す）
+func (u *GitHubAuthUsecase) getJSON(token *oauth2.Token, path string, v interface{}) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, u.config.APIBaseURL+path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", githubAPIVersion)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("%s failed with status: %d", path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, oidcMaxResponseSize)).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// selectGitHubEmail 確認済みのプライマリメールアドレスを選ぶ（プライマリが未確認の場合は空）
+func selectGitHubEmail(emails []domain.GitHubEmail) string {
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email
+		}
+	}
+	return ""
+}
@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+// githubStub GitHubのトークンエンドポイントとREST APIのスタブ
+type githubStub struct {
+	server *httptest.Server
+
+	emails []domain.GitHubEmail
+	// Organization名ごとのレスポンス（登録していないOrganizationは404）
+	memberships map[string]githubMembershipResponse
+}
+
+type githubMembershipResponse struct {
+	status int
+	state  string
+}
+
+func newGitHubStub(t *testing.T) *githubStub {
+	t.Helper()
+
+	stub := &githubStub{memberships: map[string]githubMembershipResponse{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "test-code" || r.PostFormValue("code_verifier") != testCodeVerifier || r.PostFormValue("client_id") != testClientID {
+			writeTestJSON(w, http.StatusOK, map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{
+			"access_token": testAccessToken,
+			"token_type":   "bearer",
+			"scope":        "read:user,user:email,read:org",
+		})
+	})
+	mux.HandleFunc("GET /user", stub.authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"id":         12345,
+			"login":      "octocat",
+			"name":       "",
+			"avatar_url": "https://avatars.example.com/u/12345",
+		})
+	}))
+	mux.HandleFunc("GET /user/emails", stub.authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, stub.emails)
+	}))
+	mux.HandleFunc("GET /user/memberships/orgs/{org}", stub.authorized(func(w http.ResponseWriter, r *http.Request) {
+		membership, exists := stub.memberships[r.PathValue("org")]
+		if !exists {
+			writeTestJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		if membership.status != http.StatusOK {
+			writeTestJSON(w, membership.status, map[string]string{"message": http.StatusText(membership.status)})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, domain.GitHubOrgMembership{State: membership.state, Role: "member"})
+	}))
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+
+	return stub
+}
+
+// authorized アクセストークンとAPIバージョンのヘッダーを確認する
+func (s *githubStub) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testAccessToken || r.Header.Get("X-GitHub-Api-Version") != githubAPIVersion {
+			writeTestJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newTestGitHubAuth(baseURL string, organizations ...string) domain.OAuthUsecase {
+	return NewGitHubAuthUsecase(domain.GitHubAuthConfig{
+		ClientID:             testClientID,
+		ClientSecret:         "test-secret",
+		RedirectURL:          "http://localhost:8080/auth/github/callback",
+		Scopes:               []string{"read:user", "user:email", "read:org"},
+		BaseURL:              baseURL,
+		APIBaseURL:           baseURL + "/",
+		AllowedOrganizations: organizations,
+	})
+}
+
+func TestGitHubAuthenticate(t *testing.T) {
+	stub := newGitHubStub(t)
+	stub.emails = []domain.GitHubEmail{
+		{Email: "secondary@example.com", Primary: false, Verified: true},
+		{Email: "octocat@example.com", Primary: true, Verified: true},
+	}
+	u := newTestGitHubAuth(stub.server.URL)
+
+	oauthUser, err := u.Authenticate("test-code", testOAuthState())
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if oauthUser.ProviderID != "12345" || oauthUser.ProviderName != "github" {
+		t.Errorf("Authenticate() identity = %s/%s, want github/12345", oauthUser.ProviderName, oauthUser.ProviderID)
+	}
+	if oauthUser.Email != "octocat@example.com" || !oauthUser.Verified {
+		t.Errorf("Authenticate() email = %q (verified %v), want verified primary email", oauthUser.Email, oauthUser.Verified)
+	}
+	// 表示名がない場合はログイン名
+	if oauthUser.Name != "octocat" {
+		t.Errorf("Authenticate() name = %q, want login name", oauthUser.Name)
+	}
+	if oauthUser.Raw["login"] != "octocat" {
+		t.Errorf("Authenticate() raw profile = %v, want the /user response", oauthUser.Raw)
+	}
+}
+
+func TestGitHubAuthenticateUnverifiedPrimaryEmail(t *testing.T) {
+	stub := newGitHubStub(t)
+	// 確認済みでもプライマリでないメールアドレスは使わない
+	stub.emails = []domain.GitHubEmail{
+		{Email: "octocat@example.com", Primary: true, Verified: false},
+		{Email: "secondary@example.com", Primary: false, Verified: true},
+	}
+	u := newTestGitHubAuth(stub.server.URL)
+
+	oauthUser, err := u.Authenticate("test-code", testOAuthState())
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if oauthUser.Email != "" || oauthUser.Verified {
+		t.Errorf("Authenticate() email = %q (verified %v), want empty and unverified", oauthUser.Email, oauthUser.Verified)
+	}
+}
+
+func TestGitHubAuthenticateTokenExchangeFailure(t *testing.T) {
+	stub := newGitHubStub(t)
+	u := newTestGitHubAuth(stub.server.URL)
+
+	state := testOAuthState()
+	state.CodeVerifier = "other-code-verifier-0123456789-abcdefghijklmnopqrstuvwxyz"
+	if _, err := u.Authenticate("test-code", state); err == nil {
+		t.Fatal("Authenticate() with wrong code verifier succeeded")
+	}
+}
+
+func TestGitHubAuthenticateOrganizations(t *testing.T) {
+	tests := []struct {
+		name        string
+		memberships map[string]githubMembershipResponse
+		wantDenied  bool
+		wantErr     bool
+	}{
+		{
+			name:        "active member",
+			memberships: map[string]githubMembershipResponse{"allowed-org": {status: http.StatusOK, state: "active"}},
+		},
+		{
+			name: "active member of the second organization",
+			memberships: map[string]githubMembershipResponse{
+				"other-org":   {status: http.StatusForbidden},
+				"allowed-org": {status: http.StatusOK, state: "active"},
+			},
+		},
+		{
+			name:       "not a member",
+			wantDenied: true,
+		},
+		{
+			name:        "organization has not approved the app",
+			memberships: map[string]githubMembershipResponse{"allowed-org": {status: http.StatusForbidden}, "other-org": {status: http.StatusForbidden}},
+			wantDenied:  true,
+		},
+		{
+			name:        "pending invitation",
+			memberships: map[string]githubMembershipResponse{"allowed-org": {status: http.StatusOK, state: "pending"}},
+			wantDenied:  true,
+		},
+		{
+			name:        "GitHub error",
+			memberships: map[string]githubMembershipResponse{"allowed-org": {status: http.StatusInternalServerError}},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newGitHubStub(t)
+			stub.emails = []domain.GitHubEmail{{Email: "octocat@example.com", Primary: true, Verified: true}}
+			if tt.memberships != nil {
+				stub.memberships = tt.memberships
+			}
+			u := newTestGitHubAuth(stub.server.URL, "allowed-org", "other-org")
+
+			oauthUser, err := u.Authenticate("test-code", testOAuthState())
+			switch {
+			case tt.wantDenied:
+				if !errors.Is(err, domain.ErrOAuthAccessDenied) {
+					t.Fatalf("Authenticate() error = %v, want ErrOAuthAccessDenied", err)
+				}
+			case tt.wantErr:
+				if err == nil || errors.Is(err, domain.ErrOAuthAccessDenied) {
+					t.Fatalf("Authenticate() error = %v, want a non-access-denied error", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Authenticate() error = %v", err)
+				}
+				if oauthUser.ProviderID != "12345" {
+					t.Errorf("Authenticate() provider id = %q, want 12345", oauthUser.ProviderID)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectGitHubEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		emails []domain.GitHubEmail
+		want   string
+	}{
+		{name: "no emails"},
+		{
+			name:   "verified primary",
+			emails: []domain.GitHubEmail{{Email: "a@example.com", Verified: true}, {Email: "b@example.com", Primary: true, Verified: true}},
+			want:   "b@example.com",
+		},
+		{
+			name:   "unverified primary",
+			emails: []domain.GitHubEmail{{Email: "a@example.com", Verified: true}, {Email: "b@example.com", Primary: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectGitHubEmail(tt.emails); got != tt.want {
+				t.Errorf("selectGitHubEmail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,14 +13,20 @@ import (
 	"google.golang.org/api/option"
 )
 
+const (
+	// googleJWKSURL GoogleのIDトークンの署名検証用の公開鍵
+	googleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// googleIssuers GoogleのIDトークンのiss（どちらも使われる）
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
 type GoogleAuthUsecase struct {
-	config       *oauth2.Config
-	oauthRepo    domain.OAuthRepository
-	authUsecase  domain.AuthUsecase
-	stateManager domain.StateManager
+	config          *oauth2.Config
+	idTokenVerifier domain.IDTokenVerifier
 }
 
-func NewGoogleAuthUsecase(config domain.GoogleAuthConfig, oauthRepo domain.OAuthRepository, authUsecase domain.AuthUsecase, stateManager domain.StateManager) domain.OAuthUsecase {
+func NewGoogleAuthUsecase(config domain.GoogleAuthConfig) domain.OAuthUsecase {
 	oauthConfig := &oauth2.Config{
 		ClientID:     config.ClientID,
 		ClientSecret: config.ClientSecret,
@@ -30,10 +36,13 @@ func NewGoogleAuthUsecase(config domain.GoogleAuthConfig, oauthRepo domain.OAuth
 	}
 
 	return &GoogleAuthUsecase{
-		config:       oauthConfig,
-		oauthRepo:    oauthRepo,
-		authUsecase:  authUsecase,
-		stateManager: stateManager,
+		config: oauthConfig,
+		idTokenVerifier: NewIDTokenVerifier(domain.IDTokenVerifierConfig{
+			Issuers:  googleIssuers,
+			ClientID: config.ClientID,
+			JWKSURL:  googleJWKSURL,
+			Leeway:   providerIDTokenLeeway,
+		}, nil),
 	}
 }
 
@@ -41,15 +50,18 @@ func (u *GoogleAuthUsecase) GetProviderName() string {
 	return "google"
 }
 
-func (u *GoogleAuthUsecase) GetAuthURL() string {
-	state := u.stateManager.GenerateState()
-	return u.config.AuthCodeURL(state)
+func (u *GoogleAuthUsecase) GetAuthURL(state *domain.OAuthState) (string, error) {
+	// stateと一緒に保存したコードベリファイアとnonceを認可リクエストに含める
+	return u.config.AuthCodeURL(state.State,
+		oauth2.S256ChallengeOption(state.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", state.Nonce),
+	), nil
 }
 
-func (u *GoogleAuthUsecase) ExchangeCodeForToken(code string) (*oauth2.Token, error) {
+func (u *GoogleAuthUsecase) ExchangeCodeForToken(code, codeVerifier string) (*oauth2.Token, error) {
 	log.Printf("Exchanging code for token...")
 	ctx := context.Background()
-	token, err := u.config.Exchange(ctx, code)
+	token, err := u.config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
 	if err != nil {
 		log.Printf("Failed to exchange code for token: %v", err)
 		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
@@ -96,47 +108,34 @@ func (u *GoogleAuthUsecase) GetUserInfo(token interface{}) (*domain.OAuthUser, e
 	return oauthUser, nil
 }
 
-func (u *GoogleAuthUsecase) Authenticate(code string, state string) (*domain.AuthResponse, error) {
+func (u *GoogleAuthUsecase) Authenticate(code string, oauthState *domain.OAuthState) (*domain.OAuthUser, error) {
 	log.Printf("Starting Google authentication...")
 
-	// stateパラメータを検証
-	if !u.stateManager.ValidateState(state) {
-		return nil, fmt.Errorf("invalid state parameter")
-	}
-
-	// 認証コードをトークンに交換
-	token, err := u.ExchangeCodeForToken(code)
+	// 認証コードをトークンに交換（PKCEのコードベリファイアを送信）
+	token, err := u.ExchangeCodeForToken(code, oauthState.CodeVerifier)
 	if err != nil {
 		log.Printf("Token exchange failed: %v", err)
 		return nil, err
 	}
 
-	// Googleユーザー情報を取得
-	oauthUser, err := u.GetUserInfo(token)
-	if err != nil {
-		log.Printf("Get user info failed: %v", err)
-		return nil, err
-	}
-
-	log.Printf("Creating or getting user from database...")
-	// データベースからユーザーを取得または作成
-	user, err := u.oauthRepo.GetOrCreateUser(oauthUser)
+	// IDトークンの署名・iss・aud・exp・nonceを検証し、ユーザー情報はそのクレームから取得する
+	idToken, _ := token.Extra("id_token").(string)
+	claims, err := u.idTokenVerifier.Verify(idToken, oauthState.Nonce)
 	if err != nil {
-		log.Printf("Get or create user failed: %v", err)
+		log.Printf("ID token verification failed: %v", err)
 		return nil, err
 	}
 
-	log.Printf("Generating JWT token...")
-	// JWTトークンを生成
-	jwtToken, err := u.authUsecase.GenerateToken(user)
-	if err != nil {
-		log.Printf("Generate token failed: %v", err)
-		return nil, err
+	oauthUser := &domain.OAuthUser{
+		ProviderID:   claims.Subject,
+		ProviderName: "google",
+		Email:        claims.Email,
+		Name:         claims.Name,
+		Picture:      claims.Picture,
+		Verified:     claims.EmailVerified,
+		Raw:          claims.Raw,
 	}
+	log.Printf("User info retrieved from ID token: %s (%s)", oauthUser.Name, oauthUser.Email)
 
-	log.Printf("Google authentication completed successfully")
-	return &domain.AuthResponse{
-		Token: jwtToken,
-		User:  *user,
-	}, nil
+	return oauthUser, nil
 }
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+
+	"golang.org/x/oauth2"
+)
+
+// newTestGoogleAuth トークンエンドポイントとIDトークンの検証先をスタブに向けたGoogleAuthUsecaseを作成
+func newTestGoogleAuth(idp *fakeIdP) *GoogleAuthUsecase {
+	u := NewGoogleAuthUsecase(domain.GoogleAuthConfig{
+		ClientID:     testClientID,
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/auth/google/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}).(*GoogleAuthUsecase)
+
+	u.config.Endpoint = oauth2.Endpoint{
+		AuthURL:   idp.server.URL + "/authorize",
+		TokenURL:  idp.server.URL + "/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+	u.idTokenVerifier = NewIDTokenVerifier(domain.IDTokenVerifierConfig{
+		Issuers:  []string{idp.server.URL},
+		ClientID: testClientID,
+		JWKSURL:  idp.server.URL + "/jwks",
+		Leeway:   providerIDTokenLeeway,
+	}, nil)
+	return u
+}
+
+func TestProviderAuthURLIncludesPKCEAndNonce(t *testing.T) {
+	tests := []struct {
+		name      string
+		usecase   domain.OAuthUsecase
+		wantHost  string
+		wantScope string
+	}{
+		{
+			name: "google",
+			usecase: NewGoogleAuthUsecase(domain.GoogleAuthConfig{
+				ClientID:    testClientID,
+				RedirectURL: "http://localhost:8080/auth/google/callback",
+				Scopes:      []string{"openid", "email", "profile"},
+			}),
+			wantHost:  "accounts.google.com",
+			wantScope: "openid email profile",
+		},
+		{
+			name: "line",
+			usecase: NewLineAuthUsecase(domain.LineAuthConfig{
+				ChannelID:   testClientID,
+				RedirectURL: "http://localhost:8080/auth/line/callback",
+				Scopes:      []string{"profile"},
+			}),
+			wantHost: "access.line.me",
+			// nonceを検証するためにopenidスコープを追加する
+			wantScope: "profile openid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := testOAuthState()
+
+			authURL, err := tt.usecase.GetAuthURL(state)
+			if err != nil {
+				t.Fatalf("GetAuthURL() error = %v", err)
+			}
+			location, err := url.Parse(authURL)
+			if err != nil {
+				t.Fatalf("failed to parse %q: %v", authURL, err)
+			}
+			query := location.Query()
+
+			if location.Host != tt.wantHost {
+				t.Errorf("host = %q, want %q", location.Host, tt.wantHost)
+			}
+			if query.Get("state") != state.State || query.Get("nonce") != state.Nonce {
+				t.Errorf("state = %q, nonce = %q, want %q and %q", query.Get("state"), query.Get("nonce"), state.State, state.Nonce)
+			}
+			if query.Get("code_challenge") != state.CodeChallenge() || query.Get("code_challenge_method") != "S256" {
+				t.Errorf("code_challenge = %q (%s), want S256 challenge %q", query.Get("code_challenge"), query.Get("code_challenge_method"), state.CodeChallenge())
+			}
+			// コードベリファイア自体は認可リクエストに含めない
+			if strings.Contains(authURL, state.CodeVerifier) {
+				t.Errorf("GetAuthURL() = %s, want no code verifier", authURL)
+			}
+			if query.Get("scope") != tt.wantScope {
+				t.Errorf("scope = %q, want %q", query.Get("scope"), tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestGoogleAuthenticate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(state *domain.OAuthState)
+		wantErr bool
+	}{
+		{name: "matching verifier and nonce", modify: func(state *domain.OAuthState) {}},
+		{
+			// 横取りした認可コードはコードベリファイアがなければ交換できない
+			name:    "other code verifier",
+			modify:  func(state *domain.OAuthState) { state.CodeVerifier = strings.Repeat("x", 43) },
+			wantErr: true,
+		},
+		{
+			// 別の認可リクエストで発行されたIDトークンは受け付けない
+			name:    "other nonce",
+			modify:  func(state *domain.OAuthState) { state.Nonce = "other-nonce" },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			idp.idTokenClaims["email"] = "user@example.com"
+			idp.idTokenClaims["email_verified"] = true
+			u := newTestGoogleAuth(idp)
+			state := testOAuthState()
+			tt.modify(state)
+
+			oauthUser, err := u.Authenticate("test-code", state)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if oauthUser.ProviderID != "subject-1" || oauthUser.Email != "user@example.com" || !oauthUser.Verified {
+				t.Errorf("Authenticate() = %+v, want the ID token claims", oauthUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksMinRefreshInterval 未知のkidによるJWKSの再取得の最小間隔（不正なトークンで取得先に負荷をかけさせない）
+	jwksMinRefreshInterval = time.Minute
+	// jwksMaxResponseSize JWKSのレスポンスの最大サイズ
+	jwksMaxResponseSize = 1 << 20
+	// providerIDTokenLeeway 外部プロバイダーのIDトークンのexp / iat の検証で許容する時刻のずれ
+	providerIDTokenLeeway = 30 * time.Second
+)
+
+// providerIDTokenClaims 外部プロバイダーのIDトークンのクレーム
+type providerIDTokenClaims struct {
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp"`
+	Email           string `json:"email"`
+	EmailVerified   bool   `json:"email_verified"`
+	Name            string `json:"name"`
+	Picture         string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenVerifier 外部プロバイダーのIDトークンの検証（取得したJWKSはキャッシュする）
+type IDTokenVerifier struct {
+	config     domain.IDTokenVerifierConfig
+	httpClient *http.Client
+
+	mutex     sync.Mutex
+	keys      map[string]*domain.SigningKey
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+func NewIDTokenVerifier(config domain.IDTokenVerifierConfig, httpClient *http.Client) domain.IDTokenVerifier {
+	if config.CacheDuration <= 0 {
+		config.CacheDuration = time.Hour
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &IDTokenVerifier{
+		config:     config,
+		httpClient: httpClient,
+		keys:       make(map[string]*domain.SigningKey),
+	}
+}
+
+// Verify IDトークンの署名・iss・aud・exp・nonceを検証（OpenID Connect Core 3.1.3.7）
+func (v *IDTokenVerifier) Verify(idToken, nonce string) (*domain.IDTokenClaims, error) {
+	if idToken == "" {
+		return nil, errors.New("id_token is missing from token response")
+	}
+
+	methods := []string{domain.SigningAlgRS256, domain.SigningAlgES256}
+	if v.config.ClientSecret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	claims := &providerIDTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, v.keyFunc,
+		jwt.WithValidMethods(methods),
+		jwt.WithAudience(v.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.config.Leeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if !slices.Contains(v.config.Issuers, claims.Issuer) {
+		return nil, fmt.Errorf("invalid id_token: unexpected issuer %q", claims.Issuer)
+	}
+	// 複数のaudを含む場合は、azpが自分のクライアントIDであることを確認する
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != v.config.ClientID {
+		return nil, errors.New("invalid id_token: azp does not match client_id")
+	}
+	// 別の認可リクエストで発行されたIDトークンの再利用を防ぐ
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("invalid id_token: nonce does not match the authorization request")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: sub is missing")
+	}
+
+	// 署名を検証済みのため、マッピング用のクレームはそのまま読み取る
+	raw := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, raw); err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	return &domain.IDTokenClaims{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+		Raw:           raw,
+	}, nil
+}
+
+// keyFunc HS256の場合は共有鍵、それ以外はkidに対応するJWKSの公開鍵を返す
+func (v *IDTokenVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		return []byte(v.config.ClientSecret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("missing kid header")
+	}
+
+	key, err := v.key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing algorithm %s for key %s", token.Method.Alg(), kid)
+	}
+
+	return key.PublicKey, nil
+}
+
+// key kidに対応する公開鍵を取得
+// キャッシュの期限切れ、または未知のkid（プロバイダーの鍵のローテーション）の場合はJWKSを再取得する
+func (v *IDTokenVerifier) key(kid string) (*domain.SigningKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := time.Now()
+	key, exists := v.keys[kid]
+	if exists && now.Before(v.expiresAt) {
+		return key, nil
+	}
+	if !exists && now.Sub(v.fetchedAt) < jwksMinRefreshInterval && now.Before(v.expiresAt) {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+
+	if err := v.refresh(now); err != nil {
+		// 取得に失敗した場合、期限切れでも既知の鍵があればそれで検証する
+		if exists {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	key, exists = v.keys[kid]
+	if !exists {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+	return key, nil
+}
+
+// refresh JWKSを取得してキャッシュを置き換える（呼び出し側でロックを取得すること）
+func (v *IDTokenVerifier) refresh(now time.Time) error {
+	v.fetchedAt = now
+
+	resp, err := v.httpClient.Get(v.config.JWKSURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set domain.JWKSet
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, jwksMaxResponseSize)).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	// 署名用以外の鍵や未対応の鍵は読み飛ばす
+	keys := make(map[string]*domain.SigningKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kid == "" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := fromJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	v.keys = keys
+	v.expiresAt = now.Add(cacheMaxAge(resp.Header.Get("Cache-Control"), v.config.CacheDuration))
+	return nil
+}
+
+// cacheMaxAge Cache-Controlのmax-ageを取得（指定がない場合はdefaultDuration）
+func cacheMaxAge(cacheControl string, defaultDuration time.Duration) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		value, found := strings.CutPrefix(strings.TrimSpace(directive), "max-age=")
+		if !found {
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultDuration
+}
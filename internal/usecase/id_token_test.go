@@ -0,0 +1,394 @@
+package usecase
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testClientID = "test-client"
+	testNonce    = "test-nonce"
+)
+
+// jwksStub 外部プロバイダーのJWKSの取得先のスタブ
+type jwksStub struct {
+	server *httptest.Server
+
+	mutex        sync.Mutex
+	keys         []*domain.SigningKey
+	cacheControl string
+	status       int
+	fetches      int
+}
+
+func newJWKSStub(t *testing.T, keys ...*domain.SigningKey) *jwksStub {
+	t.Helper()
+
+	stub := &jwksStub{keys: keys, status: http.StatusOK}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.serveHTTP))
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+func (s *jwksStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.fetches++
+	if s.status != http.StatusOK {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	set := domain.JWKSet{Keys: make([]domain.JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, toJWK(key))
+	}
+	if s.cacheControl != "" {
+		w.Header().Set("Cache-Control", s.cacheControl)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(set)
+}
+
+func (s *jwksStub) setKeys(keys ...*domain.SigningKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksStub) setCacheControl(cacheControl string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cacheControl = cacheControl
+}
+
+func (s *jwksStub) setStatus(status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = status
+}
+
+func (s *jwksStub) fetchCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.fetches
+}
+
+func newTestRSAKey(t *testing.T, kid string) *domain.SigningKey {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	key, err := NewSigningKey(kid, privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signing key: %v", err)
+	}
+	return key
+}
+
+func newTestECKey(t *testing.T, kid string) *domain.SigningKey {
+	t.Helper()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	key, err := NewSigningKey(kid, privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signing key: %v", err)
+	}
+	return key
+}
+
+// signTestToken 指定した鍵でトークンに署名（ヘッダーのkidは鍵のKID）
+func signTestToken(t *testing.T, key *domain.SigningKey, claims jwt.Claims) string {
+	t.Helper()
+
+	keyManager, err := NewKeyManager(key.KID, []*domain.SigningKey{key})
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	token, err := signJWT(keyManager, claims)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// testIDTokenClaims 検証に成功するIDトークンのクレーム
+func testIDTokenClaims() *providerIDTokenClaims {
+	now := time.Now()
+	return &providerIDTokenClaims{
+		Nonce:         testNonce,
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Name:          "Test User",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Subject:   "subject-1",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+}
+
+func newTestIDTokenVerifier(jwksURL, clientSecret string) *IDTokenVerifier {
+	return NewIDTokenVerifier(domain.IDTokenVerifierConfig{
+		Issuers:      []string{testIssuer},
+		ClientID:     testClientID,
+		JWKSURL:      jwksURL,
+		ClientSecret: clientSecret,
+		Leeway:       providerIDTokenLeeway,
+	}, nil).(*IDTokenVerifier)
+}
+
+func TestIDTokenVerifierVerify(t *testing.T) {
+	rsaKey := newTestRSAKey(t, "rsa-1")
+	ecKey := newTestECKey(t, "ec-1")
+	// JWKSに公開していない鍵（kidは公開している鍵と同じ）
+	forgedKey := newTestRSAKey(t, "rsa-1")
+	stub := newJWKSStub(t, rsaKey, ecKey)
+
+	tests := []struct {
+		name    string
+		key     *domain.SigningKey
+		modify  func(claims *providerIDTokenClaims)
+		nonce   string
+		wantErr string
+	}{
+		{name: "valid RS256", key: rsaKey},
+		{name: "valid ES256", key: ecKey},
+		{
+			name: "multiple audiences with matching azp",
+			key:  rsaKey,
+			modify: func(claims *providerIDTokenClaims) {
+				claims.Audience = jwt.ClaimStrings{testClientID, "other-client"}
+				claims.AuthorizedParty = testClientID
+			},
+		},
+		{name: "bad signature", key: forgedKey, wantErr: "signature is invalid"},
+		{
+			name:    "wrong issuer",
+			key:     rsaKey,
+			modify:  func(claims *providerIDTokenClaims) { claims.Issuer = "https://evil.example.com" },
+			wantErr: "unexpected issuer",
+		},
+		{
+			name:    "wrong audience",
+			key:     rsaKey,
+			modify:  func(claims *providerIDTokenClaims) { claims.Audience = jwt.ClaimStrings{"other-client"} },
+			wantErr: "token has invalid audience",
+		},
+		{
+			name: "multiple audiences with wrong azp",
+			key:  rsaKey,
+			modify: func(claims *providerIDTokenClaims) {
+				claims.Audience = jwt.ClaimStrings{testClientID, "other-client"}
+				claims.AuthorizedParty = "other-client"
+			},
+			wantErr: "azp does not match",
+		},
+		{
+			name: "expired",
+			key:  rsaKey,
+			modify: func(claims *providerIDTokenClaims) {
+				claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			},
+			wantErr: "token is expired",
+		},
+		{name: "nonce mismatch", key: rsaKey, nonce: "other-nonce", wantErr: "nonce does not match"},
+		{
+			name:    "missing subject",
+			key:     rsaKey,
+			modify:  func(claims *providerIDTokenClaims) { claims.Subject = "" },
+			wantErr: "sub is missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := testIDTokenClaims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			nonce := tt.nonce
+			if nonce == "" {
+				nonce = testNonce
+			}
+
+			verifier := newTestIDTokenVerifier(stub.server.URL, "")
+			got, err := verifier.Verify(signTestToken(t, tt.key, claims), nonce)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.Subject != claims.Subject || got.Email != claims.Email || !got.EmailVerified {
+				t.Errorf("Verify() = %+v, want claims of %+v", got, claims)
+			}
+			if got.Raw["nonce"] != testNonce {
+				t.Errorf("Verify() raw claims = %v, want nonce %q", got.Raw, testNonce)
+			}
+		})
+	}
+}
+
+func TestIDTokenVerifierUnknownKeyRefetch(t *testing.T) {
+	oldKey := newTestRSAKey(t, "old")
+	newKey := newTestRSAKey(t, "new")
+	stub := newJWKSStub(t, oldKey)
+	verifier := newTestIDTokenVerifier(stub.server.URL, "")
+
+	if _, err := verifier.Verify(signTestToken(t, oldKey, testIDTokenClaims()), testNonce); err != nil {
+		t.Fatalf("Verify() with cached key error = %v", err)
+	}
+
+	// プロバイダーが鍵をローテーションした直後
+	stub.setKeys(oldKey, newKey)
+	newToken := signTestToken(t, newKey, testIDTokenClaims())
+
+	// 直前に取得したばかりの場合は、未知のkidでも再取得しない
+	if _, err := verifier.Verify(newToken, testNonce); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("Verify() error = %v, want unknown key id", err)
+	}
+	if got := stub.fetchCount(); got != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh interval, want 1", got)
+	}
+
+	// 最小間隔を過ぎた後は、未知のkidでJWKSを再取得する
+	verifier.fetchedAt = time.Now().Add(-jwksMinRefreshInterval - time.Second)
+	if _, err := verifier.Verify(newToken, testNonce); err != nil {
+		t.Fatalf("Verify() after refetch error = %v", err)
+	}
+	if got := stub.fetchCount(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", got)
+	}
+
+	// JWKSに存在しないkidは、再取得しても受け付けない
+	verifier.fetchedAt = time.Now().Add(-jwksMinRefreshInterval - time.Second)
+	unknownToken := signTestToken(t, newTestRSAKey(t, "unknown"), testIDTokenClaims())
+	if _, err := verifier.Verify(unknownToken, testNonce); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("Verify() error = %v, want unknown key id", err)
+	}
+	if got := stub.fetchCount(); got != 3 {
+		t.Fatalf("JWKS fetched %d times, want 3", got)
+	}
+}
+
+func TestIDTokenVerifierCacheMaxAge(t *testing.T) {
+	key := newTestRSAKey(t, "rsa-1")
+	stub := newJWKSStub(t, key)
+	stub.setCacheControl("public, max-age=120, must-revalidate")
+	verifier := newTestIDTokenVerifier(stub.server.URL, "")
+	token := signTestToken(t, key, testIDTokenClaims())
+
+	if _, err := verifier.Verify(token, testNonce); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	// CacheDuration（1時間）よりCache-Controlのmax-ageを優先する
+	if got := verifier.expiresAt.Sub(verifier.fetchedAt); got != 120*time.Second {
+		t.Fatalf("cache duration = %v, want 2m0s from max-age", got)
+	}
+
+	// 期限内はキャッシュした鍵で検証する
+	if _, err := verifier.Verify(token, testNonce); err != nil {
+		t.Fatalf("Verify() with cached key error = %v", err)
+	}
+	if got := stub.fetchCount(); got != 1 {
+		t.Fatalf("JWKS fetched %d times before max-age, want 1", got)
+	}
+
+	// 期限切れの場合は既知のkidでも再取得する
+	verifier.expiresAt = time.Now().Add(-time.Second)
+	if _, err := verifier.Verify(token, testNonce); err != nil {
+		t.Fatalf("Verify() after max-age error = %v", err)
+	}
+	if got := stub.fetchCount(); got != 2 {
+		t.Fatalf("JWKS fetched %d times after max-age, want 2", got)
+	}
+
+	// 再取得に失敗した場合は、期限切れでもキャッシュした鍵で検証する
+	stub.setStatus(http.StatusInternalServerError)
+	verifier.expiresAt = time.Now().Add(-time.Second)
+	if _, err := verifier.Verify(token, testNonce); err != nil {
+		t.Fatalf("Verify() with stale key error = %v", err)
+	}
+	if got := stub.fetchCount(); got != 3 {
+		t.Fatalf("JWKS fetched %d times, want 3", got)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{cacheControl: "", want: time.Hour},
+		{cacheControl: "max-age=300", want: 5 * time.Minute},
+		{cacheControl: "public, max-age=60, must-revalidate", want: time.Minute},
+		{cacheControl: "no-cache", want: time.Hour},
+		{cacheControl: "max-age=0", want: time.Hour},
+		{cacheControl: "max-age=invalid", want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := cacheMaxAge(tt.cacheControl, time.Hour); got != tt.want {
+			t.Errorf("cacheMaxAge(%q) = %v, want %v", tt.cacheControl, got, tt.want)
+		}
+	}
+}
+
+// LINEのウェブログインのIDトークンはチャネルシークレットを鍵にHS256で署名される
+func TestIDTokenVerifierHS256(t *testing.T) {
+	const channelSecret = "line-channel-secret"
+	stub := newJWKSStub(t)
+
+	sign := func(secret string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testIDTokenClaims()).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	verifier := newTestIDTokenVerifier(stub.server.URL, channelSecret)
+	if _, err := verifier.Verify(sign(channelSecret), testNonce); err != nil {
+		t.Fatalf("Verify() with channel secret error = %v", err)
+	}
+	if _, err := verifier.Verify(sign("other-secret"), testNonce); err == nil || !strings.Contains(err.Error(), "signature is invalid") {
+		t.Fatalf("Verify() with other secret error = %v, want signature is invalid", err)
+	}
+	if got := stub.fetchCount(); got != 0 {
+		t.Fatalf("JWKS fetched %d times for HS256, want 0", got)
+	}
+
+	// クライアントシークレットを設定していないプロバイダーはHS256を受け付けない
+	verifier = newTestIDTokenVerifier(stub.server.URL, "")
+	if _, err := verifier.Verify(sign(""), testNonce); err == nil || !strings.Contains(err.Error(), "signing method HS256 is invalid") {
+		t.Fatalf("Verify() without client secret error = %v, want signing method HS256 is invalid", err)
+	}
+}
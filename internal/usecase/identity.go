@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// accountLinkClaims 確認待ちの連携のトークンのクレーム
+// 認証済みのプロバイダーのユーザー情報を署名付きで保持し、連携先のユーザー（sub）でログインした場合のみ連携する
+type accountLinkClaims struct {
+	TokenUse        string `json:"token_use"`
+	Provider        string `json:"provider"`
+	ProviderSubject string `json:"provider_sub"`
+	Email           string `json:"email,omitempty"`
+	EmailVerified   bool   `json:"email_verified,omitempty"`
+	Name            string `json:"name,omitempty"`
+	Picture         string `json:"picture,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type IdentityUsecase struct {
+	oauthRepo         domain.OAuthRepository
+	authRepo          domain.AuthRepository
+	securityEventRepo domain.SecurityEventRepository
+	revokedTokenStore domain.RevokedTokenStore
+	authUsecase       domain.AuthUsecase
+	keyManager        domain.KeyManager
+	jwtConfig         domain.JWTConfig
+	policy            domain.AccountLinkPolicy
+}
+
+func NewIdentityUsecase(
+	oauthRepo domain.OAuthRepository,
+	authRepo domain.AuthRepository,
+	securityEventRepo domain.SecurityEventRepository,
+	revokedTokenStore domain.RevokedTokenStore,
+	authUsecase domain.AuthUsecase,
+	keyManager domain.KeyManager,
+	jwtConfig domain.JWTConfig,
+	policy domain.AccountLinkPolicy,
+) domain.IdentityUsecase {
+	return &IdentityUsecase{
+		oauthRepo:         oauthRepo,
+		authRepo:          authRepo,
+		securityEventRepo: securityEventRepo,
+		revokedTokenStore: revokedTokenStore,
+		authUsecase:       authUsecase,
+		keyManager:        keyManager,
+		jwtConfig:         jwtConfig,
+		policy:            policy,
+	}
+}
+
+// SignIn プロバイダーで認証したユーザーでログイン
+func (u *IdentityUsecase) SignIn(oauthUser *domain.OAuthUser, deviceInfo, ipAddress string) (*domain.AuthResponse, error) {
+	log.Printf("Resolving user for %s account...", oauthUser.ProviderName)
+	// 連携済みのユーザーを取得、または連携・作成
+	user, err := u.resolveUser(oauthUser, ipAddress)
+	if err != nil {
+		log.Printf("Resolve user failed: %v", err)
+		return nil, err
+	}
+
+	// 二要素認証が有効なユーザーにはトークンの代わりにチャレンジを返す
+	challenge, err := u.authUsecase.IssueMFAChallenge(user)
+	if err != nil {
+		log.Printf("Issue MFA challenge failed: %v", err)
+		return nil, err
+	}
+	if challenge != nil {
+		log.Printf("MFA is required for user %d", user.ID)
+		return challenge, nil
+	}
+
+	log.Printf("Generating token pair...")
+	// パスワードでのログインと同じくトークンペアを生成（セッション管理のためデバイス情報とIPアドレスを記録）
+	tokenPair, err := u.authUsecase.GenerateTokenPair(user, deviceInfo, ipAddress)
+	if err != nil {
+		log.Printf("Generate token pair failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("%s authentication completed successfully", oauthUser.ProviderName)
+	return &domain.AuthResponse{
+		Token:        tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+		User:         *user,
+	}, nil
+}
+
+// resolveUser プロバイダーのアカウントに対応するユーザーを決める
+//  1. プロバイダーとsubjectで連携済みのユーザー
+//  2. メールアドレスが一致する既存のユーザー（ポリシーで許可された場合のみ自動連携し、それ以外は既存のアカウントでのログインによる確認を求める）
+//  3. どちらもなければユーザーを新規作成
+func (u *IdentityUsecase) resolveUser(oauthUser *domain.OAuthUser, ipAddress string) (*domain.User, error) {
+	user, err := u.oauthRepo.FindUserByIdentity(oauthUser)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	if oauthUser.Email != "" {
+		existing, err := u.authRepo.GetUserByEmail(oauthUser.Email)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if !u.canAutoLink(oauthUser, existing) {
+				return nil, u.accountLinkRequired(existing.ID, oauthUser)
+			}
+			if _, err := u.Link(existing.ID, oauthUser, ipAddress); err != nil {
+				return nil, err
+			}
+			log.Printf("Auto-linked %s account to user %d by verified email", oauthUser.ProviderName, existing.ID)
+			return existing, nil
+		}
+	}
+
+	return u.oauthRepo.CreateUserWithIdentity(oauthUser)
+}
+
+// canAutoLink 確認なしで既存のアカウントに連携できるか
+// 既存のアカウントのメールアドレスも確認済みであることを要求する（未確認のまま先に登録された他人のアカウントに連携しないため）
+func (u *IdentityUsecase) canAutoLink(oauthUser *domain.OAuthUser, existing *domain.User) bool {
+	return slices.Contains(u.policy.AutoLinkProviders, oauthUser.ProviderName) &&
+		oauthUser.Verified &&
+		existing.EmailVerifiedAt != nil
+}
+
+// accountLinkRequired 連携先のユーザーでのログインによる確認を求めるエラーを作成
+func (u *IdentityUsecase) accountLinkRequired(userID int, oauthUser *domain.OAuthUser) error {
+	now := time.Now()
+	expiresAt := now.Add(u.policy.LinkDuration)
+	claims := accountLinkClaims{
+		TokenUse:        domain.TokenUseAccountLink,
+		Provider:        oauthUser.ProviderName,
+		ProviderSubject: oauthUser.ProviderID,
+		Email:           oauthUser.Email,
+		EmailVerified:   oauthUser.Verified,
+		Name:            oauthUser.Name,
+		Picture:         oauthUser.Picture,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			Subject:   strconv.Itoa(userID),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := signJWT(u.keyManager, claims)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s account matches user %d by email; confirmation by signing in is required", oauthUser.ProviderName, userID)
+	return &domain.AccountLinkRequiredError{
+		Provider:  oauthUser.ProviderName,
+		Email:     oauthUser.Email,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// GetPendingLink 確認待ちの連携の内容を取得
+func (u *IdentityUsecase) GetPendingLink(token string) (*domain.PendingAccountLink, error) {
+	claims, err := u.parseAccountLink(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.PendingAccountLink{
+		Provider:  claims.Provider,
+		Email:     claims.Email,
+		Name:      claims.Name,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// ConfirmLink 確認待ちの連携を確定（トークンは一度だけ使用可能）
+func (u *IdentityUsecase) ConfirmLink(userID int, token, ipAddress string) (*domain.UserIdentity, error) {
+	claims, err := u.parseAccountLink(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != strconv.Itoa(userID) {
+		return nil, domain.ErrInvalidAccountLink
+	}
+
+	if err := u.revokedTokenStore.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	return u.Link(userID, &domain.OAuthUser{
+		ProviderID:   claims.ProviderSubject,
+		ProviderName: claims.Provider,
+		Email:        claims.Email,
+		Name:         claims.Name,
+		Picture:      claims.Picture,
+		Verified:     claims.EmailVerified,
+	}, ipAddress)
+}
+
+// parseAccountLink 確認待ちの連携のトークンを検証
+func (u *IdentityUsecase) parseAccountLink(token string) (*accountLinkClaims, error) {
+	claims := &accountLinkClaims{}
+	if _, err := parseJWT(u.keyManager, token, claims, jwtParserOptions(u.jwtConfig)...); err != nil {
+		return nil, domain.ErrInvalidAccountLink
+	}
+	if claims.TokenUse != domain.TokenUseAccountLink || claims.Provider == "" || claims.ProviderSubject == "" {
+		return nil, domain.ErrInvalidAccountLink
+	}
+
+	revoked, err := u.revokedTokenStore.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, domain.ErrInvalidAccountLink
+	}
+
+	return claims, nil
+}
+
+// Link ログイン中のユーザーにプロバイダーのアカウントを連携
+func (u *IdentityUsecase) Link(userID int, oauthUser *domain.OAuthUser, ipAddress string) (*domain.UserIdentity, error) {
+	identity, err := u.oauthRepo.LinkIdentity(userID, oauthUser)
+	if err != nil {
+		return nil, err
+	}
+
+	u.recordEvent(userID, domain.SecurityEventIdentityLinked, fmt.Sprintf("%s account linked", identity.Provider), ipAddress)
+	return identity, nil
+}
+
+// ListIdentities 連携一覧を取得
+func (u *IdentityUsecase) ListIdentities(userID int) ([]*domain.UserIdentity, error) {
+	return u.oauthRepo.ListIdentities(userID)
+}
+
+// Unlink 連携を解除（パスワードも他の連携もない場合はログインできなくなるため拒否する）
+func (u *IdentityUsecase) Unlink(userID, identityID int, ipAddress string) error {
+	identity, err := u.oauthRepo.GetIdentity(userID, identityID)
+	if err != nil {
+		return err
+	}
+	if identity == nil {
+		return domain.ErrIdentityNotFound
+	}
+
+	deleted, err := u.oauthRepo.DeleteIdentity(userID, identityID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.ErrLastLoginMethod
+	}
+
+	u.recordEvent(userID, domain.SecurityEventIdentityUnlinked, fmt.Sprintf("%s account unlinked", identity.Provider), ipAddress)
+	return nil
+}
+
+func (u *IdentityUsecase) recordEvent(userID int, eventType, description, ipAddress string) {
+	event := &domain.SecurityEvent{
+		UserID:      &userID,
+		EventType:   eventType,
+		Description: description,
+		IPAddress:   ipAddress,
+	}
+	if err := u.securityEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+}
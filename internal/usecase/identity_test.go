@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+type testIdentity struct {
+	*testAuthDeps
+	usecase *IdentityUsecase
+	oauth   *fakeOAuthRepository
+}
+
+func newTestIdentityUsecase(t *testing.T, users ...*domain.User) *testIdentity {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t, users...)
+	oauth := &fakeOAuthRepository{users: deps.users}
+	identityUsecase := NewIdentityUsecase(
+		oauth, deps.users, deps.securityEvents, deps.revokedTokens, deps.usecase, deps.keyManager, testJWTConfig(),
+		domain.AccountLinkPolicy{AutoLinkProviders: []string{"google"}, LinkDuration: 10 * time.Minute},
+	)
+	return &testIdentity{testAuthDeps: deps, usecase: identityUsecase.(*IdentityUsecase), oauth: oauth}
+}
+
+// testOAuthUser プロバイダーで認証したテストユーザー
+func testOAuthUser(provider, subject, email string) *domain.OAuthUser {
+	return &domain.OAuthUser{ProviderID: subject, ProviderName: provider, Email: email, Name: "Alice", Verified: true}
+}
+
+func TestSignInByLinkedIdentity(t *testing.T) {
+	tests := []struct {
+		name      string
+		oauthUser *domain.OAuthUser
+		wantUser  int
+	}{
+		{name: "linked identity", oauthUser: testOAuthUser("google", "google-1", "alice@example.com"), wantUser: 1},
+		// 連携済みであればプロバイダー側のメールアドレスが変わってもsubjectで特定する
+		{name: "linked identity with changed email", oauthUser: testOAuthUser("google", "google-1", "alice@new.example.com"), wantUser: 1},
+		// LINEはメールアドレスを返さないことがある
+		{name: "new account without email", oauthUser: testOAuthUser("line", "line-1", ""), wantUser: 2},
+		{name: "new account", oauthUser: testOAuthUser("google", "google-2", "bob@example.com"), wantUser: 2},
+		// 同じsubjectでも別のプロバイダーであれば別のアカウント
+		{name: "same subject on another provider", oauthUser: testOAuthUser("line", "google-1", ""), wantUser: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestIdentityUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+			if _, err := i.usecase.Link(1, testOAuthUser("google", "google-1", "alice@example.com"), "192.0.2.1"); err != nil {
+				t.Fatalf("Link() error = %v", err)
+			}
+
+			response, err := i.usecase.SignIn(tt.oauthUser, "test-agent", "192.0.2.1")
+			if err != nil {
+				t.Fatalf("SignIn() error = %v", err)
+			}
+			if response.User.ID != tt.wantUser || response.RefreshToken == "" {
+				t.Errorf("SignIn() = user %d (refresh token %q), want user %d with a token pair", response.User.ID, response.RefreshToken, tt.wantUser)
+			}
+			if claims, err := i.testAuthDeps.usecase.ValidateToken(response.Token); err != nil || claims.UserID != tt.wantUser {
+				t.Errorf("ValidateToken() = %+v, %v, want user %d", claims, err, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestSignInRequiresMFA(t *testing.T) {
+	i := newTestIdentityUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+	i.oauth.LinkIdentity(1, testOAuthUser("google", "google-1", "alice@example.com"))
+	i.mfa.configs[1] = &domain.UserMFA{UserID: 1, Enabled: true}
+
+	response, err := i.usecase.SignIn(testOAuthUser("google", "google-1", "alice@example.com"), "test-agent", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("SignIn() error = %v", err)
+	}
+	if response.MFAToken == "" || response.Token != "" {
+		t.Errorf("SignIn() = %+v, want an MFA challenge instead of tokens", response)
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    int
+		oauthUser *domain.OAuthUser
+		wantErr   error
+	}{
+		{name: "new provider", userID: 1, oauthUser: testOAuthUser("line", "line-1", "")},
+		{name: "second account on the same provider", userID: 1, oauthUser: testOAuthUser("google", "google-3", "alice@work.example.com")},
+		{name: "already linked to the user", userID: 1, oauthUser: testOAuthUser("google", "google-1", "alice@example.com")},
+		{
+			name:      "linked to another user",
+			userID:    2,
+			oauthUser: testOAuthUser("google", "google-1", "alice@example.com"),
+			wantErr:   domain.ErrIdentityAlreadyLinked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestIdentityUsecase(t,
+				verifiedUser("alice@example.com", testStrongPassword),
+				verifiedUser("bob@example.com", testStrongPassword),
+			)
+			i.oauth.LinkIdentity(1, testOAuthUser("google", "google-1", "alice@example.com"))
+
+			identity, err := i.usecase.Link(tt.userID, tt.oauthUser, "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Link() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if events := i.securityEvents.eventsOfType(domain.SecurityEventIdentityLinked); len(events) != 0 {
+					t.Errorf("recorded %d identity linked events, want none", len(events))
+				}
+				return
+			}
+
+			if identity.UserID != tt.userID || identity.Provider != tt.oauthUser.ProviderName || identity.Subject != tt.oauthUser.ProviderID {
+				t.Errorf("Link() = %+v, want %s/%s for user %d", identity, tt.oauthUser.ProviderName, tt.oauthUser.ProviderID, tt.userID)
+			}
+			if events := i.securityEvents.eventsOfType(domain.SecurityEventIdentityLinked); len(events) != 1 {
+				t.Errorf("recorded %d identity linked events, want 1", len(events))
+			}
+		})
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string // 空の場合は外部プロバイダーのみでログインするユーザー
+		identities []*domain.OAuthUser
+		userID     int
+		wantErr    error
+	}{
+		{
+			name:       "password remains",
+			password:   testStrongPassword,
+			identities: []*domain.OAuthUser{testOAuthUser("google", "google-1", "alice@example.com")},
+			userID:     1,
+		},
+		{
+			name: "another identity remains",
+			identities: []*domain.OAuthUser{
+				testOAuthUser("google", "google-1", "alice@example.com"),
+				testOAuthUser("line", "line-1", ""),
+			},
+			userID: 1,
+		},
+		{
+			name:       "last login method",
+			identities: []*domain.OAuthUser{testOAuthUser("google", "google-1", "alice@example.com")},
+			userID:     1,
+			wantErr:    domain.ErrLastLoginMethod,
+		},
+		{
+			name:       "another user's identity",
+			password:   testStrongPassword,
+			identities: []*domain.OAuthUser{testOAuthUser("google", "google-1", "alice@example.com")},
+			userID:     2,
+			wantErr:    domain.ErrIdentityNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestIdentityUsecase(t,
+				verifiedUser("alice@example.com", tt.password),
+				verifiedUser("bob@example.com", testStrongPassword),
+			)
+			for _, oauthUser := range tt.identities {
+				i.oauth.LinkIdentity(1, oauthUser)
+			}
+
+			err := i.usecase.Unlink(tt.userID, 1, "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Unlink() error = %v, want %v", err, tt.wantErr)
+			}
+
+			identities, _ := i.usecase.ListIdentities(1)
+			wantRemaining := len(tt.identities)
+			if err == nil {
+				wantRemaining--
+			}
+			if len(identities) != wantRemaining {
+				t.Errorf("%d identities remain, want %d", len(identities), wantRemaining)
+			}
+			if events := i.securityEvents.eventsOfType(domain.SecurityEventIdentityUnlinked); len(events) != len(tt.identities)-wantRemaining {
+				t.Errorf("recorded %d identity unlinked events, want %d", len(events), len(tt.identities)-wantRemaining)
+			}
+		})
+	}
+}
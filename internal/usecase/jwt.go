@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingMethods 署名アルゴリズムとjwtライブラリの署名方式の対応
+var signingMethods = map[string]jwt.SigningMethod{
+	domain.SigningAlgRS256: jwt.SigningMethodRS256,
+	domain.SigningAlgES256: jwt.SigningMethodES256,
+	domain.SigningAlgEdDSA: jwt.SigningMethodEdDSA,
+}
+
+// signJWT アクティブな鍵でクレームに署名し、ヘッダーにkidを設定する
+func signJWT(keyManager domain.KeyManager, claims jwt.Claims) (string, error) {
+	key := keyManager.SigningKey()
+	method, ok := signingMethods[key.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported signing algorithm: %s", key.Algorithm)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.PrivateKey)
+}
+
+// parseJWT kidに対応する鍵で署名を検証してクレームを取り出す
+// ヘッダーのalgは鍵に紐づくアルゴリズムと一致する場合のみ受け付ける
+// optsでjwt.WithValidMethodsを指定すると、受け付けるアルゴリズムをさらに絞り込める
+func parseJWT(keyManager domain.KeyManager, tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append([]jwt.ParserOption{jwt.WithValidMethods([]string{
+		domain.SigningAlgRS256,
+		domain.SigningAlgES256,
+		domain.SigningAlgEdDSA,
+	})}, opts...)
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("missing kid header")
+		}
+
+		key, err := keyManager.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing algorithm %s for key %s", token.Method.Alg(), kid)
+		}
+
+		return key.PublicKey, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return token, nil
+}
+
+// jwtParserOptions 設定に基づくトークン検証のオプション（iss・署名アルゴリズム・時刻のずれ）
+func jwtParserOptions(config domain.JWTConfig) []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithLeeway(config.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if len(config.Algorithms) > 0 {
+		opts = append(opts, jwt.WithValidMethods(config.Algorithms))
+	}
+	return opts
+}
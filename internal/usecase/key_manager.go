@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"go-echo-demo/internal/domain"
+)
+
+type keyManager struct {
+	activeKey *domain.SigningKey
+	keys      map[string]*domain.SigningKey
+	order     []string
+}
+
+// NewKeyManager 署名鍵マネージャーのコンストラクタ
+// activeKIDの鍵で署名し、それ以外の鍵は検証専用として扱う
+func NewKeyManager(activeKID string, keys []*domain.SigningKey) (domain.KeyManager, error) {
+	km := &keyManager{keys: make(map[string]*domain.SigningKey)}
+
+	for _, key := range keys {
+		if _, exists := km.keys[key.KID]; exists {
+			return nil, fmt.Errorf("duplicate signing key id: %s", key.KID)
+		}
+		km.keys[key.KID] = key
+		km.order = append(km.order, key.KID)
+	}
+
+	activeKey, exists := km.keys[activeKID]
+	if !exists {
+		return nil, fmt.Errorf("active signing key not found: %s", activeKID)
+	}
+	if activeKey.PrivateKey == nil {
+		return nil, fmt.Errorf("active signing key has no private key: %s", activeKID)
+	}
+	km.activeKey = activeKey
+
+	return km, nil
+}
+
+// NewSigningKey 鍵の種類から署名アルゴリズムを判定してSigningKeyを作成
+// 秘密鍵（crypto.Signer）を渡すと署名用、公開鍵のみを渡すと検証専用の鍵になる
+func NewSigningKey(kid string, key interface{}) (*domain.SigningKey, error) {
+	signingKey := &domain.SigningKey{KID: kid}
+
+	if signer, ok := key.(crypto.Signer); ok {
+		signingKey.PrivateKey = signer
+		key = signer.Public()
+	}
+	signingKey.PublicKey = key
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key %s must be at least 2048 bits", kid)
+		}
+		signingKey.Algorithm = domain.SigningAlgRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ECDSA key %s must use the P-256 curve", kid)
+		}
+		signingKey.Algorithm = domain.SigningAlgES256
+	case ed25519.PublicKey:
+		signingKey.Algorithm = domain.SigningAlgEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported signing key type for %s: %T", kid, key)
+	}
+
+	return signingKey, nil
+}
+
+// SigningKey 署名に使用するアクティブな鍵を取得
+func (km *keyManager) SigningKey() *domain.SigningKey {
+	return km.activeKey
+}
+
+// VerificationKey kidに対応する検証用の鍵を取得
+func (km *keyManager) VerificationKey(kid string) (*domain.SigningKey, error) {
+	key, exists := km.keys[kid]
+	if !exists {
+		return nil, fmt.Errorf("unknown signing key id: %s", kid)
+	}
+	return key, nil
+}
+
+// JWKS すべての公開鍵をJWKS形式で取得
+func (km *keyManager) JWKS() domain.JWKSet {
+	set := domain.JWKSet{Keys: make([]domain.JWK, 0, len(km.order))}
+	for _, kid := range km.order {
+		set.Keys = append(set.Keys, toJWK(km.keys[kid]))
+	}
+	return set
+}
+
+// toJWK 公開鍵をJWKに変換
+func toJWK(key *domain.SigningKey) domain.JWK {
+	jwk := domain.JWK{
+		Kid: key.KID,
+		Use: "sig",
+		Alg: key.Algorithm,
+	}
+
+	enc := base64.RawURLEncoding
+	switch pub := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = enc.EncodeToString(pub.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = enc.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = enc.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = enc.EncodeToString(pub)
+	}
+
+	return jwk
+}
+
+// fromJWK JWKを検証専用のSigningKeyに変換（外部プロバイダーのJWKSの読み込みに使う）
+func fromJWK(jwk domain.JWK) (*domain.SigningKey, error) {
+	enc := base64.RawURLEncoding
+	switch jwk.Kty {
+	case "RSA":
+		n, err := enc.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus for key %s: %w", jwk.Kid, err)
+		}
+		e, err := enc.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent for key %s", jwk.Kid)
+		}
+		return NewSigningKey(jwk.Kid, &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		})
+	case "EC":
+		if jwk.Crv != elliptic.P256().Params().Name {
+			return nil, fmt.Errorf("unsupported curve for key %s: %s", jwk.Kid, jwk.Crv)
+		}
+		x, err := enc.DecodeString(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate for key %s: %w", jwk.Kid, err)
+		}
+		y, err := enc.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate for key %s: %w", jwk.Kid, err)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, fmt.Errorf("EC point for key %s is not on the curve", jwk.Kid)
+		}
+		return NewSigningKey(jwk.Kid, pub)
+	case "OKP":
+		x, err := enc.DecodeString(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %s", jwk.Kid)
+		}
+		return NewSigningKey(jwk.Kid, ed25519.PublicKey(x))
+	default:
+		return nil, fmt.Errorf("unsupported key type for key %s: %s", jwk.Kid, jwk.Kty)
+	}
+}
@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	weakRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		key            interface{}
+		wantAlg        string
+		wantPrivateKey bool
+		wantErr        bool
+	}{
+		{name: "RSA private key", key: rsaKey, wantAlg: domain.SigningAlgRS256, wantPrivateKey: true},
+		{name: "RSA public key only", key: &rsaKey.PublicKey, wantAlg: domain.SigningAlgRS256},
+		{name: "RSA key shorter than 2048 bits", key: weakRSAKey, wantErr: true},
+		{name: "P-256 private key", key: ecKey, wantAlg: domain.SigningAlgES256, wantPrivateKey: true},
+		{name: "P-384 key", key: p384Key, wantErr: true},
+		{name: "Ed25519 private key", key: edKey, wantAlg: domain.SigningAlgEdDSA, wantPrivateKey: true},
+		{name: "symmetric key", key: []byte("your-secret-key"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewSigningKey("kid", tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSigningKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if key.Algorithm != tt.wantAlg {
+				t.Errorf("Algorithm = %s, want %s", key.Algorithm, tt.wantAlg)
+			}
+			if hasPrivateKey := key.PrivateKey != nil; hasPrivateKey != tt.wantPrivateKey {
+				t.Errorf("has private key = %v, want %v", hasPrivateKey, tt.wantPrivateKey)
+			}
+		})
+	}
+}
+
+func TestNewKeyManagerValidatesKeys(t *testing.T) {
+	active := newTestECKey(t, "active")
+	retired, err := NewSigningKey("retired", newTestRSAKey(t, "retired").PublicKey)
+	if err != nil {
+		t.Fatalf("NewSigningKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		activeKID string
+		keys      []*domain.SigningKey
+		wantErr   bool
+	}{
+		{name: "active and retired keys", activeKID: "active", keys: []*domain.SigningKey{active, retired}},
+		{name: "unknown active key", activeKID: "missing", keys: []*domain.SigningKey{active}, wantErr: true},
+		{name: "active key without private key", activeKID: "retired", keys: []*domain.SigningKey{active, retired}, wantErr: true},
+		{name: "duplicate key id", activeKID: "active", keys: []*domain.SigningKey{active, active}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyManager(tt.activeKID, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewKeyManager() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := newTestECKey(t, "2024-01")
+	oldManager, err := NewKeyManager(oldKey.KID, []*domain.SigningKey{oldKey})
+	if err != nil {
+		t.Fatalf("NewKeyManager() error = %v", err)
+	}
+
+	// ローテーション後は旧鍵を公開鍵のみの検証専用として残す
+	newKey := newTestRSAKey(t, "2024-07")
+	retiredKey, err := NewSigningKey(oldKey.KID, oldKey.PublicKey)
+	if err != nil {
+		t.Fatalf("NewSigningKey() error = %v", err)
+	}
+	rotatedManager, err := NewKeyManager(newKey.KID, []*domain.SigningKey{newKey, retiredKey})
+	if err != nil {
+		t.Fatalf("NewKeyManager() error = %v", err)
+	}
+
+	jwks := rotatedManager.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(jwks.Keys))
+	}
+	for i, want := range []struct{ kid, alg, kty string }{
+		{newKey.KID, domain.SigningAlgRS256, "RSA"},
+		{oldKey.KID, domain.SigningAlgES256, "EC"},
+	} {
+		if got := jwks.Keys[i]; got.Kid != want.kid || got.Alg != want.alg || got.Kty != want.kty || got.Use != "sig" {
+			t.Errorf("JWKS().Keys[%d] = %+v, want kid=%s alg=%s kty=%s", i, got, want.kid, want.alg, want.kty)
+		}
+	}
+
+	claims := func() jwt.Claims {
+		return jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	}
+	signedByOldKey, err := signJWT(oldManager, claims())
+	if err != nil {
+		t.Fatalf("signJWT() error = %v", err)
+	}
+	signedByNewKey, err := signJWT(rotatedManager, claims())
+	if err != nil {
+		t.Fatalf("signJWT() error = %v", err)
+	}
+
+	unknownKey := newTestECKey(t, "unknown")
+	unknownManager, err := NewKeyManager(unknownKey.KID, []*domain.SigningKey{unknownKey})
+	if err != nil {
+		t.Fatalf("NewKeyManager() error = %v", err)
+	}
+	signedByUnknownKey, err := signJWT(unknownManager, claims())
+	if err != nil {
+		t.Fatalf("signJWT() error = %v", err)
+	}
+
+	// 旧鍵のkidを名乗るHS256のトークン（公開鍵をHMACの鍵として使わせる攻撃）
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	hmacToken.Header["kid"] = oldKey.KID
+	signedWithHMAC, err := hmacToken.SignedString([]byte("your-secret-key"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "signed by active key", token: signedByNewKey},
+		{name: "signed by retired key", token: signedByOldKey},
+		{name: "signed by unknown key", token: signedByUnknownKey, wantErr: true},
+		{name: "HS256 with known kid", token: signedWithHMAC, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJWT(rotatedManager, tt.token, &jwt.RegisteredClaims{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseJWT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if key := rotatedManager.SigningKey(); key.KID != newKey.KID {
+		t.Errorf("SigningKey().KID = %s, want %s", key.KID, newKey.KID)
+	}
+}
@@ -6,24 +6,40 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 
 	"go-echo-demo/internal/domain"
 )
 
+const (
+	// lineIssuer LINEのIDトークンのiss
+	lineIssuer = "https://access.line.me"
+	// lineJWKSURL LINEのIDトークン（ES256）の署名検証用の公開鍵
+	lineJWKSURL = "https://api.line.me/oauth2/v2.1/certs"
+)
+
 type LineAuthUsecase struct {
-	config       domain.LineAuthConfig
-	oauthRepo    domain.OAuthRepository
-	authUsecase  domain.AuthUsecase
-	stateManager domain.StateManager
+	config          domain.LineAuthConfig
+	idTokenVerifier domain.IDTokenVerifier
 }
 
-func NewLineAuthUsecase(config domain.LineAuthConfig, oauthRepo domain.OAuthRepository, authUsecase domain.AuthUsecase, stateManager domain.StateManager) domain.OAuthUsecase {
+func NewLineAuthUsecase(config domain.LineAuthConfig) domain.OAuthUsecase {
+	// nonceを検証するIDトークンはopenidスコープを要求した場合のみ発行される
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append(slices.Clone(config.Scopes), "openid")
+	}
+
 	return &LineAuthUsecase{
-		config:       config,
-		oauthRepo:    oauthRepo,
-		authUsecase:  authUsecase,
-		stateManager: stateManager,
+		config: config,
+		// ウェブログインのIDトークンはチャネルシークレットによるHS256、アプリ経由のものはES256（JWKS）で署名される
+		idTokenVerifier: NewIDTokenVerifier(domain.IDTokenVerifierConfig{
+			Issuers:      []string{lineIssuer},
+			ClientID:     config.ChannelID,
+			JWKSURL:      lineJWKSURL,
+			ClientSecret: config.ChannelSecret,
+			Leeway:       providerIDTokenLeeway,
+		}, nil),
 	}
 }
 
@@ -31,20 +47,22 @@ func (u *LineAuthUsecase) GetProviderName() string {
 	return "line"
 }
 
-func (u *LineAuthUsecase) GetAuthURL() string {
-	state := u.stateManager.GenerateState()
-
+func (u *LineAuthUsecase) GetAuthURL(state *domain.OAuthState) (string, error) {
+	// stateと一緒に保存したコードベリファイアとnonceを認可リクエストに含める
 	params := url.Values{}
 	params.Add("response_type", "code")
 	params.Add("client_id", u.config.ChannelID)
 	params.Add("redirect_uri", u.config.RedirectURL)
-	params.Add("state", state)
+	params.Add("state", state.State)
 	params.Add("scope", strings.Join(u.config.Scopes, " "))
+	params.Add("nonce", state.Nonce)
+	params.Add("code_challenge", state.CodeChallenge())
+	params.Add("code_challenge_method", "S256")
 
-	return "https://access.line.me/oauth2/v2.1/authorize?" + params.Encode()
+	return "https://access.line.me/oauth2/v2.1/authorize?" + params.Encode(), nil
 }
 
-func (u *LineAuthUsecase) ExchangeCodeForToken(code string) (*domain.LineTokenResponse, error) {
+func (u *LineAuthUsecase) ExchangeCodeForToken(code, codeVerifier string) (*domain.LineTokenResponse, error) {
 	log.Printf("Exchanging LINE code for token...")
 
 	data := url.Values{}
@@ -53,6 +71,7 @@ func (u *LineAuthUsecase) ExchangeCodeForToken(code string) (*domain.LineTokenRe
 	data.Set("redirect_uri", u.config.RedirectURL)
 	data.Set("client_id", u.config.ChannelID)
 	data.Set("client_secret", u.config.ChannelSecret)
+	data.Set("code_verifier", codeVerifier)
 
 	req, err := http.NewRequest("POST", "https://api.line.me/oauth2/v2.1/token", strings.NewReader(data.Encode()))
 	if err != nil {
@@ -127,47 +146,35 @@ func (u *LineAuthUsecase) GetUserInfo(token interface{}) (*domain.OAuthUser, err
 	return oauthUser, nil
 }
 
-func (u *LineAuthUsecase) Authenticate(code string, state string) (*domain.AuthResponse, error) {
+func (u *LineAuthUsecase) Authenticate(code string, oauthState *domain.OAuthState) (*domain.OAuthUser, error) {
 	log.Printf("Starting LINE authentication...")
 
-	// stateパラメータを検証
-	if !u.stateManager.ValidateState(state) {
-		return nil, fmt.Errorf("invalid state parameter")
-	}
-
-	// 認証コードをトークンに交換
-	token, err := u.ExchangeCodeForToken(code)
+	// 認証コードをトークンに交換（PKCEのコードベリファイアを送信）
+	token, err := u.ExchangeCodeForToken(code, oauthState.CodeVerifier)
 	if err != nil {
 		log.Printf("Token exchange failed: %v", err)
 		return nil, err
 	}
 
-	// LINEユーザー情報を取得
-	oauthUser, err := u.GetUserInfo(token)
-	if err != nil {
-		log.Printf("Get user info failed: %v", err)
-		return nil, err
-	}
-
-	log.Printf("Creating or getting user from database...")
-	// データベースからユーザーを取得または作成
-	user, err := u.oauthRepo.GetOrCreateUser(oauthUser)
+	// IDトークンの署名・iss・aud・exp・nonceを検証し、ユーザー情報はそのクレームから取得する
+	claims, err := u.idTokenVerifier.Verify(token.IDToken, oauthState.Nonce)
 	if err != nil {
-		log.Printf("Get or create user failed: %v", err)
+		log.Printf("ID token verification failed: %v", err)
 		return nil, err
 	}
 
-	log.Printf("Generating JWT token...")
-	// JWTトークンを生成
-	jwtToken, err := u.authUsecase.GenerateToken(user)
-	if err != nil {
-		log.Printf("Generate token failed: %v", err)
-		return nil, err
+	// emailクレームはemailスコープが許可された場合のみ含まれる
+	// LINEはemail_verifiedを返さないため、メールアドレスは確認済みとして扱わない
+	oauthUser := &domain.OAuthUser{
+		ProviderID:   claims.Subject,
+		ProviderName: "line",
+		Email:        claims.Email,
+		Name:         claims.Name,
+		Picture:      claims.Picture,
+		Verified:     claims.EmailVerified,
+		Raw:          claims.Raw,
 	}
+	log.Printf("LINE user info retrieved from ID token: %s (%s)", oauthUser.Name, oauthUser.ProviderID)
 
-	log.Printf("LINE authentication completed successfully")
-	return &domain.AuthResponse{
-		Token: jwtToken,
-		User:  *user,
-	}, nil
+	return oauthUser, nil
 }
@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+// ログイン試行制限のキー
+const (
+	accountAttemptKeyPrefix = "account:"
+	ipAttemptKeyPrefix      = "ip:"
+	mfaAttemptKeyPrefix     = "mfa:"
+	// パスワードリセットの要求回数（ログインのロックと分けるため、アカウント・IPアドレスのキーとは別にする）
+	passwordResetAttemptKeyPrefix   = "password_reset:"
+	passwordResetIPAttemptKeyPrefix = "password_reset_ip:"
+	// マジックリンクの要求回数
+	magicLinkAttemptKeyPrefix   = "magic_link:"
+	magicLinkIPAttemptKeyPrefix = "magic_link_ip:"
+	// 確認メールの再送回数
+	verificationAttemptKeyPrefix   = "verification:"
+	verificationIPAttemptKeyPrefix = "verification_ip:"
+)
+
+// IPアドレスごとのキー（共有IPの利用者を巻き込まないようバックオフをかけず、IPアドレスの閾値でロックする）
+var ipAttemptKeyPrefixes = []string{
+	ipAttemptKeyPrefix, passwordResetIPAttemptKeyPrefix, magicLinkIPAttemptKeyPrefix, verificationIPAttemptKeyPrefix,
+}
+
+// メール送信の要求回数のキー（ロック時はログインのロックとは別のセキュリティイベントを記録する）
+var mailRequestAttemptKeyPrefixes = []string{
+	passwordResetAttemptKeyPrefix, passwordResetIPAttemptKeyPrefix,
+	magicLinkAttemptKeyPrefix, magicLinkIPAttemptKeyPrefix,
+	verificationAttemptKeyPrefix, verificationIPAttemptKeyPrefix,
+}
+
+func accountAttemptKey(email string) string {
+	return accountAttemptKeyPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ipAddress string) string {
+	return ipAttemptKeyPrefix + ipAddress
+}
+
+func mfaAttemptKey(userID int) string {
+	return fmt.Sprintf("%s%d", mfaAttemptKeyPrefix, userID)
+}
+
+func passwordResetAttemptKey(email string) string {
+	return passwordResetAttemptKeyPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func passwordResetIPAttemptKey(ipAddress string) string {
+	return passwordResetIPAttemptKeyPrefix + ipAddress
+}
+
+func magicLinkAttemptKey(email string) string {
+	return magicLinkAttemptKeyPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func magicLinkIPAttemptKey(ipAddress string) string {
+	return magicLinkIPAttemptKeyPrefix + ipAddress
+}
+
+func verificationAttemptKey(email string) string {
+	return verificationAttemptKeyPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func verificationIPAttemptKey(ipAddress string) string {
+	return verificationIPAttemptKeyPrefix + ipAddress
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type loginThrottle struct {
+	store             domain.LoginAttemptStore
+	securityEventRepo domain.SecurityEventRepository
+	config            domain.LoginAttemptConfig
+}
+
+// NewLoginThrottle ログイン試行制限のコンストラクタ
+//
+// アカウント（および二要素認証）のキーは一定回数の失敗後に指数バックオフをかけ、
+// 閾値に達するとロックする。IPアドレスのキーは共有IPの利用者を巻き込まないよう
+// バックオフはかけず、より大きな閾値でのみロックする。
+func NewLoginThrottle(store domain.LoginAttemptStore, securityEventRepo domain.SecurityEventRepository, config domain.LoginAttemptConfig) domain.LoginThrottle {
+	return &loginThrottle{
+		store:             store,
+		securityEventRepo: securityEventRepo,
+		config:            config,
+	}
+}
+
+// Check いずれかのキーがロック中またはバックオフ中なら拒否
+func (t *loginThrottle) Check(keys ...string) error {
+	now := time.Now()
+
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempt, err := t.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if attempt == nil {
+			continue
+		}
+
+		if wait := t.waitTime(key, attempt, now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &domain.LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// waitTime 次の試行を受け付けるまでの待ち時間
+func (t *loginThrottle) waitTime(key string, attempt *domain.LoginAttempt, now time.Time) time.Duration {
+	if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now)
+	}
+
+	// 集計期間を過ぎた失敗は数えない
+	if now.Sub(attempt.LastFailureAt) > t.config.LockoutDuration {
+		return 0
+	}
+	if hasAnyPrefix(key, ipAttemptKeyPrefixes) || attempt.Failures <= t.config.FreeAttempts {
+		return 0
+	}
+
+	delay := t.config.BaseDelay
+	for i := t.config.FreeAttempts + 1; i < attempt.Failures && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+
+	if next := attempt.LastFailureAt.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// RecordFailure 失敗を記録し、閾値に達したキーをロック
+// 記録に失敗してもログイン処理自体は継続させるため、エラーはログに残すのみ
+func (t *loginThrottle) RecordFailure(userID *int, ipAddress string, keys ...string) {
+	for _, key := range keys {
+		attempt, err := t.store.RecordFailure(key, t.config.LockoutDuration)
+		if err != nil {
+			log.Printf("Failed to record login failure for %s: %v", key, err)
+			continue
+		}
+
+		maxFailures := t.config.MaxAccountFailures
+		if hasAnyPrefix(key, ipAttemptKeyPrefixes) {
+			maxFailures = t.config.MaxIPFailures
+		}
+		if attempt.Failures < maxFailures {
+			continue
+		}
+
+		if err := t.store.Lock(key, time.Now().Add(t.config.LockoutDuration)); err != nil {
+			log.Printf("Failed to lock %s: %v", key, err)
+			continue
+		}
+
+		log.Printf("Login locked: key=%s failures=%d", key, attempt.Failures)
+		eventType := domain.SecurityEventLoginLocked
+		if hasAnyPrefix(key, mailRequestAttemptKeyPrefixes) {
+			eventType = domain.SecurityEventMailRequestLocked
+		}
+		event := &domain.SecurityEvent{
+			UserID:      userID,
+			EventType:   eventType,
+			Description: fmt.Sprintf("%s locked for %s after %d failed attempts", key, t.config.LockoutDuration, attempt.Failures),
+			IPAddress:   ipAddress,
+		}
+		if err := t.securityEventRepo.Create(event); err != nil {
+			log.Printf("Failed to record security event: %v", err)
+		}
+	}
+}
+
+// Reset 成功時に失敗回数をリセット
+func (t *loginThrottle) Reset(keys ...string) {
+	for _, key := range keys {
+		if err := t.store.Reset(key); err != nil {
+			log.Printf("Failed to reset login attempts for %s: %v", key, err)
+		}
+	}
+}
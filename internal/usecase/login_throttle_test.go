@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+func TestLoginThrottleCheck(t *testing.T) {
+	config := testLoginAttemptConfig()
+
+	tests := []struct {
+		name         string
+		key          string
+		failures     int
+		elapsed      time.Duration // 最後の失敗からの経過時間
+		wantRetryMin time.Duration // 0の場合は受け付ける
+		wantRetryMax time.Duration
+		wantEvent    string
+	}{
+		{name: "account within free attempts", key: accountAttemptKey("alice@example.com"), failures: config.FreeAttempts},
+		{
+			name:         "account backoff after free attempts",
+			key:          accountAttemptKey("alice@example.com"),
+			failures:     config.FreeAttempts + 1,
+			wantRetryMin: config.BaseDelay / 2,
+			wantRetryMax: config.BaseDelay,
+		},
+		{
+			name:     "account backoff elapsed",
+			key:      accountAttemptKey("alice@example.com"),
+			failures: config.FreeAttempts + 1,
+			elapsed:  2 * config.BaseDelay,
+		},
+		{
+			name:         "account locked at threshold",
+			key:          accountAttemptKey("alice@example.com"),
+			failures:     config.MaxAccountFailures,
+			wantRetryMin: config.LockoutDuration - time.Minute,
+			wantRetryMax: config.LockoutDuration,
+			wantEvent:    domain.SecurityEventLoginLocked,
+		},
+		{
+			name:      "account lockout expired",
+			key:       accountAttemptKey("alice@example.com"),
+			failures:  config.MaxAccountFailures,
+			elapsed:   config.LockoutDuration + time.Second,
+			wantEvent: domain.SecurityEventLoginLocked,
+		},
+		{name: "ip has no backoff", key: ipAttemptKey("192.0.2.1"), failures: config.MaxIPFailures - 1},
+		{
+			name:         "ip locked at ip threshold",
+			key:          ipAttemptKey("192.0.2.1"),
+			failures:     config.MaxIPFailures,
+			wantRetryMin: config.LockoutDuration - time.Minute,
+			wantRetryMax: config.LockoutDuration,
+			wantEvent:    domain.SecurityEventLoginLocked,
+		},
+		{
+			name:         "mfa backoff after free attempts",
+			key:          mfaAttemptKey(1),
+			failures:     config.FreeAttempts + 1,
+			wantRetryMin: config.BaseDelay / 2,
+			wantRetryMax: config.BaseDelay,
+		},
+		{
+			name:         "password reset locked as mail request",
+			key:          passwordResetAttemptKey("alice@example.com"),
+			failures:     config.MaxAccountFailures,
+			wantRetryMin: config.LockoutDuration - time.Minute,
+			wantRetryMax: config.LockoutDuration,
+			wantEvent:    domain.SecurityEventMailRequestLocked,
+		},
+		{name: "magic link ip has no backoff", key: magicLinkIPAttemptKey("192.0.2.1"), failures: config.MaxIPFailures - 1},
+		{
+			name:         "verification ip locked as mail request",
+			key:          verificationIPAttemptKey("192.0.2.1"),
+			failures:     config.MaxIPFailures,
+			wantRetryMin: config.LockoutDuration - time.Minute,
+			wantRetryMax: config.LockoutDuration,
+			wantEvent:    domain.SecurityEventMailRequestLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeLoginAttemptStore()
+			securityEvents := &fakeSecurityEventRepository{}
+			throttle := NewLoginThrottle(store, securityEvents, config)
+
+			for i := 0; i < tt.failures; i++ {
+				throttle.RecordFailure(nil, "192.0.2.1", tt.key)
+			}
+			store.age(tt.key, tt.elapsed)
+
+			err := throttle.Check(tt.key)
+			var lockedErr *domain.LoginLockedError
+			if tt.wantRetryMin == 0 {
+				if err != nil {
+					t.Errorf("Check() error = %v, want nil", err)
+				}
+			} else if !errors.As(err, &lockedErr) {
+				t.Errorf("Check() error = %v, want *domain.LoginLockedError", err)
+			} else if lockedErr.RetryAfter < tt.wantRetryMin || lockedErr.RetryAfter > tt.wantRetryMax {
+				t.Errorf("RetryAfter = %v, want between %v and %v", lockedErr.RetryAfter, tt.wantRetryMin, tt.wantRetryMax)
+			}
+
+			if len(securityEvents.events) != 0 && tt.wantEvent == "" {
+				t.Errorf("recorded events %+v, want none", securityEvents.events)
+			}
+			if tt.wantEvent != "" && len(securityEvents.eventsOfType(tt.wantEvent)) != 1 {
+				t.Errorf("recorded events %+v, want one %s event", securityEvents.events, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleReset(t *testing.T) {
+	throttle := NewLoginThrottle(newFakeLoginAttemptStore(), &fakeSecurityEventRepository{}, testLoginAttemptConfig())
+	accountKey := accountAttemptKey("alice@example.com")
+	otherKey := accountAttemptKey("bob@example.com")
+
+	for i := 0; i <= testLoginAttemptConfig().FreeAttempts; i++ {
+		throttle.RecordFailure(nil, "192.0.2.1", accountKey, otherKey)
+	}
+	throttle.Reset(accountKey)
+
+	if err := throttle.Check(accountKey); err != nil {
+		t.Errorf("Check() after Reset error = %v, want nil", err)
+	}
+	if err := throttle.Check(otherKey); err == nil {
+		t.Error("Check() for another key after Reset error = nil, want backoff")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	const password = "correct horse battery staple"
+
+	deps := newTestAuthUsecase(t,
+		verifiedUser("alice@example.com", password),
+		verifiedUser("bob@example.com", password),
+	)
+
+	for i := 0; i <= testLoginAttemptConfig().FreeAttempts; i++ {
+		if _, err := deps.usecase.Login("alice@example.com", "wrong password", "test", "192.0.2.1"); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d error = %v, want %v", i+1, err, domain.ErrInvalidCredentials)
+		}
+	}
+
+	// 正しいパスワードでもバックオフ中は拒否する
+	var lockedErr *domain.LoginLockedError
+	if _, err := deps.usecase.Login("alice@example.com", password, "test", "192.0.2.1"); !errors.As(err, &lockedErr) {
+		t.Fatalf("Login() error = %v, want *domain.LoginLockedError", err)
+	}
+	// 大文字・空白の違いで制限を回避できない
+	if _, err := deps.usecase.Login(" Alice@Example.com", password, "test", "192.0.2.1"); !errors.As(err, &lockedErr) {
+		t.Errorf("Login() with differently formatted email error = %v, want *domain.LoginLockedError", err)
+	}
+
+	// 同じIPアドレスの別アカウントは巻き込まない
+	if _, err := deps.usecase.Login("bob@example.com", password, "test", "192.0.2.1"); err != nil {
+		t.Errorf("Login() for another account error = %v", err)
+	}
+
+	if err := deps.usecase.UnlockAccount("admin", 1); err != nil {
+		t.Fatalf("UnlockAccount() error = %v", err)
+	}
+	if _, err := deps.usecase.Login("alice@example.com", password, "test", "192.0.2.1"); err != nil {
+		t.Errorf("Login() after unlock error = %v", err)
+	}
+}
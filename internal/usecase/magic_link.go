@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+type MagicLinkUsecase struct {
+	authRepo      domain.AuthRepository
+	userRepo      domain.UserRepository
+	magicLinkRepo domain.MagicLinkRepository
+	authUsecase   domain.AuthUsecase
+	mailer        domain.Mailer
+	loginThrottle domain.LoginThrottle
+	config        domain.MagicLinkConfig
+}
+
+func NewMagicLinkUsecase(
+	authRepo domain.AuthRepository,
+	userRepo domain.UserRepository,
+	magicLinkRepo domain.MagicLinkRepository,
+	authUsecase domain.AuthUsecase,
+	mailer domain.Mailer,
+	loginThrottle domain.LoginThrottle,
+	config domain.MagicLinkConfig,
+) domain.MagicLinkUsecase {
+	return &MagicLinkUsecase{
+		authRepo:      authRepo,
+		userRepo:      userRepo,
+		magicLinkRepo: magicLinkRepo,
+		authUsecase:   authUsecase,
+		mailer:        mailer,
+		loginThrottle: loginThrottle,
+		config:        config,
+	}
+}
+
+// RequestLink ログインリンクをメールで送信し、要求元ブラウザに保存するバインディング値とリンクの有効期限を返す
+// アカウントの存在有無が応答の内容と時間から分からないよう、リンクの発行と送信は応答とは別に行う
+func (u *MagicLinkUsecase) RequestLink(email, ipAddress string) (string, time.Time, error) {
+	email = strings.TrimSpace(email)
+
+	// 特定のアカウントへの大量送信や、アカウントの探索を防ぐため、メールアドレスとクライアントIPごとに要求回数を制限する
+	// 要求自体に成否はないため、アカウントの有無に関わらずすべての要求を記録する
+	attemptKeys := []string{magicLinkAttemptKey(email), magicLinkIPAttemptKey(ipAddress)}
+	if err := u.loginThrottle.Check(attemptKeys...); err != nil {
+		return "", time.Time{}, err
+	}
+	u.loginThrottle.RecordFailure(nil, ipAddress, attemptKeys...)
+
+	// アカウントの有無に関わらず、常にバインディング値を発行する
+	binding, err := generateSecureToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(u.config.TokenDuration)
+
+	user, err := u.authRepo.GetUserByEmail(email)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if user == nil {
+		return binding, expiresAt, nil
+	}
+
+	go func() {
+		if err := u.sendLink(user, binding, ipAddress, expiresAt); err != nil {
+			log.Printf("Failed to send magic link email to user %d: %v", user.ID, err)
+		}
+	}()
+	return binding, expiresAt, nil
+}
+
+// sendLink ログインリンクのトークンを発行してメールで送信
+func (u *MagicLinkUsecase) sendLink(user *domain.User, binding, ipAddress string, expiresAt time.Time) error {
+	// 以前に発行した未使用のリンクは無効にする
+	if err := u.magicLinkRepo.InvalidateByUserID(user.ID); err != nil {
+		return err
+	}
+
+	tokenString, err := generateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	// トークンとバインディング値はハッシュ値のみをデータベースに保存
+	token := &domain.MagicLinkToken{
+		UserID:      user.ID,
+		TokenHash:   hashToken(tokenString),
+		BindingHash: hashToken(binding),
+		IPAddress:   ipAddress,
+		ExpiresAt:   expiresAt,
+	}
+	if err := u.magicLinkRepo.Create(token); err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(u.config.BaseURL, "/") + "/login/magic-link/verify?token=" + url.QueryEscape(tokenString)
+	return u.mailer.Send(&domain.MailMessage{
+		To:      user.Email,
+		Subject: "ログインリンク",
+		Body: fmt.Sprintf("%s 様\n\n以下のリンクからログインしてください。リンクは要求したときと同じブラウザで開いてください。\n\n%s\n\nこのリンクの有効期限は%sで、一度だけ使用できます。心当たりがない場合はこのメールを破棄してください。\n",
+			user.Name, link, formatDuration(u.config.TokenDuration)),
+	})
+}
+
+// VerifyLink ログインリンクのトークンとバインディング値を検証してトークンペアを発行
+func (u *MagicLinkUsecase) VerifyLink(tokenString, binding, deviceInfo, ipAddress string) (*domain.TokenPair, error) {
+	token, err := u.magicLinkRepo.GetByTokenHash(hashToken(tokenString))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, domain.ErrInvalidMagicLink
+	}
+
+	// メールの転送や盗み見でリンクだけを入手しても、要求元ブラウザ以外ではログインできない
+	// 正規のブラウザで開き直せるよう、この場合はトークンを消費しない
+	if subtle.ConstantTimeCompare([]byte(hashToken(binding)), []byte(token.BindingHash)) != 1 {
+		return nil, domain.ErrMagicLinkBrowserMismatch
+	}
+
+	used, err := u.magicLinkRepo.MarkUsed(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, domain.ErrInvalidMagicLink
+	}
+
+	user, err := u.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrInvalidMagicLink
+	}
+
+	// リンクを受け取れたことでメールアドレスの所有も確認できている
+	if user.EmailVerifiedAt == nil {
+		if _, err := u.authRepo.MarkEmailVerified(user.ID, user.Email); err != nil {
+			log.Printf("Failed to mark email verified for user %d: %v", user.ID, err)
+		}
+	}
+
+	// マジックリンクはパスワードの代わりであり、二要素認証は省略しない
+	challenge, err := u.authUsecase.IssueMFAChallenge(user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return nil, &domain.MFARequiredError{Challenge: challenge}
+	}
+
+	return u.authUsecase.GenerateTokenPair(user, deviceInfo, ipAddress)
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+type testMagicLink struct {
+	*testAuthDeps
+	usecase    *MagicLinkUsecase
+	magicLinks *fakeMagicLinkRepository
+	mailer     *fakeMailer
+}
+
+func newTestMagicLinkUsecase(t *testing.T) *testMagicLink {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t, &domain.User{Name: "Alice", Email: "alice@example.com"})
+	m := &testMagicLink{testAuthDeps: deps, magicLinks: &fakeMagicLinkRepository{}, mailer: &fakeMailer{}}
+	magicLinkUsecase := NewMagicLinkUsecase(
+		deps.users, deps.users, m.magicLinks, deps.usecase, m.mailer, deps.loginThrottle,
+		domain.MagicLinkConfig{BaseURL: "http://localhost:8080", TokenDuration: 15 * time.Minute},
+	)
+	m.usecase = magicLinkUsecase.(*MagicLinkUsecase)
+	return m
+}
+
+// requestLink ログインリンクを要求し、バインディング値とメールのトークンを取得
+func (m *testMagicLink) requestLink(t *testing.T) (string, string) {
+	t.Helper()
+
+	sent := len(m.mailer.sent())
+	binding, _, err := m.usecase.RequestLink("alice@example.com", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("RequestLink() error = %v", err)
+	}
+	messages := m.mailer.waitForMessages(t, sent+1)
+	return binding, mailLinkToken(t, messages[sent], "token")
+}
+
+func TestRequestLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantSent bool
+	}{
+		{name: "registered email", email: "alice@example.com", wantSent: true},
+		{name: "unknown email", email: "bob@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMagicLinkUsecase(t)
+
+			// アカウントの有無に関わらず同じ形の応答を返す
+			binding, expiresAt, err := m.usecase.RequestLink(tt.email, "192.0.2.1")
+			if err != nil {
+				t.Fatalf("RequestLink() error = %v", err)
+			}
+			if binding == "" || time.Until(expiresAt) <= 0 {
+				t.Errorf("RequestLink() = %q, %v, want a binding and a future expiry", binding, expiresAt)
+			}
+
+			if !tt.wantSent {
+				time.Sleep(20 * time.Millisecond)
+				if messages := m.mailer.sent(); len(messages) != 0 {
+					t.Errorf("sent %d messages, want none", len(messages))
+				}
+				return
+			}
+			token := mailLinkToken(t, m.mailer.waitForMessages(t, 1)[0], "token")
+			stored, _ := m.magicLinks.GetByTokenHash(hashToken(token))
+			if stored == nil || stored.BindingHash != hashToken(binding) {
+				t.Errorf("stored token = %+v, want digests of the token and binding", stored)
+			}
+		})
+	}
+}
+
+func TestRequestLinkIsThrottled(t *testing.T) {
+	m := newTestMagicLinkUsecase(t)
+
+	for i := 0; i <= testLoginAttemptConfig().FreeAttempts; i++ {
+		if _, _, err := m.usecase.RequestLink("bob@example.com", "192.0.2.1"); err != nil {
+			t.Fatalf("attempt %d error = %v", i+1, err)
+		}
+	}
+
+	var lockedErr *domain.LoginLockedError
+	if _, _, err := m.usecase.RequestLink(" Bob@Example.com", "192.0.2.1"); !errors.As(err, &lockedErr) {
+		t.Errorf("RequestLink() error = %v, want *domain.LoginLockedError", err)
+	}
+}
+
+func TestVerifyLink(t *testing.T) {
+	tests := []struct {
+		name string
+		// link 検証に使うトークンとバインディング値を用意する
+		link    func(t *testing.T, m *testMagicLink) (string, string)
+		wantErr error
+	}{
+		{
+			name: "same browser",
+			link: func(t *testing.T, m *testMagicLink) (string, string) {
+				binding, token := m.requestLink(t)
+				return token, binding
+			},
+		},
+		{
+			name: "different browser",
+			link: func(t *testing.T, m *testMagicLink) (string, string) {
+				_, token := m.requestLink(t)
+				return token, "other-browser-binding"
+			},
+			wantErr: domain.ErrMagicLinkBrowserMismatch,
+		},
+		{
+			name: "unknown token",
+			link: func(t *testing.T, m *testMagicLink) (string, string) {
+				binding, _ := m.requestLink(t)
+				return "unknown-token", binding
+			},
+			wantErr: domain.ErrInvalidMagicLink,
+		},
+		{
+			name: "used token",
+			link: func(t *testing.T, m *testMagicLink) (string, string) {
+				binding, token := m.requestLink(t)
+				if _, err := m.usecase.VerifyLink(token, binding, "test-agent", "192.0.2.1"); err != nil {
+					t.Fatalf("VerifyLink() error = %v", err)
+				}
+				return token, binding
+			},
+			wantErr: domain.ErrInvalidMagicLink,
+		},
+		{
+			name: "expired token",
+			link: func(t *testing.T, m *testMagicLink) (string, string) {
+				binding, token := m.requestLink(t)
+				m.magicLinks.expire(hashToken(token))
+				return token, binding
+			},
+			wantErr: domain.ErrInvalidMagicLink,
+		},
+		{
+			name: "superseded by a newer link",
+			link: func(t *testing.T, m *testMagicLink) (string, string) {
+				binding, token := m.requestLink(t)
+				m.requestLink(t)
+				return token, binding
+			},
+			wantErr: domain.ErrInvalidMagicLink,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMagicLinkUsecase(t)
+			token, binding := tt.link(t, m)
+
+			tokenPair, err := m.usecase.VerifyLink(token, binding, "test-agent", "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyLink() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if _, err := m.testAuthDeps.usecase.ValidateToken(tokenPair.AccessToken); err != nil {
+				t.Errorf("ValidateToken() error = %v", err)
+			}
+			if m.users.user(1).EmailVerifiedAt == nil {
+				t.Error("email was not marked verified")
+			}
+		})
+	}
+}
+
+func TestVerifyLinkBrowserMismatchKeepsToken(t *testing.T) {
+	m := newTestMagicLinkUsecase(t)
+	binding, token := m.requestLink(t)
+
+	if _, err := m.usecase.VerifyLink(token, "other-browser-binding", "test-agent", "203.0.113.9"); !errors.Is(err, domain.ErrMagicLinkBrowserMismatch) {
+		t.Fatalf("VerifyLink() error = %v, want %v", err, domain.ErrMagicLinkBrowserMismatch)
+	}
+	// 要求元のブラウザで開き直せる
+	if _, err := m.usecase.VerifyLink(token, binding, "test-agent", "192.0.2.1"); err != nil {
+		t.Errorf("VerifyLink() from the requesting browser error = %v", err)
+	}
+}
+
+func TestVerifyLinkRequiresMFA(t *testing.T) {
+	m := newTestMagicLinkUsecase(t)
+	m.mfa.configs[1] = &domain.UserMFA{UserID: 1, Enabled: true}
+	binding, token := m.requestLink(t)
+
+	var mfaErr *domain.MFARequiredError
+	if _, err := m.usecase.VerifyLink(token, binding, "test-agent", "192.0.2.1"); !errors.As(err, &mfaErr) {
+		t.Fatalf("VerifyLink() error = %v, want *domain.MFARequiredError", err)
+	}
+	if mfaErr.Challenge == nil || mfaErr.Challenge.MFAToken == "" {
+		t.Errorf("MFARequiredError.Challenge = %+v, want a challenge token", mfaErr.Challenge)
+	}
+}
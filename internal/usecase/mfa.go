@@ -0,0 +1,356 @@
+package usecase
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 5 // 40ビット（"xxxxx-xxxxx" 形式の10桁16進数）
+
+	// 暗号化済みシークレットの形式バージョン（鍵や方式を変更する場合に備える）
+	mfaSecretVersion = "v1"
+)
+
+type MFAUsecase struct {
+	mfaRepo           domain.MFARepository
+	userRepo          domain.UserRepository
+	authUsecase       domain.AuthUsecase
+	revokedTokenStore domain.RevokedTokenStore
+	loginThrottle     domain.LoginThrottle
+	config            domain.MFAConfig
+}
+
+func NewMFAUsecase(
+	mfaRepo domain.MFARepository,
+	userRepo domain.UserRepository,
+	authUsecase domain.AuthUsecase,
+	revokedTokenStore domain.RevokedTokenStore,
+	loginThrottle domain.LoginThrottle,
+	config domain.MFAConfig,
+) domain.MFAUsecase {
+	return &MFAUsecase{
+		mfaRepo:           mfaRepo,
+		userRepo:          userRepo,
+		authUsecase:       authUsecase,
+		revokedTokenStore: revokedTokenStore,
+		loginThrottle:     loginThrottle,
+		config:            config,
+	}
+}
+
+// GetStatus 二要素認証の設定状況を取得
+func (u *MFAUsecase) GetStatus(userID int) (*domain.MFAStatus, error) {
+	mfa, err := u.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return &domain.MFAStatus{}, nil
+	}
+
+	remaining, err := u.mfaRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.MFAStatus{Enabled: true, RecoveryCodesRemaining: remaining}, nil
+}
+
+// BeginEnrollment シークレットを生成し、認証アプリ登録用のURIとQRコードを返す
+// 最初のコードで確認されるまでは有効にならない
+func (u *MFAUsecase) BeginEnrollment(userID int) (*domain.MFAEnrollment, error) {
+	mfa, err := u.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// シークレットは暗号化して保存する
+	encrypted, err := u.encryptSecret(userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.mfaRepo.SavePending(userID, encrypted); err != nil {
+		return nil, err
+	}
+
+	uri := totpURI(u.config.Issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate qr code: %w", err)
+	}
+
+	return &domain.MFAEnrollment{
+		Secret:     totpEncoding.EncodeToString(secret),
+		OTPAuthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// ConfirmEnrollment 最初のコードで登録を確認して有効化し、リカバリーコードを発行
+func (u *MFAUsecase) ConfirmEnrollment(userID int, code, ipAddress string) ([]string, error) {
+	mfa, err := u.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, domain.ErrMFAEnrollmentNotFound
+	}
+	if mfa.Enabled {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+
+	err = u.throttleCodeCheck(userID, ipAddress, func() error {
+		return u.verifyTOTP(mfa, normalizeMFACode(code))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.mfaRepo.Enable(userID); err != nil {
+		return nil, err
+	}
+
+	return u.issueRecoveryCodes(userID)
+}
+
+// Disable 現在のコード（またはリカバリーコード）を確認して二要素認証を無効化
+func (u *MFAUsecase) Disable(userID int, code, ipAddress string) error {
+	if err := u.verifyCodeWithThrottle(userID, code, ipAddress); err != nil {
+		return err
+	}
+
+	return u.mfaRepo.Delete(userID)
+}
+
+// RegenerateRecoveryCodes 既存のリカバリーコードを破棄して再発行
+func (u *MFAUsecase) RegenerateRecoveryCodes(userID int, code, ipAddress string) ([]string, error) {
+	if err := u.verifyCodeWithThrottle(userID, code, ipAddress); err != nil {
+		return nil, err
+	}
+
+	return u.issueRecoveryCodes(userID)
+}
+
+// Verify ログイン時のチャレンジトークンと認証コードを検証してトークンペアを発行
+func (u *MFAUsecase) Verify(mfaToken, code, deviceInfo, ipAddress string) (*domain.AuthResponse, error) {
+	claims, err := u.authUsecase.ValidateMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, domain.ErrInvalidMFAChallenge
+	}
+
+	user, err := u.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrInvalidMFAChallenge
+	}
+
+	if err := u.verifyCodeWithThrottle(user.ID, code, ipAddress); err != nil {
+		return nil, err
+	}
+
+	// チャレンジトークンは一度だけ使用できる
+	if err := u.revokedTokenStore.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	tokenPair, err := u.authUsecase.GenerateTokenPair(user, deviceInfo, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AuthResponse{
+		Token:        tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+		User:         *user,
+	}, nil
+}
+
+// verifyCodeWithThrottle 試行回数の制限を確認してから有効化済みの設定に対してコードを検証する
+func (u *MFAUsecase) verifyCodeWithThrottle(userID int, code, ipAddress string) error {
+	return u.throttleCodeCheck(userID, ipAddress, func() error {
+		return u.verifyCode(userID, code)
+	})
+}
+
+// throttleCodeCheck 試行回数の制限を確認してからコードを検証し、失敗を記録する（成功した場合はユーザーの失敗回数をリセット）
+// 6桁のコードは総当たりしやすいため、ログイン時もログイン中の操作（登録の確認・無効化・リカバリーコードの再発行）でも
+// パスワードログインと同様に、ユーザーとIPアドレスごとに試行回数を制限する
+func (u *MFAUsecase) throttleCodeCheck(userID int, ipAddress string, verify func() error) error {
+	attemptKeys := []string{mfaAttemptKey(userID), ipAttemptKey(ipAddress)}
+	if err := u.loginThrottle.Check(attemptKeys...); err != nil {
+		return err
+	}
+
+	if err := verify(); err != nil {
+		if errors.Is(err, domain.ErrInvalidMFACode) {
+			u.loginThrottle.RecordFailure(&userID, ipAddress, attemptKeys...)
+		}
+		return err
+	}
+	u.loginThrottle.Reset(mfaAttemptKey(userID))
+	return nil
+}
+
+// verifyCode 有効化済みの設定に対してTOTPコードまたはリカバリーコードを検証
+func (u *MFAUsecase) verifyCode(userID int, code string) error {
+	mfa, err := u.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return domain.ErrMFANotEnabled
+	}
+
+	code = normalizeMFACode(code)
+	if len(code) == totpDigits {
+		return u.verifyTOTP(mfa, code)
+	}
+
+	// 6桁以外はリカバリーコードとして扱う
+	used, err := u.mfaRepo.UseRecoveryCode(userID, hashToken(strings.ReplaceAll(code, "-", "")))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return domain.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// verifyTOTP TOTPコードを検証し、同じタイムステップのコードの再利用を拒否する
+func (u *MFAUsecase) verifyTOTP(mfa *domain.UserMFA, code string) error {
+	secret, err := u.decryptSecret(mfa.UserID, mfa.SecretEncrypted)
+	if err != nil {
+		return err
+	}
+
+	step, ok := validateTOTP(secret, code, time.Now())
+	if !ok || step <= mfa.LastUsedStep {
+		return domain.ErrInvalidMFACode
+	}
+
+	// 並行リクエストでも同じステップを二度受け付けないよう条件付きで更新
+	accepted, err := u.mfaRepo.UpdateLastUsedStep(mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return domain.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// issueRecoveryCodes リカバリーコードを生成し、ハッシュ値のみを保存
+func (u *MFAUsecase) issueRecoveryCodes(userID int) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := hex.EncodeToString(b)
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashToken(raw))
+	}
+
+	if err := u.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// encryptSecret TOTPシークレットをAES-256-GCMで暗号化
+// ユーザーIDを追加認証データにして、別ユーザーの行への付け替えを検知する
+func (u *MFAUsecase) encryptSecret(userID int, secret []byte) (string, error) {
+	gcm, err := u.newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, secret, mfaAdditionalData(userID))
+	return mfaSecretVersion + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret 暗号化されたTOTPシークレットを復号
+func (u *MFAUsecase) decryptSecret(userID int, encrypted string) ([]byte, error) {
+	version, encoded, ok := strings.Cut(encrypted, ":")
+	if !ok || version != mfaSecretVersion {
+		return nil, errors.New("unsupported mfa secret format")
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mfa secret: %w", err)
+	}
+
+	gcm, err := u.newGCM()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("invalid mfa secret")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	secret, err := gcm.Open(nil, nonce, ciphertext, mfaAdditionalData(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt mfa secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (u *MFAUsecase) newGCM() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(u.config.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mfa encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func mfaAdditionalData(userID int) []byte {
+	return []byte(fmt.Sprintf("user_mfa:%d", userID))
+}
+
+// normalizeMFACode 入力された認証コードの空白を除去して小文字に揃える
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.Join(strings.Fields(code), ""))
+}
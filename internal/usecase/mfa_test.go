@@ -0,0 +1,299 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// RFC 6238 付録Bのテストベクター（SHA1）の下位6桁
+	secret := []byte("12345678901234567890")
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: "287082", wantStep: 1, wantOK: true},
+		{name: "previous step within skew", code: hotpCode(secret, 0), wantStep: 0, wantOK: true},
+		{name: "next step within skew", code: hotpCode(secret, 2), wantStep: 2, wantOK: true},
+		{name: "outside skew", code: hotpCode(secret, 3)},
+		{name: "wrong code", code: "000000"},
+		{name: "wrong length", code: "28708"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := validateTOTP(secret, tt.code, now)
+			if ok != tt.wantOK || (ok && step != tt.wantStep) {
+				t.Errorf("validateTOTP() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+// testMFA テスト用の二要素認証ユースケースと、登録済みのシークレット・リカバリーコード
+type testMFA struct {
+	*testAuthDeps
+	usecase       *MFAUsecase
+	secret        []byte
+	confirmedStep int64
+	recoveryCodes []string
+}
+
+func newTestMFAUsecase(t *testing.T) *testMFA {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+	mfaUsecase := NewMFAUsecase(deps.mfa, deps.users, deps.usecase, deps.revokedTokens, deps.loginThrottle, domain.MFAConfig{
+		Issuer:        "go-echo-demo",
+		EncryptionKey: []byte("0123456789abcdef0123456789abcdef"),
+	})
+	return &testMFA{testAuthDeps: deps, usecase: mfaUsecase.(*MFAUsecase)}
+}
+
+// beginEnrollment 登録を開始してシークレットを取得
+func (m *testMFA) beginEnrollment(t *testing.T) {
+	t.Helper()
+
+	enrollment, err := m.usecase.BeginEnrollment(1)
+	if err != nil {
+		t.Fatalf("BeginEnrollment() error = %v", err)
+	}
+	if !strings.HasPrefix(enrollment.OTPAuthURI, "otpauth://totp/") {
+		t.Errorf("OTPAuthURI = %s, want otpauth://totp/ URI", enrollment.OTPAuthURI)
+	}
+	m.secret, err = totpEncoding.DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("failed to decode secret: %v", err)
+	}
+}
+
+// enroll 現在のコードで登録を確認して二要素認証を有効にする
+func (m *testMFA) enroll(t *testing.T) {
+	t.Helper()
+
+	m.beginEnrollment(t)
+	m.confirmedStep = totpStep(time.Now())
+	codes, err := m.usecase.ConfirmEnrollment(1, hotpCode(m.secret, m.confirmedStep), "192.0.2.1")
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() error = %v", err)
+	}
+	m.recoveryCodes = codes
+}
+
+// challenge ログイン時のチャレンジトークンを発行
+func (m *testMFA) challenge(t *testing.T) string {
+	t.Helper()
+
+	user, _ := m.users.GetByID(1)
+	response, err := m.testAuthDeps.usecase.IssueMFAChallenge(user)
+	if err != nil || response == nil || !response.MFARequired {
+		t.Fatalf("IssueMFAChallenge() = %+v, %v, want a challenge", response, err)
+	}
+	return response.MFAToken
+}
+
+func TestMFAConfirmEnrollment(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, m *testMFA)
+		code    func(m *testMFA) string
+		wantErr error
+	}{
+		{
+			name:  "current code",
+			setup: func(t *testing.T, m *testMFA) { m.beginEnrollment(t) },
+			code:  func(m *testMFA) string { return hotpCode(m.secret, totpStep(time.Now())) },
+		},
+		{
+			name:    "wrong code",
+			setup:   func(t *testing.T, m *testMFA) { m.beginEnrollment(t) },
+			code:    func(m *testMFA) string { return "000000" },
+			wantErr: domain.ErrInvalidMFACode,
+		},
+		{
+			name:    "not started",
+			setup:   func(t *testing.T, m *testMFA) {},
+			code:    func(m *testMFA) string { return "000000" },
+			wantErr: domain.ErrMFAEnrollmentNotFound,
+		},
+		{
+			name:    "already enabled",
+			setup:   func(t *testing.T, m *testMFA) { m.enroll(t) },
+			code:    func(m *testMFA) string { return hotpCode(m.secret, m.confirmedStep+1) },
+			wantErr: domain.ErrMFAAlreadyEnabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMFAUsecase(t)
+			tt.setup(t, m)
+
+			codes, err := m.usecase.ConfirmEnrollment(1, tt.code(m), "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConfirmEnrollment() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(codes) != recoveryCodeCount {
+				t.Errorf("ConfirmEnrollment() returned %d recovery codes, want %d", len(codes), recoveryCodeCount)
+			}
+			status, err := m.usecase.GetStatus(1)
+			if err != nil || !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount {
+				t.Errorf("GetStatus() = %+v, %v, want enabled with %d recovery codes", status, err, recoveryCodeCount)
+			}
+		})
+	}
+}
+
+func TestMFAVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup 二要素認証を有効にした後、コードを入力する前の準備
+		setup   func(t *testing.T, m *testMFA)
+		code    func(m *testMFA) string
+		wantErr error
+	}{
+		{
+			name: "next step code",
+			code: func(m *testMFA) string { return hotpCode(m.secret, m.confirmedStep+1) },
+		},
+		{
+			name:    "code replayed from enrollment",
+			code:    func(m *testMFA) string { return hotpCode(m.secret, m.confirmedStep) },
+			wantErr: domain.ErrInvalidMFACode,
+		},
+		{
+			name:    "wrong code",
+			code:    func(m *testMFA) string { return "000000" },
+			wantErr: domain.ErrInvalidMFACode,
+		},
+		{
+			name: "recovery code",
+			code: func(m *testMFA) string { return m.recoveryCodes[0] },
+		},
+		{
+			name: "recovery code with spaces and upper case",
+			code: func(m *testMFA) string { return " " + strings.ToUpper(m.recoveryCodes[0]) + " " },
+		},
+		{
+			name: "used recovery code",
+			setup: func(t *testing.T, m *testMFA) {
+				if _, err := m.usecase.Verify(m.challenge(t), m.recoveryCodes[0], "test-agent", "192.0.2.1"); err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+			},
+			code:    func(m *testMFA) string { return m.recoveryCodes[0] },
+			wantErr: domain.ErrInvalidMFACode,
+		},
+		{
+			name:    "unknown recovery code",
+			code:    func(m *testMFA) string { return "00000-00000" },
+			wantErr: domain.ErrInvalidMFACode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMFAUsecase(t)
+			m.enroll(t)
+			if tt.setup != nil {
+				tt.setup(t, m)
+			}
+
+			response, err := m.usecase.Verify(m.challenge(t), tt.code(m), "test-agent", "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && response.Token == "" {
+				t.Error("Verify() returned no access token")
+			}
+		})
+	}
+}
+
+func TestMFAChallengeSingleUse(t *testing.T) {
+	m := newTestMFAUsecase(t)
+	m.enroll(t)
+	challenge := m.challenge(t)
+
+	if _, err := m.usecase.Verify(challenge, m.recoveryCodes[0], "test-agent", "192.0.2.1"); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if _, err := m.usecase.Verify(challenge, m.recoveryCodes[1], "test-agent", "192.0.2.1"); !errors.Is(err, domain.ErrInvalidMFAChallenge) {
+		t.Errorf("Verify() with used challenge error = %v, want %v", err, domain.ErrInvalidMFAChallenge)
+	}
+}
+
+func TestMFACodeChecksAreThrottled(t *testing.T) {
+	tests := []struct {
+		name   string
+		enroll bool
+		check  func(t *testing.T, m *testMFA, code string) error
+	}{
+		{
+			name: "confirm enrollment",
+			check: func(t *testing.T, m *testMFA, code string) error {
+				_, err := m.usecase.ConfirmEnrollment(1, code, "192.0.2.1")
+				return err
+			},
+		},
+		{
+			name:   "verify",
+			enroll: true,
+			check: func(t *testing.T, m *testMFA, code string) error {
+				_, err := m.usecase.Verify(m.challenge(t), code, "test-agent", "192.0.2.1")
+				return err
+			},
+		},
+		{
+			name:   "disable",
+			enroll: true,
+			check: func(t *testing.T, m *testMFA, code string) error {
+				return m.usecase.Disable(1, code, "192.0.2.1")
+			},
+		},
+		{
+			name:   "regenerate recovery codes",
+			enroll: true,
+			check: func(t *testing.T, m *testMFA, code string) error {
+				_, err := m.usecase.RegenerateRecoveryCodes(1, code, "192.0.2.1")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMFAUsecase(t)
+			if tt.enroll {
+				m.enroll(t)
+			} else {
+				m.beginEnrollment(t)
+			}
+
+			// バックオフなしで許可される回数を超えて失敗させる
+			for i := 0; i <= testLoginAttemptConfig().FreeAttempts; i++ {
+				if err := tt.check(t, m, "000000"); !errors.Is(err, domain.ErrInvalidMFACode) {
+					t.Fatalf("attempt %d error = %v, want %v", i+1, err, domain.ErrInvalidMFACode)
+				}
+			}
+
+			// 失敗が続くと正しいコードでもバックオフが終わるまで拒否する
+			var lockedErr *domain.LoginLockedError
+			if err := tt.check(t, m, hotpCode(m.secret, totpStep(time.Now())+1)); !errors.As(err, &lockedErr) {
+				t.Errorf("error after failures = %v, want *domain.LoginLockedError", err)
+			}
+		})
+	}
+}
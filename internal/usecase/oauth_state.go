@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// oauthStateClaims 認可リクエストを開始したブラウザのクッキーに保存するトークンのクレーム
+// stateを署名付きで保持し、別のブラウザで開始した認可リクエストのコールバック（ログインCSRF）を拒否する
+type oauthStateClaims struct {
+	TokenUse string `json:"token_use"`
+	State    string `json:"state"`
+	jwt.RegisteredClaims
+}
+
+type OAuthStateUsecase struct {
+	stateManager domain.StateManager
+	keyManager   domain.KeyManager
+	jwtConfig    domain.JWTConfig
+	duration     time.Duration
+}
+
+func NewOAuthStateUsecase(stateManager domain.StateManager, keyManager domain.KeyManager, jwtConfig domain.JWTConfig, duration time.Duration) domain.OAuthStateUsecase {
+	return &OAuthStateUsecase{
+		stateManager: stateManager,
+		keyManager:   keyManager,
+		jwtConfig:    jwtConfig,
+		duration:     duration,
+	}
+}
+
+// Begin stateとPKCEのコードベリファイア・nonceを生成して保存し、クッキーに保存するトークンを返す
+func (u *OAuthStateUsecase) Begin(intent domain.OAuthIntent) (*domain.OAuthState, string, error) {
+	state, err := generateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	// コードベリファイアは43文字以上（RFC 7636 4.1）。32バイトをbase64urlで43文字にする
+	codeVerifier, err := generateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	nonce, err := generateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	oauthState := &domain.OAuthState{
+		OAuthIntent:  intent,
+		State:        state,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(u.duration),
+	}
+	if err := u.stateManager.SaveState(oauthState); err != nil {
+		return nil, "", err
+	}
+
+	claims := oauthStateClaims{
+		TokenUse: domain.TokenUseOAuthState,
+		State:    state,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			ExpiresAt: jwt.NewNumericDate(oauthState.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	cookie, err := signJWT(u.keyManager, claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return oauthState, cookie, nil
+}
+
+// Complete クッキーのトークンでブラウザを確認してからstateを取り出す
+// 別のブラウザからのコールバックではstateを消費しない（開始したブラウザでのログインを妨げないため）
+func (u *OAuthStateUsecase) Complete(state, cookie string) (*domain.OAuthState, error) {
+	if state == "" {
+		return nil, domain.ErrInvalidOAuthState
+	}
+
+	claims := &oauthStateClaims{}
+	if _, err := parseJWT(u.keyManager, cookie, claims, jwtParserOptions(u.jwtConfig)...); err != nil {
+		return nil, domain.ErrOAuthStateBrowserMismatch
+	}
+	if claims.TokenUse != domain.TokenUseOAuthState || subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return nil, domain.ErrOAuthStateBrowserMismatch
+	}
+
+	oauthState, err := u.stateManager.ConsumeState(state)
+	if err != nil {
+		return nil, err
+	}
+	if oauthState == nil {
+		return nil, domain.ErrInvalidOAuthState
+	}
+
+	return oauthState, nil
+}
@@ -0,0 +1,667 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// oidcClientIDSize クライアントIDのランダム部分のバイト数
+	oidcClientIDSize = 12
+	// oidcClientSecretSize クライアントシークレットのランダム部分のバイト数（256ビット）
+	oidcClientSecretSize = 32
+	// oidcCodeSize 認可コードのバイト数（256ビット）
+	oidcCodeSize = 32
+	// oidcMaxClientNameLength クライアント名の最大文字数
+	oidcMaxClientNameLength = 100
+	// PKCEのコードベリファイアの長さ（RFC 7636 4.1）
+	pkceVerifierMinLength = 43
+	pkceVerifierMaxLength = 128
+)
+
+// oidcConsentClaims 同意画面のトークンのクレーム
+// 検証済みの認可リクエストを署名付きで保持し、同意の送信時に改ざんや別ユーザーによる送信（CSRF）を防ぐ
+type oidcConsentClaims struct {
+	TokenUse      string `json:"token_use"`
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	State         string `json:"state,omitempty"`
+	Nonce         string `json:"nonce,omitempty"`
+	CodeChallenge string `json:"code_challenge"`
+	jwt.RegisteredClaims
+}
+
+// oidcIDTokenClaims IDトークンのクレーム（OpenID Connect Core 2）
+type oidcIDTokenClaims struct {
+	Nonce           string `json:"nonce,omitempty"`
+	AuthorizedParty string `json:"azp"`
+	Name            string `json:"name,omitempty"`
+	Email           string `json:"email,omitempty"`
+	EmailVerified   *bool  `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type OIDCUsecase struct {
+	oidcRepo          domain.OIDCRepository
+	userRepo          domain.UserRepository
+	revokedTokenStore domain.RevokedTokenStore
+	keyManager        domain.KeyManager
+	jwtConfig         domain.JWTConfig
+	oidcConfig        domain.OIDCConfig
+}
+
+func NewOIDCUsecase(
+	oidcRepo domain.OIDCRepository,
+	userRepo domain.UserRepository,
+	revokedTokenStore domain.RevokedTokenStore,
+	keyManager domain.KeyManager,
+	jwtConfig domain.JWTConfig,
+	oidcConfig domain.OIDCConfig,
+) domain.OIDCUsecase {
+	return &OIDCUsecase{
+		oidcRepo:          oidcRepo,
+		userRepo:          userRepo,
+		revokedTokenStore: revokedTokenStore,
+		keyManager:        keyManager,
+		jwtConfig:         jwtConfig,
+		oidcConfig:        oidcConfig,
+	}
+}
+
+// RegisterClient クライアントを登録し、コンフィデンシャルクライアントの場合はシークレットを一度だけ返す
+func (u *OIDCUsecase) RegisterClient(req *domain.RegisterOIDCClientRequest) (*domain.OIDCClientCredentials, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > oidcMaxClientNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", domain.ErrInvalidOIDCClientRequest, oidcMaxClientNameLength)
+	}
+	if len(req.RedirectURIs) == 0 {
+		return nil, fmt.Errorf("%w: at least one redirect_uri is required", domain.ErrInvalidOIDCClientRequest)
+	}
+	for _, redirectURI := range req.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidOIDCClientRequest, err)
+		}
+	}
+
+	clientID, err := generateSecureToken(oidcClientIDSize)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &domain.OIDCClient{
+		ClientID:     domain.OIDCClientIDPrefix + clientID,
+		Name:         name,
+		RedirectURIs: req.RedirectURIs,
+		Public:       req.Public,
+	}
+
+	// パブリッククライアントはシークレットを発行せず、PKCEのみで認可コードを保護する
+	var secret string
+	if !client.Public {
+		random, err := generateSecureToken(oidcClientSecretSize)
+		if err != nil {
+			return nil, err
+		}
+		secret = domain.OIDCClientSecretPrefix + random
+		client.ClientSecretHash = hashToken(secret)
+	}
+
+	if err := u.oidcRepo.CreateClient(client); err != nil {
+		return nil, err
+	}
+
+	return &domain.OIDCClientCredentials{
+		ClientSecret: secret,
+		OIDCClient:   *client,
+	}, nil
+}
+
+// ListClients クライアント一覧を取得
+func (u *OIDCUsecase) ListClients() ([]*domain.OIDCClient, error) {
+	return u.oidcRepo.ListClients()
+}
+
+// DeleteClient クライアントを削除（発行済みのアクセストークンも使えなくなる）
+func (u *OIDCUsecase) DeleteClient(id int) error {
+	deleted, err := u.oidcRepo.DeleteClient(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.ErrOIDCClientNotFound
+	}
+	return nil
+}
+
+// ValidateAuthorizationRequest 認可リクエストを検証
+func (u *OIDCUsecase) ValidateAuthorizationRequest(req *domain.AuthorizationRequest) error {
+	_, _, err := u.validateAuthorizationRequest(req)
+	return err
+}
+
+// Authorize ログイン済みユーザーの認可リクエストを処理
+// userIDが0（未ログイン）の場合はlogin_requiredを返す（prompt=none の応答に使う）
+func (u *OIDCUsecase) Authorize(userID int, req *domain.AuthorizationRequest) (*domain.AuthorizationResult, error) {
+	client, scopes, err := u.validateAuthorizationRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID == 0 {
+		return nil, u.authorizationError(req, "login_required", "End-user authentication is required")
+	}
+
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, u.authorizationError(req, "login_required", "End-user authentication is required")
+	}
+
+	// 要求されたスコープをすべて許可済みであれば同意画面を省略する
+	consent, err := u.oidcRepo.GetConsent(userID, client.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	consented := consent != nil && !slices.ContainsFunc(scopes, func(scope string) bool {
+		return !slices.Contains(consent.Scopes, scope)
+	})
+
+	if consented && !req.HasPrompt("consent") {
+		redirectURL, err := u.issueCode(userID, req, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.AuthorizationResult{RedirectURL: redirectURL}, nil
+	}
+
+	if req.HasPrompt("none") {
+		return nil, u.authorizationError(req, "consent_required", "End-user consent is required")
+	}
+
+	challenge, err := u.issueConsentChallenge(userID, req, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AuthorizationResult{
+		Consent: &domain.ConsentPrompt{
+			ClientName: client.Name,
+			Scopes:     scopes,
+			UserEmail:  user.Email,
+			Challenge:  challenge,
+		},
+	}, nil
+}
+
+// Consent 同意画面の結果を処理
+func (u *OIDCUsecase) Consent(userID int, challenge string, approved bool) (string, error) {
+	claims := &oidcConsentClaims{}
+	if _, err := parseJWT(u.keyManager, challenge, claims, jwtParserOptions(u.jwtConfig)...); err != nil {
+		return "", domain.ErrInvalidConsentChallenge
+	}
+	if claims.TokenUse != domain.TokenUseOIDCConsent || claims.Subject != strconv.Itoa(userID) {
+		return "", domain.ErrInvalidConsentChallenge
+	}
+
+	// 同じ同意画面から二重に送信されても認可コードは一度だけ発行する
+	revoked, err := u.revokedTokenStore.IsRevoked(claims.ID)
+	if err != nil {
+		return "", err
+	}
+	if revoked {
+		return "", domain.ErrInvalidConsentChallenge
+	}
+	if err := u.revokedTokenStore.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return "", err
+	}
+
+	// 同意画面の表示中にクライアントが削除・変更された場合に備えて再検証する
+	req := &domain.AuthorizationRequest{
+		ClientID:            claims.ClientID,
+		RedirectURI:         claims.RedirectURI,
+		ResponseType:        "code",
+		Scope:               claims.Scope,
+		State:               claims.State,
+		Nonce:               claims.Nonce,
+		CodeChallenge:       claims.CodeChallenge,
+		CodeChallengeMethod: domain.CodeChallengeMethodS256,
+	}
+	_, scopes, err := u.validateAuthorizationRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	if !approved {
+		return "", u.authorizationError(req, "access_denied", "The end-user denied the request")
+	}
+
+	if err := u.oidcRepo.SaveConsent(&domain.OIDCConsent{
+		UserID:   userID,
+		ClientID: req.ClientID,
+		Scopes:   scopes,
+	}); err != nil {
+		return "", err
+	}
+
+	return u.issueCode(userID, req, scopes)
+}
+
+// ExchangeCode クライアントを認証し、認可コードをIDトークンとアクセストークンに交換
+func (u *OIDCUsecase) ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier string) (*domain.OAuthTokenResponse, error) {
+	client, err := u.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	authCode, err := u.oidcRepo.GetAuthorizationCodeByHash(hashToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if authCode == nil || authCode.ClientID != client.ClientID {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	// 使用済みのコードが再度提示された場合は漏洩とみなし、そのコードで発行したアクセストークンを失効させる（RFC 6749 4.1.2）
+	if authCode.UsedAt != nil {
+		log.Printf("Authorization code %d for client %s was presented again; revoking issued access token", authCode.ID, client.ClientID)
+		if authCode.AccessTokenJTI != "" {
+			if err := u.revokedTokenStore.Revoke(authCode.AccessTokenJTI, authCode.UsedAt.Add(u.jwtConfig.Duration)); err != nil {
+				log.Printf("Failed to revoke access token %s: %v", authCode.AccessTokenJTI, err)
+			}
+		}
+		return nil, domain.ErrInvalidGrant
+	}
+
+	if time.Now().After(authCode.ExpiresAt) || authCode.RedirectURI != redirectURI {
+		return nil, domain.ErrInvalidGrant
+	}
+	if !verifyCodeChallenge(codeVerifier, authCode.CodeChallenge) {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	user, err := u.userRepo.GetByID(authCode.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	// 同時に交換された場合は先に使用済みにした方だけがトークンを受け取る
+	jti := uuid.New().String()
+	marked, err := u.oidcRepo.MarkAuthorizationCodeUsed(authCode.ID, jti)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	accessToken, err := u.generateAccessToken(jti, user, client, authCode.Scope)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := u.generateIDToken(user, client, authCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(u.jwtConfig.Duration.Seconds()),
+		Scope:       authCode.Scope,
+		IDToken:     idToken,
+	}, nil
+}
+
+// UserInfo アクセストークンのスコープに応じたユーザー情報を取得（OpenID Connect Core 5.3）
+func (u *OIDCUsecase) UserInfo(accessToken string) (map[string]interface{}, error) {
+	claims := &domain.Claims{}
+	if _, err := parseJWT(u.keyManager, accessToken, claims, jwtParserOptions(u.jwtConfig)...); err != nil {
+		return nil, err
+	}
+
+	// 自サービスのAPI向けのアクセストークンは受け付けない（逆も同様にValidateTokenで拒否される）
+	if claims.TokenUse != domain.TokenUseOIDCAccess {
+		return nil, errors.New("token is not an oidc access token")
+	}
+	if !slices.Contains(claims.Audience, u.jwtConfig.Issuer) {
+		return nil, domain.ErrInvalidAudience
+	}
+
+	revoked, err := u.revokedTokenStore.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, domain.ErrTokenRevoked
+	}
+
+	// 削除されたクライアントのトークンは有効期限内でも拒否する
+	client, err := u.oidcRepo.GetClientByClientID(claims.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, domain.ErrTokenRevoked
+	}
+
+	user, err := u.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrTokenRevoked
+	}
+
+	info := map[string]interface{}{"sub": claims.Subject}
+	scopes := strings.Fields(claims.Scope)
+	if slices.Contains(scopes, domain.ScopeProfile) {
+		info["name"] = user.Name
+	}
+	if slices.Contains(scopes, domain.ScopeEmail) {
+		info["email"] = user.Email
+		info["email_verified"] = user.EmailVerifiedAt != nil
+	}
+
+	return info, nil
+}
+
+// Discovery ディスカバリードキュメントを取得（各エンドポイントのURLはissを基準にする）
+func (u *OIDCUsecase) Discovery() *domain.OpenIDConfiguration {
+	issuer := strings.TrimSuffix(u.jwtConfig.Issuer, "/")
+	algorithms := u.jwtConfig.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{domain.SigningAlgRS256, domain.SigningAlgES256, domain.SigningAlgEdDSA}
+	}
+
+	return &domain.OpenIDConfiguration{
+		Issuer:                            u.jwtConfig.Issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserinfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   domain.SupportedOIDCScopes,
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{domain.GrantTypeAuthorizationCode, domain.GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{domain.CodeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "nonce", "azp",
+			"name", "email", "email_verified",
+		},
+		AuthorizationResponseIssParameterSupported: true,
+	}
+}
+
+// validateAuthorizationRequest 認可リクエストを検証し、クライアントと有効なスコープを返す
+// クライアントとリダイレクトURIが確認できるまではリダイレクトしないエラーを返す（オープンリダイレクト対策）
+func (u *OIDCUsecase) validateAuthorizationRequest(req *domain.AuthorizationRequest) (*domain.OIDCClient, []string, error) {
+	if req.ClientID == "" {
+		return nil, nil, &domain.AuthorizationError{Code: "invalid_request", Description: "client_id is required"}
+	}
+	client, err := u.oidcRepo.GetClientByClientID(req.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		return nil, nil, &domain.AuthorizationError{Code: "invalid_request", Description: "Unknown client_id"}
+	}
+	if req.RedirectURI == "" || !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, &domain.AuthorizationError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	if req.ResponseType != "code" {
+		return nil, nil, u.authorizationError(req, "unsupported_response_type", "Only response_type=code is supported")
+	}
+
+	// 未対応のスコープは無視し、openidは必須とする
+	var scopes []string
+	for _, scope := range strings.Fields(req.Scope) {
+		if slices.Contains(domain.SupportedOIDCScopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if !slices.Contains(scopes, domain.ScopeOpenID) {
+		return nil, nil, u.authorizationError(req, "invalid_scope", "The openid scope is required")
+	}
+
+	// PKCEはすべてのクライアントで必須（plainは認可コードの横取りを防げないため受け付けない）
+	if req.CodeChallenge == "" {
+		return nil, nil, u.authorizationError(req, "invalid_request", "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != domain.CodeChallengeMethodS256 {
+		return nil, nil, u.authorizationError(req, "invalid_request", "code_challenge_method must be S256")
+	}
+	if decoded, err := base64.RawURLEncoding.DecodeString(req.CodeChallenge); err != nil || len(decoded) != sha256.Size {
+		return nil, nil, u.authorizationError(req, "invalid_request", "code_challenge must be a base64url-encoded SHA-256 digest")
+	}
+
+	if req.HasPrompt("none") && len(strings.Fields(req.Prompt)) > 1 {
+		return nil, nil, u.authorizationError(req, "invalid_request", "prompt=none must not be combined with other values")
+	}
+
+	return client, scopes, nil
+}
+
+// authorizationError エラーをクライアントのリダイレクトURIに付与して返す（OpenID Connect Core 3.1.2.6）
+func (u *OIDCUsecase) authorizationError(req *domain.AuthorizationRequest, code, description string) error {
+	params := url.Values{}
+	params.Set("error", code)
+	params.Set("error_description", description)
+
+	return &domain.AuthorizationError{
+		Code:        code,
+		Description: description,
+		Location:    u.redirectURL(req, params),
+	}
+}
+
+// issueCode 認可コードを発行し、コードを付与したリダイレクト先を返す
+func (u *OIDCUsecase) issueCode(userID int, req *domain.AuthorizationRequest, scopes []string) (string, error) {
+	code, err := generateSecureToken(oidcCodeSize)
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.oidcRepo.CreateAuthorizationCode(&domain.AuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(u.oidcConfig.CodeDuration),
+	}); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+	return u.redirectURL(req, params), nil
+}
+
+// redirectURL リダイレクトURIにstateとiss（RFC 9207）を加えたパラメータを付与
+func (u *OIDCUsecase) redirectURL(req *domain.AuthorizationRequest, params url.Values) string {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", u.jwtConfig.Issuer)
+
+	// 登録済みのリダイレクトURIのクエリは保持する
+	location, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return req.RedirectURI
+	}
+	query := location.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	location.RawQuery = query.Encode()
+	return location.String()
+}
+
+// issueConsentChallenge 同意画面のトークンを発行
+func (u *OIDCUsecase) issueConsentChallenge(userID int, req *domain.AuthorizationRequest, scopes []string) (string, error) {
+	now := time.Now()
+	claims := oidcConsentClaims{
+		TokenUse:      domain.TokenUseOIDCConsent,
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         req.State,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			Subject:   strconv.Itoa(userID),
+			ExpiresAt: jwt.NewNumericDate(now.Add(u.oidcConfig.ConsentDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	return signJWT(u.keyManager, claims)
+}
+
+// authenticateClient トークンエンドポイントでのクライアント認証
+// パブリッククライアントはシークレットを送らず、コンフィデンシャルクライアントはシークレットが必須
+func (u *OIDCUsecase) authenticateClient(clientID, clientSecret string) (*domain.OIDCClient, error) {
+	client, err := u.oidcRepo.GetClientByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client != nil && client.Public {
+		if clientSecret != "" {
+			return nil, domain.ErrInvalidClient
+		}
+		return client, nil
+	}
+
+	// クライアントIDの存在有無で応答時間が変わらないよう、常にハッシュを比較する
+	storedHash := strings.Repeat("0", 64)
+	if client != nil {
+		storedHash = client.ClientSecretHash
+	}
+	match := subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(storedHash)) == 1
+	if client == nil || clientSecret == "" || !match {
+		return nil, domain.ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// generateAccessToken /oauth/userinfo 用のアクセストークンを生成（audは自サービスのiss）
+func (u *OIDCUsecase) generateAccessToken(jti string, user *domain.User, client *domain.OIDCClient, scope string) (string, error) {
+	now := time.Now()
+	claims := domain.Claims{
+		UserID:   user.ID,
+		TokenUse: domain.TokenUseOIDCAccess,
+		Scope:    scope,
+		ClientID: client.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    u.jwtConfig.Issuer,
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{u.jwtConfig.Issuer},
+			ExpiresAt: jwt.NewNumericDate(now.Add(u.jwtConfig.Duration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	return signJWT(u.keyManager, claims)
+}
+
+// generateIDToken IDトークンを生成（audはクライアントID、スコープに応じてprofile・emailのクレームを含める）
+func (u *OIDCUsecase) generateIDToken(user *domain.User, client *domain.OIDCClient, authCode *domain.AuthorizationCode) (string, error) {
+	now := time.Now()
+	claims := oidcIDTokenClaims{
+		Nonce:           authCode.Nonce,
+		AuthorizedParty: client.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{client.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(u.jwtConfig.Duration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	scopes := strings.Fields(authCode.Scope)
+	if slices.Contains(scopes, domain.ScopeProfile) {
+		claims.Name = user.Name
+	}
+	if slices.Contains(scopes, domain.ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+
+	return signJWT(u.keyManager, claims)
+}
+
+// validateRedirectURI 登録するリダイレクトURIを検証
+// httpsの絶対URI（ループバックアドレスのみhttpを許可）で、フラグメントを含まないこと
+func validateRedirectURI(raw string) error {
+	location, err := url.Parse(raw)
+	if err != nil || !location.IsAbs() || location.Host == "" {
+		return fmt.Errorf("redirect_uri must be an absolute URI: %q", raw)
+	}
+	if location.Fragment != "" || strings.Contains(raw, "#") {
+		return fmt.Errorf("redirect_uri must not contain a fragment: %q", raw)
+	}
+
+	switch location.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := location.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("redirect_uri must use https (http is allowed only for loopback addresses): %q", raw)
+}
+
+// verifyCodeChallenge PKCEのコードベリファイアがS256のコードチャレンジと一致するか（RFC 7636 4.6）
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < pkceVerifierMinLength || len(verifier) > pkceVerifierMaxLength {
+		return false
+	}
+	for _, r := range verifier {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("-._~", r)) {
+			return false
+		}
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
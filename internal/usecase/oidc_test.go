@@ -0,0 +1,380 @@
+package usecase
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+const (
+	testClientRedirectURI = "https://app.example.com/callback"
+	// RFC 7636 付録B のコードベリファイアとコードチャレンジ
+	testPKCEVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testPKCEChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+type testOIDC struct {
+	*testAuthDeps
+	usecase     *OIDCUsecase
+	oidc        *fakeOIDCRepository
+	credentials *domain.OIDCClientCredentials
+}
+
+func newTestOIDCUsecase(t *testing.T, user *domain.User) *testOIDC {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t, user)
+	o := &testOIDC{testAuthDeps: deps, oidc: newFakeOIDCRepository()}
+	oidcUsecase := NewOIDCUsecase(
+		o.oidc, deps.users, deps.revokedTokens, deps.keyManager, testJWTConfig(),
+		domain.OIDCConfig{CodeDuration: time.Minute, ConsentDuration: 10 * time.Minute},
+	)
+	o.usecase = oidcUsecase.(*OIDCUsecase)
+
+	credentials, err := o.usecase.RegisterClient(&domain.RegisterOIDCClientRequest{
+		Name:         "Internal App",
+		RedirectURIs: []string{testClientRedirectURI},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() error = %v", err)
+	}
+	o.credentials = credentials
+	return o
+}
+
+// authorizationRequest 登録済みクライアントの有効な認可リクエスト
+func (o *testOIDC) authorizationRequest() *domain.AuthorizationRequest {
+	return &domain.AuthorizationRequest{
+		ClientID:            o.credentials.ClientID,
+		RedirectURI:         testClientRedirectURI,
+		ResponseType:        "code",
+		Scope:               "openid profile email",
+		State:               "client-state",
+		Nonce:               "client-nonce",
+		CodeChallenge:       testPKCEChallenge,
+		CodeChallengeMethod: domain.CodeChallengeMethodS256,
+	}
+}
+
+// authorize 同意画面を経てユーザー1の認可コードを取得
+func (o *testOIDC) authorize(t *testing.T) string {
+	t.Helper()
+
+	result, err := o.usecase.Authorize(1, o.authorizationRequest())
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if result.Consent == nil {
+		t.Fatalf("Authorize() = %+v, want a consent prompt", result)
+	}
+	redirectURL, err := o.usecase.Consent(1, result.Consent.Challenge, true)
+	if err != nil {
+		t.Fatalf("Consent() error = %v", err)
+	}
+	return redirectQuery(t, redirectURL).Get("code")
+}
+
+// redirectQuery リダイレクト先のクエリパラメータ
+func redirectQuery(t *testing.T, redirectURL string) url.Values {
+	t.Helper()
+
+	location, err := url.Parse(redirectURL)
+	if err != nil {
+		t.Fatalf("failed to parse redirect URL %q: %v", redirectURL, err)
+	}
+	return location.Query()
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{name: "matching verifier", verifier: testPKCEVerifier, challenge: testPKCEChallenge, want: true},
+		{name: "other verifier", verifier: strings.Repeat("a", 43), challenge: testPKCEChallenge},
+		{name: "plain method", verifier: testPKCEChallenge, challenge: testPKCEChallenge},
+		{name: "too short", verifier: strings.Repeat("a", pkceVerifierMinLength-1), challenge: testPKCEChallenge},
+		{name: "too long", verifier: strings.Repeat("a", pkceVerifierMaxLength+1), challenge: testPKCEChallenge},
+		{name: "invalid character", verifier: testPKCEVerifier[:42] + "+", challenge: testPKCEChallenge},
+		{name: "empty", verifier: "", challenge: testPKCEChallenge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyCodeChallenge(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("verifyCodeChallenge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAuthorizationRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(req *domain.AuthorizationRequest)
+		// wantCode 空の場合は受け付ける
+		wantCode     string
+		wantRedirect bool
+	}{
+		{name: "valid", modify: func(req *domain.AuthorizationRequest) {}},
+		{
+			name:     "missing client_id",
+			modify:   func(req *domain.AuthorizationRequest) { req.ClientID = "" },
+			wantCode: "invalid_request",
+		},
+		{
+			name:     "unknown client",
+			modify:   func(req *domain.AuthorizationRequest) { req.ClientID = domain.OIDCClientIDPrefix + "unknown" },
+			wantCode: "invalid_request",
+		},
+		{
+			name:     "unregistered redirect_uri",
+			modify:   func(req *domain.AuthorizationRequest) { req.RedirectURI = "https://evil.example.com/callback" },
+			wantCode: "invalid_request",
+		},
+		{
+			name:         "implicit flow",
+			modify:       func(req *domain.AuthorizationRequest) { req.ResponseType = "token" },
+			wantCode:     "unsupported_response_type",
+			wantRedirect: true,
+		},
+		{
+			name:         "missing openid scope",
+			modify:       func(req *domain.AuthorizationRequest) { req.Scope = "profile email" },
+			wantCode:     "invalid_scope",
+			wantRedirect: true,
+		},
+		{
+			name:         "missing code_challenge",
+			modify:       func(req *domain.AuthorizationRequest) { req.CodeChallenge = "" },
+			wantCode:     "invalid_request",
+			wantRedirect: true,
+		},
+		{
+			name:         "plain code_challenge_method",
+			modify:       func(req *domain.AuthorizationRequest) { req.CodeChallengeMethod = "plain" },
+			wantCode:     "invalid_request",
+			wantRedirect: true,
+		},
+		{
+			name:         "code_challenge is not a SHA-256 digest",
+			modify:       func(req *domain.AuthorizationRequest) { req.CodeChallenge = testPKCEVerifier[:20] },
+			wantCode:     "invalid_request",
+			wantRedirect: true,
+		},
+		{
+			name:         "prompt none combined with login",
+			modify:       func(req *domain.AuthorizationRequest) { req.Prompt = "none login" },
+			wantCode:     "invalid_request",
+			wantRedirect: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOIDCUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+			req := o.authorizationRequest()
+			tt.modify(req)
+
+			err := o.usecase.ValidateAuthorizationRequest(req)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("ValidateAuthorizationRequest() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var authErr *domain.AuthorizationError
+			if !errors.As(err, &authErr) || authErr.Code != tt.wantCode {
+				t.Fatalf("ValidateAuthorizationRequest() error = %v, want %s", err, tt.wantCode)
+			}
+			// クライアントとリダイレクトURIを確認できない場合はリダイレクトしない
+			if !tt.wantRedirect {
+				if authErr.Location != "" {
+					t.Errorf("Location = %q, want no redirect", authErr.Location)
+				}
+				return
+			}
+			query := redirectQuery(t, authErr.Location)
+			if !strings.HasPrefix(authErr.Location, testClientRedirectURI) || query.Get("error") != tt.wantCode || query.Get("state") != "client-state" {
+				t.Errorf("Location = %q, want an error redirect to the client", authErr.Location)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	tests := []struct {
+		name              string
+		user              *domain.User
+		wantEmailVerified bool
+	}{
+		{name: "verified email", user: verifiedUser("alice@example.com", testStrongPassword), wantEmailVerified: true},
+		{name: "unverified email", user: &domain.User{Name: "alice", Email: "alice@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOIDCUsecase(t, tt.user)
+			code := o.authorize(t)
+
+			tokens, err := o.usecase.ExchangeCode(o.credentials.ClientID, o.credentials.ClientSecret, code, testClientRedirectURI, testPKCEVerifier)
+			if err != nil {
+				t.Fatalf("ExchangeCode() error = %v", err)
+			}
+
+			idClaims := &oidcIDTokenClaims{}
+			if _, err := parseJWT(o.keyManager, tokens.IDToken, idClaims, jwtParserOptions(testJWTConfig())...); err != nil {
+				t.Fatalf("failed to parse ID token: %v", err)
+			}
+			if idClaims.Nonce != "client-nonce" || idClaims.Subject != "1" || !slices.Contains(idClaims.Audience, o.credentials.ClientID) {
+				t.Errorf("ID token claims = %+v, want nonce, sub and aud of the client", idClaims)
+			}
+			if idClaims.EmailVerified == nil || *idClaims.EmailVerified != tt.wantEmailVerified {
+				t.Errorf("ID token email_verified = %v, want %v", idClaims.EmailVerified, tt.wantEmailVerified)
+			}
+
+			info, err := o.usecase.UserInfo(tokens.AccessToken)
+			if err != nil {
+				t.Fatalf("UserInfo() error = %v", err)
+			}
+			if info["email"] != "alice@example.com" || info["email_verified"] != tt.wantEmailVerified {
+				t.Errorf("UserInfo() = %v, want email with email_verified %v", info, tt.wantEmailVerified)
+			}
+
+			// 自サービスのAPIではOIDCのアクセストークンを受け付けない
+			if _, err := o.testAuthDeps.usecase.ValidateToken(tokens.AccessToken); err == nil {
+				t.Error("ValidateToken() with OIDC access token error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeCodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// exchange 認可コードを取得したあとに交換を試みる
+		exchange func(o *testOIDC, code string) error
+		wantErr  error
+	}{
+		{
+			name: "wrong code verifier",
+			exchange: func(o *testOIDC, code string) error {
+				_, err := o.usecase.ExchangeCode(o.credentials.ClientID, o.credentials.ClientSecret, code, testClientRedirectURI, strings.Repeat("a", 43))
+				return err
+			},
+			wantErr: domain.ErrInvalidGrant,
+		},
+		{
+			name: "missing code verifier",
+			exchange: func(o *testOIDC, code string) error {
+				_, err := o.usecase.ExchangeCode(o.credentials.ClientID, o.credentials.ClientSecret, code, testClientRedirectURI, "")
+				return err
+			},
+			wantErr: domain.ErrInvalidGrant,
+		},
+		{
+			name: "different redirect_uri",
+			exchange: func(o *testOIDC, code string) error {
+				_, err := o.usecase.ExchangeCode(o.credentials.ClientID, o.credentials.ClientSecret, code, "https://app.example.com/other", testPKCEVerifier)
+				return err
+			},
+			wantErr: domain.ErrInvalidGrant,
+		},
+		{
+			name: "wrong client secret",
+			exchange: func(o *testOIDC, code string) error {
+				_, err := o.usecase.ExchangeCode(o.credentials.ClientID, domain.OIDCClientSecretPrefix+"wrong", code, testClientRedirectURI, testPKCEVerifier)
+				return err
+			},
+			wantErr: domain.ErrInvalidClient,
+		},
+		{
+			name: "another client",
+			exchange: func(o *testOIDC, code string) error {
+				other, _ := o.usecase.RegisterClient(&domain.RegisterOIDCClientRequest{Name: "Other App", RedirectURIs: []string{testClientRedirectURI}})
+				_, err := o.usecase.ExchangeCode(other.ClientID, other.ClientSecret, code, testClientRedirectURI, testPKCEVerifier)
+				return err
+			},
+			wantErr: domain.ErrInvalidGrant,
+		},
+		{
+			name: "expired code",
+			exchange: func(o *testOIDC, code string) error {
+				o.oidc.expireCodes()
+				_, err := o.usecase.ExchangeCode(o.credentials.ClientID, o.credentials.ClientSecret, code, testClientRedirectURI, testPKCEVerifier)
+				return err
+			},
+			wantErr: domain.ErrInvalidGrant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOIDCUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+			code := o.authorize(t)
+
+			if err := tt.exchange(o, code); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ExchangeCode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCCodeReuseRevokesAccessToken(t *testing.T) {
+	o := newTestOIDCUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+	code := o.authorize(t)
+
+	tokens, err := o.usecase.ExchangeCode(o.credentials.ClientID, o.credentials.ClientSecret, code, testClientRedirectURI, testPKCEVerifier)
+	if err != nil {
+		t.Fatalf("ExchangeCode() error = %v", err)
+	}
+	if _, err := o.usecase.ExchangeCode(o.credentials.ClientID, o.credentials.ClientSecret, code, testClientRedirectURI, testPKCEVerifier); !errors.Is(err, domain.ErrInvalidGrant) {
+		t.Fatalf("ExchangeCode() reuse error = %v, want %v", err, domain.ErrInvalidGrant)
+	}
+	if _, err := o.usecase.UserInfo(tokens.AccessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("UserInfo() after code reuse error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+}
+
+func TestOIDCConsent(t *testing.T) {
+	o := newTestOIDCUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+	result, err := o.usecase.Authorize(1, o.authorizationRequest())
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	challenge := result.Consent.Challenge
+
+	// 他のユーザーは同意画面を送信できない
+	if _, err := o.usecase.Consent(2, challenge, true); !errors.Is(err, domain.ErrInvalidConsentChallenge) {
+		t.Errorf("Consent() by another user error = %v, want %v", err, domain.ErrInvalidConsentChallenge)
+	}
+	if _, err := o.usecase.Consent(1, challenge, true); err != nil {
+		t.Fatalf("Consent() error = %v", err)
+	}
+	if _, err := o.usecase.Consent(1, challenge, true); !errors.Is(err, domain.ErrInvalidConsentChallenge) {
+		t.Errorf("Consent() twice error = %v, want %v", err, domain.ErrInvalidConsentChallenge)
+	}
+
+	// 許可済みのスコープでは同意画面を省略する
+	result, err = o.usecase.Authorize(1, o.authorizationRequest())
+	if err != nil {
+		t.Fatalf("Authorize() after consent error = %v", err)
+	}
+	if result.Consent != nil || redirectQuery(t, result.RedirectURL).Get("code") == "" {
+		t.Errorf("Authorize() after consent = %+v, want a code redirect", result)
+	}
+
+	req := o.authorizationRequest()
+	req.Prompt = "consent"
+	if result, err = o.usecase.Authorize(1, req); err != nil || result.Consent == nil {
+		t.Errorf("Authorize() with prompt=consent = %+v, %v, want a consent prompt", result, err)
+	}
+}
@@ -0,0 +1,242 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+type PasswordUsecase struct {
+	authRepo          domain.AuthRepository
+	userRepo          domain.UserRepository
+	passwordResetRepo domain.PasswordResetRepository
+	patRepo           domain.PersonalAccessTokenRepository
+	securityEventRepo domain.SecurityEventRepository
+	authUsecase       domain.AuthUsecase
+	mailer            domain.Mailer
+	passwordHasher    domain.PasswordHasher
+	loginThrottle     domain.LoginThrottle
+	config            domain.PasswordResetConfig
+}
+
+func NewPasswordUsecase(
+	authRepo domain.AuthRepository,
+	userRepo domain.UserRepository,
+	passwordResetRepo domain.PasswordResetRepository,
+	patRepo domain.PersonalAccessTokenRepository,
+	securityEventRepo domain.SecurityEventRepository,
+	authUsecase domain.AuthUsecase,
+	mailer domain.Mailer,
+	passwordHasher domain.PasswordHasher,
+	loginThrottle domain.LoginThrottle,
+	config domain.PasswordResetConfig,
+) domain.PasswordUsecase {
+	return &PasswordUsecase{
+		authRepo:          authRepo,
+		userRepo:          userRepo,
+		passwordResetRepo: passwordResetRepo,
+		patRepo:           patRepo,
+		securityEventRepo: securityEventRepo,
+		authUsecase:       authUsecase,
+		mailer:            mailer,
+		passwordHasher:    passwordHasher,
+		loginThrottle:     loginThrottle,
+		config:            config,
+	}
+}
+
+// ForgotPassword リセットリンクをメールで送信
+// アカウントの存在有無が応答の内容と時間から分からないよう、リンクの発行と送信は応答とは別に行う
+func (u *PasswordUsecase) ForgotPassword(email, ipAddress string) error {
+	email = strings.TrimSpace(email)
+
+	// 特定のアカウントへの大量送信や、アカウントの探索を防ぐため、メールアドレスとクライアントIPごとに要求回数を制限する
+	// 要求自体に成否はないため、アカウントの有無に関わらずすべての要求を記録する
+	attemptKeys := []string{passwordResetAttemptKey(email), passwordResetIPAttemptKey(ipAddress)}
+	if err := u.loginThrottle.Check(attemptKeys...); err != nil {
+		return err
+	}
+	u.loginThrottle.RecordFailure(nil, ipAddress, attemptKeys...)
+
+	user, err := u.authRepo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	go func() {
+		if err := u.sendResetLink(user, ipAddress); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendResetLink リセットトークンを発行してリンクをメールで送信
+func (u *PasswordUsecase) sendResetLink(user *domain.User, ipAddress string) error {
+	// 以前に発行した未使用のリンクは無効にする
+	if err := u.passwordResetRepo.InvalidateByUserID(user.ID); err != nil {
+		return err
+	}
+
+	tokenString, err := generateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	// トークンはハッシュ値のみをデータベースに保存
+	token := &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(tokenString),
+		ExpiresAt: time.Now().Add(u.config.TokenDuration),
+	}
+	if err := u.passwordResetRepo.Create(token); err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(u.config.BaseURL, "/") + "/reset-password?token=" + url.QueryEscape(tokenString)
+	return u.mailer.Send(&domain.MailMessage{
+		To:      user.Email,
+		Subject: "パスワードの再設定",
+		Body: fmt.Sprintf("%s 様\n\nパスワードの再設定が要求されました（IPアドレス: %s）。以下のリンクから新しいパスワードを設定してください。\n\n%s\n\nこのリンクの有効期限は%sで、一度だけ使用できます。心当たりがない場合はこのメールを破棄してください。\n",
+			user.Name, ipAddress, link, formatDuration(u.config.TokenDuration)),
+	})
+}
+
+// ResetPassword リセットトークンを検証して新しいパスワードを設定
+func (u *PasswordUsecase) ResetPassword(tokenString, newPassword, ipAddress string) error {
+	token, err := u.passwordResetRepo.GetByTokenHash(hashToken(tokenString))
+	if err != nil {
+		return err
+	}
+	if token == nil || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return domain.ErrInvalidResetToken
+	}
+
+	user, err := u.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return domain.ErrInvalidResetToken
+	}
+
+	// ポリシー違反の場合はトークンを消費せず、再入力できるようにする
+	if err := validatePassword(newPassword, user.Email); err != nil {
+		return err
+	}
+	passwordHash, err := u.passwordHasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	used, err := u.passwordResetRepo.MarkUsed(token.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return domain.ErrInvalidResetToken
+	}
+
+	if err := u.authRepo.UpdatePassword(user.ID, passwordHash); err != nil {
+		return err
+	}
+
+	// 他の端末のセッションはすべてログアウトさせる
+	if err := u.authUsecase.RevokeAllSessions(user.ID); err != nil {
+		return err
+	}
+	// アカウントを乗っ取られていた場合に備え、攻撃者が作成したかもしれないパーソナルアクセストークンも失効させる
+	if err := u.patRepo.RevokeAllByUserID(user.ID); err != nil {
+		return err
+	}
+
+	// リンクを受け取れたことでメールアドレスの所有も確認できている
+	if _, err := u.authRepo.MarkEmailVerified(user.ID, user.Email); err != nil {
+		log.Printf("Failed to mark email verified for user %d: %v", user.ID, err)
+	}
+
+	u.recordEvent(user.ID, domain.SecurityEventPasswordReset, "password reset via emailed link", ipAddress)
+	return nil
+}
+
+// ChangePassword 現在のパスワードを確認して変更
+func (u *PasswordUsecase) ChangePassword(userID int, currentPassword, newPassword, deviceInfo, ipAddress string) (*domain.TokenPair, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	// パスワードハッシュは認証リポジトリからのみ取得できる
+	credentials, err := u.authRepo.GetUserByEmail(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if credentials == nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	// 盗まれたセッションから現在のパスワードを総当たりされないよう、ログインと同じアカウントのキーで試行回数を制限する
+	attemptKey := accountAttemptKey(user.Email)
+	if err := u.loginThrottle.Check(attemptKey); err != nil {
+		return nil, err
+	}
+
+	match, _, err := u.passwordHasher.Verify(credentials.Password, currentPassword)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		u.loginThrottle.RecordFailure(&user.ID, ipAddress, attemptKey)
+		return nil, domain.ErrInvalidCredentials
+	}
+	u.loginThrottle.Reset(attemptKey)
+
+	if newPassword == currentPassword {
+		return nil, fmt.Errorf("%w: must differ from the current password", domain.ErrWeakPassword)
+	}
+	if err := validatePassword(newPassword, user.Email); err != nil {
+		return nil, err
+	}
+	passwordHash, err := u.passwordHasher.Hash(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.authRepo.UpdatePassword(user.ID, passwordHash); err != nil {
+		return nil, err
+	}
+
+	// 現在のセッションを含めてすべて無効化し、この端末には新しいトークンペアを発行する
+	if err := u.authUsecase.RevokeAllSessions(user.ID); err != nil {
+		return nil, err
+	}
+	if err := u.passwordResetRepo.InvalidateByUserID(user.ID); err != nil {
+		log.Printf("Failed to invalidate password reset tokens for user %d: %v", user.ID, err)
+	}
+
+	u.recordEvent(user.ID, domain.SecurityEventPasswordChanged, "password changed by user", ipAddress)
+
+	return u.authUsecase.GenerateTokenPair(user, deviceInfo, ipAddress)
+}
+
+func (u *PasswordUsecase) recordEvent(userID int, eventType, description, ipAddress string) {
+	event := &domain.SecurityEvent{
+		UserID:      &userID,
+		EventType:   eventType,
+		Description: description,
+		IPAddress:   ipAddress,
+	}
+	if err := u.securityEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+}
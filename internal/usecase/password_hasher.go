@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-echo-demo/internal/domain"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+type passwordHasher struct {
+	config domain.PasswordHashConfig
+}
+
+// NewPasswordHasher パスワードハッシャーのコンストラクタ
+// 不正なコスト（argon2.IDKeyは反復回数・並列度が0の場合にpanicする）で起動しないよう、設定を検証する
+func NewPasswordHasher(config domain.PasswordHashConfig) (domain.PasswordHasher, error) {
+	switch config.Algorithm {
+	case domain.PasswordHashArgon2id, domain.PasswordHashBcrypt:
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %s", config.Algorithm)
+	}
+	if config.Argon2Time < 1 {
+		return nil, errors.New("argon2 iterations must be at least 1")
+	}
+	if config.Argon2Threads < 1 {
+		return nil, errors.New("argon2 parallelism must be at least 1")
+	}
+	if config.Argon2Memory < 8*uint32(config.Argon2Threads) {
+		return nil, fmt.Errorf("argon2 memory must be at least %d KiB (8 KiB per thread)", 8*uint32(config.Argon2Threads))
+	}
+	if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	return &passwordHasher{config: config}, nil
+}
+
+// Hash 設定されたアルゴリズムでパスワードをハッシュ化
+func (h *passwordHasher) Hash(password string) (string, error) {
+	switch h.config.Algorithm {
+	case domain.PasswordHashBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		return string(hash), nil
+	case domain.PasswordHashArgon2id:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("failed to generate salt: %w", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, h.config.Argon2Time, h.config.Argon2Memory, h.config.Argon2Threads, argon2KeyLength)
+		// PHC形式: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			h.config.Argon2Memory,
+			h.config.Argon2Time,
+			h.config.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm: %s", h.config.Algorithm)
+	}
+}
+
+// Verify ハッシュ形式を判別してパスワードを検証
+func (h *passwordHasher) Verify(encodedHash, password string) (bool, bool, error) {
+	// パスワード未設定（OAuth専用ユーザーなど）は常に不一致
+	if encodedHash == "" {
+		return false, false, nil
+	}
+
+	switch {
+	case strings.HasPrefix(encodedHash, "$argon2id$"):
+		return h.verifyArgon2id(encodedHash, password)
+	case strings.HasPrefix(encodedHash, "$2a$"), strings.HasPrefix(encodedHash, "$2b$"), strings.HasPrefix(encodedHash, "$2y$"):
+		return h.verifyBcrypt(encodedHash, password)
+	case strings.HasPrefix(encodedHash, "$"):
+		return false, false, errors.New("unknown password hash format")
+	default:
+		// レガシーの平文パスワード：一致した場合は必ず再ハッシュする
+		match := subtle.ConstantTimeCompare([]byte(encodedHash), []byte(password)) == 1
+		return match, match, nil
+	}
+}
+
+func (h *passwordHasher) verifyArgon2id(encodedHash, password string) (bool, bool, error) {
+	// ["", "argon2id", "v=19", "m=65536,t=3,p=2", salt, hash]
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return false, false, errors.New("invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2id version: %d", version)
+	}
+
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, false, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(actual, expected) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash := h.config.Algorithm != domain.PasswordHashArgon2id ||
+		memory < h.config.Argon2Memory ||
+		iterations < h.config.Argon2Time ||
+		threads < h.config.Argon2Threads
+	return true, needsRehash, nil
+}
+
+func (h *passwordHasher) verifyBcrypt(encodedHash, password string) (bool, bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to verify bcrypt hash: %w", err)
+	}
+
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	if err != nil {
+		return false, false, fmt.Errorf("invalid bcrypt hash: %w", err)
+	}
+
+	needsRehash := h.config.Algorithm != domain.PasswordHashBcrypt || cost < h.config.BcryptCost
+	return true, needsRehash, nil
+}
@@ -0,0 +1,243 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewPasswordHasherValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(config *domain.PasswordHashConfig)
+		wantErr bool
+	}{
+		{name: "argon2id", modify: func(config *domain.PasswordHashConfig) {}},
+		{name: "bcrypt", modify: func(config *domain.PasswordHashConfig) { config.Algorithm = domain.PasswordHashBcrypt }},
+		{name: "unknown algorithm", modify: func(config *domain.PasswordHashConfig) { config.Algorithm = "md5" }, wantErr: true},
+		{name: "zero iterations", modify: func(config *domain.PasswordHashConfig) { config.Argon2Time = 0 }, wantErr: true},
+		{name: "zero parallelism", modify: func(config *domain.PasswordHashConfig) { config.Argon2Threads = 0 }, wantErr: true},
+		{name: "memory below 8 KiB per thread", modify: func(config *domain.PasswordHashConfig) {
+			config.Argon2Threads = 4
+			config.Argon2Memory = 31
+		}, wantErr: true},
+		{name: "bcrypt cost too low", modify: func(config *domain.PasswordHashConfig) { config.BcryptCost = bcrypt.MinCost - 1 }, wantErr: true},
+		{name: "bcrypt cost too high", modify: func(config *domain.PasswordHashConfig) { config.BcryptCost = bcrypt.MaxCost + 1 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testPasswordHashConfig()
+			tt.modify(&config)
+
+			_, err := NewPasswordHasher(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPasswordHasher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	const password = "correct horse battery staple"
+
+	hashWith := func(t *testing.T, modify func(config *domain.PasswordHashConfig)) string {
+		t.Helper()
+
+		config := testPasswordHashConfig()
+		modify(&config)
+		hasher, err := NewPasswordHasher(config)
+		if err != nil {
+			t.Fatalf("NewPasswordHasher() error = %v", err)
+		}
+		hash, err := hasher.Hash(password)
+		if err != nil {
+			t.Fatalf("Hash() error = %v", err)
+		}
+		return hash
+	}
+
+	tests := []struct {
+		name       string
+		hashConfig func(config *domain.PasswordHashConfig)
+		hash       func(t *testing.T) string
+		password   string
+		wantMatch  bool
+		wantRehash bool
+		wantErr    bool
+	}{
+		{
+			name:      "argon2id with current parameters",
+			hash:      func(t *testing.T) string { return hashWith(t, func(config *domain.PasswordHashConfig) {}) },
+			password:  password,
+			wantMatch: true,
+		},
+		{
+			name:     "argon2id with wrong password",
+			hash:     func(t *testing.T) string { return hashWith(t, func(config *domain.PasswordHashConfig) {}) },
+			password: "wrong password",
+		},
+		{
+			name:       "argon2id with weaker memory cost",
+			hashConfig: func(config *domain.PasswordHashConfig) { config.Argon2Memory = 128 },
+			hash:       func(t *testing.T) string { return hashWith(t, func(config *domain.PasswordHashConfig) {}) },
+			password:   password,
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:       "argon2id with fewer iterations",
+			hashConfig: func(config *domain.PasswordHashConfig) { config.Argon2Time = 2 },
+			hash:       func(t *testing.T) string { return hashWith(t, func(config *domain.PasswordHashConfig) {}) },
+			password:   password,
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name: "argon2id with stronger parameters",
+			hash: func(t *testing.T) string {
+				return hashWith(t, func(config *domain.PasswordHashConfig) { config.Argon2Time = 2 })
+			},
+			password:  password,
+			wantMatch: true,
+		},
+		{
+			name: "bcrypt while argon2id is configured",
+			hash: func(t *testing.T) string {
+				return hashWith(t, func(config *domain.PasswordHashConfig) { config.Algorithm = domain.PasswordHashBcrypt })
+			},
+			password:   password,
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name: "bcrypt with lower cost",
+			hashConfig: func(config *domain.PasswordHashConfig) {
+				config.Algorithm = domain.PasswordHashBcrypt
+				config.BcryptCost = 5
+			},
+			hash: func(t *testing.T) string {
+				return hashWith(t, func(config *domain.PasswordHashConfig) { config.Algorithm = domain.PasswordHashBcrypt })
+			},
+			password:   password,
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:       "bcrypt with current cost",
+			hashConfig: func(config *domain.PasswordHashConfig) { config.Algorithm = domain.PasswordHashBcrypt },
+			hash: func(t *testing.T) string {
+				return hashWith(t, func(config *domain.PasswordHashConfig) { config.Algorithm = domain.PasswordHashBcrypt })
+			},
+			password:  password,
+			wantMatch: true,
+		},
+		{
+			name: "bcrypt with wrong password",
+			hash: func(t *testing.T) string {
+				return hashWith(t, func(config *domain.PasswordHashConfig) { config.Algorithm = domain.PasswordHashBcrypt })
+			},
+			password: "wrong password",
+		},
+		{
+			name:       "legacy plaintext match",
+			hash:       func(t *testing.T) string { return password },
+			password:   password,
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:     "legacy plaintext mismatch",
+			hash:     func(t *testing.T) string { return password },
+			password: "wrong password",
+		},
+		{
+			name:     "empty hash never matches",
+			hash:     func(t *testing.T) string { return "" },
+			password: "",
+		},
+		{
+			name:     "unknown hash format",
+			hash:     func(t *testing.T) string { return "$1$salt$hash" },
+			password: password,
+			wantErr:  true,
+		},
+		{
+			name:     "malformed argon2id hash",
+			hash:     func(t *testing.T) string { return "$argon2id$v=19$m=64,t=1,p=1$salt" },
+			password: password,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testPasswordHashConfig()
+			if tt.hashConfig != nil {
+				tt.hashConfig(&config)
+			}
+			hasher, err := NewPasswordHasher(config)
+			if err != nil {
+				t.Fatalf("NewPasswordHasher() error = %v", err)
+			}
+
+			match, needsRehash, err := hasher.Verify(tt.hash(t), tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if match != tt.wantMatch {
+				t.Errorf("Verify() match = %v, want %v", match, tt.wantMatch)
+			}
+			if needsRehash != tt.wantRehash {
+				t.Errorf("Verify() needsRehash = %v, want %v", needsRehash, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	const password = "correct horse battery staple"
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		storedHash string
+		password   string
+		wantErr    error
+		wantRehash bool
+	}{
+		{name: "legacy plaintext", storedHash: password, password: password, wantRehash: true},
+		{name: "bcrypt", storedHash: string(bcryptHash), password: password, wantRehash: true},
+		{name: "wrong password", storedHash: password, password: "wrong password", wantErr: domain.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", tt.storedHash))
+
+			response, err := deps.usecase.Login("alice@example.com", tt.password, "test", "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && response.Token == "" {
+				t.Error("Login() returned no access token")
+			}
+
+			stored := deps.users.user(1).Password
+			if rehashed := stored != tt.storedHash; rehashed != tt.wantRehash {
+				t.Fatalf("stored hash rehashed = %v, want %v", rehashed, tt.wantRehash)
+			}
+			if tt.wantRehash && !strings.HasPrefix(stored, "$argon2id$") {
+				t.Errorf("stored hash = %q, want argon2id", stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go-echo-demo/internal/domain"
+)
+
+// パスワードポリシー（NIST SP 800-63Bに倣い、文字種の組み合わせではなく長さと既知の弱いパスワードで判定）
+const (
+	passwordMinLength = 10
+	passwordMaxLength = 128 // ハッシュ計算のコストを抑えるための上限
+)
+
+// commonPasswords 漏えいリストで頻出する弱いパスワード
+var commonPasswords = map[string]struct{}{
+	"password":     {},
+	"password1":    {},
+	"password12":   {},
+	"password123":  {},
+	"password1234": {},
+	"passw0rd":     {},
+	"1234567890":   {},
+	"12345678910":  {},
+	"123456789012": {},
+	"0123456789":   {},
+	"1111111111":   {},
+	"qwertyuiop":   {},
+	"qwerty12345":  {},
+	"qwerty123456": {},
+	"1q2w3e4r5t":   {},
+	"iloveyou123":  {},
+	"abc1234567":   {},
+	"abcdefghij":   {},
+	"letmein123":   {},
+	"welcome123":   {},
+	"admin12345":   {},
+	"changeme123":  {},
+}
+
+// validatePassword パスワードがポリシーを満たしているか検証
+func validatePassword(password, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < passwordMinLength {
+		return fmt.Errorf("%w: must be at least %d characters", domain.ErrWeakPassword, passwordMinLength)
+	}
+	if length > passwordMaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", domain.ErrWeakPassword, passwordMaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if _, exists := commonPasswords[lower]; exists {
+		return fmt.Errorf("%w: this password is too common", domain.ErrWeakPassword)
+	}
+
+	// メールアドレス（またはそのローカル部）をそのまま使ったパスワードは推測されやすい
+	if email != "" {
+		localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+		if lower == strings.ToLower(email) || (len(localPart) >= 4 && strings.Contains(lower, localPart)) {
+			return fmt.Errorf("%w: must not contain your email address", domain.ErrWeakPassword)
+		}
+	}
+
+	// 同じ文字の繰り返しのみは不可
+	if strings.Count(password, string([]rune(password)[0])) == length {
+		return fmt.Errorf("%w: must not be a single repeated character", domain.ErrWeakPassword)
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+type testPassword struct {
+	*testAuthDeps
+	usecase        *PasswordUsecase
+	passwordResets *fakePasswordResetRepository
+	pats           *fakePersonalAccessTokenRepository
+	mailer         *fakeMailer
+}
+
+func newTestPasswordUsecase(t *testing.T) *testPassword {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t, &domain.User{Name: "Alice", Email: "alice@example.com", Password: testStrongPassword})
+	p := &testPassword{
+		testAuthDeps:   deps,
+		passwordResets: &fakePasswordResetRepository{},
+		pats:           &fakePersonalAccessTokenRepository{},
+		mailer:         &fakeMailer{},
+	}
+	passwordUsecase := NewPasswordUsecase(
+		deps.users, deps.users, p.passwordResets, p.pats, deps.securityEvents, deps.usecase, p.mailer,
+		newTestPasswordHasher(t), deps.loginThrottle,
+		domain.PasswordResetConfig{BaseURL: "http://localhost:8080", TokenDuration: time.Hour},
+	)
+	p.usecase = passwordUsecase.(*PasswordUsecase)
+	return p
+}
+
+// issueResetToken リセットリンクを送信し、メールからトークンを取り出す
+func (p *testPassword) issueResetToken(t *testing.T) string {
+	t.Helper()
+
+	user, _ := p.users.GetByID(1)
+	if err := p.usecase.sendResetLink(user, "192.0.2.1"); err != nil {
+		t.Fatalf("sendResetLink() error = %v", err)
+	}
+	messages := p.mailer.sent()
+	return mailLinkToken(t, messages[len(messages)-1], "token")
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantSent bool
+	}{
+		{name: "registered email", email: "alice@example.com", wantSent: true},
+		{name: "registered email with surrounding spaces", email: " alice@example.com ", wantSent: true},
+		{name: "unknown email", email: "bob@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPasswordUsecase(t)
+
+			if err := p.usecase.ForgotPassword(tt.email, "192.0.2.1"); err != nil {
+				t.Fatalf("ForgotPassword() error = %v", err)
+			}
+
+			if !tt.wantSent {
+				time.Sleep(20 * time.Millisecond)
+				if messages := p.mailer.sent(); len(messages) != 0 {
+					t.Errorf("sent %d messages, want none", len(messages))
+				}
+				return
+			}
+
+			token := mailLinkToken(t, p.mailer.waitForMessages(t, 1)[0], "token")
+			if stored, _ := p.passwordResets.GetByTokenHash(token); stored != nil {
+				t.Error("reset token is stored in plaintext")
+			}
+			if stored, _ := p.passwordResets.GetByTokenHash(hashToken(token)); stored == nil {
+				t.Error("reset token digest is not stored")
+			}
+		})
+	}
+}
+
+func TestForgotPasswordIsThrottled(t *testing.T) {
+	p := newTestPasswordUsecase(t)
+
+	for i := 0; i <= testLoginAttemptConfig().FreeAttempts; i++ {
+		if err := p.usecase.ForgotPassword("bob@example.com", "192.0.2.1"); err != nil {
+			t.Fatalf("attempt %d error = %v", i+1, err)
+		}
+	}
+
+	var lockedErr *domain.LoginLockedError
+	if err := p.usecase.ForgotPassword("bob@example.com", "192.0.2.1"); !errors.As(err, &lockedErr) {
+		t.Fatalf("ForgotPassword() error = %v, want *domain.LoginLockedError", err)
+	}
+	// リセットの要求回数はログインの制限とは別に数える
+	if err := p.usecase.ForgotPassword("alice@example.com", "192.0.2.1"); err != nil {
+		t.Errorf("ForgotPassword() for another email error = %v", err)
+	}
+	if _, err := p.testAuthDeps.usecase.Login("alice@example.com", testStrongPassword, "test", "192.0.2.1"); errors.As(err, &lockedErr) {
+		t.Errorf("Login() from the same IP error = %v, want not locked", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	const newPassword = "a brand new passphrase"
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T, p *testPassword) string
+		wantErr error
+	}{
+		{
+			name:  "valid token",
+			token: func(t *testing.T, p *testPassword) string { return p.issueResetToken(t) },
+		},
+		{
+			name:    "unknown token",
+			token:   func(t *testing.T, p *testPassword) string { return "unknown-token" },
+			wantErr: domain.ErrInvalidResetToken,
+		},
+		{
+			name: "expired token",
+			token: func(t *testing.T, p *testPassword) string {
+				token := p.issueResetToken(t)
+				p.passwordResets.expire(hashToken(token))
+				return token
+			},
+			wantErr: domain.ErrInvalidResetToken,
+		},
+		{
+			name: "used token",
+			token: func(t *testing.T, p *testPassword) string {
+				token := p.issueResetToken(t)
+				if err := p.usecase.ResetPassword(token, "an earlier new passphrase", "192.0.2.1"); err != nil {
+					t.Fatalf("ResetPassword() error = %v", err)
+				}
+				return token
+			},
+			wantErr: domain.ErrInvalidResetToken,
+		},
+		{
+			name: "superseded by a newer link",
+			token: func(t *testing.T, p *testPassword) string {
+				token := p.issueResetToken(t)
+				p.issueResetToken(t)
+				return token
+			},
+			wantErr: domain.ErrInvalidResetToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPasswordUsecase(t)
+			session := loginTestUser(t, p.testAuthDeps)
+			p.pats.Create(&domain.PersonalAccessToken{UserID: 1, Name: "ci", TokenHash: hashToken("pat")})
+			token := tt.token(t, p)
+			passwordBefore := p.users.user(1).Password
+
+			err := p.usecase.ResetPassword(token, newPassword, "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResetPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if p.users.user(1).Password != passwordBefore {
+					t.Error("password changed by a rejected reset")
+				}
+				return
+			}
+
+			user := p.users.user(1)
+			if match, _, _ := newTestPasswordHasher(t).Verify(user.Password, newPassword); !match {
+				t.Error("password was not updated")
+			}
+			if user.EmailVerifiedAt == nil {
+				t.Error("email was not marked verified")
+			}
+			if _, err := p.testAuthDeps.usecase.ValidateToken(session.AccessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+				t.Errorf("existing session ValidateToken() error = %v, want %v", err, domain.ErrTokenRevoked)
+			}
+			if pats, _ := p.pats.ListByUserID(1); len(pats) != 0 {
+				t.Errorf("%d personal access tokens remain, want none", len(pats))
+			}
+			if events := p.securityEvents.eventsOfType(domain.SecurityEventPasswordReset); len(events) != 1 {
+				t.Errorf("recorded %d password reset events, want 1", len(events))
+			}
+		})
+	}
+}
+
+func TestResetPasswordWeakPasswordKeepsToken(t *testing.T) {
+	p := newTestPasswordUsecase(t)
+	token := p.issueResetToken(t)
+
+	if err := p.usecase.ResetPassword(token, "short", "192.0.2.1"); !errors.Is(err, domain.ErrWeakPassword) {
+		t.Fatalf("ResetPassword() error = %v, want %v", err, domain.ErrWeakPassword)
+	}
+	// ポリシー違反ではトークンを消費しない
+	if err := p.usecase.ResetPassword(token, "a brand new passphrase", "192.0.2.1"); err != nil {
+		t.Errorf("ResetPassword() retry error = %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	const newPassword = "a brand new passphrase"
+
+	tests := []struct {
+		name            string
+		currentPassword string
+		newPassword     string
+		wantErr         error
+	}{
+		{name: "correct current password", currentPassword: testStrongPassword, newPassword: newPassword},
+		{name: "wrong current password", currentPassword: "wrong password", newPassword: newPassword, wantErr: domain.ErrInvalidCredentials},
+		{name: "same as current password", currentPassword: testStrongPassword, newPassword: testStrongPassword, wantErr: domain.ErrWeakPassword},
+		{name: "weak new password", currentPassword: testStrongPassword, newPassword: "password123", wantErr: domain.ErrWeakPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPasswordUsecase(t)
+			session := loginTestUser(t, p.testAuthDeps)
+			resetToken := p.issueResetToken(t)
+
+			tokenPair, err := p.usecase.ChangePassword(1, tt.currentPassword, tt.newPassword, "test-agent", "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if _, err := p.testAuthDeps.usecase.ValidateToken(tokenPair.AccessToken); err != nil {
+				t.Errorf("new session ValidateToken() error = %v", err)
+			}
+			if _, err := p.testAuthDeps.usecase.ValidateToken(session.AccessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+				t.Errorf("existing session ValidateToken() error = %v, want %v", err, domain.ErrTokenRevoked)
+			}
+			if err := p.usecase.ResetPassword(resetToken, "another new passphrase", "192.0.2.1"); !errors.Is(err, domain.ErrInvalidResetToken) {
+				t.Errorf("ResetPassword() with earlier link error = %v, want %v", err, domain.ErrInvalidResetToken)
+			}
+		})
+	}
+}
+
+func TestChangePasswordIsThrottled(t *testing.T) {
+	p := newTestPasswordUsecase(t)
+
+	for i := 0; i <= testLoginAttemptConfig().FreeAttempts; i++ {
+		if _, err := p.usecase.ChangePassword(1, "wrong password", "a brand new passphrase", "test", "192.0.2.1"); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d error = %v, want %v", i+1, err, domain.ErrInvalidCredentials)
+		}
+	}
+
+	// ログインと同じアカウントのキーで制限する
+	var lockedErr *domain.LoginLockedError
+	if _, err := p.usecase.ChangePassword(1, testStrongPassword, "a brand new passphrase", "test", "192.0.2.1"); !errors.As(err, &lockedErr) {
+		t.Errorf("ChangePassword() error = %v, want *domain.LoginLockedError", err)
+	}
+	if _, err := p.testAuthDeps.usecase.Login("alice@example.com", testStrongPassword, "test", "192.0.2.1"); !errors.As(err, &lockedErr) {
+		t.Errorf("Login() error = %v, want *domain.LoginLockedError", err)
+	}
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+const (
+	// personalAccessTokenSize トークン本体のランダム部分のバイト数（256ビット）
+	personalAccessTokenSize = 32
+	// personalAccessTokenDisplayLength 一覧表示用に保存する先頭部分の長さ（接頭辞を含む）
+	personalAccessTokenDisplayLength = 12
+	// personalAccessTokenMaxNameLength トークン名の最大文字数
+	personalAccessTokenMaxNameLength = 100
+)
+
+type PersonalAccessTokenUsecase struct {
+	tokenRepo domain.PersonalAccessTokenRepository
+	userRepo  domain.UserRepository
+	rbacRepo  domain.RBACRepository
+}
+
+func NewPersonalAccessTokenUsecase(
+	tokenRepo domain.PersonalAccessTokenRepository,
+	userRepo domain.UserRepository,
+	rbacRepo domain.RBACRepository,
+) domain.PersonalAccessTokenUsecase {
+	return &PersonalAccessTokenUsecase{
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+		rbacRepo:  rbacRepo,
+	}
+}
+
+// Create トークンを作成し、平文のトークンを一度だけ返す
+func (u *PersonalAccessTokenUsecase) Create(userID int, req *domain.CreatePersonalAccessTokenRequest) (*domain.CreatedPersonalAccessToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > personalAccessTokenMaxNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", domain.ErrInvalidPersonalAccessTokenRequest, personalAccessTokenMaxNameLength)
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, fmt.Errorf("%w: expires_in_days must not be negative", domain.ErrInvalidPersonalAccessTokenRequest)
+	}
+
+	scopes, err := u.validateScopes(userID, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	random, err := generateSecureToken(personalAccessTokenSize)
+	if err != nil {
+		return nil, err
+	}
+	tokenString := domain.PersonalAccessTokenPrefix + random
+
+	token := &domain.PersonalAccessToken{
+		UserID:      userID,
+		Name:        name,
+		TokenPrefix: tokenString[:personalAccessTokenDisplayLength],
+		TokenHash:   hashToken(tokenString),
+		Scopes:      scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := u.tokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+
+	return &domain.CreatedPersonalAccessToken{
+		Token:               tokenString,
+		PersonalAccessToken: *token,
+	}, nil
+}
+
+// validateScopes スコープの形式と、ユーザーが現在その権限を持つかを確認
+func (u *PersonalAccessTokenUsecase) validateScopes(userID int, scopes []string) ([]string, error) {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		resource, action, ok := strings.Cut(scope, ":")
+		if !ok || resource == "" || action == "" {
+			return nil, fmt.Errorf("%w: %q must be in resource:action format", domain.ErrInvalidScope, scope)
+		}
+		if seen[scope] {
+			continue
+		}
+
+		// 持っていない権限をスコープに指定しても使えないため、作成時点で拒否する
+		allowed, err := u.rbacRepo.HasPermission(userID, resource, action)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: you do not have the %s permission", domain.ErrInvalidScope, scope)
+		}
+
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	return result, nil
+}
+
+// List ユーザーのトークン一覧を取得
+func (u *PersonalAccessTokenUsecase) List(userID int) ([]*domain.PersonalAccessToken, error) {
+	return u.tokenRepo.ListByUserID(userID)
+}
+
+// Revoke トークンを失効させる
+func (u *PersonalAccessTokenUsecase) Revoke(userID, tokenID int) error {
+	revoked, err := u.tokenRepo.Revoke(userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return domain.ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate 平文のトークンを検証し、トークンと所有ユーザーを返す
+func (u *PersonalAccessTokenUsecase) Authenticate(tokenString string) (*domain.PersonalAccessToken, *domain.User, error) {
+	if !strings.HasPrefix(tokenString, domain.PersonalAccessTokenPrefix) {
+		return nil, nil, domain.ErrInvalidPersonalAccessToken
+	}
+
+	token, err := u.tokenRepo.GetByTokenHash(hashToken(tokenString))
+	if err != nil {
+		return nil, nil, err
+	}
+	if token == nil || token.RevokedAt != nil {
+		return nil, nil, domain.ErrInvalidPersonalAccessToken
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, nil, domain.ErrInvalidPersonalAccessToken
+	}
+
+	user, err := u.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, domain.ErrInvalidPersonalAccessToken
+	}
+
+	// 使用状況の記録に失敗しても認証自体は成功とする
+	if err := u.tokenRepo.UpdateLastUsed(token.ID, time.Now()); err != nil {
+		log.Printf("Failed to update last used time of personal access token %d: %v", token.ID, err)
+	}
+
+	return token, user, nil
+}
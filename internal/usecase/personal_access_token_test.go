@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+type testPersonalAccessToken struct {
+	*testAuthDeps
+	usecase *PersonalAccessTokenUsecase
+	tokens  *fakePersonalAccessTokenRepository
+}
+
+func newTestPersonalAccessTokenUsecase(t *testing.T) *testPersonalAccessToken {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+	assignTestRoles(deps)
+	tokens := &fakePersonalAccessTokenRepository{}
+	patUsecase := NewPersonalAccessTokenUsecase(tokens, deps.users, deps.rbac)
+	return &testPersonalAccessToken{testAuthDeps: deps, usecase: patUsecase.(*PersonalAccessTokenUsecase), tokens: tokens}
+}
+
+func TestPersonalAccessTokenAllowsScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		resource string
+		action   string
+		want     bool
+	}{
+		{name: "no scopes allows everything", resource: "products", action: "write", want: true},
+		{name: "matching scope", scopes: []string{"products:read"}, resource: "products", action: "read", want: true},
+		{name: "other action", scopes: []string{"products:read"}, resource: "products", action: "write"},
+		{name: "other resource", scopes: []string{"products:read"}, resource: "users", action: "read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &domain.PersonalAccessToken{Scopes: tt.scopes}
+			if got := token.AllowsScope(tt.resource, tt.action); got != tt.want {
+				t.Errorf("AllowsScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         domain.CreatePersonalAccessTokenRequest
+		wantErr     error
+		wantScopes  []string
+		wantExpires bool
+	}{
+		{
+			name:       "scoped token",
+			req:        domain.CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{"products:read"}},
+			wantScopes: []string{"products:read"},
+		},
+		{
+			name:       "duplicate scopes",
+			req:        domain.CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{"products:read", " products:read "}},
+			wantScopes: []string{"products:read"},
+		},
+		{
+			name:        "unscoped token with expiry",
+			req:         domain.CreatePersonalAccessTokenRequest{Name: "ci", ExpiresInDays: 30},
+			wantScopes:  []string{},
+			wantExpires: true,
+		},
+		{
+			name:    "permission the user does not have",
+			req:     domain.CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{"users:delete"}},
+			wantErr: domain.ErrInvalidScope,
+		},
+		{
+			name:    "malformed scope",
+			req:     domain.CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{"products"}},
+			wantErr: domain.ErrInvalidScope,
+		},
+		{
+			name:    "empty name",
+			req:     domain.CreatePersonalAccessTokenRequest{Name: "  "},
+			wantErr: domain.ErrInvalidPersonalAccessTokenRequest,
+		},
+		{
+			name:    "name too long",
+			req:     domain.CreatePersonalAccessTokenRequest{Name: strings.Repeat("a", personalAccessTokenMaxNameLength+1)},
+			wantErr: domain.ErrInvalidPersonalAccessTokenRequest,
+		},
+		{
+			name:    "negative expiry",
+			req:     domain.CreatePersonalAccessTokenRequest{Name: "ci", ExpiresInDays: -1},
+			wantErr: domain.ErrInvalidPersonalAccessTokenRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersonalAccessTokenUsecase(t)
+
+			created, err := p.usecase.Create(1, &tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(p.tokens.tokens) != 0 {
+					t.Errorf("stored %d tokens, want none", len(p.tokens.tokens))
+				}
+				return
+			}
+
+			if !strings.HasPrefix(created.Token, domain.PersonalAccessTokenPrefix) || !strings.HasPrefix(created.Token, created.TokenPrefix) {
+				t.Errorf("Token = %q, TokenPrefix = %q, want the %q prefix", created.Token, created.TokenPrefix, domain.PersonalAccessTokenPrefix)
+			}
+			if !reflect.DeepEqual(created.Scopes, tt.wantScopes) {
+				t.Errorf("Scopes = %v, want %v", created.Scopes, tt.wantScopes)
+			}
+			if (created.ExpiresAt != nil) != tt.wantExpires {
+				t.Errorf("ExpiresAt = %v, want set %v", created.ExpiresAt, tt.wantExpires)
+			}
+			if stored, _ := p.tokens.GetByTokenHash(created.Token); stored != nil {
+				t.Error("token is stored in plaintext")
+			}
+		})
+	}
+}
+
+func TestAuthenticatePersonalAccessToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// token 作成したトークンから認証に使う文字列を用意する
+		token   func(p *testPersonalAccessToken, created *domain.CreatedPersonalAccessToken) string
+		wantErr error
+	}{
+		{
+			name:  "valid token",
+			token: func(p *testPersonalAccessToken, created *domain.CreatedPersonalAccessToken) string { return created.Token },
+		},
+		{
+			name: "revoked token",
+			token: func(p *testPersonalAccessToken, created *domain.CreatedPersonalAccessToken) string {
+				p.usecase.Revoke(1, created.ID)
+				return created.Token
+			},
+			wantErr: domain.ErrInvalidPersonalAccessToken,
+		},
+		{
+			name: "expired token",
+			token: func(p *testPersonalAccessToken, created *domain.CreatedPersonalAccessToken) string {
+				p.tokens.expire(hashToken(created.Token))
+				return created.Token
+			},
+			wantErr: domain.ErrInvalidPersonalAccessToken,
+		},
+		{
+			name: "owner deleted",
+			token: func(p *testPersonalAccessToken, created *domain.CreatedPersonalAccessToken) string {
+				p.users.Delete(1)
+				return created.Token
+			},
+			wantErr: domain.ErrInvalidPersonalAccessToken,
+		},
+		{
+			name: "unknown token",
+			token: func(p *testPersonalAccessToken, created *domain.CreatedPersonalAccessToken) string {
+				return domain.PersonalAccessTokenPrefix + "unknown"
+			},
+			wantErr: domain.ErrInvalidPersonalAccessToken,
+		},
+		{
+			name: "missing prefix",
+			token: func(p *testPersonalAccessToken, created *domain.CreatedPersonalAccessToken) string {
+				return strings.TrimPrefix(created.Token, domain.PersonalAccessTokenPrefix)
+			},
+			wantErr: domain.ErrInvalidPersonalAccessToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPersonalAccessTokenUsecase(t)
+			created, err := p.usecase.Create(1, &domain.CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{"products:read"}})
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			token, user, err := p.usecase.Authenticate(tt.token(p, created))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if user.ID != 1 || token.ID != created.ID {
+				t.Errorf("Authenticate() = token %d, user %d, want token %d, user 1", token.ID, user.ID, created.ID)
+			}
+			// 実際の権限はスコープとユーザーのRBAC権限の積
+			if !token.AllowsScope("products", "read") || token.AllowsScope("products", "write") {
+				t.Errorf("Scopes = %v, want only products:read", token.Scopes)
+			}
+			if stored, _ := p.tokens.GetByTokenHash(hashToken(created.Token)); stored.LastUsedAt == nil {
+				t.Error("LastUsedAt was not updated")
+			}
+		})
+	}
+}
+
+func TestRevokePersonalAccessToken(t *testing.T) {
+	p := newTestPersonalAccessTokenUsecase(t)
+	created, err := p.usecase.Create(1, &domain.CreatePersonalAccessTokenRequest{Name: "ci"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// 他のユーザーのトークンは失効できない
+	if err := p.usecase.Revoke(2, created.ID); !errors.Is(err, domain.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("Revoke() by another user error = %v, want %v", err, domain.ErrPersonalAccessTokenNotFound)
+	}
+	if err := p.usecase.Revoke(1, created.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if tokens, _ := p.usecase.List(1); len(tokens) != 0 {
+		t.Errorf("List() = %d tokens, want none", len(tokens))
+	}
+	if err := p.usecase.Revoke(1, created.ID); !errors.Is(err, domain.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("Revoke() twice error = %v, want %v", err, domain.ErrPersonalAccessTokenNotFound)
+	}
+}
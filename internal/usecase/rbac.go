@@ -185,3 +185,46 @@ func (u *RBACUsecaseImpl) CheckPermission(userID int, resource, action string) e
 	}
 	return nil
 }
+
+// サービスアカウントロール管理
+func (u *RBACUsecaseImpl) GetServiceAccountRoles(accountID int) ([]domain.Role, error) {
+	return u.rbacRepo.GetServiceAccountRoles(accountID)
+}
+
+func (u *RBACUsecaseImpl) AssignRoleToServiceAccount(accountID int, roleName string) error {
+	role, err := u.rbacRepo.GetRoleByName(roleName)
+	if err != nil {
+		return fmt.Errorf("failed to get role by name: %w", err)
+	}
+	if role == nil {
+		return fmt.Errorf("%w: %s", domain.ErrRoleNotFound, roleName)
+	}
+	return u.rbacRepo.AssignRoleToServiceAccount(accountID, role.ID)
+}
+
+func (u *RBACUsecaseImpl) RemoveRoleFromServiceAccount(accountID int, roleName string) error {
+	role, err := u.rbacRepo.GetRoleByName(roleName)
+	if err != nil {
+		return fmt.Errorf("failed to get role by name: %w", err)
+	}
+	if role == nil {
+		return fmt.Errorf("%w: %s", domain.ErrRoleNotFound, roleName)
+	}
+	return u.rbacRepo.RemoveRoleFromServiceAccount(accountID, role.ID)
+}
+
+// サービスアカウントの権限チェック
+func (u *RBACUsecaseImpl) ServiceAccountHasRole(accountID int, roleName string) (bool, error) {
+	return u.rbacRepo.ServiceAccountHasRole(accountID, roleName)
+}
+
+func (u *RBACUsecaseImpl) CheckServiceAccountPermission(accountID int, resource, action string) error {
+	hasPermission, err := u.rbacRepo.ServiceAccountHasPermission(accountID, resource, action)
+	if err != nil {
+		return fmt.Errorf("failed to check permission: %w", err)
+	}
+	if !hasPermission {
+		return fmt.Errorf("permission denied: %s:%s", resource, action)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+// loginTestUser テストユーザーでログインしてトークンペアを取得
+func loginTestUser(t *testing.T, deps *testAuthDeps) *domain.TokenPair {
+	t.Helper()
+
+	user, err := deps.users.GetByID(1)
+	if err != nil || user == nil {
+		t.Fatalf("GetByID() = %v, %v", user, err)
+	}
+	tokenPair, err := deps.usecase.GenerateTokenPair(user, "test-agent", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	return tokenPair
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+	first := loginTestUser(t, deps)
+
+	second, err := deps.usecase.RefreshToken(first.RefreshToken, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("RefreshToken() returned the same refresh token")
+	}
+
+	old, _ := deps.refreshTokens.GetByTokenHash(hashToken(first.RefreshToken))
+	current, _ := deps.refreshTokens.GetByTokenHash(hashToken(second.RefreshToken))
+	if !old.Revoked || old.ReplacedByID == nil || *old.ReplacedByID != current.ID {
+		t.Errorf("old token = %+v, want revoked and replaced by %d", old, current.ID)
+	}
+	if current.Revoked || current.FamilyID != old.FamilyID {
+		t.Errorf("new token = %+v, want active in family %s", current, old.FamilyID)
+	}
+	if !current.ExpiresAt.Equal(old.ExpiresAt) {
+		t.Errorf("new token expires at %v, want the family's original expiry %v", current.ExpiresAt, old.ExpiresAt)
+	}
+
+	// ローテーション後の新しいトークンは引き続き使える
+	if _, err := deps.usecase.RefreshToken(second.RefreshToken, "192.0.2.1", "test-agent"); err != nil {
+		t.Errorf("RefreshToken() with rotated token error = %v", err)
+	}
+}
+
+func TestRefreshTokenRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     func(t *testing.T, deps *testAuthDeps) string
+		wantReuse bool
+	}{
+		{
+			name:  "unknown token",
+			token: func(t *testing.T, deps *testAuthDeps) string { return "unknown-refresh-token" },
+		},
+		{
+			name: "revoked by logout",
+			token: func(t *testing.T, deps *testAuthDeps) string {
+				tokenPair := loginTestUser(t, deps)
+				claims, err := deps.usecase.ValidateToken(tokenPair.AccessToken)
+				if err != nil {
+					t.Fatalf("ValidateToken() error = %v", err)
+				}
+				if err := deps.usecase.Logout(1, claims.ID); err != nil {
+					t.Fatalf("Logout() error = %v", err)
+				}
+				return tokenPair.RefreshToken
+			},
+		},
+		{
+			name: "rotated token presented again",
+			token: func(t *testing.T, deps *testAuthDeps) string {
+				tokenPair := loginTestUser(t, deps)
+				if _, err := deps.usecase.RefreshToken(tokenPair.RefreshToken, "192.0.2.1", "test-agent"); err != nil {
+					t.Fatalf("RefreshToken() error = %v", err)
+				}
+				return tokenPair.RefreshToken
+			},
+			wantReuse: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+			token := tt.token(t, deps)
+
+			_, err := deps.usecase.RefreshToken(token, "203.0.113.9", "attacker-agent")
+			if err == nil {
+				t.Fatal("RefreshToken() error = nil, want error")
+			}
+			if reused := errors.Is(err, domain.ErrRefreshTokenReused); reused != tt.wantReuse {
+				t.Errorf("RefreshToken() error = %v, want reuse detected = %v", err, tt.wantReuse)
+			}
+
+			events := deps.securityEvents.eventsOfType(domain.SecurityEventRefreshTokenReuse)
+			if !tt.wantReuse {
+				if len(events) != 0 {
+					t.Errorf("recorded %d reuse events, want none", len(events))
+				}
+				return
+			}
+			if len(events) != 1 || events[0].IPAddress != "203.0.113.9" {
+				t.Fatalf("reuse events = %+v, want one from the presenting IP", events)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+	first := loginTestUser(t, deps)
+	other := loginTestUser(t, deps)
+
+	second, err := deps.usecase.RefreshToken(first.RefreshToken, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+
+	if _, err := deps.usecase.RefreshToken(first.RefreshToken, "203.0.113.9", "attacker-agent"); !errors.Is(err, domain.ErrRefreshTokenReused) {
+		t.Fatalf("RefreshToken() error = %v, want %v", err, domain.ErrRefreshTokenReused)
+	}
+
+	// 正規の利用者が持つ後継トークンと、そのアクセストークンも無効になる
+	if _, err := deps.usecase.RefreshToken(second.RefreshToken, "192.0.2.1", "test-agent"); err == nil {
+		t.Error("RefreshToken() with successor token error = nil, want error")
+	}
+	if _, err := deps.usecase.ValidateToken(second.AccessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("ValidateToken() error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+
+	// 別のログインのファミリーには影響しない
+	if _, err := deps.usecase.RefreshToken(other.RefreshToken, "192.0.2.1", "test-agent"); err != nil {
+		t.Errorf("RefreshToken() in another family error = %v", err)
+	}
+}
+
+func TestRefreshTokenStoredAsDigest(t *testing.T) {
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+	first := loginTestUser(t, deps)
+	second, err := deps.usecase.RefreshToken(first.RefreshToken, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "issued at login", token: first.RefreshToken},
+		{name: "issued on rotation", token: second.RefreshToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stored, _ := deps.refreshTokens.GetByTokenHash(tt.token); stored != nil {
+				t.Fatal("refresh token is stored in plaintext")
+			}
+			stored, _ := deps.refreshTokens.GetByTokenHash(hashToken(tt.token))
+			if stored == nil {
+				t.Fatal("refresh token digest is not stored")
+			}
+		})
+	}
+
+	// 漏洩したダイジェストそのものはリフレッシュトークンとして使えない
+	if _, err := deps.usecase.RefreshToken(hashToken(second.RefreshToken), "192.0.2.1", "test-agent"); err == nil {
+		t.Error("RefreshToken() with stored digest error = nil, want error")
+	}
+}
@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type RegistrationUsecase struct {
+	authRepo       domain.AuthRepository
+	userRepo       domain.UserRepository
+	mailer         domain.Mailer
+	passwordHasher domain.PasswordHasher
+	keyManager     domain.KeyManager
+	loginThrottle  domain.LoginThrottle
+	jwtConfig      domain.JWTConfig
+	config         domain.RegistrationConfig
+}
+
+func NewRegistrationUsecase(
+	authRepo domain.AuthRepository,
+	userRepo domain.UserRepository,
+	mailer domain.Mailer,
+	passwordHasher domain.PasswordHasher,
+	keyManager domain.KeyManager,
+	loginThrottle domain.LoginThrottle,
+	jwtConfig domain.JWTConfig,
+	config domain.RegistrationConfig,
+) domain.RegistrationUsecase {
+	return &RegistrationUsecase{
+		authRepo:       authRepo,
+		userRepo:       userRepo,
+		mailer:         mailer,
+		passwordHasher: passwordHasher,
+		keyManager:     keyManager,
+		loginThrottle:  loginThrottle,
+		jwtConfig:      jwtConfig,
+		config:         config,
+	}
+}
+
+// Register 未確認のアカウントを作成して確認メールを送信
+func (u *RegistrationUsecase) Register(req *domain.RegisterRequest) error {
+	name := strings.TrimSpace(req.Name)
+	email := strings.TrimSpace(req.Email)
+
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return domain.ErrInvalidEmail
+	}
+	if err := validatePassword(req.Password, email); err != nil {
+		return err
+	}
+
+	// 登録済みかどうかで応答時間が変わらないよう、先にハッシュ化する
+	passwordHash, err := u.passwordHasher.Hash(req.Password)
+	if err != nil {
+		return err
+	}
+
+	existing, err := u.authRepo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		u.sendAlreadyRegisteredNotice(existing)
+		return nil
+	}
+
+	user := &domain.User{
+		Name:     name,
+		Email:    email,
+		Password: passwordHash,
+	}
+	if err := u.userRepo.Create(user); err != nil {
+		return err
+	}
+
+	// 送信に失敗しても再送できるため、登録自体は成功とする
+	if err := u.sendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// VerifyEmail 確認リンクのトークンを検証してアカウントを有効化
+func (u *RegistrationUsecase) VerifyEmail(token string) error {
+	claims := &domain.Claims{}
+	if _, err := parseJWT(u.keyManager, token, claims, jwtParserOptions(u.jwtConfig)...); err != nil {
+		return domain.ErrInvalidVerificationToken
+	}
+	if claims.TokenUse != domain.TokenUseEmailVerification {
+		return domain.ErrInvalidVerificationToken
+	}
+
+	// リンク発行後にメールアドレスが変更されていれば無効
+	verified, err := u.authRepo.MarkEmailVerified(claims.UserID, claims.Email)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return domain.ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+// ResendVerification 未確認のアカウントに確認メールを再送（該当しない場合も成功扱い）
+// アカウントの存在有無が応答の内容と時間から分からないよう、送信は応答とは別に行う
+func (u *RegistrationUsecase) ResendVerification(email, ipAddress string) error {
+	email = strings.TrimSpace(email)
+
+	// 特定のアカウントへの大量送信や、アカウントの探索を防ぐため、メールアドレスとクライアントIPごとに要求回数を制限する
+	attemptKeys := []string{verificationAttemptKey(email), verificationIPAttemptKey(ipAddress)}
+	if err := u.loginThrottle.Check(attemptKeys...); err != nil {
+		return err
+	}
+	u.loginThrottle.RecordFailure(nil, ipAddress, attemptKeys...)
+
+	user, err := u.authRepo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	go func() {
+		if err := u.sendVerification(user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendVerification 署名付きの確認リンクをメールで送信
+func (u *RegistrationUsecase) sendVerification(user *domain.User) error {
+	claims := domain.Claims{
+		UserID:   user.ID,
+		Email:    user.Email,
+		TokenUse: domain.TokenUseEmailVerification,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(u.config.VerificationDuration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := signJWT(u.keyManager, claims)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(u.config.BaseURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	return u.mailer.Send(&domain.MailMessage{
+		To:      user.Email,
+		Subject: "メールアドレスの確認",
+		Body: fmt.Sprintf("%s 様\n\nご登録ありがとうございます。以下のリンクからメールアドレスを確認してください。\n\n%s\n\nこのリンクの有効期限は%sです。心当たりがない場合はこのメールを破棄してください。\n",
+			user.Name, link, formatDuration(u.config.VerificationDuration)),
+	})
+}
+
+// sendAlreadyRegisteredNotice 登録済みのメールアドレスで登録が試みられたことを持ち主に通知
+func (u *RegistrationUsecase) sendAlreadyRegisteredNotice(user *domain.User) {
+	err := u.mailer.Send(&domain.MailMessage{
+		To:      user.Email,
+		Subject: "アカウント登録のお知らせ",
+		Body: fmt.Sprintf("%s 様\n\nこのメールアドレスで新規登録が試みられましたが、既にアカウントが存在します。\nログイン画面からログインしてください。\n\n%s/login\n\n心当たりがない場合はこのメールを破棄してください。\n",
+			user.Name, strings.TrimRight(u.config.BaseURL, "/")),
+	})
+	if err != nil {
+		log.Printf("Failed to send registration notice to user %d: %v", user.ID, err)
+	}
+}
+
+// formatDuration 有効期限をメール本文用に整形
+func formatDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d時間", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d分", int(d.Minutes()))
+}
@@ -0,0 +1,304 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testStrongPassword = "correct horse battery staple"
+
+type testRegistration struct {
+	*testAuthDeps
+	usecase *RegistrationUsecase
+	mailer  *fakeMailer
+}
+
+func newTestRegistrationUsecase(t *testing.T, users ...*domain.User) *testRegistration {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t, users...)
+	mailer := &fakeMailer{}
+	registrationUsecase := NewRegistrationUsecase(
+		deps.users, deps.users, mailer, newTestPasswordHasher(t), deps.keyManager, deps.loginThrottle, testJWTConfig(),
+		domain.RegistrationConfig{BaseURL: "http://localhost:8080/", VerificationDuration: 24 * time.Hour},
+	)
+	return &testRegistration{testAuthDeps: deps, usecase: registrationUsecase.(*RegistrationUsecase), mailer: mailer}
+}
+
+// verificationClaims 確認リンクのトークンのクレーム
+func verificationClaims(userID int, email string) domain.Claims {
+	return domain.Claims{
+		UserID:   userID,
+		Email:    email,
+		TokenUse: domain.TokenUseEmailVerification,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "verification-jti",
+			Issuer:    testServiceIssuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         domain.RegisterRequest
+		wantErr     error
+		wantUsers   int
+		wantSubject string
+	}{
+		{
+			name:        "new account",
+			req:         domain.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: testStrongPassword},
+			wantUsers:   2,
+			wantSubject: "メールアドレスの確認",
+		},
+		{
+			name:        "already registered",
+			req:         domain.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: testStrongPassword},
+			wantUsers:   1,
+			wantSubject: "アカウント登録のお知らせ",
+		},
+		{
+			name:      "invalid email",
+			req:       domain.RegisterRequest{Name: "Bob", Email: "Bob <bob@example.com>", Password: testStrongPassword},
+			wantErr:   domain.ErrInvalidEmail,
+			wantUsers: 1,
+		},
+		{
+			name:      "short password",
+			req:       domain.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "short"},
+			wantErr:   domain.ErrWeakPassword,
+			wantUsers: 1,
+		},
+		{
+			name:      "common password",
+			req:       domain.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "Password123"},
+			wantErr:   domain.ErrWeakPassword,
+			wantUsers: 1,
+		},
+		{
+			name:      "password containing email",
+			req:       domain.RegisterRequest{Name: "Bob", Email: "bobby@example.com", Password: "bobby-2024-secure"},
+			wantErr:   domain.ErrWeakPassword,
+			wantUsers: 1,
+		},
+		{
+			name:      "repeated character",
+			req:       domain.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "aaaaaaaaaaaa"},
+			wantErr:   domain.ErrWeakPassword,
+			wantUsers: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistrationUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+
+			err := r.usecase.Register(&tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Register() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(r.users.users) != tt.wantUsers {
+				t.Errorf("%d users, want %d", len(r.users.users), tt.wantUsers)
+			}
+
+			messages := r.mailer.sent()
+			if tt.wantSubject == "" {
+				if len(messages) != 0 {
+					t.Errorf("sent %d messages, want none", len(messages))
+				}
+				return
+			}
+			if len(messages) != 1 || messages[0].Subject != tt.wantSubject || messages[0].To != tt.req.Email {
+				t.Fatalf("sent %+v, want one %q message to %s", messages, tt.wantSubject, tt.req.Email)
+			}
+
+			if tt.wantUsers > 1 {
+				user := r.users.user(2)
+				if user.EmailVerifiedAt != nil {
+					t.Error("new account is already verified")
+				}
+				if user.Password == tt.req.Password {
+					t.Error("password is stored in plaintext")
+				}
+			}
+		})
+	}
+}
+
+func TestRegisteredAccountMustBeVerified(t *testing.T) {
+	r := newTestRegistrationUsecase(t)
+	req := &domain.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: testStrongPassword}
+	if err := r.usecase.Register(req); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if _, err := r.testAuthDeps.usecase.Login(req.Email, req.Password, "test", "192.0.2.1"); !errors.Is(err, domain.ErrEmailNotVerified) {
+		t.Fatalf("Login() before verification error = %v, want %v", err, domain.ErrEmailNotVerified)
+	}
+
+	token := mailLinkToken(t, r.mailer.sent()[0], "token")
+	if err := r.usecase.VerifyEmail(token); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if _, err := r.testAuthDeps.usecase.Login(req.Email, req.Password, "test", "192.0.2.1"); err != nil {
+		t.Errorf("Login() after verification error = %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   func(t *testing.T, r *testRegistration) string
+		wantErr error
+	}{
+		{
+			name: "valid token",
+			token: func(t *testing.T, r *testRegistration) string {
+				token, _ := signJWT(r.keyManager, verificationClaims(1, "bob@example.com"))
+				return token
+			},
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T, r *testRegistration) string {
+				claims := verificationClaims(1, "bob@example.com")
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				token, _ := signJWT(r.keyManager, claims)
+				return token
+			},
+			wantErr: domain.ErrInvalidVerificationToken,
+		},
+		{
+			name: "another issuer",
+			token: func(t *testing.T, r *testRegistration) string {
+				claims := verificationClaims(1, "bob@example.com")
+				claims.Issuer = "https://evil.example.com"
+				token, _ := signJWT(r.keyManager, claims)
+				return token
+			},
+			wantErr: domain.ErrInvalidVerificationToken,
+		},
+		{
+			name: "access token",
+			token: func(t *testing.T, r *testRegistration) string {
+				user, _ := r.users.GetByID(1)
+				token, _ := r.testAuthDeps.usecase.GenerateToken(user)
+				return token
+			},
+			wantErr: domain.ErrInvalidVerificationToken,
+		},
+		{
+			name: "email changed after the link was sent",
+			token: func(t *testing.T, r *testRegistration) string {
+				token, _ := signJWT(r.keyManager, verificationClaims(1, "bob@example.com"))
+				r.users.Update(&domain.User{ID: 1, Name: "Bob", Email: "robert@example.com"})
+				return token
+			},
+			wantErr: domain.ErrInvalidVerificationToken,
+		},
+		{
+			name: "signed by unknown key",
+			token: func(t *testing.T, r *testRegistration) string {
+				return signTestToken(t, newTestECKey(t, "test-key"), verificationClaims(1, "bob@example.com"))
+			},
+			wantErr: domain.ErrInvalidVerificationToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistrationUsecase(t, &domain.User{Name: "Bob", Email: "bob@example.com", Password: testStrongPassword})
+
+			err := r.usecase.VerifyEmail(tt.token(t, r))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyEmail() error = %v, want %v", err, tt.wantErr)
+			}
+			if verified := r.users.user(1).EmailVerifiedAt != nil; verified != (tt.wantErr == nil) {
+				t.Errorf("verified = %v, want %v", verified, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantSent bool
+	}{
+		{name: "unverified account", email: "bob@example.com", wantSent: true},
+		{name: "unverified account with surrounding spaces", email: " bob@example.com ", wantSent: true},
+		{name: "verified account", email: "alice@example.com"},
+		{name: "unknown account", email: "carol@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistrationUsecase(t,
+				verifiedUser("alice@example.com", testStrongPassword),
+				&domain.User{Name: "Bob", Email: "bob@example.com", Password: testStrongPassword},
+			)
+
+			if err := r.usecase.ResendVerification(tt.email, "192.0.2.1"); err != nil {
+				t.Fatalf("ResendVerification() error = %v", err)
+			}
+
+			if !tt.wantSent {
+				time.Sleep(20 * time.Millisecond)
+				if messages := r.mailer.sent(); len(messages) != 0 {
+					t.Errorf("sent %d messages, want none", len(messages))
+				}
+				return
+			}
+			messages := r.mailer.waitForMessages(t, 1)
+			if err := r.usecase.VerifyEmail(mailLinkToken(t, messages[0], "token")); err != nil {
+				t.Errorf("VerifyEmail() with resent link error = %v", err)
+			}
+		})
+	}
+}
+
+func TestResendVerificationIsThrottled(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    func(i int) string
+		attempts int
+	}{
+		{
+			name:     "same email",
+			email:    func(i int) string { return "bob@example.com" },
+			attempts: testLoginAttemptConfig().FreeAttempts + 1,
+		},
+		{
+			name:     "same ip",
+			email:    func(i int) string { return fmt.Sprintf("user%d@example.com", i) },
+			attempts: testLoginAttemptConfig().MaxIPFailures,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistrationUsecase(t)
+
+			for i := 0; i < tt.attempts; i++ {
+				if err := r.usecase.ResendVerification(tt.email(i), "192.0.2.1"); err != nil {
+					t.Fatalf("attempt %d error = %v", i+1, err)
+				}
+			}
+
+			var lockedErr *domain.LoginLockedError
+			if err := r.usecase.ResendVerification(tt.email(tt.attempts), "192.0.2.1"); !errors.As(err, &lockedErr) {
+				t.Errorf("ResendVerification() error = %v, want *domain.LoginLockedError", err)
+			}
+		})
+	}
+}
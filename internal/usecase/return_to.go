@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// maxReturnToLength 戻り先の最大長（クッキーに保存するため）
+const maxReturnToLength = 2048
+
+// returnToClaims ログイン後の戻り先を保存するクッキーのトークンのクレーム
+// 署名で、サブドメインなどから書き込まれたクッキーの値を戻り先として使わないようにする
+type returnToClaims struct {
+	TokenUse string `json:"token_use"`
+	ReturnTo string `json:"return_to"`
+	jwt.RegisteredClaims
+}
+
+type ReturnToUsecase struct {
+	keyManager domain.KeyManager
+	jwtConfig  domain.JWTConfig
+	config     domain.ReturnToConfig
+}
+
+func NewReturnToUsecase(keyManager domain.KeyManager, jwtConfig domain.JWTConfig, config domain.ReturnToConfig) domain.ReturnToUsecase {
+	return &ReturnToUsecase{
+		keyManager: keyManager,
+		jwtConfig:  jwtConfig,
+		config:     config,
+	}
+}
+
+// Validate 戻り先を検証して正規化する
+//   - このサービスのパス（/ で始まる相対URL）: 許可したパスの配下のみ。// や \ で始まる値（ブラウザが別のホストとして扱う）と、. や .. を含むパスは受け付けない
+//   - 絶対URL: http / https で、許可したホストのみ（ユーザー情報を含むURLは受け付けない）
+func (u *ReturnToUsecase) Validate(returnTo string) (string, error) {
+	if returnTo == "" || len(returnTo) > maxReturnToLength || strings.Contains(returnTo, `\`) {
+		return "", domain.ErrInvalidReturnTo
+	}
+
+	// 制御文字を含む値はParseでエラーになる
+	parsed, err := url.Parse(returnTo)
+	if err != nil || parsed.User != nil || parsed.Opaque != "" {
+		return "", domain.ErrInvalidReturnTo
+	}
+
+	if parsed.Scheme == "" && parsed.Host == "" {
+		if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+			return "", domain.ErrInvalidReturnTo
+		}
+		if !isCleanPath(parsed.Path) || !u.pathAllowed(parsed.Path) {
+			return "", domain.ErrInvalidReturnTo
+		}
+		return parsed.String(), nil
+	}
+
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || !u.hostAllowed(parsed.Host) {
+		return "", domain.ErrInvalidReturnTo
+	}
+	return parsed.String(), nil
+}
+
+// Seal 戻り先を検証し、クッキーに保存する署名付きのトークンを返す
+func (u *ReturnToUsecase) Seal(returnTo string) (string, time.Time, error) {
+	returnTo, err := u.Validate(returnTo)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(u.config.Duration)
+	claims := returnToClaims{
+		TokenUse: domain.TokenUseReturnTo,
+		ReturnTo: returnTo,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    u.jwtConfig.Issuer,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	sealed, err := signJWT(u.keyManager, claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return sealed, expiresAt, nil
+}
+
+// Open クッキーのトークンの署名を検証して戻り先を取り出す（保存後に許可リストが変わった場合も再検証で拒否する）
+func (u *ReturnToUsecase) Open(sealed string) (string, error) {
+	claims := &returnToClaims{}
+	if _, err := parseJWT(u.keyManager, sealed, claims, jwtParserOptions(u.jwtConfig)...); err != nil {
+		return "", domain.ErrInvalidReturnTo
+	}
+	if claims.TokenUse != domain.TokenUseReturnTo {
+		return "", domain.ErrInvalidReturnTo
+	}
+
+	return u.Validate(claims.ReturnTo)
+}
+
+// pathAllowed 許可したパスと一致するか、その配下か（/protected は /protected/x に一致し、/protected-admin には一致しない）
+func (u *ReturnToUsecase) pathAllowed(requestPath string) bool {
+	for _, allowed := range u.config.AllowedPaths {
+		if requestPath == allowed || strings.HasPrefix(requestPath, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *ReturnToUsecase) hostAllowed(host string) bool {
+	for _, allowed := range u.config.AllowedHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCleanPath . や .. を含まないパスか（/protected/../admin のように許可したパスの外を指す値を拒否する）
+func isCleanPath(requestPath string) bool {
+	cleaned := path.Clean(requestPath)
+	return requestPath == cleaned || requestPath == cleaned+"/"
+}
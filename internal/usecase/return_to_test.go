@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+func newTestReturnToUsecase(t *testing.T) *ReturnToUsecase {
+	t.Helper()
+
+	return NewReturnToUsecase(newTestKeyManager(t), testJWTConfig(), domain.ReturnToConfig{
+		AllowedPaths: []string{"/protected", "/settings/"},
+		AllowedHosts: []string{"app.example.com", "localhost:3000"},
+		Duration:     10 * time.Minute,
+	}).(*ReturnToUsecase)
+}
+
+func TestReturnToValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		returnTo string
+		want     string
+		wantErr  bool
+	}{
+		{name: "allowed path", returnTo: "/protected", want: "/protected"},
+		{name: "below allowed path", returnTo: "/protected/orders?page=2#top", want: "/protected/orders?page=2#top"},
+		{name: "allowed path with trailing slash", returnTo: "/settings/profile/", want: "/settings/profile/"},
+		{name: "allowed host", returnTo: "https://app.example.com/dashboard", want: "https://app.example.com/dashboard"},
+		{name: "allowed host is case insensitive", returnTo: "https://APP.example.com/", want: "https://APP.example.com/"},
+		{name: "allowed host with port", returnTo: "http://localhost:3000/", want: "http://localhost:3000/"},
+		{name: "empty", returnTo: "", wantErr: true},
+		{name: "too long", returnTo: "/protected/" + strings.Repeat("a", maxReturnToLength), wantErr: true},
+		{name: "path not allowed", returnTo: "/admin", wantErr: true},
+		// パスの区切りで前方一致する
+		{name: "path sharing a prefix", returnTo: "/protected-admin", wantErr: true},
+		{name: "relative path", returnTo: "protected", wantErr: true},
+		{name: "dot segments", returnTo: "/protected/../admin", wantErr: true},
+		{name: "duplicate slashes", returnTo: "/protected//orders", wantErr: true},
+		// ブラウザは // や \ で始まる値を別のホストとして扱う
+		{name: "protocol-relative URL", returnTo: "//evil.example.com/protected", wantErr: true},
+		{name: "backslash", returnTo: `/\evil.example.com`, wantErr: true},
+		{name: "backslash in path", returnTo: `/protected\..\admin`, wantErr: true},
+		{name: "control character", returnTo: "/protected\n/orders", wantErr: true},
+		{name: "host not allowed", returnTo: "https://evil.example.com/", wantErr: true},
+		{name: "subdomain of allowed host", returnTo: "https://evil.app.example.com/", wantErr: true},
+		{name: "port not allowed", returnTo: "http://localhost:4000/", wantErr: true},
+		{name: "user info", returnTo: "https://app.example.com@evil.example.com/", wantErr: true},
+		{name: "user info on allowed host", returnTo: "https://user@app.example.com/", wantErr: true},
+		{name: "javascript scheme", returnTo: "javascript:alert(1)", wantErr: true},
+		{name: "data scheme", returnTo: "data:text/html,<script>alert(1)</script>", wantErr: true},
+		{name: "other scheme on allowed host", returnTo: "ftp://app.example.com/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newTestReturnToUsecase(t)
+
+			got, err := u.Validate(tt.returnTo)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidReturnTo) {
+					t.Errorf("Validate(%q) error = %v, want %v", tt.returnTo, err, domain.ErrInvalidReturnTo)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate(%q) error = %v", tt.returnTo, err)
+			}
+			if got != tt.want {
+				t.Errorf("Validate(%q) = %q, want %q", tt.returnTo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReturnToSealAndOpen(t *testing.T) {
+	u := newTestReturnToUsecase(t)
+
+	sealed, expiresAt, err := u.Seal("/protected/orders")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if time.Until(expiresAt) <= 0 || time.Until(expiresAt) > 10*time.Minute {
+		t.Errorf("Seal() expiresAt = %v, want within the configured duration", expiresAt)
+	}
+	if got, err := u.Open(sealed); err != nil || got != "/protected/orders" {
+		t.Errorf("Open() = %q, %v, want %q", got, err, "/protected/orders")
+	}
+
+	if _, _, err := u.Seal("//evil.example.com"); !errors.Is(err, domain.ErrInvalidReturnTo) {
+		t.Errorf("Seal() with disallowed value error = %v, want %v", err, domain.ErrInvalidReturnTo)
+	}
+}
+
+func TestReturnToOpenRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		sealed func(t *testing.T, u *ReturnToUsecase) string
+	}{
+		{
+			// サブドメインなどから書き込まれた署名のない値
+			name:   "unsigned value",
+			sealed: func(t *testing.T, u *ReturnToUsecase) string { return "/protected" },
+		},
+		{
+			name: "tampered token",
+			sealed: func(t *testing.T, u *ReturnToUsecase) string {
+				sealed, _, err := u.Seal("/protected")
+				if err != nil {
+					t.Fatalf("Seal() error = %v", err)
+				}
+				return sealed + "x"
+			},
+		},
+		{
+			name: "expired token",
+			sealed: func(t *testing.T, u *ReturnToUsecase) string {
+				u.config.Duration = -time.Hour
+				sealed, _, err := u.Seal("/protected")
+				if err != nil {
+					t.Fatalf("Seal() error = %v", err)
+				}
+				return sealed
+			},
+		},
+		{
+			// 他の用途のトークンを戻り先として使わない
+			name: "access token",
+			sealed: func(t *testing.T, u *ReturnToUsecase) string {
+				deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", testStrongPassword))
+				u.keyManager = deps.keyManager
+				return loginTestUser(t, deps).AccessToken
+			},
+		},
+		{
+			// 保存後に許可リストから外れた戻り先は拒否する
+			name: "no longer allowed",
+			sealed: func(t *testing.T, u *ReturnToUsecase) string {
+				sealed, _, err := u.Seal("/settings/profile")
+				if err != nil {
+					t.Fatalf("Seal() error = %v", err)
+				}
+				u.config.AllowedPaths = []string{"/protected"}
+				return sealed
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newTestReturnToUsecase(t)
+			sealed := tt.sealed(t, u)
+
+			if got, err := u.Open(sealed); !errors.Is(err, domain.ErrInvalidReturnTo) {
+				t.Errorf("Open() = %q, %v, want %v", got, err, domain.ErrInvalidReturnTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+func TestAccessTokenRevocation(t *testing.T) {
+	tests := []struct {
+		name string
+		// revoke 1つ目のセッションのアクセストークン（JTI）を渡して失効処理を行う
+		revoke           func(t *testing.T, deps *testAuthDeps, jti string) error
+		wantOtherRevoked bool
+	}{
+		{
+			name:   "logout",
+			revoke: func(t *testing.T, deps *testAuthDeps, jti string) error { return deps.usecase.Logout(1, jti) },
+		},
+		{
+			name: "force logout",
+			revoke: func(t *testing.T, deps *testAuthDeps, jti string) error {
+				return deps.usecase.ForceLogout("admin", 1)
+			},
+			wantOtherRevoked: true,
+		},
+		{
+			name: "revoke all sessions",
+			revoke: func(t *testing.T, deps *testAuthDeps, jti string) error {
+				return deps.usecase.RevokeAllSessions(1)
+			},
+			wantOtherRevoked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+			current := loginTestUser(t, deps)
+			other := loginTestUser(t, deps)
+
+			claims, err := deps.usecase.ValidateToken(current.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if err := tt.revoke(t, deps, claims.ID); err != nil {
+				t.Fatalf("revoke error = %v", err)
+			}
+
+			if _, err := deps.usecase.ValidateToken(current.AccessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+				t.Errorf("ValidateToken() error = %v, want %v", err, domain.ErrTokenRevoked)
+			}
+
+			_, err = deps.usecase.ValidateToken(other.AccessToken)
+			if otherRevoked := errors.Is(err, domain.ErrTokenRevoked); otherRevoked != tt.wantOtherRevoked {
+				t.Errorf("other session ValidateToken() error = %v, want revoked = %v", err, tt.wantOtherRevoked)
+			}
+		})
+	}
+}
+
+func TestLogoutRevokesAccessTokenWithoutRefreshToken(t *testing.T) {
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+	user, _ := deps.users.GetByID(1)
+
+	// リフレッシュトークンを伴わないアクセストークンもログアウトで失効する
+	accessToken, err := deps.usecase.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	claims, err := deps.usecase.ValidateToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+
+	if err := deps.usecase.Logout(user.ID, claims.ID); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := deps.usecase.ValidateToken(accessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("ValidateToken() error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+}
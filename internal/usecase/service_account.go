@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-echo-demo/internal/domain"
+)
+
+const (
+	// serviceAccountClientIDSize クライアントIDのランダム部分のバイト数
+	serviceAccountClientIDSize = 12
+	// serviceAccountSecretSize クライアントシークレットのランダム部分のバイト数（256ビット）
+	serviceAccountSecretSize = 32
+	// serviceAccountMaxNameLength サービスアカウント名の最大文字数
+	serviceAccountMaxNameLength = 100
+)
+
+type ServiceAccountUsecase struct {
+	accountRepo domain.ServiceAccountRepository
+	authUsecase domain.AuthUsecase
+}
+
+func NewServiceAccountUsecase(
+	accountRepo domain.ServiceAccountRepository,
+	authUsecase domain.AuthUsecase,
+) domain.ServiceAccountUsecase {
+	return &ServiceAccountUsecase{
+		accountRepo: accountRepo,
+		authUsecase: authUsecase,
+	}
+}
+
+// Create サービスアカウントを作成し、クライアントシークレットを一度だけ返す
+func (u *ServiceAccountUsecase) Create(req *domain.CreateServiceAccountRequest) (*domain.ServiceAccountCredentials, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > serviceAccountMaxNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", domain.ErrInvalidServiceAccountRequest, serviceAccountMaxNameLength)
+	}
+
+	clientID, err := generateSecureToken(serviceAccountClientIDSize)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateServiceAccountSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	account := &domain.ServiceAccount{
+		Name:             name,
+		Description:      strings.TrimSpace(req.Description),
+		ClientID:         domain.ServiceAccountClientIDPrefix + clientID,
+		ClientSecretHash: hashToken(secret),
+	}
+	if err := u.accountRepo.Create(account); err != nil {
+		return nil, err
+	}
+
+	return &domain.ServiceAccountCredentials{
+		ClientSecret:   secret,
+		ServiceAccount: *account,
+	}, nil
+}
+
+// List サービスアカウント一覧を取得
+func (u *ServiceAccountUsecase) List() ([]*domain.ServiceAccount, error) {
+	return u.accountRepo.List()
+}
+
+// Get サービスアカウントを取得
+func (u *ServiceAccountUsecase) Get(id int) (*domain.ServiceAccount, error) {
+	account, err := u.accountRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, domain.ErrServiceAccountNotFound
+	}
+	return account, nil
+}
+
+// RotateSecret クライアントシークレットを再発行
+// 発行済みのアクセストークンは有効期限まで使えるため、漏洩時は削除して作り直す
+func (u *ServiceAccountUsecase) RotateSecret(id int) (*domain.ServiceAccountCredentials, error) {
+	secret, err := generateServiceAccountSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := u.accountRepo.UpdateSecretHash(id, hashToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, domain.ErrServiceAccountNotFound
+	}
+
+	account, err := u.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.ServiceAccountCredentials{
+		ClientSecret:   secret,
+		ServiceAccount: *account,
+	}, nil
+}
+
+// Delete サービスアカウントを削除（発行済みのアクセストークンも使えなくなる）
+func (u *ServiceAccountUsecase) Delete(id int) error {
+	deleted, err := u.accountRepo.Delete(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.ErrServiceAccountNotFound
+	}
+	return nil
+}
+
+// IssueToken クライアントIDとシークレットを検証してアクセストークンを発行
+func (u *ServiceAccountUsecase) IssueToken(clientID, clientSecret, audience string) (*domain.OAuthTokenResponse, error) {
+	account, err := u.accountRepo.GetByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// クライアントIDの存在有無で応答時間が変わらないよう、常にハッシュを比較する
+	storedHash := strings.Repeat("0", 64)
+	if account != nil {
+		storedHash = account.ClientSecretHash
+	}
+	match := subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(storedHash)) == 1
+	if account == nil || !match {
+		return nil, domain.ErrInvalidClient
+	}
+
+	token, err := u.authUsecase.GenerateServiceAccountToken(account, audience)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用状況の記録に失敗してもトークンの発行自体は成功とする
+	if err := u.accountRepo.UpdateLastUsed(account.ID, time.Now()); err != nil {
+		log.Printf("Failed to update last used time of service account %d: %v", account.ID, err)
+	}
+
+	return token, nil
+}
+
+// generateServiceAccountSecret 接頭辞付きのクライアントシークレットを生成
+func generateServiceAccountSecret() (string, error) {
+	random, err := generateSecureToken(serviceAccountSecretSize)
+	if err != nil {
+		return "", err
+	}
+	return domain.ServiceAccountSecretPrefix + random, nil
+}
@@ -0,0 +1,223 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+type testServiceAccount struct {
+	*testAuthDeps
+	usecase *ServiceAccountUsecase
+}
+
+func newTestServiceAccountUsecase(t *testing.T) *testServiceAccount {
+	t.Helper()
+
+	deps := newTestAuthUsecase(t)
+	deps.usecase.jwtConfig.Audiences = []string{testOtherAudience}
+	serviceAccountUsecase := NewServiceAccountUsecase(deps.serviceAccounts, deps.usecase)
+	return &testServiceAccount{testAuthDeps: deps, usecase: serviceAccountUsecase.(*ServiceAccountUsecase)}
+}
+
+// createAccount サービスアカウントを作成し、クライアントIDとシークレットを返す
+func (s *testServiceAccount) createAccount(t *testing.T) *domain.ServiceAccountCredentials {
+	t.Helper()
+
+	credentials, err := s.usecase.Create(&domain.CreateServiceAccountRequest{Name: "nightly-report"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return credentials
+}
+
+func TestCreateServiceAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     domain.CreateServiceAccountRequest
+		wantErr error
+	}{
+		{name: "valid", req: domain.CreateServiceAccountRequest{Name: " nightly-report ", Description: "batch job"}},
+		{name: "empty name", req: domain.CreateServiceAccountRequest{Name: " "}, wantErr: domain.ErrInvalidServiceAccountRequest},
+		{
+			name:    "name too long",
+			req:     domain.CreateServiceAccountRequest{Name: strings.Repeat("a", serviceAccountMaxNameLength+1)},
+			wantErr: domain.ErrInvalidServiceAccountRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServiceAccountUsecase(t)
+
+			credentials, err := s.usecase.Create(&tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !strings.HasPrefix(credentials.ClientID, domain.ServiceAccountClientIDPrefix) {
+				t.Errorf("ClientID = %q, want the %q prefix", credentials.ClientID, domain.ServiceAccountClientIDPrefix)
+			}
+			if !strings.HasPrefix(credentials.ClientSecret, domain.ServiceAccountSecretPrefix) {
+				t.Errorf("ClientSecret = %q, want the %q prefix", credentials.ClientSecret, domain.ServiceAccountSecretPrefix)
+			}
+			stored, _ := s.serviceAccounts.GetByID(credentials.ID)
+			if stored.Name != "nightly-report" || stored.ClientSecretHash != hashToken(credentials.ClientSecret) {
+				t.Errorf("stored account = %+v, want trimmed name and secret digest", stored)
+			}
+		})
+	}
+}
+
+func TestIssueServiceAccountToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientID     func(credentials *domain.ServiceAccountCredentials) string
+		clientSecret func(credentials *domain.ServiceAccountCredentials) string
+		audience     string
+		wantErr      error
+		wantAudience string
+	}{
+		{
+			name:         "valid credentials",
+			clientID:     func(c *domain.ServiceAccountCredentials) string { return c.ClientID },
+			clientSecret: func(c *domain.ServiceAccountCredentials) string { return c.ClientSecret },
+			wantAudience: testAudience,
+		},
+		{
+			name:         "other service audience",
+			clientID:     func(c *domain.ServiceAccountCredentials) string { return c.ClientID },
+			clientSecret: func(c *domain.ServiceAccountCredentials) string { return c.ClientSecret },
+			audience:     testOtherAudience,
+			wantAudience: testOtherAudience,
+		},
+		{
+			name:         "audience not configured",
+			clientID:     func(c *domain.ServiceAccountCredentials) string { return c.ClientID },
+			clientSecret: func(c *domain.ServiceAccountCredentials) string { return c.ClientSecret },
+			audience:     "https://evil.example.com",
+			wantErr:      domain.ErrInvalidAudience,
+		},
+		{
+			name:         "wrong secret",
+			clientID:     func(c *domain.ServiceAccountCredentials) string { return c.ClientID },
+			clientSecret: func(c *domain.ServiceAccountCredentials) string { return domain.ServiceAccountSecretPrefix + "wrong" },
+			wantErr:      domain.ErrInvalidClient,
+		},
+		{
+			name:         "secret digest instead of secret",
+			clientID:     func(c *domain.ServiceAccountCredentials) string { return c.ClientID },
+			clientSecret: func(c *domain.ServiceAccountCredentials) string { return c.ClientSecretHash },
+			wantErr:      domain.ErrInvalidClient,
+		},
+		{
+			name:         "unknown client",
+			clientID:     func(c *domain.ServiceAccountCredentials) string { return domain.ServiceAccountClientIDPrefix + "unknown" },
+			clientSecret: func(c *domain.ServiceAccountCredentials) string { return c.ClientSecret },
+			wantErr:      domain.ErrInvalidClient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServiceAccountUsecase(t)
+			credentials := s.createAccount(t)
+
+			token, err := s.usecase.IssueToken(tt.clientID(credentials), tt.clientSecret(credentials), tt.audience)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IssueToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if token.TokenType != "Bearer" {
+				t.Errorf("TokenType = %q, want %q", token.TokenType, "Bearer")
+			}
+			claims, err := s.testAuthDeps.usecase.ValidateToken(token.AccessToken, tt.wantAudience)
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if !claims.IsServiceAccount() || claims.UserID != 0 || claims.Subject != domain.ServiceAccountSubject(credentials.ClientID) {
+				t.Errorf("claims = %+v, want the service account as subject", claims)
+			}
+			if stored, _ := s.serviceAccounts.GetByID(credentials.ID); stored.LastUsedAt == nil {
+				t.Error("LastUsedAt was not updated")
+			}
+		})
+	}
+}
+
+func TestServiceAccountTokenAuthzClaims(t *testing.T) {
+	s := newTestServiceAccountUsecase(t)
+	s.testAuthDeps.usecase.jwtConfig.AuthzClaims = true
+	credentials := s.createAccount(t)
+	s.rbac.serviceAccountRoles[credentials.ID] = []domain.Role{{ID: 2, Name: "viewer"}}
+	s.rbac.rolePermissions[2] = []domain.Permission{{Resource: "products", Action: "read"}}
+
+	token, err := s.usecase.IssueToken(credentials.ClientID, credentials.ClientSecret, "")
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	if token.Scope != "products:read" {
+		t.Errorf("Scope = %q, want %q", token.Scope, "products:read")
+	}
+	claims, err := s.testAuthDeps.usecase.ValidateToken(token.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	if !claims.HasRole("viewer") || !claims.HasScope("products", "read") {
+		t.Errorf("Roles = %v, Scope = %q, want viewer with products:read", claims.Roles, claims.Scope)
+	}
+}
+
+func TestRotateServiceAccountSecret(t *testing.T) {
+	s := newTestServiceAccountUsecase(t)
+	credentials := s.createAccount(t)
+
+	rotated, err := s.usecase.RotateSecret(credentials.ID)
+	if err != nil {
+		t.Fatalf("RotateSecret() error = %v", err)
+	}
+	if rotated.ClientID != credentials.ClientID {
+		t.Errorf("ClientID = %q, want %q", rotated.ClientID, credentials.ClientID)
+	}
+	if _, err := s.usecase.IssueToken(credentials.ClientID, credentials.ClientSecret, ""); !errors.Is(err, domain.ErrInvalidClient) {
+		t.Errorf("IssueToken() with old secret error = %v, want %v", err, domain.ErrInvalidClient)
+	}
+	if _, err := s.usecase.IssueToken(rotated.ClientID, rotated.ClientSecret, ""); err != nil {
+		t.Errorf("IssueToken() with new secret error = %v", err)
+	}
+
+	if _, err := s.usecase.RotateSecret(credentials.ID + 1); !errors.Is(err, domain.ErrServiceAccountNotFound) {
+		t.Errorf("RotateSecret() for unknown account error = %v, want %v", err, domain.ErrServiceAccountNotFound)
+	}
+}
+
+func TestDeleteServiceAccountRevokesTokens(t *testing.T) {
+	s := newTestServiceAccountUsecase(t)
+	credentials := s.createAccount(t)
+	token, err := s.usecase.IssueToken(credentials.ClientID, credentials.ClientSecret, "")
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+
+	if err := s.usecase.Delete(credentials.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	// 有効期限内のトークンも削除後は拒否する
+	if _, err := s.testAuthDeps.usecase.ValidateToken(token.AccessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("ValidateToken() after Delete error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if _, err := s.usecase.IssueToken(credentials.ClientID, credentials.ClientSecret, ""); !errors.Is(err, domain.ErrInvalidClient) {
+		t.Errorf("IssueToken() after Delete error = %v, want %v", err, domain.ErrInvalidClient)
+	}
+	if err := s.usecase.Delete(credentials.ID); !errors.Is(err, domain.ErrServiceAccountNotFound) {
+		t.Errorf("Delete() twice error = %v, want %v", err, domain.ErrServiceAccountNotFound)
+	}
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"go-echo-demo/internal/domain"
+)
+
+// accessTokenJTI アクセストークンのJTIを取得
+func accessTokenJTI(t *testing.T, deps *testAuthDeps, accessToken string) string {
+	t.Helper()
+
+	claims, err := deps.usecase.ValidateToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	return claims.ID
+}
+
+func TestGetSessionsMarksCurrentSession(t *testing.T) {
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+	current := loginTestUser(t, deps)
+	loginTestUser(t, deps)
+
+	rotated, err := deps.usecase.RefreshToken(current.RefreshToken, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		accessToken string
+	}{
+		{name: "access token issued with the latest refresh token", accessToken: rotated.AccessToken},
+		// ローテーション前に発行されたアクセストークンも同じセッションとして扱う
+		{name: "access token issued before rotation", accessToken: current.AccessToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, err := deps.usecase.GetSessions(1, accessTokenJTI(t, deps, tt.accessToken))
+			if err != nil {
+				t.Fatalf("GetSessions() error = %v", err)
+			}
+			if len(sessions) != 2 {
+				t.Fatalf("GetSessions() returned %d sessions, want 2", len(sessions))
+			}
+
+			var currentSessions int
+			for _, session := range sessions {
+				if session.DeviceInfo != "test-agent" || session.IPAddress != "192.0.2.1" {
+					t.Errorf("session = %+v, want device and IP recorded at login", session)
+				}
+				if session.Current {
+					currentSessions++
+				}
+			}
+			if currentSessions != 1 {
+				t.Errorf("%d sessions marked current, want 1", currentSessions)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    int
+		sessionID string // 空の場合はログインしたセッションのID
+		wantErr   error
+	}{
+		{name: "own session", userID: 1},
+		{name: "another user's session", userID: 2, wantErr: domain.ErrSessionNotFound},
+		{name: "unknown session", userID: 1, sessionID: "unknown", wantErr: domain.ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestAuthUsecase(t,
+				verifiedUser("alice@example.com", ""),
+				verifiedUser("bob@example.com", ""),
+			)
+			tokenPair := loginTestUser(t, deps)
+			stored, _ := deps.refreshTokens.GetByTokenHash(hashToken(tokenPair.RefreshToken))
+
+			sessionID := tt.sessionID
+			if sessionID == "" {
+				sessionID = stored.FamilyID
+			}
+
+			err := deps.usecase.RevokeSession(tt.userID, sessionID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevokeSession() error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, err = deps.usecase.RefreshToken(tokenPair.RefreshToken, "192.0.2.1", "test-agent")
+			if revoked := err != nil; revoked != (tt.wantErr == nil) {
+				t.Errorf("RefreshToken() error = %v, want session revoked = %v", err, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	deps := newTestAuthUsecase(t, verifiedUser("alice@example.com", ""))
+	current := loginTestUser(t, deps)
+	others := []*domain.TokenPair{loginTestUser(t, deps), loginTestUser(t, deps)}
+
+	if err := deps.usecase.RevokeOtherSessions(1, accessTokenJTI(t, deps, current.AccessToken)); err != nil {
+		t.Fatalf("RevokeOtherSessions() error = %v", err)
+	}
+
+	if _, err := deps.usecase.ValidateToken(current.AccessToken); err != nil {
+		t.Errorf("current session ValidateToken() error = %v", err)
+	}
+	for _, other := range others {
+		if _, err := deps.usecase.ValidateToken(other.AccessToken); !errors.Is(err, domain.ErrTokenRevoked) {
+			t.Errorf("other session ValidateToken() error = %v, want %v", err, domain.ErrTokenRevoked)
+		}
+		if _, err := deps.usecase.RefreshToken(other.RefreshToken, "192.0.2.1", "test-agent"); err == nil {
+			t.Error("other session RefreshToken() error = nil, want error")
+		}
+	}
+
+	sessions, err := deps.usecase.GetSessions(1, accessTokenJTI(t, deps, current.AccessToken))
+	if err != nil {
+		t.Fatalf("GetSessions() error = %v", err)
+	}
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("GetSessions() = %+v, want only the current session", sessions)
+	}
+}
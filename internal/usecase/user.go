@@ -14,11 +14,12 @@ type UserUsecase interface {
 }
 
 type userUsecaseImpl struct {
-	repo repository.UserRepository
+	repo           repository.UserRepository
+	passwordHasher domain.PasswordHasher
 }
 
-func NewUserUsecase(repo repository.UserRepository) UserUsecase {
-	return &userUsecaseImpl{repo: repo}
+func NewUserUsecase(repo repository.UserRepository, passwordHasher domain.PasswordHasher) UserUsecase {
+	return &userUsecaseImpl{repo: repo, passwordHasher: passwordHasher}
 }
 
 func (u *userUsecaseImpl) GetUsers() ([]domain.User, error) {
@@ -30,6 +31,14 @@ func (u *userUsecaseImpl) GetUser(id int) (*domain.User, error) {
 }
 
 func (u *userUsecaseImpl) CreateUser(user *domain.User) error {
+	// 平文のパスワードは保存せず、必ずハッシュ化してから渡す
+	if user.Password != "" {
+		hash, err := u.passwordHasher.Hash(user.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+	}
 	return u.repo.Create(user)
 }
 
@@ -0,0 +1,8 @@
+-- メールアドレス確認日時カラムの追加
+-- セルフ登録したアカウントは確認リンクを開くまでパスワードログインできない
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- 既存のユーザーは確認済みとして扱う
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE email_verified_at IS NULL;
+
+COMMENT ON COLUMN users.email_verified_at IS 'メールアドレスの確認日時（未確認の場合はNULL）';
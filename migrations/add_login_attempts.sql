@@ -0,0 +1,16 @@
+-- ログイン試行制限テーブルの作成
+-- アカウント（"account:<email>"）・クライアントIP（"ip:<address>"）・二要素認証（"mfa:<user_id>"）ごとに
+-- 連続失敗回数を記録し、指数バックオフと一時的なロックアウトに使用する
+CREATE TABLE IF NOT EXISTS login_attempts (
+    attempt_key VARCHAR(320) PRIMARY KEY,
+    -- 集計期間内の連続失敗回数（ロック時に0に戻す）
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    -- この時刻まではログインを受け付けない
+    locked_until TIMESTAMP
+);
+
+-- 古いエントリの定期削除のため
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
+
+COMMENT ON TABLE login_attempts IS 'ログイン失敗回数とロックアウト状態を管理するテーブル';
@@ -0,0 +1,18 @@
+-- マジックリンク（パスワードなしログイン）トークンテーブルの作成
+CREATE TABLE IF NOT EXISTS magic_link_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- トークン本体のSHA-256ダイジェスト（平文はメールでのみ送信）
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    -- 要求元ブラウザのクッキーに保存した値のSHA-256ダイジェスト
+    binding_hash VARCHAR(64) NOT NULL,
+    ip_address VARCHAR(45),
+    expires_at TIMESTAMP NOT NULL,
+    -- 使用済み・無効化の日時（一度だけ使用可能）
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_link_tokens_user_id ON magic_link_tokens(user_id);
+
+COMMENT ON TABLE magic_link_tokens IS 'メールで送信するワンタイムログインリンクのトークン（ハッシュ値）を管理するテーブル';
@@ -0,0 +1,21 @@
+-- 外部プロバイダーへの認可リクエストのstateテーブルの作成
+-- 再起動や複数インスタンスの構成でも、認可リクエストを開始したインスタンス以外でコールバックを受けられるようにする
+CREATE TABLE IF NOT EXISTS oauth_states (
+    -- stateのSHA-256ダイジェスト（16進数）
+    state_hash VARCHAR(64) PRIMARY KEY,
+    -- PKCEのコードベリファイア（トークン交換時に送信する）
+    code_verifier VARCHAR(128) NOT NULL,
+    -- IDトークンのnonce
+    nonce VARCHAR(128) NOT NULL,
+    -- 連携を開始したユーザー（ログインの場合はNULL）
+    link_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    -- ログイン後の戻り先
+    return_to TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- 期限切れエントリの定期削除のため
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires_at ON oauth_states(expires_at);
+
+COMMENT ON TABLE oauth_states IS '外部プロバイダーへの認可リクエストのstateを管理するテーブル。コールバックで一度だけ取り出して削除する';
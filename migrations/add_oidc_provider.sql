@@ -0,0 +1,51 @@
+-- OpenID Connect プロバイダー（内部アプリ向けの "Sign in with go-echo-demo"）のテーブルの作成
+
+-- 登録済みクライアント（リライングパーティ）
+CREATE TABLE IF NOT EXISTS oidc_clients (
+    id SERIAL PRIMARY KEY,
+    -- クライアントID（例: oidc_AbCdEfGhIjKlMnOp）
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    -- クライアントシークレットのSHA-256ダイジェスト（パブリッククライアントは空文字）
+    client_secret_hash VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(100) NOT NULL,
+    -- 完全一致で照合するリダイレクトURI
+    redirect_uris TEXT[] NOT NULL,
+    -- シークレットを保持できないクライアント（SPA・ネイティブアプリ）。PKCEのみで保護する
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 認可コード（一度だけ使用可能）
+CREATE TABLE IF NOT EXISTS oidc_authorization_codes (
+    id SERIAL PRIMARY KEY,
+    -- 認可コードのSHA-256ダイジェスト（平文はリダイレクトでのみ渡す）
+    code_hash VARCHAR(64) NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oidc_clients(client_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    -- PKCEのコードチャレンジ（S256）
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    -- 発行したアクセストークンのJTI（コードが再利用された場合に失効させる）
+    access_token_jti VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_authorization_codes_expires_at ON oidc_authorization_codes(expires_at);
+
+-- ユーザーがクライアントに許可したスコープ
+CREATE TABLE IF NOT EXISTS oidc_consents (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oidc_clients(client_id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, client_id)
+);
+
+COMMENT ON TABLE oidc_clients IS 'OpenID Connect プロバイダーに登録した内部アプリ（リライングパーティ）を管理するテーブル';
+COMMENT ON TABLE oidc_authorization_codes IS '認可コードフロー（PKCE必須）で発行した認可コード';
+COMMENT ON TABLE oidc_consents IS 'ユーザーがクライアントに許可したスコープ（同意済みの場合は同意画面を省略する）';
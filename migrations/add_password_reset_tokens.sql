@@ -0,0 +1,15 @@
+-- パスワードリセットトークンテーブルの作成
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- トークン本体のSHA-256ダイジェスト（平文はメールでのみ送信）
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    -- 使用済み・無効化の日時（一度だけ使用可能）
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+
+COMMENT ON TABLE password_reset_tokens IS 'パスワードリセットトークン（ハッシュ値）を管理するテーブル';
@@ -0,0 +1,21 @@
+-- パーソナルアクセストークン（APIキー）テーブルの作成
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    -- 一覧で識別するためのトークン先頭部分（例: gedp_AbCdEfG）
+    token_prefix VARCHAR(20) NOT NULL,
+    -- トークン本体のSHA-256ダイジェスト（平文は作成時にのみ返す）
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    -- "resource:action" 形式のスコープ（空の場合はユーザーの全権限）
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens(user_id);
+
+COMMENT ON TABLE personal_access_tokens IS '自動化スクリプト等で使うパーソナルアクセストークン（ハッシュ値）を管理するテーブル';
+COMMENT ON COLUMN personal_access_tokens.scopes IS 'トークンのスコープ。実際の権限はユーザーのRBAC権限との積になる';
@@ -0,0 +1,35 @@
+-- リフレッシュトークンのローテーションと再利用検知のためのマイグレーション
+
+-- 同じログインから派生したトークンを束ねるファミリーID
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id VARCHAR(36);
+-- ローテーション後の後継トークン（再利用検知に使用）
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS replaced_by_id INTEGER REFERENCES refresh_tokens(id) ON DELETE SET NULL;
+
+-- 既存のトークンはそれぞれ独立したファミリーとして扱う
+UPDATE refresh_tokens SET family_id = gen_random_uuid()::text WHERE family_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+-- ファミリー単位の無効化のため
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+COMMENT ON COLUMN refresh_tokens.family_id IS 'ローテーションで派生したトークンを束ねるファミリーID';
+COMMENT ON COLUMN refresh_tokens.replaced_by_id IS 'ローテーションにより発行された後継トークンのID';
+
+-- セキュリティイベント（監査ログ）テーブルの作成
+CREATE TABLE IF NOT EXISTS security_events (
+    id SERIAL PRIMARY KEY,
+    -- 対象ユーザー（ユーザー削除後もイベントは残す）
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    -- イベントの種類（refresh_token_reuse など）
+    event_type VARCHAR(50) NOT NULL,
+    -- 詳細
+    description TEXT,
+    -- 発生元のIPアドレス
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_security_events_event_type ON security_events(event_type);
+
+COMMENT ON TABLE security_events IS '認証まわりのセキュリティイベントを記録する監査テーブル';
@@ -0,0 +1,15 @@
+-- アクセストークン失効リスト（JTIデナイリスト）テーブルの作成
+-- ログアウト・強制ログアウト・リフレッシュトークンファミリーの無効化時にJTIを登録し、
+-- JWTAuthミドルウェアで署名が有効でも拒否する
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    -- アクセストークンのJWT ID
+    jti VARCHAR(255) PRIMARY KEY,
+    -- この時刻を過ぎるとトークン自体が期限切れになるため、エントリを削除してよい
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 期限切れエントリの定期削除のため
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+COMMENT ON TABLE revoked_tokens IS '失効したアクセストークンのJTIを管理するテーブル';
@@ -0,0 +1,28 @@
+-- サービスアカウント（クライアントクレデンシャルズグラント用の非人間の主体）テーブルの作成
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    -- クライアントID（例: sa_AbCdEfGhIjKlMnOp）
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    -- クライアントシークレットのSHA-256ダイジェスト（平文は作成・再発行時にのみ返す）
+    client_secret_hash VARCHAR(64) NOT NULL,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- service_account_rolesテーブルの作成（サービスアカウントとロールの関連）
+CREATE TABLE IF NOT EXISTS service_account_roles (
+    id SERIAL PRIMARY KEY,
+    service_account_id INTEGER NOT NULL REFERENCES service_accounts(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(service_account_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_account_roles_service_account_id ON service_account_roles(service_account_id);
+
+COMMENT ON TABLE service_accounts IS 'バックエンドのジョブ等がクライアントクレデンシャルズグラントで使うサービスアカウントを管理するテーブル';
+COMMENT ON TABLE service_account_roles IS 'サービスアカウントに割り当てたロール（DBベースのRBAC）';
@@ -0,0 +1,27 @@
+-- 外部プロバイダーの連携テーブルの作成（1人のユーザーに複数のプロバイダーを連携できる）
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- プロバイダー名（google / line / github / 汎用OIDCプロバイダーの名前）
+    provider VARCHAR(50) NOT NULL,
+    -- プロバイダー内で不変のユーザーID（IDトークンのsub、GitHubの数値ID等）
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    profile JSONB NOT NULL DEFAULT '{}',
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- 既存のusers.provider_id / provider_name を連携として移行
+INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+SELECT id, provider_name, provider_id, email, created_at
+FROM users
+WHERE provider_id IS NOT NULL AND provider_id <> ''
+  AND provider_name IS NOT NULL AND provider_name <> ''
+ON CONFLICT (provider, subject) DO NOTHING;
+
+COMMENT ON TABLE user_identities IS 'ユーザーに連携した外部プロバイダーのアカウントを管理するテーブル。ログイン時はprovider + subjectで検索し、メールアドレスだけでは紐付けない';
+COMMENT ON COLUMN user_identities.profile IS '最後のログイン時にプロバイダーから取得したプロフィール（IDトークンのクレーム等）';
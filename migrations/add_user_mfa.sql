@@ -0,0 +1,28 @@
+-- 二要素認証（TOTP）テーブルの作成
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    -- AES-256-GCMで暗号化したTOTPシークレット（"v1:<base64>" 形式、鍵はMFA_ENCRYPTION_KEY）
+    secret_encrypted TEXT NOT NULL,
+    -- 最初のコードで確認されるまではfalse（ログイン時に要求しない）
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    -- 最後に受け付けたタイムステップ（同じコードの再利用防止）
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    confirmed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- リカバリーコードテーブルの作成（一度だけ使用可能）
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- コード本体のSHA-256ダイジェスト（平文は発行時に一度だけ表示）
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+
+COMMENT ON TABLE user_mfa IS 'ユーザーごとのTOTP二要素認証設定を管理するテーブル';
+COMMENT ON TABLE mfa_recovery_codes IS '二要素認証のリカバリーコード（ハッシュ値）を管理するテーブル';
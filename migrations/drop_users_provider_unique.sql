@@ -0,0 +1,7 @@
+-- 外部プロバイダーのアカウント（provider + subject）は user_identities のみで一意にする
+-- users.provider_id / provider_name の一意制約が残ると、連携を解除した後に同じプロバイダーのアカウントで
+-- 再度ログインしてユーザーを作成する際に制約違反になる（メールアドレスのないLINEのユーザーは必ず該当する）
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_provider_id_provider_name_key;
+
+COMMENT ON COLUMN users.provider_id IS '旧形式の外部プロバイダーの連携（user_identities に移行済み。新たには書き込まない）';
+COMMENT ON COLUMN users.provider_name IS '旧形式の外部プロバイダーの連携（user_identities に移行済み。新たには書き込まない）';
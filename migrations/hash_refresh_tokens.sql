@@ -0,0 +1,19 @@
+-- リフレッシュトークンをハッシュ値で保存するためのマイグレーション
+-- DBダンプが漏洩しても /api/auth/refresh に再送できないよう、トークン本体は保存しない
+
+-- SHA-256ダイジェスト（16進数64文字）を格納するカラムを追加
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
+
+-- 既存の行はトークン本体からダイジェストを計算して移行（アプリケーション側の hashToken と同じ値）
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex')
+WHERE token_hash IS NULL;
+
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
+
+-- 平文のトークンカラムとそのインデックスを削除
+DROP INDEX IF EXISTS idx_refresh_tokens_token;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token;
+
+COMMENT ON COLUMN refresh_tokens.token_hash IS 'リフレッシュトークン本体のSHA-256ダイジェスト（16進数）';
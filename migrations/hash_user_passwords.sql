@@ -0,0 +1,9 @@
+-- パスワードハッシュ保存のためのマイグレーション
+-- PHC形式のハッシュ（argon2idは約100文字）を格納できるようにカラムを拡張します
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+
+-- 既存の平文パスワードは次回ログイン時にアプリケーション側で透過的にハッシュ化されます
+-- （平文のまま残っているユーザー数の確認用）
+-- SELECT COUNT(*) FROM users WHERE password IS NOT NULL AND password <> '' AND password NOT LIKE '$%';
+
+COMMENT ON COLUMN users.password IS 'PHC形式のパスワードハッシュ（$argon2id$... / $2a$...）';
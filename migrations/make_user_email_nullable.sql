@@ -0,0 +1,7 @@
+-- メールアドレスを返さないプロバイダー（LINE等）でもユーザーを作成できるよう、メールアドレスをNULL可能にする
+-- 空文字で保存すると一意制約により2人目以降のユーザーを作成できないため、メールアドレスのないユーザーはNULLとする
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+
+UPDATE users SET email = NULL, email_verified_at = NULL WHERE email = '';
+
+COMMENT ON COLUMN users.email IS 'メールアドレス（一意）。外部プロバイダーで作成したユーザーは、プロバイダーが確認済みとしたメールアドレスのみを保存し、それ以外はNULL';